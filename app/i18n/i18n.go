@@ -0,0 +1,316 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default Язык интерфейса, если ни один из предпочитаемых пользователем языков не поддерживается
+const Default = "ru"
+
+// Ключ сообщения с названием языка на самом этом языке
+const nameKey = "language.name"
+
+// Каталоги сообщений, встроенные в бинарный файл
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+// Каталоги поддерживаемых языков, разобранные и проверенные при запуске
+var catalogs map[string]*catalog
+
+func init() {
+	var err error
+	if catalogs, err = load(locales); err != nil {
+		panic(err)
+	}
+}
+
+// Language Поддерживаемый язык интерфейса
+type Language struct {
+	Code string
+	Name string
+}
+
+// Languages Возвращает поддерживаемые языки, язык по умолчанию идет первым
+func Languages() []Language {
+	res := make([]Language, 0, len(catalogs))
+	for code, c := range catalogs {
+		res = append(res, Language{Code: code, Name: c.messages[nameKey].text})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Code == Default || res[j].Code == Default {
+			return res[i].Code == Default
+		}
+		return res[i].Code < res[j].Code
+	})
+
+	return res
+}
+
+// Supported Проверяет, что язык поддерживается
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Сообщение каталога: строка или набор форм множественного числа
+type message struct {
+	text  string
+	forms map[string]string
+}
+
+// UnmarshalJSON Читает сообщение из строки или из объекта с формами множественного числа
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.text); err == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, &m.forms)
+}
+
+// Каталог сообщений одного языка
+type catalog struct {
+	plural   func(n int64) string
+	messages map[string]message
+}
+
+// Читает каталоги из файлов <язык>.json и проверяет, что они согласованы с каталогом языка по умолчанию:
+// содержат те же ключи, а сообщения во множественном числе содержат все формы, которые требуют правила языка
+func load(fsys fs.FS) (map[string]*catalog, error) {
+	files, err := fs.Glob(fsys, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]*catalog{}
+	for _, file := range files {
+		lang := strings.TrimSuffix(path.Base(file), ".json")
+		rule, ok := pluralRules[lang]
+		if !ok {
+			return nil, fmt.Errorf("locale %s: no plural rule", lang)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		var messages map[string]message
+		if err = json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("locale %s: %w", lang, err)
+		}
+
+		for key, m := range messages {
+			for _, form := range rule.forms {
+				if m.forms != nil && m.forms[form] == "" {
+					return nil, fmt.Errorf("locale %s: message %s has no %s form", lang, key, form)
+				}
+			}
+		}
+
+		res[lang] = &catalog{plural: rule.form, messages: messages}
+	}
+
+	def, ok := res[Default]
+	if !ok {
+		return nil, fmt.Errorf("locale %s not found", Default)
+	}
+
+	for lang, c := range res {
+		if _, ok := c.messages[nameKey]; !ok {
+			return nil, fmt.Errorf("locale %s: message %s not found", lang, nameKey)
+		}
+
+		for key, m := range def.messages {
+			translated, ok := c.messages[key]
+			if !ok {
+				return nil, fmt.Errorf("locale %s: message %s not found", lang, key)
+			}
+			if (m.forms == nil) != (translated.forms == nil) {
+				return nil, fmt.Errorf("locale %s: message %s plural mismatch", lang, key)
+			}
+		}
+
+		for key := range c.messages {
+			if _, ok := def.messages[key]; !ok {
+				return nil, fmt.Errorf("locale %s: unknown message %s", lang, key)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Localizer Переводит сообщения интерфейса на выбранный язык и форматирует даты и суммы по его правилам
+// Пустой Localizer переводит на язык по умолчанию и показывает время в UTC
+type Localizer struct {
+	lang string
+	loc  *time.Location
+}
+
+// New Возвращает переводчик на язык lang, неподдерживаемый язык заменяется языком по умолчанию
+func New(lang string) *Localizer {
+	if !Supported(lang) {
+		lang = Default
+	}
+
+	return &Localizer{lang: lang}
+}
+
+// Negotiate Выбирает язык интерфейса
+// Язык из настроек пользователя важнее заголовка Accept-Language, из заголовка выбирается
+// поддерживаемый язык с наибольшим весом, а если подходящего нет, то используется язык по умолчанию
+func Negotiate(preferred, acceptLanguage string) *Localizer {
+	if Supported(preferred) {
+		return New(preferred)
+	}
+
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		if Supported(lang) {
+			return New(lang)
+		}
+	}
+
+	return New(Default)
+}
+
+// Разбирает заголовок Accept-Language и возвращает основные подтеги языков в порядке убывания веса
+// Языки с нулевым весом отбрасываются, ru-RU и ru считаются одним языком
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(params[0]))
+		if lang == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					value = 0
+				}
+				q = value
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		if i := strings.IndexByte(lang, '-'); i >= 0 {
+			lang = lang[:i]
+		}
+		langs = append(langs, weighted{lang: lang, q: q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+
+	res := make([]string, len(langs))
+	for i, l := range langs {
+		res[i] = l.lang
+	}
+
+	return res
+}
+
+// In Возвращает копию переводчика, которая показывает время в часовом поясе loc
+func (l *Localizer) In(loc *time.Location) *Localizer {
+	return &Localizer{lang: l.Lang(), loc: loc}
+}
+
+// Location Возвращает часовой пояс, в котором показывается время
+func (l *Localizer) Location() *time.Location {
+	if l == nil || l.loc == nil {
+		return time.UTC
+	}
+
+	return l.loc
+}
+
+// Lang Возвращает код языка
+func (l *Localizer) Lang() string {
+	if l == nil || l.lang == "" {
+		return Default
+	}
+
+	return l.lang
+}
+
+// T Возвращает перевод сообщения, подставляя args по правилам fmt.Sprintf
+// Если сообщения нет в каталоге, то возвращается сам ключ, чтобы пропущенный перевод было видно на странице
+func (l *Localizer) T(key string, args ...interface{}) string {
+	m, ok := catalogs[l.Lang()].messages[key]
+	if !ok || m.forms != nil {
+		return key
+	}
+
+	if len(args) == 0 {
+		return m.text
+	}
+
+	return fmt.Sprintf(m.text, args...)
+}
+
+// N Возвращает перевод сообщения во множественном числе в форме, соответствующей count
+// Количество подставляется первым аргументом, за ним следуют args
+func (l *Localizer) N(key string, count int64, args ...interface{}) string {
+	c := catalogs[l.Lang()]
+	m, ok := c.messages[key]
+	if !ok || m.forms == nil {
+		return key
+	}
+
+	text, ok := m.forms[c.plural(count)]
+	if !ok {
+		text = m.forms[formOther]
+	}
+
+	return fmt.Sprintf(text, append([]interface{}{count}, args...)...)
+}
+
+// DateTime Форматирует момент времени в часовом поясе переводчика
+func (l *Localizer) DateTime(t time.Time) string {
+	return t.In(l.Location()).Format(l.T("format.datetime"))
+}
+
+// Amount Форматирует денежную сумму с двумя знаками после запятой, разделяя разряды по правилам языка
+func (l *Localizer) Amount(amount float64) string {
+	digits := strconv.FormatFloat(math.Abs(amount), 'f', 2, 64)
+	whole, fraction := digits[:len(digits)-3], digits[len(digits)-2:]
+
+	group := l.T("format.group")
+	var b strings.Builder
+	if amount < 0 && digits != "0.00" {
+		b.WriteByte('-')
+	}
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(c)
+	}
+	b.WriteString(l.T("format.decimal"))
+	b.WriteString(fraction)
+
+	return b.String()
+}
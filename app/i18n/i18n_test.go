@@ -0,0 +1,154 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRussianPlural(t *testing.T) {
+	cases := map[int64]string{
+		0: formMany, 1: formOne, 2: formFew, 4: formFew, 5: formMany, 11: formMany, 12: formMany,
+		14: formMany, 21: formOne, 22: formFew, 25: formMany, 101: formOne, 111: formMany, 112: formMany, -3: formFew,
+	}
+
+	for n, exp := range cases {
+		assert.Equalf(t, exp, russianPlural(n), "n = %d", n)
+	}
+}
+
+func TestEnglishPlural(t *testing.T) {
+	assert.Equal(t, formOne, englishPlural(1))
+	assert.Equal(t, formOther, englishPlural(0))
+	assert.Equal(t, formOther, englishPlural(21))
+}
+
+func TestLocalizer_N(t *testing.T) {
+	ru := New("ru")
+	assert.Equal(t, "1 год", ru.N("index.age.value", 1))
+	assert.Equal(t, "23 года", ru.N("index.age.value", 23))
+	assert.Equal(t, "11 лет", ru.N("index.age.value", 11))
+	assert.Equal(t, "35 лет", ru.N("index.age.value", 35))
+
+	en := New("en")
+	assert.Equal(t, "1 year", en.N("index.age.value", 1))
+	assert.Equal(t, "35 years", en.N("index.age.value", 35))
+
+	// Сообщение без форм множественного числа не переводится через N
+	assert.Equal(t, "index.age", en.N("index.age", 1))
+}
+
+func TestLocalizer_T(t *testing.T) {
+	assert.Equal(t, "Привет, Борис", New("ru").T("index.greeting", "Борис"))
+	assert.Equal(t, "Hello, Boris", New("en").T("index.greeting", "Boris"))
+	assert.Equal(t, "Операции", New("de").T("nav.operations"))
+	assert.Equal(t, "unknown.key", New("en").T("unknown.key"))
+
+	var l *Localizer
+	assert.Equal(t, Default, l.Lang())
+	assert.Equal(t, "Операции", l.T("nav.operations"))
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		preferred string
+		header    string
+		exp       string
+	}{
+		{preferred: "", header: "", exp: "ru"},
+		{preferred: "", header: "en-US,en;q=0.9", exp: "en"},
+		{preferred: "", header: "de-DE,de;q=0.9,en;q=0.8,ru;q=0.7", exp: "en"},
+		{preferred: "", header: "ru;q=0.5, EN-gb;q=0.8", exp: "en"},
+		{preferred: "", header: "en;q=0, ru", exp: "ru"},
+		{preferred: "", header: "fr, *;q=0.5", exp: "ru"},
+		{preferred: "ru", header: "en", exp: "ru"},
+		{preferred: "de", header: "en", exp: "en"},
+	}
+
+	for _, c := range cases {
+		assert.Equalf(t, c.exp, Negotiate(c.preferred, c.header).Lang(), "preferred %q, header %q", c.preferred, c.header)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	assert.Equal(t, []Language{{Code: "ru", Name: "Русский"}, {Code: "en", Name: "English"}}, Languages())
+	assert.True(t, Supported("en"))
+	assert.False(t, Supported(""))
+}
+
+func TestLoad(t *testing.T) {
+	valid := fstest.MapFS{
+		"locales/ru.json": {Data: []byte(`{"language.name": "Русский", "age": {"one": "%d год", "few": "%d года", "many": "%d лет"}}`)},
+		"locales/en.json": {Data: []byte(`{"language.name": "English", "age": {"one": "%d year", "other": "%d years"}}`)},
+	}
+	res, err := load(valid)
+	require.NoError(t, err)
+	assert.Len(t, res, 2)
+
+	invalid := []fstest.MapFS{
+		// Нет формы many
+		{
+			"locales/ru.json": {Data: []byte(`{"language.name": "Русский", "age": {"one": "%d год", "few": "%d года"}}`)},
+		},
+		// Нет перевода сообщения
+		{
+			"locales/ru.json": {Data: []byte(`{"language.name": "Русский", "title": "Заголовок"}`)},
+			"locales/en.json": {Data: []byte(`{"language.name": "English"}`)},
+		},
+		// Сообщение, которого нет в каталоге по умолчанию
+		{
+			"locales/ru.json": {Data: []byte(`{"language.name": "Русский"}`)},
+			"locales/en.json": {Data: []byte(`{"language.name": "English", "title": "Title"}`)},
+		},
+		// Нет правил множественного числа
+		{
+			"locales/ru.json": {Data: []byte(`{"language.name": "Русский"}`)},
+			"locales/xx.json": {Data: []byte(`{"language.name": "Xx"}`)},
+		},
+		// Нет каталога по умолчанию
+		{
+			"locales/en.json": {Data: []byte(`{"language.name": "English"}`)},
+		},
+	}
+	for i, fsys := range invalid {
+		_, err = load(fsys)
+		assert.Errorf(t, err, "case %d", i)
+	}
+}
+
+func TestLocalizer_Amount(t *testing.T) {
+	cases := []struct {
+		amount float64
+		ru     string
+		en     string
+	}{
+		{amount: 0, ru: "0,00", en: "0.00"},
+		{amount: 12.5, ru: "12,50", en: "12.50"},
+		{amount: 999.999, ru: "1\u00a0000,00", en: "1,000.00"},
+		{amount: 1234567.891, ru: "1\u00a0234\u00a0567,89", en: "1,234,567.89"},
+		{amount: -4200.1, ru: "-4\u00a0200,10", en: "-4,200.10"},
+		{amount: -0.001, ru: "0,00", en: "0.00"},
+	}
+
+	// Разряды в русском языке разделяются неразрывным пробелом
+	for _, c := range cases {
+		assert.Equal(t, c.ru, New("ru").Amount(c.amount))
+		assert.Equal(t, c.en, New("en").Amount(c.amount))
+	}
+}
+
+func TestLocalizer_DateTime(t *testing.T) {
+	moment := time.Date(2021, 9, 1, 22, 30, 5, 0, time.UTC)
+	tokyo := time.FixedZone("Asia/Tokyo", 9*60*60)
+
+	assert.Equal(t, "01.09.2021 22:30:05", New("ru").DateTime(moment))
+	assert.Equal(t, "02.09.2021 07:30:05", New("ru").In(tokyo).DateTime(moment))
+	assert.Equal(t, "Sep 2, 2021 7:30:05 AM", New("en").In(tokyo).DateTime(moment))
+
+	var l *Localizer
+	assert.Equal(t, time.UTC, l.Location())
+	assert.Equal(t, tokyo, l.In(tokyo).Location())
+}
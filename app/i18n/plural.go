@@ -0,0 +1,43 @@
+package i18n
+
+// Формы множественного числа по классификации CLDR
+const (
+	formOne   = "one"
+	formFew   = "few"
+	formMany  = "many"
+	formOther = "other"
+)
+
+// Правила выбора формы множественного числа и формы, которые обязан содержать каталог языка
+var pluralRules = map[string]struct {
+	form  func(n int64) string
+	forms []string
+}{
+	"ru": {form: russianPlural, forms: []string{formOne, formFew, formMany}},
+	"en": {form: englishPlural, forms: []string{formOne, formOther}},
+}
+
+// Выбирает форму для русского языка: 1 год, 2 года, 5 лет, 11 лет, 21 год
+func russianPlural(n int64) string {
+	if n < 0 {
+		n = -n
+	}
+
+	switch mod10, mod100 := n%10, n%100; {
+	case mod10 == 1 && mod100 != 11:
+		return formOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return formFew
+	default:
+		return formMany
+	}
+}
+
+// Выбирает форму для английского языка: 1 year, 2 years
+func englishPlural(n int64) string {
+	if n == 1 {
+		return formOne
+	}
+
+	return formOther
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+)
+
+// File Сохраняет письма в файлы .eml вместо отправки
+// Используется для локальной разработки и тестов
+type File struct {
+	dir  string
+	from string
+}
+
+// NewFile Возвращает отправителя, сохраняющего письма в каталог
+func NewFile(dir, from string) *File {
+	return &File{dir: dir, from: from}
+}
+
+// Send Сохраняет письмо в новый файл каталога
+func (m *File) Send(msg *Message) error {
+	now := time.Now()
+	data, err := format(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.dir, fmt.Sprintf("%d.eml", now.UnixNano()))
+	if err = ioutil.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+
+	logger.Log.WithField("to", msg.To).WithField("path", path).Info("mail saved")
+	return nil
+}
+
+// Log Пишет письма в лог вместо отправки
+type Log struct{}
+
+// NewLog Возвращает отправителя, пишущего письма в лог
+func NewLog() *Log {
+	return &Log{}
+}
+
+// Send Пишет письмо в лог
+func (m *Log) Send(msg *Message) error {
+	logger.Log.WithField("to", msg.To).WithField("subject", msg.Subject).Info(msg.Body)
+	return nil
+}
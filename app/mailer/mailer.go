@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+const (
+	// Длина строки тела письма в base64
+	lineLength = 76
+)
+
+var (
+	ErrInvalidHeader = errors.New("mail header contains line break")
+)
+
+// Message Письмо пользователю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer Отправитель писем
+// Реализации: SMTP для продакшена, File и Log для локальной разработки и тестов
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// Собирает письмо в формате RFC 5322 с телом в UTF-8
+// Переводы строк в заголовках запрещены, что бы в письмо нельзя было внедрить свои заголовки
+func format(from string, msg *Message, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("\r\n")
+
+	body := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(body) > lineLength {
+		buf.WriteString(body[:lineLength] + "\r\n")
+		body = body[lineLength:]
+	}
+	buf.WriteString(body + "\r\n")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var message = Message{
+	To:      "jondoe@example.com",
+	Subject: "Восстановление пароля",
+	Body:    "Ссылка для восстановления пароля: https://casher.example.com/password/reset/?token=abc",
+}
+
+// Возвращает раскодированное тело письма
+func decodeBody(t *testing.T, data []byte) string {
+	parts := strings.SplitN(string(data), "\r\n\r\n", 2)
+	require.Len(t, parts, 2)
+
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(parts[1], "\r\n", ""))
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestSMTP_Send(t *testing.T) {
+	m, err := NewSMTP("smtp.example.com:587", "user", "secret", "casher@example.com")
+	require.NoError(t, err)
+
+	var sent []byte
+	var recipients []string
+	m.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.NotNil(t, a)
+		assert.Equal(t, "casher@example.com", from)
+		recipients, sent = to, msg
+		return nil
+	}
+
+	require.NoError(t, m.Send(&message))
+
+	assert.Equal(t, []string{message.To}, recipients)
+	assert.Contains(t, string(sent), "To: jondoe@example.com\r\n")
+	assert.Contains(t, string(sent), "Subject: =?utf-8?q?")
+	assert.Equal(t, message.Body, decodeBody(t, sent))
+}
+
+func TestSMTP_Send_HeaderInjection(t *testing.T) {
+	m, err := NewSMTP("smtp.example.com:25", "", "", "casher@example.com")
+	require.NoError(t, err)
+
+	m.send = func(string, smtp.Auth, string, []string, []byte) error {
+		t.Fatal("message must not be sent")
+		return nil
+	}
+
+	msg := message
+	msg.To = "jondoe@example.com\r\nBcc: spam@example.com"
+
+	assert.ErrorIs(t, m.Send(&msg), ErrInvalidHeader)
+}
+
+func TestFile_Send(t *testing.T) {
+	dir := t.TempDir()
+	m := NewFile(dir, "casher@localhost")
+
+	require.NoError(t, m.Send(&message))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := ioutil.ReadFile(files[0])
+	require.NoError(t, err)
+
+	assert.Contains(t, string(data), "From: casher@localhost\r\n")
+	assert.Equal(t, message.Body, decodeBody(t, data))
+}
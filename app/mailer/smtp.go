@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type sendFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// SMTP Отправляет письма через SMTP сервер
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+	send sendFunc
+}
+
+// NewSMTP Возвращает отправителя писем через SMTP сервер по адресу host:port
+// Если имя пользователя не указано, то письма отправляются без аутентификации
+func NewSMTP(addr, username, password, from string) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTP{
+		addr: addr,
+		from: from,
+		auth: auth,
+		send: smtp.SendMail,
+	}, nil
+}
+
+// Send Отправляет письмо
+func (m *SMTP) Send(msg *Message) error {
+	data, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return m.send(m.addr, m.auth, m.from, []string{msg.To}, data)
+}
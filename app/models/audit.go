@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Действия пользователя, записываемые в журнал аудита
+const (
+	AuditLogin           = "login"
+	AuditLoginFailed     = "login.failed"
+	AuditLogout          = "logout"
+	AuditRegistration    = "registration"
+	AuditOperationCreate = "operation.create"
+	AuditOperationDelete = "operation.delete"
+	AuditTokenCreate     = "token.create"
+	AuditTokenDelete     = "token.delete"
+	AuditWebhookCreate   = "webhook.create"
+	AuditWebhookDelete   = "webhook.delete"
+	AuditLedgerCreate    = "ledger.create"
+	AuditMemberAdd       = "ledger.member.add"
+	AuditMemberRemove    = "ledger.member.remove"
+	AuditInviteCreate    = "invite.create"
+	AuditInviteRevoke    = "invite.revoke"
+	AuditPasswordReset   = "password.reset"
+	AuditEmailVerify     = "email.verify"
+	AuditTwoFactorEnable = "2fa.enable"
+	AuditTwoFactorCodes  = "2fa.codes"
+	AuditTwoFactorOff    = "2fa.disable"
+	AuditSSOLink         = "sso.link"
+	AuditSessionRevoke   = "session.revoke"
+	AuditSessionsRevoke  = "session.revoke_all"
+	AuditProfileUpdate   = "profile.update"
+	AuditPasswordChange  = "password.change"
+	AuditAccountDelete   = "account.delete"
+	AuditDataExport      = "account.export"
+	// Действия администраторов, из них собирается журнал администрирования
+	AuditAdminDisable = "admin.user.disable"
+	AuditAdminEnable  = "admin.user.enable"
+	AuditAdminDelete  = "admin.user.delete"
+)
+
+// AuditAdminPrefix Общий префикс действий администраторов
+const AuditAdminPrefix = "admin."
+
+// Типы объектов, над которыми совершаются действия
+const (
+	AuditTargetUser      = "user"
+	AuditTargetOperation = "operation"
+	AuditTargetToken     = "token"
+	AuditTargetWebhook   = "webhook"
+	AuditTargetLedger    = "ledger"
+	AuditTargetInvite    = "invite"
+	AuditTargetSession   = "session"
+)
+
+// AuditEntry Запись журнала аудита
+// Снимки Before и After хранятся в JSON, ActorID равен 0, если действие совершил неизвестный пользователь
+type AuditEntry struct {
+	ID         int64
+	ActorID    int64
+	Action     string
+	TargetType string
+	TargetID   int64
+	Before     []byte
+	After      []byte
+	IP         string
+	UserAgent  string
+	Created    time.Time
+}
+
+// AuditPaginator Обертка для пагинации записей журнала аудита
+type AuditPaginator struct {
+	Entries []AuditEntry
+	HasMore bool
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound Объект не найден, с ней совпадает любая NotFoundError при проверке через errors.Is
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError Объект не найден среди объектов пользователя
+// Чужой объект неотличим от несуществующего, что бы перебором ID нельзя было узнать о чужих данных
+type NotFoundError struct {
+	Entity string
+	ID     int64
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %d not found", e.Entity, e.ID)
+}
+
+// Is Позволяет сравнивать ошибку с ErrNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Event Модель доменного события из outbox
+type Event struct {
+	ID       int64
+	Type     string
+	Payload  []byte
+	Attempts int
+	Created  time.Time
+	// Обработчики, которые уже успешно обработали событие и не вызываются при повторной попытке
+	Handled []string
+}
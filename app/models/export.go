@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// EventExportRequested Событие о запросе выгрузки персональных данных, по нему архив собирается в фоне
+const EventExportRequested = "export.requested"
+
+// Статусы выгрузки персональных данных
+const (
+	ExportPending ExportStatus = "pending"
+	ExportReady   ExportStatus = "ready"
+)
+
+// ExportStatus Статус выгрузки персональных данных
+type ExportStatus string
+
+// Export Выгрузка персональных данных пользователя
+// Сам архив хранится в базе отдельно и читается только при скачивании
+type Export struct {
+	ID      int64
+	UserID  int64
+	Status  ExportStatus
+	Size    int64
+	Expires *time.Time
+	Ready   *time.Time
+	Created time.Time
+}
+
+// Available Проверяет, что архив собран и его еще можно скачать
+func (e *Export) Available(now time.Time) bool {
+	return e.Status == ExportReady && e.Expires != nil && now.Before(*e.Expires)
+}
+
+// ExportEvent Данные события о запросе выгрузки
+type ExportEvent struct {
+	ExportID int64 `json:"export_id"`
+	UserID   int64 `json:"user_id"`
+}
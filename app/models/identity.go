@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Identity Внешняя учетная запись пользователя у провайдера OpenID Connect
+type Identity struct {
+	ID      int64
+	UserID  int64
+	Issuer  string
+	Subject string
+	Email   string
+	Created time.Time
+}
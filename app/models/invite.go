@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Invite Приглашение на регистрацию
+// В базе хранится только хеш подписанной ссылки приглашения
+type Invite struct {
+	ID        int64
+	InviterID int64
+	Hash      string
+	Expires   time.Time
+	UsedBy    int64
+	Used      *time.Time
+	Revoked   *time.Time
+	Created   time.Time
+}
+
+// Active Проверяет, что приглашение еще можно использовать для регистрации
+func (i *Invite) Active(now time.Time) bool {
+	return i.Used == nil && i.Revoked == nil && now.Before(i.Expires)
+}
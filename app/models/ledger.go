@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+const (
+	RoleOwner  LedgerRole = "owner"
+	RoleEditor LedgerRole = "editor"
+	RoleViewer LedgerRole = "viewer"
+)
+
+// LedgerRole Роль участника бухгалтерии
+type LedgerRole string
+
+// CanRead Может ли участник просматривать операции
+func (r LedgerRole) CanRead() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// CanWrite Может ли участник создавать и удалять операции
+func (r LedgerRole) CanWrite() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// CanManage Может ли участник управлять составом бухгалтерии
+func (r LedgerRole) CanManage() bool {
+	return r == RoleOwner
+}
+
+// Ledger Модель бухгалтерии, которой принадлежат операции
+// Role заполняется ролью пользователя, для которого запрошена бухгалтерия
+type Ledger struct {
+	ID      int64
+	Name    string
+	Role    LedgerRole
+	Created time.Time
+}
+
+// LedgerMember Модель участника бухгалтерии
+type LedgerMember struct {
+	LedgerID int64
+	UserID   int64
+	Login    string
+	Name     string
+	Role     LedgerRole
+	Created  time.Time
+}
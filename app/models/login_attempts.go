@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// LoginAttempts Счетчик неудачных попыток входа по логину или по адресу клиента
+type LoginAttempts struct {
+	Key string
+	// Неудачные попытки текущей серии, по ним считаются задержка и блокировка
+	FailedAttempts int64
+	// Все неудачные попытки с последнего успешного входа
+	TotalFailures int64
+	FailedAt      time.Time
+	// Время последней временной блокировки, nil если блокировок не было
+	Locked *time.Time
+	// Время, до которого новые попытки запрещены
+	BlockedUntil time.Time
+}
+
+// LoginSchedule Расписание задержек между неудачными попытками входа
+type LoginSchedule struct {
+	// Задержка после n-й неудачи серии находится под индексом n-1, последняя задержка повторяется
+	Delays []time.Duration
+	// Количество неудач, после которого вход блокируется
+	Limit int64
+	// Время блокировки
+	Lockout time.Duration
+}
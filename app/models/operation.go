@@ -11,14 +11,16 @@ const (
 type OperationType int64
 
 // Operation Модель финансовой операции
+// Операция принадлежит бухгалтерии, UserID указывает на автора операции или равен 0, если автор удалил аккаунт
 type Operation struct {
-	ID      int64
-	UserID  int64
-	Subject string
-	Amount  int64
-	Type    OperationType
-	Message string
-	Created time.Time
+	ID       int64         `json:"id"`
+	LedgerID int64         `json:"ledger_id"`
+	UserID   int64         `json:"user_id"`
+	Subject  string        `json:"subject"`
+	Amount   int64         `json:"amount"`
+	Type     OperationType `json:"type"`
+	Message  string        `json:"message"`
+	Created  time.Time     `json:"created_at"`
 }
 
 // OperationPaginator Обертка для пагинации данных о финансовых операциях
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PasswordReset Одноразовый токен восстановления пароля
+// В базе хранится только хеш токена
+type PasswordReset struct {
+	ID      int64
+	UserID  int64
+	Hash    string
+	Expires time.Time
+	Used    *time.Time
+	Created time.Time
+}
+
+// Active Проверяет, что токеном еще можно сменить пароль
+func (r *PasswordReset) Active(now time.Time) bool {
+	return r.Used == nil && now.Before(r.Expires)
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Session Сессия браузера
+// В базе хранится только хеш токена, сам токен есть только в куки
+type Session struct {
+	ID        int64
+	Hash      string
+	UserID    *int64
+	Data      []byte
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+}
+
+// Active Проверяет, что сессия не истекла по абсолютному времени жизни и по времени простоя
+func (s *Session) Active(now time.Time, absolute, idle time.Duration) bool {
+	return now.Before(s.Created.Add(absolute)) && now.Before(s.LastSeen.Add(idle))
+}
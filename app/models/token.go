@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Token Модель токена доступа пользователя к API
+// В базе хранится только хеш токена, сам токен показывается пользователю один раз при создании
+type Token struct {
+	ID      int64
+	UserID  int64
+	Name    string
+	Hash    string
+	Created time.Time
+}
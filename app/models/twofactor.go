@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TwoFactor Настройки двухфакторной аутентификации пользователя
+// Пока Enabled не заполнено, секрет ожидает подтверждения кодом из приложения
+type TwoFactor struct {
+	UserID      int64
+	Secret      string
+	Enabled     *time.Time
+	LastCounter int64
+	// Неудачные попытки ввода кода подряд и время последней из них
+	FailedAttempts int
+	FailedAt       *time.Time
+	Created        time.Time
+}
+
+// IsEnabled Проверяет, что двухфакторная аутентификация подтверждена и включена
+func (t *TwoFactor) IsEnabled() bool {
+	return t != nil && t.Enabled != nil
+}
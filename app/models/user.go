@@ -2,13 +2,55 @@ package models
 
 import "time"
 
+// UserRole Роль пользователя в приложении
+type UserRole string
+
+const (
+	// UserRoleUser Обычный пользователь
+	UserRoleUser UserRole = "user"
+	// UserRoleAdmin Администратор, которому доступна панель управления пользователями
+	UserRoleAdmin UserRole = "admin"
+)
+
 // User Модель пользователя
 type User struct {
 	ID       int64
 	Login    string
 	Password string
-	Name     string
-	Birth    time.Time
-	Balance  int64
-	Created  time.Time
+	// Адрес электронной почты, пустой если не указан
+	Email string
+	// Время подтверждения адреса, nil если адрес не подтвержден
+	EmailVerified *time.Time
+	Name          string
+	Birth         time.Time
+	Balance       int64
+	// Идентификатор пригласившего пользователя, 0 если регистрация была открытой
+	InvitedBy int64
+	Role      UserRole
+	// Время блокировки аккаунта, nil если аккаунт активен
+	Disabled  *time.Time
+	LastLogin *time.Time
+	// Язык интерфейса, пустой если язык выбирается по настройкам браузера
+	Language string
+	// Часовой пояс IANA, пустой если используется часовой пояс по умолчанию
+	Timezone string
+	// Количество операций пользователя, заполняется только в списке пользователей
+	Operations int64
+	Created    time.Time
+}
+
+// HasVerifiedEmail Проверяет, что у пользователя есть подтвержденный адрес электронной почты
+func (u *User) HasVerifiedEmail() bool {
+	return u.Email != "" && u.EmailVerified != nil
+}
+
+// IsAdmin Проверяет, является ли пользователь администратором
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// UserPaginator Обертка для пагинации пользователей
+type UserPaginator struct {
+	Users   []User
+	HasMore bool
 }
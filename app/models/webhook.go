@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// События, на которые можно подписаться
+const (
+	EventOperationCreated = "operation.created"
+	EventOperationDeleted = "operation.deleted"
+)
+
+// Events Список всех событий
+var Events = []string{EventOperationCreated, EventOperationDeleted}
+
+// Статусы доставки события
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// DeliveryStatus Статус доставки события подписчику
+type DeliveryStatus string
+
+// Webhook Модель подписки пользователя на события
+type Webhook struct {
+	ID      int64
+	UserID  int64
+	URL     string
+	Events  []string
+	Secret  string
+	Created time.Time
+}
+
+// Delivery Модель доставки события по подписке
+type Delivery struct {
+	ID           int64
+	WebhookID    int64
+	Event        string
+	Payload      []byte
+	Status       DeliveryStatus
+	Attempts     int
+	NextAttempt  time.Time
+	ResponseCode int
+	LastError    string
+	Created      time.Time
+	Delivered    *time.Time
+	// Подписка, по которой отправляется событие
+	Webhook Webhook
+}
@@ -0,0 +1,178 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "https://casher.example.com/auth/sso/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Issuer) {
+	issuer := oidctest.NewIssuer("casher", "secret")
+	t.Cleanup(issuer.Close)
+
+	provider := New(Config{
+		Issuer:       issuer.URL,
+		ClientID:     "casher",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	}, issuer.Client())
+
+	return provider, issuer
+}
+
+// Проходит страницу входа провайдера и возвращает параметры обратного перенаправления
+func authorize(t *testing.T, issuer *oidctest.Issuer, authURL string) url.Values {
+	client := issuer.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/auth/sso/callback", location.Path)
+
+	return location.Query()
+}
+
+func TestProvider_Flow(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	issuer.SetUser(map[string]interface{}{"sub": "42", "email": "jondoe@example.com", "email_verified": true, "name": "Jon Doe"})
+
+	verifier, err := RandomString()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	require.NoError(t, err)
+
+	// Провайдеру передается только хеш проверочного кода
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, Challenge(verifier), u.Query().Get("code_challenge"))
+	assert.NotContains(t, authURL, verifier)
+
+	params := authorize(t, issuer, authURL)
+	assert.Equal(t, "state", params.Get("state"))
+
+	claims, err := provider.Exchange(context.Background(), params.Get("code"), verifier, "nonce")
+
+	require.NoError(t, err)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, issuer.URL, claims.Issuer)
+	assert.Equal(t, "jondoe@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+	assert.Equal(t, "Jon Doe", claims.Name)
+}
+
+func TestProvider_Exchange_WrongVerifier(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	require.NoError(t, err)
+	params := authorize(t, issuer, authURL)
+
+	// Перехваченный код бесполезен без проверочного кода
+	claims, err := provider.Exchange(context.Background(), params.Get("code"), "other", "nonce")
+
+	assert.Nil(t, claims)
+	assert.True(t, errors.Is(err, ErrProvider))
+}
+
+func TestProvider_Exchange_WrongNonce(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	require.NoError(t, err)
+	params := authorize(t, issuer, authURL)
+
+	claims, err := provider.Exchange(context.Background(), params.Get("code"), "verifier", "other")
+
+	assert.Nil(t, claims)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestProvider_Verify(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+
+	valid := func() map[string]interface{} {
+		return issuer.Claims("nonce", map[string]interface{}{"sub": "42"})
+	}
+
+	claims, err := provider.Verify(context.Background(), issuer.Sign(valid()), "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "42", claims.Subject)
+
+	tests := []struct {
+		name   string
+		change func(map[string]interface{})
+	}{
+		{"issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }},
+		{"audience", func(c map[string]interface{}) { c["aud"] = "other" }},
+		{"audience list", func(c map[string]interface{}) { c["aud"] = []string{"other", "another"} }},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"future", func(c map[string]interface{}) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{"nonce", func(c map[string]interface{}) { c["nonce"] = "other" }},
+		{"subject", func(c map[string]interface{}) { delete(c, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.change(c)
+
+			act, err := provider.Verify(context.Background(), issuer.Sign(c), "nonce")
+
+			assert.Nil(t, act)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+
+	// Получатель может быть списком
+	c := valid()
+	c["aud"] = []string{"other", "casher"}
+	_, err = provider.Verify(context.Background(), issuer.Sign(c), "nonce")
+	assert.NoError(t, err)
+}
+
+func TestProvider_Verify_Forged(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+
+	// Токен, подписанный другим провайдером
+	other := oidctest.NewIssuer("casher", "secret")
+	defer other.Close()
+	forged := other.Sign(issuer.Claims("nonce", map[string]interface{}{"sub": "42"}))
+
+	_, err := provider.Verify(context.Background(), forged, "nonce")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Токен без подписи
+	unsigned := "eyJhbGciOiJub25lIn0.eyJzdWIiOiI0MiJ9."
+	_, err = provider.Verify(context.Background(), unsigned, "nonce")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestProvider_Discovery_IssuerMismatch(t *testing.T) {
+	// Документ discovery указывает на другого издателя
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"issuer":"https://evil.example.com","authorization_endpoint":"https://evil.example.com/a",` +
+			`"token_endpoint":"https://evil.example.com/t","jwks_uri":"https://evil.example.com/k"}`))
+	}))
+	defer server.Close()
+
+	provider := New(Config{Issuer: server.URL, ClientID: "casher"}, server.Client())
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.ErrorIs(t, err, ErrProvider)
+}
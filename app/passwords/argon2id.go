@@ -0,0 +1,129 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// Префикс хешей argon2id в формате PHC
+	argon2idPrefix = "$argon2id$"
+)
+
+var (
+	ErrInvalidHash = errors.New("invalid password hash format")
+)
+
+// Argon2Params Параметры argon2id
+type Argon2Params struct {
+	// Объем памяти в КиБ
+	Memory uint32
+	// Количество проходов по памяти
+	Iterations uint32
+	// Количество потоков
+	Parallelism uint8
+	// Длина соли и хеша в байтах
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// Argon2id Хеширование паролей argon2id
+// Хеш хранится в формате PHC: $argon2id$v=19$m=<память>,t=<проходы>,p=<потоки>$<соль>$<хеш>,
+// поэтому параметры можно менять без потери возможности проверить старые хеши
+type Argon2id struct {
+	params Argon2Params
+}
+
+// NewArgon2id Возвращает алгоритм argon2id с указанными параметрами
+func NewArgon2id(params Argon2Params) *Argon2id {
+	return &Argon2id{params: params}
+}
+
+// Hash Берет хеш от пароля со случайной солью
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+
+	return encodeArgon2id(a.params, salt, key), nil
+}
+
+// Check Сравнивает пароль с его хешем по параметрам, записанным в хеше
+func (a *Argon2id) Check(password, hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, actual) == 1
+}
+
+// Recognizes Проверяет, что хеш получен argon2id
+func (a *Argon2id) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+// Outdated Проверяет, что хеш получен с параметрами, отличными от текущих
+func (a *Argon2id) Outdated(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+	return err != nil || params != a.params
+}
+
+// Записывает параметры, соль и хеш в формате PHC
+func encodeArgon2id(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+// Читает параметры, соль и хеш из строки в формате PHC
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	params := Argon2Params{}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,41 @@
+package passwords
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt Хеширование паролей bcrypt
+// Алгоритм учитывает только первые 72 байта пароля, поэтому оставлен для проверки старых хешей
+type Bcrypt struct {
+	cost int
+}
+
+// NewBcrypt Возвращает алгоритм bcrypt с указанной сложностью
+func NewBcrypt(cost int) *Bcrypt {
+	return &Bcrypt{cost: cost}
+}
+
+// Hash Берет хеш от пароля
+func (b *Bcrypt) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	return string(bytes), err
+}
+
+// Check Сравнивает пароль с его хешем
+func (b *Bcrypt) Check(password, hash string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// Recognizes Проверяет, что хеш получен bcrypt
+func (b *Bcrypt) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Outdated Проверяет, что хеш получен с другой сложностью
+func (b *Bcrypt) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.cost
+}
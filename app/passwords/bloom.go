@@ -0,0 +1,109 @@
+package passwords
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// Сигнатура сериализованного фильтра
+const bloomMagic = "BLM1"
+
+var (
+	ErrInvalidBloom = errors.New("invalid bloom filter")
+)
+
+// Bloom Фильтр Блума для проверки вхождения строки в большой список без хранения самого списка
+// Фильтр может ошибочно сообщить, что строка есть в списке, но никогда не пропускает строку из списка
+type Bloom struct {
+	bits   []uint64
+	hashes uint32
+}
+
+// NewBloom Возвращает пустой фильтр для count строк с заданной вероятностью ложного срабатывания
+func NewBloom(count int, falsePositive float64) *Bloom {
+	if count < 1 {
+		count = 1
+	}
+
+	// Оптимальный размер фильтра и количество хеш-функций
+	size := math.Ceil(-float64(count) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	hashes := math.Round(size / float64(count) * math.Ln2)
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &Bloom{
+		bits:   make([]uint64, (int(size)+63)/64),
+		hashes: uint32(hashes),
+	}
+}
+
+// Add Добавляет строку в фильтр
+func (b *Bloom) Add(value string) {
+	for _, i := range b.positions(value) {
+		b.bits[i/64] |= 1 << (i % 64)
+	}
+}
+
+// Contains Проверяет, что строка, возможно, была добавлена в фильтр
+func (b *Bloom) Contains(value string) bool {
+	for _, i := range b.positions(value) {
+		if b.bits[i/64]&(1<<(i%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalBinary Сериализует фильтр: сигнатура, количество хеш-функций и биты фильтра
+func (b *Bloom) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 8+8*len(b.bits))
+	copy(data, bloomMagic)
+	binary.BigEndian.PutUint32(data[4:], b.hashes)
+
+	for i, word := range b.bits {
+		binary.BigEndian.PutUint64(data[8+8*i:], word)
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary Читает фильтр, сериализованный MarshalBinary
+func (b *Bloom) UnmarshalBinary(data []byte) error {
+	if len(data) <= 8 || (len(data)-8)%8 != 0 || string(data[:4]) != bloomMagic {
+		return ErrInvalidBloom
+	}
+
+	hashes := binary.BigEndian.Uint32(data[4:])
+	if hashes == 0 {
+		return ErrInvalidBloom
+	}
+
+	bits := make([]uint64, (len(data)-8)/8)
+	for i := range bits {
+		bits[i] = binary.BigEndian.Uint64(data[8+8*i:])
+	}
+
+	b.bits, b.hashes = bits, hashes
+	return nil
+}
+
+// Возвращает номера битов строки
+// Вместо k независимых хеш-функций используется двойное хеширование двумя половинами FNV-1a
+func (b *Bloom) positions(value string) []uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	sum := h.Sum64()
+
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+	size := uint64(len(b.bits)) * 64
+
+	positions := make([]uint64, b.hashes)
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % size
+	}
+
+	return positions
+}
@@ -0,0 +1,22 @@
+//go:generate go run ../../cmd/bloom -in breached.txt -out breached.bloom
+
+package passwords
+
+import (
+	_ "embed"
+)
+
+// Фильтр Блума популярных утекших паролей, собранный из breached.txt
+// Для большего списка фильтр пересобирается командой cmd/bloom, сам список в приложение не попадает
+//
+//go:embed breached.bloom
+var breachedFilter []byte
+
+// Breached Возвращает встроенный в приложение фильтр утекших паролей
+func Breached() (*Bloom, error) {
+	bloom := &Bloom{}
+	if err := bloom.UnmarshalBinary(breachedFilter); err != nil {
+		return nil, err
+	}
+	return bloom, nil
+}
@@ -0,0 +1,52 @@
+package passwords
+
+// Hasher Алгоритм хеширования паролей
+type Hasher interface {
+	// Hash Берет хеш от пароля с текущими параметрами алгоритма
+	Hash(password string) (string, error)
+	// Check Сравнивает пароль с хешем, полученным этим алгоритмом
+	Check(password, hash string) bool
+	// Recognizes Проверяет, что хеш получен этим алгоритмом
+	Recognizes(hash string) bool
+	// Outdated Проверяет, что хеш этого алгоритма получен с параметрами, отличными от текущих
+	Outdated(hash string) bool
+}
+
+// Passwords Хеширует пароли текущим алгоритмом и проверяет хеши всех известных алгоритмов
+// Хеш устаревшего алгоритма или с устаревшими параметрами при успешной проверке нужно пересчитать
+type Passwords struct {
+	current Hasher
+	legacy  []Hasher
+}
+
+// New Возвращает экземпляр с текущим алгоритмом хеширования и алгоритмами, хеши которых еще могут храниться в базе
+func New(current Hasher, legacy ...Hasher) *Passwords {
+	return &Passwords{
+		current: current,
+		legacy:  legacy,
+	}
+}
+
+// Hash Берет хеш от пароля текущим алгоритмом
+func (p *Passwords) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+// Check Сравнивает пароль с его хешем
+// Если пароль верный, но хеш получен устаревшим алгоритмом или с устаревшими параметрами, то возвращает rehash
+// Хеш, не распознанный ни одним алгоритмом, например у пользователя без пароля, никогда не совпадает с паролем
+func (p *Passwords) Check(password, hash string) (ok, rehash bool) {
+	if p.current.Recognizes(hash) {
+		ok = p.current.Check(password, hash)
+		return ok, ok && p.current.Outdated(hash)
+	}
+
+	for _, h := range p.legacy {
+		if h.Recognizes(hash) {
+			ok = h.Check(password, hash)
+			return ok, ok
+		}
+	}
+
+	return false, false
+}
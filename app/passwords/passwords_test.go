@@ -0,0 +1,170 @@
+package passwords
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Легкие параметры, что бы тесты не тратили время на хеширование
+var params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	hasher := NewArgon2id(params)
+
+	hash, err := hasher.Hash("Qwerty1!")
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`, hash)
+	assert.True(t, hasher.Recognizes(hash))
+	assert.False(t, hasher.Outdated(hash))
+	assert.True(t, hasher.Check("Qwerty1!", hash))
+	assert.False(t, hasher.Check("qwerty1!", hash))
+
+	// Соль случайная, поэтому хеши одного пароля различаются
+	other, err := hasher.Hash("Qwerty1!")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestArgon2id_Params(t *testing.T) {
+	hash, err := NewArgon2id(params).Hash("Qwerty1!")
+	require.NoError(t, err)
+
+	// Хеш проверяется по параметрам из самого хеша, а не по текущим
+	stronger := params
+	stronger.Iterations = 2
+	hasher := NewArgon2id(stronger)
+
+	assert.True(t, hasher.Check("Qwerty1!", hash))
+	assert.True(t, hasher.Outdated(hash))
+}
+
+func TestArgon2id_InvalidHash(t *testing.T) {
+	hasher := NewArgon2id(params)
+
+	for _, hash := range []string{"", "!", "$argon2id$", "$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$"} {
+		assert.False(t, hasher.Check("", hash), hash)
+		assert.True(t, hasher.Outdated(hash), hash)
+	}
+}
+
+func TestPasswords_Check(t *testing.T) {
+	argon := NewArgon2id(params)
+	legacy := NewBcrypt(bcrypt.MinCost)
+	p := New(argon, legacy)
+
+	current, err := p.Hash("Qwerty1!")
+	require.NoError(t, err)
+
+	old, err := legacy.Hash("Qwerty1!")
+	require.NoError(t, err)
+
+	outdated, err := NewArgon2id(Argon2Params{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("Qwerty1!")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		ok       bool
+		rehash   bool
+	}{
+		{name: "current", password: "Qwerty1!", hash: current, ok: true},
+		{name: "legacy algorithm", password: "Qwerty1!", hash: old, ok: true, rehash: true},
+		{name: "outdated params", password: "Qwerty1!", hash: outdated, ok: true, rehash: true},
+		{name: "wrong password", password: "qwerty1!", hash: old},
+		// Пользователь, вошедший через SSO, не имеет пароля
+		{name: "no password", password: "!", hash: "!"},
+		{name: "empty hash", password: "", hash: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash := p.Check(tt.password, tt.hash)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.rehash, rehash)
+		})
+	}
+}
+
+func TestBcrypt_Outdated(t *testing.T) {
+	hash, err := NewBcrypt(bcrypt.MinCost).Hash("Qwerty1!")
+	require.NoError(t, err)
+
+	assert.False(t, NewBcrypt(bcrypt.MinCost).Outdated(hash))
+	assert.True(t, NewBcrypt(bcrypt.MinCost+1).Outdated(hash))
+}
+
+func TestPolicy_Check(t *testing.T) {
+	breached := NewBloom(1, 0.001)
+	breached.Add("qwerty1!")
+
+	policy := Policy{MinLength: 7, MaxLength: 20, Digit: true, Upper: true, Special: true, MinEntropy: 40, Breached: breached}
+
+	tests := []struct {
+		name     string
+		password string
+		failed   []Rule
+	}{
+		{name: "strong", password: "Gx7#kpLm2"},
+		{name: "short", password: "Gx7#k", failed: []Rule{RuleMinLength, RuleEntropy}},
+		{name: "long", password: "Gx7#kpLm2Gx7#kpLm2Gx7", failed: []Rule{RuleMaxLength}},
+		{name: "no classes", password: "gxqkplmwz", failed: []Rule{RuleDigit, RuleUpper, RuleSpecial}},
+		{name: "predictable", password: "Aaaaaaa1!", failed: []Rule{RuleEntropy}},
+		// Утекший пароль находится независимо от регистра
+		{name: "breached", password: "Qwerty1!", failed: []Rule{RuleBreached}},
+		{name: "cyrillic", password: "Пароль№7ъ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.failed, policy.Check(tt.password))
+		})
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	assert.Equal(t, 0.0, Entropy(""))
+	assert.InDelta(t, 8*math.Log2(10), Entropy("90215374"), 0.001)
+
+	// Повторы и последовательности почти не добавляют энтропии
+	assert.InDelta(t, 2*math.Log2(10)+6, Entropy("12345678"), 0.001)
+	assert.InDelta(t, 2*math.Log2(26)+6, Entropy("aaaaaaab"), 0.001)
+	assert.Greater(t, Entropy("Gx7#kpLm2"), Entropy("Abcdefg1!"))
+}
+
+func TestBloom(t *testing.T) {
+	bloom := NewBloom(100, 0.001)
+	for i := 0; i < 100; i++ {
+		bloom.Add(fmt.Sprintf("password%d", i))
+	}
+
+	data, err := bloom.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := &Bloom{}
+	require.NoError(t, restored.UnmarshalBinary(data))
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, restored.Contains(fmt.Sprintf("password%d", i)))
+	}
+	assert.False(t, restored.Contains("Gx7#kpLm2"))
+
+	for _, data := range [][]byte{nil, []byte("BLM1"), []byte("BLM0\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x01"), data[:len(data)-1]} {
+		assert.ErrorIs(t, (&Bloom{}).UnmarshalBinary(data), ErrInvalidBloom)
+	}
+}
+
+func TestBreached(t *testing.T) {
+	bloom, err := Breached()
+	require.NoError(t, err)
+
+	assert.True(t, bloom.Contains("password"))
+	assert.True(t, bloom.Contains("qwerty123"))
+	assert.False(t, bloom.Contains("Gx7#kpLm2"))
+}
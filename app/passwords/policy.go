@@ -0,0 +1,158 @@
+package passwords
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule Правило политики паролей, которому не соответствует пароль
+type Rule string
+
+const (
+	RuleMinLength Rule = "min_length"
+	RuleMaxLength Rule = "max_length"
+	RuleDigit     Rule = "digit"
+	RuleUpper     Rule = "upper"
+	RuleLower     Rule = "lower"
+	RuleSpecial   Rule = "special"
+	RuleEntropy   Rule = "entropy"
+	RuleBreached  Rule = "breached"
+)
+
+// Размеры алфавитов классов символов для оценки энтропии
+const (
+	digitAlphabet   = 10
+	letterAlphabet  = 26
+	specialAlphabet = 33
+	// Буквы национальных алфавитов и прочие символы вне ASCII
+	otherAlphabet = 100
+)
+
+// Policy Требования к новым паролям
+type Policy struct {
+	// Длина пароля в символах
+	MinLength int
+	// Ограничивает время хеширования, 0 - без ограничения
+	MaxLength int
+	// Обязательные классы символов
+	Digit   bool
+	Upper   bool
+	Lower   bool
+	Special bool
+	// Минимальная оценка энтропии в битах, 0 - без проверки
+	MinEntropy float64
+	// Список утекших паролей, nil - без проверки
+	Breached *Bloom
+}
+
+// Check Проверяет пароль и возвращает правила, которым он не соответствует, в порядке их описания в политике
+// Если пароль соответствует политике, то возвращает пустой список
+func (p Policy) Check(password string) []Rule {
+	var failed []Rule
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		failed = append(failed, RuleMinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		failed = append(failed, RuleMaxLength)
+	}
+
+	var digit, upper, lower, special bool
+	for _, c := range password {
+		switch {
+		case unicode.IsNumber(c):
+			digit = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			special = true
+		}
+	}
+
+	if p.Digit && !digit {
+		failed = append(failed, RuleDigit)
+	}
+	if p.Upper && !upper {
+		failed = append(failed, RuleUpper)
+	}
+	if p.Lower && !lower {
+		failed = append(failed, RuleLower)
+	}
+	if p.Special && !special {
+		failed = append(failed, RuleSpecial)
+	}
+
+	if p.MinEntropy > 0 && Entropy(password) < p.MinEntropy {
+		failed = append(failed, RuleEntropy)
+	}
+
+	// Списки утечек часто содержат пароль только в одном регистре, поэтому проверяется и строчный вариант
+	if p.Breached != nil && (p.Breached.Contains(password) || p.Breached.Contains(strings.ToLower(password))) {
+		failed = append(failed, RuleBreached)
+	}
+
+	return failed
+}
+
+// Entropy Оценивает энтропию пароля в битах по размеру алфавита использованных классов символов
+// Повтор предыдущего символа и продолжение последовательности вроде abc или 321 добавляют только 1 бит,
+// поэтому оценка пароля aaaaaaa1 или 12345678 заметно ниже, чем у пароля той же длины из случайных символов
+func Entropy(password string) float64 {
+	var digit, upper, lower, special, other bool
+	for _, c := range password {
+		switch {
+		case c > unicode.MaxASCII:
+			other = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		default:
+			special = true
+		}
+	}
+
+	alphabet := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{
+		{used: digit, size: digitAlphabet},
+		{used: upper, size: letterAlphabet},
+		{used: lower, size: letterAlphabet},
+		{used: special, size: specialAlphabet},
+		{used: other, size: otherAlphabet},
+	} {
+		if class.used {
+			alphabet += class.size
+		}
+	}
+	if alphabet == 0 {
+		return 0
+	}
+
+	bits := math.Log2(float64(alphabet))
+	entropy := 0.0
+	prev, step := rune(-1), rune(0)
+	for i, c := range []rune(password) {
+		diff := c - prev
+		switch {
+		case i > 0 && diff == 0:
+			entropy++
+		case i > 1 && (diff == 1 || diff == -1) && diff == step:
+			entropy++
+		default:
+			entropy += bits
+		}
+		prev, step = c, diff
+	}
+
+	return entropy
+}
@@ -0,0 +1,135 @@
+package attempts
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/lib/pq"
+)
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Get Возвращает счетчик неудачных попыток входа по ключу
+// Если неудачных попыток не было, возвращает sql.ErrNoRows
+func (store *repository) Get(key string) (*models.LoginAttempts, error) {
+	row := store.db.QueryRow(
+		"select key, failed_attempts, total_failures, failed_at, locked_at, blocked_until from login_attempts where key = $1",
+		key,
+	)
+
+	return scan(row)
+}
+
+// Reserve Учитывает попытку входа как неудачную заранее, если вход по ключу сейчас разрешен, и возвращает обновленный счетчик
+// Серия, последняя неудача которой была раньше since, начинается заново
+// Время следующей разрешенной попытки вычисляется в том же запросе по расписанию задержек, поэтому параллельные попытки
+// с разных экземпляров приложения не проходят проверку одновременно, а каждая получает свой номер в серии
+// Если вход запрещен, возвращает sql.ErrNoRows
+func (store *repository) Reserve(key string, at, since time.Time, schedule models.LoginSchedule) (*models.LoginAttempts, error) {
+	delays := make([]int64, len(schedule.Delays))
+	for i, d := range schedule.Delays {
+		delays[i] = d.Milliseconds()
+	}
+
+	row := store.db.QueryRow(
+		fmt.Sprintf(
+			`insert into login_attempts(key, failed_attempts, total_failures, failed_at, blocked_until) values ($1, 1, 1, $2, %s)
+			on conflict (key) do update set
+				failed_attempts = %s,
+				total_failures = login_attempts.total_failures + 1,
+				failed_at = $2,
+				blocked_until = %s
+			where login_attempts.failed_at < $3 or login_attempts.blocked_until <= $2
+			returning key, failed_attempts, total_failures, failed_at, locked_at, blocked_until`,
+			blockedUntil("1"),
+			nextAttempt,
+			blockedUntil(nextAttempt),
+		),
+		key,
+		at,
+		since,
+		pq.Array(delays),
+		schedule.Limit,
+		schedule.Lockout.Milliseconds(),
+	)
+
+	return scan(row)
+}
+
+// Release Снимает попытку, учтенную заранее, если пароль оказался верным
+// Время следующей разрешенной попытки не меняется
+func (store *repository) Release(key string) error {
+	_, err := store.db.Exec(
+		`update login_attempts set
+			failed_attempts = greatest(failed_attempts - 1, 0),
+			total_failures = greatest(total_failures - 1, 0)
+		where key = $1`,
+		key,
+	)
+
+	return err
+}
+
+// Lock Отмечает временную блокировку входа по ключу
+func (store *repository) Lock(key string, at time.Time) error {
+	_, err := store.db.Exec("update login_attempts set locked_at = $2 where key = $1", key, at)
+
+	return err
+}
+
+// Reset Удаляет счетчик после успешного входа и возвращает его последнее состояние
+// Если неудачных попыток не было, возвращает sql.ErrNoRows
+func (store *repository) Reset(key string) (*models.LoginAttempts, error) {
+	row := store.db.QueryRow(
+		"delete from login_attempts where key = $1 returning key, failed_attempts, total_failures, failed_at, locked_at, blocked_until",
+		key,
+	)
+
+	return scan(row)
+}
+
+// DeleteExpired Удаляет счетчики, последняя неудача которых была раньше before, и возвращает их количество
+func (store *repository) DeleteExpired(before time.Time) (int64, error) {
+	res, err := store.db.Exec("delete from login_attempts where failed_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Номер новой попытки в серии счетчика
+const nextAttempt = "case when login_attempts.failed_at < $3 then 1 else login_attempts.failed_attempts + 1 end"
+
+// Возвращает выражение времени следующей разрешенной попытки после неудачи с номером n:
+// задержки расписания $4 в миллисекундах, после лимита $5 блокировка $6
+func blockedUntil(n string) string {
+	return fmt.Sprintf(
+		"$2::timestamptz + (case when %[1]s >= $5 then $6 else ($4::bigint[])[least(%[1]s, cardinality($4::bigint[]))] end) * interval '1 millisecond'",
+		n,
+	)
+}
+
+// Читает счетчик из строки результата запроса
+func scan(row *sql.Row) (*models.LoginAttempts, error) {
+	a := models.LoginAttempts{}
+	if err := row.Scan(&a.Key, &a.FailedAttempts, &a.TotalFailures, &a.FailedAt, &a.Locked, &a.BlockedUntil); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,154 @@
+package attempts
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from login_attempts;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+// Первая попытка без задержки, после второй задержка минута, после третьей блокировка на час
+var schedule = models.LoginSchedule{Delays: []time.Duration{0, time.Minute}, Limit: 3, Lockout: time.Hour}
+
+func (s *storeSuite) TestReserve() {
+	now := time.Now().Truncate(time.Second)
+	since := now.Add(-2 * time.Hour)
+
+	for i := 1; i <= 2; i++ {
+		a, err := s.store.Reserve("login:jondoe", now, since, schedule)
+		if err != nil {
+			s.T().Fatal(err)
+		}
+
+		if a.FailedAttempts != int64(i) || a.TotalFailures != int64(i) {
+			s.T().Fatalf("expected %d failures, got %v", i, a)
+		}
+	}
+
+	// Во время задержки попытка не учитывается
+	if _, err := s.store.Reserve("login:jondoe", now.Add(time.Second), since, schedule); err != sql.ErrNoRows {
+		s.T().Fatalf("expected %v, got %v", sql.ErrNoRows, err)
+	}
+
+	// После задержки попытка учитывается, а после исчерпания лимита действует блокировка
+	later := now.Add(time.Minute)
+	a, err := s.store.Reserve("login:jondoe", later, since, schedule)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if a.FailedAttempts != 3 || !a.BlockedUntil.Equal(later.Add(time.Hour)) {
+		s.T().Errorf("expected lockout until %v, got %v", later.Add(time.Hour), a)
+	}
+
+	// Снятая попытка уменьшает счетчики, но не задержку
+	if err = s.store.Release("login:jondoe"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	a, err = s.store.Get("login:jondoe")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if a.FailedAttempts != 2 || a.TotalFailures != 2 || !a.BlockedUntil.Equal(later.Add(time.Hour)) {
+		s.T().Errorf("expected released attempt, got %v", a)
+	}
+
+	// После паузы дольше окна серия начинается заново, а общее количество неудач сохраняется
+	afterWindow := later.Add(3 * time.Hour)
+	a, err = s.store.Reserve("login:jondoe", afterWindow, afterWindow.Add(-2*time.Hour), schedule)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if a.FailedAttempts != 1 || a.TotalFailures != 3 {
+		s.T().Errorf("expected new series with 3 total failures, got %v", a)
+	}
+
+	// Счетчик по другому ключу не меняется
+	if _, err = s.store.Get("ip:10.0.0.1"); err != sql.ErrNoRows {
+		s.T().Errorf("expected %v, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func (s *storeSuite) TestLockAndReset() {
+	now := time.Now().Truncate(time.Second)
+
+	if _, err := s.store.Reserve("login:jondoe", now, now.Add(-time.Hour), schedule); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err := s.store.Lock("login:jondoe", now); err != nil {
+		s.T().Fatal(err)
+	}
+
+	a, err := s.store.Reset("login:jondoe")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if a.Locked == nil || !a.Locked.Equal(now) {
+		s.T().Errorf("expected locked at %v, got %v", now, a.Locked)
+	}
+
+	if _, err = s.store.Reset("login:jondoe"); err != sql.ErrNoRows {
+		s.T().Errorf("expected %v, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func (s *storeSuite) TestDeleteExpired() {
+	now := time.Now()
+
+	if _, err := s.store.Reserve("ip:10.0.0.1", now.Add(-48*time.Hour), now.Add(-49*time.Hour), schedule); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if _, err := s.store.Reserve("ip:10.0.0.2", now, now.Add(-time.Hour), schedule); err != nil {
+		s.T().Fatal(err)
+	}
+
+	count, err := s.store.DeleteExpired(now.Add(-24 * time.Hour))
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if count != 1 {
+		s.T().Errorf("incorrect count, wanted 1, got %d", count)
+	}
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+const (
+	// Колонки, из которых читается запись журнала
+	entryColumns = "id, actor_id, action, target_type, target_id, before, after, ip, user_agent, created_at"
+)
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Добавляет запись в журнал аудита
+// Журнал только дополняется, изменение и удаление записей запрещено триггером в БД
+func (store *repository) Create(entry *models.AuditEntry) (int64, error) {
+	row := store.db.QueryRow(
+		`insert into audit_log(actor_id, action, target_type, target_id, before, after, ip, user_agent)
+values ($1,$2,$3,$4,$5,$6,$7,$8) returning id`,
+		nullID(entry.ActorID),
+		entry.Action,
+		entry.TargetType,
+		nullID(entry.TargetID),
+		nullJSON(entry.Before),
+		nullJSON(entry.After),
+		entry.IP,
+		entry.UserAgent,
+	)
+
+	var entryID int64
+	err := row.Scan(&entryID)
+
+	return entryID, err
+}
+
+// Get Возвращает историю действий пользователя
+// В историю попадают действия самого пользователя и неудачные попытки входа в его аккаунт
+func (store *repository) Get(userID, page, size int64) (*models.AuditPaginator, error) {
+	query := `select ` + entryColumns + `
+from audit_log
+where actor_id = $1 or (target_type = $2 and target_id = $1)
+order by created_at desc, id desc`
+	query = addPagination(query, page, size)
+
+	rows, err := store.db.Query(query, userID, models.AuditTargetUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEntries(rows, page, size)
+}
+
+// GetByAction Возвращает записи всех пользователей, действие которых начинается с префикса
+func (store *repository) GetByAction(prefix string, page, size int64) (*models.AuditPaginator, error) {
+	query := `select ` + entryColumns + `
+from audit_log
+where action like $1 || '%'
+order by created_at desc, id desc`
+	query = addPagination(query, page, size)
+
+	rows, err := store.db.Query(query, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEntries(rows, page, size)
+}
+
+// Читает записи журнала из результата запроса по колонкам entryColumns и закрывает его
+func scanEntries(rows *sql.Rows, page, size int64) (*models.AuditPaginator, error) {
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		e := models.AuditEntry{}
+		var actorID, targetID sql.NullInt64
+		err := rows.Scan(
+			&e.ID, &actorID, &e.Action, &e.TargetType, &targetID, &e.Before, &e.After, &e.IP, &e.UserAgent, &e.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		e.ActorID, e.TargetID = actorID.Int64, targetID.Int64
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Если пагинация не нужна или количество объектов меньше размера страницы возвращаем все
+	if !needPagination(page, size) || len(entries) <= int(size) {
+		return &models.AuditPaginator{
+			Entries: entries,
+		}, nil
+	}
+
+	return &models.AuditPaginator{
+		Entries: entries[:size],
+		HasMore: true,
+	}, nil
+}
+
+// Преобразует пустой идентификатор в NULL
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// Преобразует пустой снимок в NULL
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return string(data)
+}
+
+// Добавляет к строке SQL запроса данные пагинации
+func addPagination(query string, page, size int64) string {
+	if !needPagination(page, size) {
+		return query
+	}
+
+	// Запрашиваем на 1 объект больше, что бы проверить есть ли еще данные не запрашивая дополнительное count
+	limit, offset := size+1, (page-1)*size
+	return fmt.Sprintf("%s limit %d offset %d", query, limit, offset)
+}
+
+// Определяет нужна ли в запросе пагинация
+func needPagination(page, size int64) bool {
+	return page != 0 && size != 0
+}
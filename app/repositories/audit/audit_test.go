@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	// Журнал защищен от delete, но не от truncate
+	_, err := s.db.Exec("truncate audit_log")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestCreate() {
+	entryID, err := s.store.Create(&models.AuditEntry{
+		ActorID:    10000000,
+		Action:     models.AuditOperationCreate,
+		TargetType: models.AuditTargetOperation,
+		TargetID:   1,
+		After:      []byte(`{"subject":"Таверна Fish & Chips"}`),
+		IP:         "127.0.0.1",
+		UserAgent:  "test",
+	})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if entryID == 0 {
+		s.T().Error("expected entry ID")
+	}
+}
+
+func (s *storeSuite) TestGet() {
+	entries := []models.AuditEntry{
+		{ActorID: 10000000, Action: models.AuditLogin, TargetType: models.AuditTargetUser, TargetID: 10000000},
+		{Action: models.AuditLoginFailed, TargetType: models.AuditTargetUser, TargetID: 10000000},
+		{ActorID: 20000000, Action: models.AuditLogin, TargetType: models.AuditTargetUser, TargetID: 20000000},
+	}
+	for i := range entries {
+		if _, err := s.store.Create(&entries[i]); err != nil {
+			s.T().Fatal(err)
+		}
+	}
+
+	paginator, err := s.store.Get(10000000, 1, 1)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(paginator.Entries) != 1 || !paginator.HasMore {
+		s.T().Errorf("incorrect page, wanted 1 entry with more, got %d", len(paginator.Entries))
+	}
+
+	paginator, err = s.store.Get(10000000, 0, 0)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(paginator.Entries) != 2 {
+		s.T().Errorf("incorrect count, wanted 2, got %d", len(paginator.Entries))
+	}
+
+	if paginator.Entries[0].Action != models.AuditLoginFailed || paginator.Entries[0].ActorID != 0 {
+		s.T().Errorf("expected anonymous failed login, got %v", paginator.Entries[0])
+	}
+}
+
+func (s *storeSuite) TestGetByAction() {
+	entries := []models.AuditEntry{
+		{ActorID: 10000000, Action: models.AuditLogin, TargetType: models.AuditTargetUser, TargetID: 10000000},
+		{ActorID: 10000000, Action: models.AuditAdminDisable, TargetType: models.AuditTargetUser, TargetID: 20000000},
+		{ActorID: 30000000, Action: models.AuditAdminDelete, TargetType: models.AuditTargetUser, TargetID: 40000000},
+	}
+	for i := range entries {
+		if _, err := s.store.Create(&entries[i]); err != nil {
+			s.T().Fatal(err)
+		}
+	}
+
+	paginator, err := s.store.GetByAction(models.AuditAdminPrefix, 0, 0)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(paginator.Entries) != 2 {
+		s.T().Fatalf("incorrect count, wanted 2, got %d", len(paginator.Entries))
+	}
+
+	if paginator.Entries[0].Action != models.AuditAdminDelete {
+		s.T().Errorf("expected %v, got %v", models.AuditAdminDelete, paginator.Entries[0].Action)
+	}
+}
+
+func (s *storeSuite) TestAppendOnly() {
+	entryID, err := s.store.Create(&models.AuditEntry{ActorID: 10000000, Action: models.AuditLogout})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if _, err := s.db.Exec("update audit_log set action = $2 where id = $1", entryID, models.AuditLogin); err == nil {
+		s.T().Error("expected update to be rejected")
+	}
+
+	if _, err := s.db.Exec("delete from audit_log where id = $1", entryID); err == nil {
+		s.T().Error("expected delete to be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package exports
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/outbox"
+)
+
+const (
+	// Колонки, из которых читается выгрузка без самого архива
+	exportColumns = "id, user_id, status, size, expires_at, ready_at, created_at"
+)
+
+var (
+	ErrExportNotFound = errors.New("export not found")
+)
+
+type queryer interface {
+	Begin() (*sql.Tx, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Создает выгрузку, ожидающую сборки архива, и возвращает ее
+// Вместе с выгрузкой в той же транзакции в outbox записывается событие, по которому архив собирается в фоне
+func (store *repository) Create(userID int64) (*models.Export, error) {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	row := tx.QueryRow("insert into exports(user_id, status) values ($1,$2) returning "+exportColumns, userID, models.ExportPending)
+	export, err := scan(row)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = outbox.Add(tx, models.EventExportRequested, models.ExportEvent{ExportID: export.ID, UserID: userID}); err != nil {
+		return nil, err
+	}
+
+	return export, tx.Commit()
+}
+
+// Get Возвращает выгрузку по ее ID
+// Если выгрузки нет, возвращает sql.ErrNoRows
+func (store *repository) Get(exportID int64) (*models.Export, error) {
+	return scan(store.db.QueryRow("select "+exportColumns+" from exports where id = $1", exportID))
+}
+
+// Latest Возвращает последнюю выгрузку пользователя
+// Если пользователь не запрашивал выгрузку, возвращает sql.ErrNoRows
+func (store *repository) Latest(userID int64) (*models.Export, error) {
+	return scan(store.db.QueryRow("select "+exportColumns+" from exports where user_id = $1 order by created_at desc, id desc limit 1", userID))
+}
+
+// SetReady Сохраняет собранный архив и срок, до которого его можно скачать
+func (store *repository) SetReady(exportID int64, data []byte, expires time.Time) error {
+	res, err := store.db.Exec(
+		"update exports set status = $2, data = $3, size = $4, expires_at = $5, ready_at = now() where id = $1",
+		exportID,
+		models.ExportReady,
+		data,
+		len(data),
+		expires,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrExportNotFound
+	}
+
+	return nil
+}
+
+// Data Возвращает собранный архив выгрузки пользователя
+// Архив чужой, еще не собранной или истекшей выгрузки неотличим от несуществующего, в этих случаях возвращается ErrExportNotFound
+func (store *repository) Data(exportID, userID int64, now time.Time) ([]byte, error) {
+	var data []byte
+	err := store.db.QueryRow(
+		"select data from exports where id = $1 and user_id = $2 and status = $3 and expires_at > $4",
+		exportID,
+		userID,
+		models.ExportReady,
+		now,
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// DeleteExpired Удаляет выгрузки, срок хранения которых истек раньше before, и возвращает их количество
+func (store *repository) DeleteExpired(before time.Time) (int64, error) {
+	res, err := store.db.Exec("delete from exports where expires_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Читает выгрузку из строки результата запроса по колонкам exportColumns
+func scan(row *sql.Row) (*models.Export, error) {
+	e := models.Export{}
+	if err := row.Scan(&e.ID, &e.UserID, &e.Status, &e.Size, &e.Expires, &e.Ready, &e.Created); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
@@ -0,0 +1,143 @@
+package exports
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from outbox; delete from exports; delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestCreate() {
+	export, err := s.store.Create(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(models.ExportPending, export.Status)
+
+	// Вместе с выгрузкой записывается событие для сборки архива
+	var count int
+	err = s.db.QueryRow("select count(*) from outbox where type = $1", models.EventExportRequested).Scan(&count)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(1, count)
+
+	act, err := s.store.Latest(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(export.ID, act.ID)
+
+	if _, err = s.store.Latest(10000001); err != sql.ErrNoRows {
+		s.T().Errorf("expected %v, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func (s *storeSuite) TestSetReady() {
+	export, err := s.store.Create(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	now := time.Now()
+
+	// Пока архив не собран, скачать его нельзя
+	if _, err = s.store.Data(export.ID, 10000000, now); err != ErrExportNotFound {
+		s.T().Errorf("expected %v, got %v", ErrExportNotFound, err)
+	}
+
+	if err = s.store.SetReady(export.ID, []byte("zip"), now.Add(time.Hour)); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.Get(export.ID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(models.ExportReady, act.Status)
+	s.Equal(int64(3), act.Size)
+
+	data, err := s.store.Data(export.ID, 10000000, now)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal([]byte("zip"), data)
+
+	// Чужой и истекший архив не отдается
+	if _, err = s.store.Data(export.ID, 10000001, now); err != ErrExportNotFound {
+		s.T().Errorf("expected %v, got %v", ErrExportNotFound, err)
+	}
+	if _, err = s.store.Data(export.ID, 10000000, now.Add(2*time.Hour)); err != ErrExportNotFound {
+		s.T().Errorf("expected %v, got %v", ErrExportNotFound, err)
+	}
+
+	if err = s.store.SetReady(20000000, []byte("zip"), now); err != ErrExportNotFound {
+		s.T().Errorf("expected %v, got %v", ErrExportNotFound, err)
+	}
+}
+
+func (s *storeSuite) TestDeleteExpired() {
+	export, err := s.store.Create(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	now := time.Now()
+	if err = s.store.SetReady(export.ID, []byte("zip"), now.Add(-time.Hour)); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Выгрузка, архив которой еще собирается, не удаляется
+	if _, err = s.store.Create(10000000); err != nil {
+		s.T().Fatal(err)
+	}
+
+	count, err := s.store.DeleteExpired(now)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if count != 1 {
+		s.T().Errorf("incorrect count, wanted 1, got %d", count)
+	}
+}
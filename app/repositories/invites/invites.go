@@ -0,0 +1,107 @@
+package invites
+
+import (
+	"database/sql"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Создает новое приглашение и возвращает его ID
+func (store *repository) Create(invite *models.Invite) (int64, error) {
+	row := store.db.QueryRow(
+		"insert into invites(inviter_id, hash, expires_at) values ($1,$2,$3) returning id",
+		invite.InviterID,
+		invite.Hash,
+		invite.Expires,
+	)
+
+	var inviteID int64
+	err := row.Scan(&inviteID)
+
+	return inviteID, err
+}
+
+// Get Возвращает список приглашений пользователя
+func (store *repository) Get(inviterID int64) ([]models.Invite, error) {
+	query := `select id, inviter_id, hash, expires_at, coalesce(used_by, 0), used_at, revoked_at, created_at
+from invites where inviter_id=$1 order by created_at desc`
+
+	rows, err := store.db.Query(query, inviterID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var invites []models.Invite
+	for rows.Next() {
+		i := models.Invite{}
+		if err := rows.Scan(&i.ID, &i.InviterID, &i.Hash, &i.Expires, &i.UsedBy, &i.Used, &i.Revoked, &i.Created); err != nil {
+			return nil, err
+		}
+
+		invites = append(invites, i)
+	}
+
+	return invites, rows.Err()
+}
+
+// GetByHash Возвращает приглашение по хешу его ссылки
+func (store *repository) GetByHash(hash string) (*models.Invite, error) {
+	query := `select id, inviter_id, hash, expires_at, coalesce(used_by, 0), used_at, revoked_at, created_at
+from invites where hash=$1`
+
+	row := store.db.QueryRow(query, hash)
+
+	i := models.Invite{}
+	if err := row.Scan(&i.ID, &i.InviterID, &i.Hash, &i.Expires, &i.UsedBy, &i.Used, &i.Revoked, &i.Created); err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+// Revoke Отзывает неиспользованное приглашение пользователя
+func (store *repository) Revoke(inviterID, inviteID int64) error {
+	res, err := store.db.Exec(
+		"update invites set revoked_at = now() where id = $1 and inviter_id = $2 and used_at is null and revoked_at is null",
+		inviteID,
+		inviterID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, &models.NotFoundError{Entity: "invite", ID: inviteID})
+}
+
+// Проверяет, что запрос затронул запись пользователя, иначе возвращает переданную ошибку
+func checkAffected(res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return notFound
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package invites
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from invites; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestCreate() {
+	expires := time.Now().Add(time.Hour)
+	inviteID, err := s.store.Create(&models.Invite{
+		InviterID: 10000000,
+		Hash:      "hash",
+		Expires:   expires,
+	})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.GetByHash("hash")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.ID != inviteID {
+		s.T().Errorf("expected %v, got %v", inviteID, act.ID)
+	}
+
+	if act.InviterID != 10000000 {
+		s.T().Errorf("expected %v, got %v", 10000000, act.InviterID)
+	}
+
+	if !act.Active(time.Now()) {
+		s.T().Errorf("expected active invite, got %v", act)
+	}
+}
+
+func (s *storeSuite) TestRevoke() {
+	inviteID, err := s.store.Create(&models.Invite{InviterID: 10000000, Hash: "hash", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Чужое приглашение отозвать нельзя
+	if err = s.store.Revoke(20000000, inviteID); !errors.Is(err, models.ErrNotFound) {
+		s.T().Fatalf("expected not found, got %v", err)
+	}
+
+	invites, err := s.store.Get(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(invites) != 1 || invites[0].Revoked != nil {
+		s.T().Fatalf("expected one active invite, got %v", invites)
+	}
+
+	if err = s.store.Revoke(10000000, inviteID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	invites, err = s.store.Get(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(invites) != 1 || invites[0].Revoked == nil {
+		s.T().Errorf("expected revoked invite, got %v", invites)
+	}
+}
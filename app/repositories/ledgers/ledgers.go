@@ -0,0 +1,181 @@
+package ledgers
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+var (
+	ErrDuplicateKey = errors.New("duplicate key value error")
+	ErrUserNotFound = errors.New("user not found error")
+)
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Создает бухгалтерию и делает пользователя ее владельцем
+func (store *repository) Create(userID int64, name string) (int64, error) {
+	row := store.db.QueryRow(
+		`with l as (insert into ledgers(name) values ($2) returning id)
+insert into ledger_members(ledger_id, user_id, role) select l.id, $1, $3 from l returning ledger_id`,
+		userID,
+		name,
+		models.RoleOwner,
+	)
+
+	var ledgerID int64
+	err := row.Scan(&ledgerID)
+
+	return ledgerID, err
+}
+
+// Get Возвращает бухгалтерии, в которых состоит пользователь, вместе с его ролью
+// Первой идет самая ранняя бухгалтерия пользователя
+func (store *repository) Get(userID int64) ([]models.Ledger, error) {
+	query := `select l.id, l.name, m.role, l.created_at
+from ledgers l
+join ledger_members m on m.ledger_id = l.id
+where m.user_id = $1
+order by l.created_at, l.id`
+
+	rows, err := store.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var ledgers []models.Ledger
+	for rows.Next() {
+		l := models.Ledger{}
+		if err := rows.Scan(&l.ID, &l.Name, &l.Role, &l.Created); err != nil {
+			return nil, err
+		}
+
+		ledgers = append(ledgers, l)
+	}
+
+	return ledgers, rows.Err()
+}
+
+// GetRole Возвращает роль пользователя в бухгалтерии
+// Если пользователь не состоит в бухгалтерии, то возвращает пустую роль
+func (store *repository) GetRole(ledgerID, userID int64) (models.LedgerRole, error) {
+	row := store.db.QueryRow("select role from ledger_members where ledger_id = $1 and user_id = $2", ledgerID, userID)
+
+	var role models.LedgerRole
+	err := row.Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return role, err
+}
+
+// GetMembers Возвращает участников бухгалтерии
+func (store *repository) GetMembers(ledgerID int64) ([]models.LedgerMember, error) {
+	query := `select m.ledger_id, m.user_id, u.login, u.name, m.role, m.created_at
+from ledger_members m
+join users u on u.id = m.user_id
+where m.ledger_id = $1
+order by m.created_at, m.user_id`
+
+	rows, err := store.db.Query(query, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var members []models.LedgerMember
+	for rows.Next() {
+		m := models.LedgerMember{}
+		if err := rows.Scan(&m.LedgerID, &m.UserID, &m.Login, &m.Name, &m.Role, &m.Created); err != nil {
+			return nil, err
+		}
+
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember Добавляет в бухгалтерию пользователя с указанным логином
+func (store *repository) AddMember(ledgerID int64, login string, role models.LedgerRole) error {
+	res, err := store.db.Exec(
+		"insert into ledger_members(ledger_id, user_id, role) select $1, id, $3 from users where login = $2",
+		ledgerID,
+		login,
+		role,
+	)
+	if isDuplicateErr(err) {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RemoveMember Исключает участника из бухгалтерии
+// Владельцы бухгалтерии не исключаются
+func (store *repository) RemoveMember(ledgerID, userID int64) error {
+	res, err := store.db.Exec(
+		"delete from ledger_members where ledger_id = $1 and user_id = $2 and role <> $3",
+		ledgerID,
+		userID,
+		models.RoleOwner,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return &models.NotFoundError{Entity: "ledger member", ID: userID}
+	}
+
+	return nil
+}
+
+// Проверяет, является ли ошибка ошибкой дупликации
+func isDuplicateErr(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
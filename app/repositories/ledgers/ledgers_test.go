@@ -0,0 +1,134 @@
+package ledgers
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values
+(10000000, 'jondoe','qwerty', 'Jon Doe', now()),
+(20000000, 'janedoe','qwerty', 'Jane Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestCreate() {
+	ledgerID, err := s.store.Create(10000000, "Семейный бюджет")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	ledgers, err := s.store.Get(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(ledgers) != 1 {
+		s.T().Fatalf("incorrect count, wanted 1, got %d", len(ledgers))
+	}
+
+	if ledgers[0].ID != ledgerID || ledgers[0].Role != models.RoleOwner {
+		s.T().Errorf("expected owned ledger %d, got %v", ledgerID, ledgers[0])
+	}
+}
+
+func (s *storeSuite) TestMembers() {
+	ledgerID, err := s.store.Create(10000000, "Семейный бюджет")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err := s.store.AddMember(ledgerID, "janedoe", models.RoleViewer); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err := s.store.AddMember(ledgerID, "janedoe", models.RoleEditor); err != ErrDuplicateKey {
+		s.T().Errorf("expected %v, got %v", ErrDuplicateKey, err)
+	}
+
+	if err := s.store.AddMember(ledgerID, "unknown", models.RoleEditor); err != ErrUserNotFound {
+		s.T().Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+
+	role, err := s.store.GetRole(ledgerID, 20000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if role != models.RoleViewer {
+		s.T().Errorf("expected %v, got %v", models.RoleViewer, role)
+	}
+
+	members, err := s.store.GetMembers(ledgerID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(members) != 2 {
+		s.T().Errorf("incorrect count, wanted 2, got %d", len(members))
+	}
+
+	// Владелец не исключается
+	if err := s.store.RemoveMember(ledgerID, 10000000); !errors.Is(err, models.ErrNotFound) {
+		s.T().Fatalf("expected not found, got %v", err)
+	}
+
+	if err := s.store.RemoveMember(ledgerID, 20000000); err != nil {
+		s.T().Fatal(err)
+	}
+
+	members, err = s.store.GetMembers(ledgerID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(members) != 1 || members[0].UserID != 10000000 {
+		s.T().Errorf("expected only owner, got %v", members)
+	}
+
+	role, err = s.store.GetRole(ledgerID, 20000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if role != "" {
+		s.T().Errorf("expected empty role, got %v", role)
+	}
+}
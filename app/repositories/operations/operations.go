@@ -5,9 +5,11 @@ import (
 	"fmt"
 
 	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/outbox"
 )
 
 type queryer interface {
+	Begin() (*sql.Tx, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 }
 
@@ -20,10 +22,21 @@ func New(db queryer) *repository {
 	return &repository{db: db}
 }
 
-// Create Создает новую операцию
-func (store *repository) Create(o *models.Operation) error {
-	_, err := store.db.Query(
-		"insert into operations(user_id, subject, amount, type, message) values ($1,$2,$3,$4,$5)",
+// Create Создает новую операцию и возвращает ее ID
+// Вместе с операцией в той же транзакции в outbox записывается событие о ее создании
+func (store *repository) Create(o *models.Operation) (int64, error) {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	row := tx.QueryRow(
+		"insert into operations(ledger_id, user_id, subject, amount, type, message) values ($1,$2,$3,$4,$5,$6) returning id, created_at",
+		o.LedgerID,
 		o.UserID,
 		o.Subject,
 		o.Amount,
@@ -31,22 +44,60 @@ func (store *repository) Create(o *models.Operation) error {
 		o.Message,
 	)
 
-	return err
+	created := *o
+	if err := row.Scan(&created.ID, &created.Created); err != nil {
+		return 0, err
+	}
+
+	if err := outbox.Add(tx, models.EventOperationCreated, created); err != nil {
+		return 0, err
+	}
+
+	return created.ID, tx.Commit()
 }
 
-// Remove Удаляет указанную операцию по ее ID
-func (store *repository) Remove(operationID int64) error {
-	_, err := store.db.Query("delete from operations where id = $1", operationID)
+// Remove Удаляет операцию бухгалтерии по ее ID и возвращает удаленную операцию
+// Вместе с удалением в той же транзакции в outbox записывается событие об удалении
+// Если операция не найдена в бухгалтерии, то возвращает models.NotFoundError
+func (store *repository) Remove(ledgerID, operationID int64) (*models.Operation, error) {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	row := tx.QueryRow(
+		"delete from operations where id = $1 and ledger_id = $2 returning id, ledger_id, coalesce(user_id, 0), subject, amount, type, message, created_at",
+		operationID,
+		ledgerID,
+	)
+
+	o := models.Operation{}
+	err = row.Scan(&o.ID, &o.LedgerID, &o.UserID, &o.Subject, &o.Amount, &o.Type, &o.Message, &o.Created)
+	// Операции нет в книге, событие не нужно
+	if err == sql.ErrNoRows {
+		return nil, &models.NotFoundError{Entity: "operation", ID: operationID}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := outbox.Add(tx, models.EventOperationDeleted, o); err != nil {
+		return nil, err
+	}
 
-	return err
+	return &o, tx.Commit()
 }
 
-// Get Возвращает список операций
-func (store *repository) Get(userID, page, size int64) (*models.OperationPaginator, error) {
-	query := "select id, user_id, subject, amount, type, message, created_at from operations where user_id=$1 order by created_at desc"
+// Get Возвращает список операций бухгалтерии
+func (store *repository) Get(ledgerID, page, size int64) (*models.OperationPaginator, error) {
+	query := "select id, ledger_id, coalesce(user_id, 0), subject, amount, type, message, created_at from operations where ledger_id=$1 order by created_at desc"
 	query = addPagination(query, page, size)
 
-	rows, err := store.db.Query(query, userID)
+	rows, err := store.db.Query(query, ledgerID)
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +109,7 @@ func (store *repository) Get(userID, page, size int64) (*models.OperationPaginat
 	var operations []models.Operation
 	for rows.Next() {
 		o := models.Operation{}
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Subject, &o.Amount, &o.Type, &o.Message, &o.Created); err != nil {
+		if err := rows.Scan(&o.ID, &o.LedgerID, &o.UserID, &o.Subject, &o.Amount, &o.Type, &o.Message, &o.Created); err != nil {
 			return nil, err
 		}
 
@@ -78,6 +129,33 @@ func (store *repository) Get(userID, page, size int64) (*models.OperationPaginat
 	}, nil
 }
 
+// GetByUser Возвращает все операции, автором которых является пользователь, во всех бухгалтериях, начиная с самой ранней
+func (store *repository) GetByUser(userID int64) ([]models.Operation, error) {
+	rows, err := store.db.Query(
+		"select id, ledger_id, user_id, subject, amount, type, message, created_at from operations where user_id=$1 order by created_at, id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var operations []models.Operation
+	for rows.Next() {
+		o := models.Operation{}
+		if err := rows.Scan(&o.ID, &o.LedgerID, &o.UserID, &o.Subject, &o.Amount, &o.Type, &o.Message, &o.Created); err != nil {
+			return nil, err
+		}
+
+		operations = append(operations, o)
+	}
+
+	return operations, rows.Err()
+}
+
 // Добавляет к строке SQL запроса данные пагинации
 func addPagination(query string, page, size int64) string {
 	if !needPagination(page, size) {
@@ -2,6 +2,7 @@ package operations
 
 import (
 	"database/sql"
+	"errors"
 	"testing"
 
 	"github.com/bgoldovsky/casher/app/models"
@@ -26,7 +27,12 @@ func (s *storeSuite) SetupSuite() {
 }
 
 func (s *storeSuite) SetupTest() {
-	_, err := s.db.Query("delete from operations")
+	_, err := s.db.Exec("delete from outbox; delete from operations; delete from ledgers")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into ledgers (id, name) values(10000000, 'Личный бюджет')`)
 	if err != nil {
 		s.T().Fatal(err)
 	}
@@ -42,17 +48,22 @@ func TestStoreSuite(t *testing.T) {
 }
 
 func (s *storeSuite) TestCreate() {
-	err := s.store.Create(&models.Operation{
-		UserID:  10000000,
-		Subject: "Таверна Fish & Chips",
-		Amount:  150000,
-		Type:    models.Withdraw,
-		Message: "Отметил приезд",
+	operationID, err := s.store.Create(&models.Operation{
+		LedgerID: 10000000,
+		UserID:   10000000,
+		Subject:  "Таверна Fish & Chips",
+		Amount:   150000,
+		Type:     models.Withdraw,
+		Message:  "Отметил приезд",
 	})
 	if err != nil {
 		s.T().Fatal(err)
 	}
 
+	if operationID == 0 {
+		s.T().Error("operation ID not returned")
+	}
+
 	res, err := s.db.Query(`select count(*) from operations where user_id=10000000 and subject='Таверна Fish & Chips' and amount=150000`)
 	if err != nil {
 		s.T().Fatal(err)
@@ -69,10 +80,63 @@ func (s *storeSuite) TestCreate() {
 	if count != 1 {
 		s.T().Errorf("incorrect count, wanted 1, got %d", count)
 	}
+
+	// Событие о создании записывается вместе с операцией
+	var events int
+	err = s.db.QueryRow(`select count(*) from outbox where type=$1 and (payload->>'id')::bigint=$2`, models.EventOperationCreated, operationID).Scan(&events)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if events != 1 {
+		s.T().Errorf("incorrect events count, wanted 1, got %d", events)
+	}
+}
+
+func (s *storeSuite) TestRemove() {
+	operationID, err := s.store.Create(&models.Operation{
+		LedgerID: 10000000,
+		UserID:   10000000,
+		Subject:  "Таверна Fish & Chips",
+		Amount:   150000,
+		Type:     models.Withdraw,
+	})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Из чужой бухгалтерии операция не удаляется
+	removed, err := s.store.Remove(20000000, operationID)
+	if !errors.Is(err, models.ErrNotFound) {
+		s.T().Fatalf("expected not found, got %v", err)
+	}
+
+	if removed != nil {
+		s.T().Errorf("expected nothing removed, got %v", removed)
+	}
+
+	removed, err = s.store.Remove(10000000, operationID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if removed == nil || removed.ID != operationID {
+		s.T().Errorf("expected removed operation %d, got %v", operationID, removed)
+	}
+
+	var events int
+	err = s.db.QueryRow(`select count(*) from outbox where type=$1 and (payload->>'id')::bigint=$2`, models.EventOperationDeleted, operationID).Scan(&events)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if events != 1 {
+		s.T().Errorf("incorrect events count, wanted 1, got %d", events)
+	}
 }
 
 func (s *storeSuite) TestGet() {
-	_, err := s.db.Query(`insert into operations (user_id, subject, amount, type, message) values(10000000,'Таверна Fish & Chips', 150000,2, 'Отметил приезд')`)
+	_, err := s.db.Query(`insert into operations (ledger_id, user_id, subject, amount, type, message) values(10000000, 10000000,'Таверна Fish & Chips', 150000,2, 'Отметил приезд')`)
 	if err != nil {
 		s.T().Fatal(err)
 	}
@@ -117,3 +181,34 @@ func (s *storeSuite) TestGet() {
 		s.T().Errorf("expected %v, got %v", exp.Message, act.Message)
 	}
 }
+
+func (s *storeSuite) TestGetByUser() {
+	_, err := s.db.Exec(`insert into ledgers (id, name) values(10000001, 'Общий бюджет')`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into operations (ledger_id, user_id, subject, amount, type, message) values
+(10000000, 10000000, 'Таверна Fish & Chips', 150000, 2, 'Отметил приезд'),
+(10000001, 10000000, 'Зарплата', 5000000, 1, ''),
+(10000001, 10000001, 'Продукты', 300000, 2, '')`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Возвращаются операции автора из всех бухгалтерий, но не операции других участников
+	operations, err := s.store.GetByUser(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(operations) != 2 {
+		s.T().Fatalf("incorrect count, wanted 2, got %d", len(operations))
+	}
+
+	for _, o := range operations {
+		if o.UserID != 10000000 {
+			s.T().Errorf("expected user %d, got %d", 10000000, o.UserID)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/lib/pq"
+)
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Add Записывает событие в outbox
+// Вызывается в транзакции, которая изменяет данные, что бы событие сохранилось вместе с изменением
+func Add(tx execer, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("insert into outbox(type, payload) values ($1,$2)", eventType, string(payload))
+
+	return err
+}
+
+// Claim Забирает готовые к обработке события
+// Забранные события откладываются на время lease, что бы их не обработал параллельно другой экземпляр приложения,
+// а изменение фиксируется сразу, поэтому обработчики выполняются вне транзакции и не держат блокировки строк
+func (store *repository) Claim(limit int64, lease time.Duration) ([]models.Event, error) {
+	query := `update outbox
+set next_attempt_at = now() + make_interval(secs => $2)
+where id in (
+    select id from outbox
+    where processed_at is null and dead_at is null and next_attempt_at <= now()
+    order by id
+    limit $1
+    for update skip locked
+)
+returning id, type, payload, attempts, created_at, handled`
+
+	rows, err := store.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var events []models.Event
+	for rows.Next() {
+		e := models.Event{}
+		if err := rows.Scan(&e.ID, &e.Type, &e.Payload, &e.Attempts, &e.Created, pq.Array(&e.Handled)); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	// Порядок строк в returning не гарантирован
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+
+	return events, rows.Err()
+}
+
+// MarkHandled Отмечает, что обработчик успешно обработал событие и не должен вызываться при повторной попытке
+func (store *repository) MarkHandled(eventID int64, handler string) error {
+	_, err := store.db.Exec(
+		"update outbox set handled = array_append(handled, $2) where id = $1 and not $2 = any(handled)",
+		eventID,
+		handler,
+	)
+
+	return err
+}
+
+// Complete Отмечает событие обработанным всеми обработчиками
+func (store *repository) Complete(eventID int64) error {
+	_, err := store.db.Exec("update outbox set processed_at = now() where id = $1", eventID)
+
+	return err
+}
+
+// Fail Откладывает событие, которое обработали не все обработчики, с экспоненциальной задержкой
+// После maxAttempts неудачных попыток событие помечается мертвым и больше не забирается, последняя ошибка сохраняется
+func (store *repository) Fail(eventID int64, lastError string, maxAttempts int) error {
+	_, err := store.db.Exec(
+		`update outbox
+set attempts = attempts + 1, last_error = $2,
+    next_attempt_at = now() + least(interval '1 second' * power(2, attempts), interval '1 hour'),
+    dead_at = case when attempts + 1 >= $3 then now() end
+where id = $1`,
+		eventID,
+		lastError,
+		maxAttempts,
+	)
+
+	return err
+}
@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from outbox")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestClaim() {
+	if err := Add(s.db, models.EventOperationCreated, map[string]int{"id": 1}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	events, err := s.store.Claim(10, time.Minute)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(events) != 1 {
+		s.T().Fatalf("incorrect count, wanted 1, got %d", len(events))
+	}
+
+	if events[0].Type != models.EventOperationCreated || len(events[0].Handled) != 0 {
+		s.T().Errorf("unexpected event %v", events[0])
+	}
+
+	// Забранное событие до окончания аренды повторно не выдается
+	events, err = s.store.Claim(10, time.Minute)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(events) != 0 {
+		s.T().Errorf("incorrect count, wanted 0, got %d", len(events))
+	}
+}
+
+func (s *storeSuite) TestComplete() {
+	if err := Add(s.db, models.EventOperationCreated, map[string]int{"id": 1}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Нулевая аренда позволяет сразу забрать событие снова
+	events, err := s.store.Claim(10, 0)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.Complete(events[0].ID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Обработанное событие повторно не выдается
+	events, err = s.store.Claim(10, 0)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(events) != 0 {
+		s.T().Errorf("incorrect count, wanted 0, got %d", len(events))
+	}
+}
+
+func (s *storeSuite) TestMarkHandledAndFail() {
+	if err := Add(s.db, models.EventOperationDeleted, map[string]int{"id": 1}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	events, err := s.store.Claim(10, time.Minute)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Повторная отметка того же обработчика не дублируется
+	for i := 0; i < 2; i++ {
+		if err = s.store.MarkHandled(events[0].ID, "webhooks"); err != nil {
+			s.T().Fatal(err)
+		}
+	}
+
+	if err = s.store.Fail(events[0].ID, "log: test error", 2); err != nil {
+		s.T().Fatal(err)
+	}
+
+	var attempts int
+	var handled []string
+	var processed, dead sql.NullTime
+	err = s.db.QueryRow("select attempts, handled, processed_at, dead_at from outbox").Scan(&attempts, pq.Array(&handled), &processed, &dead)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if attempts != 1 {
+		s.T().Errorf("expected %v, got %v", 1, attempts)
+	}
+
+	if len(handled) != 1 || handled[0] != "webhooks" {
+		s.T().Errorf("expected [webhooks], got %v", handled)
+	}
+
+	if processed.Valid {
+		s.T().Error("event should not be processed")
+	}
+
+	if dead.Valid {
+		s.T().Error("event should not be dead")
+	}
+}
+
+func (s *storeSuite) TestFailDead() {
+	if err := Add(s.db, models.EventOperationDeleted, map[string]int{"id": 1}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	events, err := s.store.Claim(10, time.Minute)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.Fail(events[0].ID, "log: test error", 1); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Мертвое событие не забирается, даже когда подошло время следующей попытки
+	if _, err = s.db.Exec("update outbox set next_attempt_at = now()"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	events, err = s.store.Claim(10, time.Minute)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(events) != 0 {
+		s.T().Errorf("expected no events, got %v", len(events))
+	}
+
+	var lastError string
+	var dead sql.NullTime
+	err = s.db.QueryRow("select last_error, dead_at from outbox").Scan(&lastError, &dead)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if lastError != "log: test error" {
+		s.T().Errorf("expected %v, got %v", "log: test error", lastError)
+	}
+
+	if !dead.Valid {
+		s.T().Error("event should be dead")
+	}
+}
@@ -0,0 +1,92 @@
+package resets
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+var (
+	ErrResetUnavailable = errors.New("password reset is used or expired")
+)
+
+type queryer interface {
+	Begin() (*sql.Tx, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Создает токен восстановления пароля и возвращает его ID
+func (store *repository) Create(reset *models.PasswordReset) (int64, error) {
+	row := store.db.QueryRow(
+		"insert into password_resets(user_id, hash, expires_at) values ($1,$2,$3) returning id",
+		reset.UserID,
+		reset.Hash,
+		reset.Expires,
+	)
+
+	var resetID int64
+	err := row.Scan(&resetID)
+
+	return resetID, err
+}
+
+// GetByHash Возвращает токен восстановления пароля по его хешу
+func (store *repository) GetByHash(hash string) (*models.PasswordReset, error) {
+	query := "select id, user_id, hash, expires_at, used_at, created_at from password_resets where hash=$1"
+
+	row := store.db.QueryRow(query, hash)
+
+	r := models.PasswordReset{}
+	if err := row.Scan(&r.ID, &r.UserID, &r.Hash, &r.Expires, &r.Used, &r.Created); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Reset Меняет пароль пользователя по токену восстановления и возвращает ID пользователя
+// Токен и все остальные неиспользованные токены пользователя гасятся в той же транзакции
+func (store *repository) Reset(resetID int64, password string) (int64, error) {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	row := tx.QueryRow(
+		"update password_resets set used_at = now() where id = $1 and used_at is null and expires_at > now() returning user_id",
+		resetID,
+	)
+
+	var userID int64
+	err = row.Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrResetUnavailable
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec("update users set password = $1 where id = $2", password, userID); err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec("update password_resets set used_at = now() where user_id = $1 and used_at is null", userID); err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
@@ -0,0 +1,123 @@
+package resets
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from password_resets; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestCreate() {
+	resetID, err := s.store.Create(&models.PasswordReset{UserID: 10000000, Hash: "hash", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.GetByHash("hash")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.ID != resetID || act.UserID != 10000000 {
+		s.T().Errorf("expected reset %v of user %v, got %v", resetID, 10000000, act)
+	}
+
+	if !act.Active(time.Now()) {
+		s.T().Errorf("expected active reset, got %v", act)
+	}
+}
+
+func (s *storeSuite) TestReset() {
+	resetID, err := s.store.Create(&models.PasswordReset{UserID: 10000000, Hash: "hash", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.store.Create(&models.PasswordReset{UserID: 10000000, Hash: "other", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	userID, err := s.store.Reset(resetID, "new-hash")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if userID != 10000000 {
+		s.T().Errorf("expected %v, got %v", 10000000, userID)
+	}
+
+	var password string
+	if err = s.db.QueryRow("select password from users where id=10000000").Scan(&password); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if password != "new-hash" {
+		s.T().Errorf("expected %v, got %v", "new-hash", password)
+	}
+
+	// Токен одноразовый, остальные токены пользователя тоже погашены
+	if _, err = s.store.Reset(resetID, "other-hash"); err != ErrResetUnavailable {
+		s.T().Errorf("expected %v, got %v", ErrResetUnavailable, err)
+	}
+
+	other, err := s.store.GetByHash("other")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if other.Used == nil {
+		s.T().Error("expected other reset to be used")
+	}
+}
+
+func (s *storeSuite) TestReset_Expired() {
+	resetID, err := s.store.Create(&models.PasswordReset{UserID: 10000000, Hash: "hash", Expires: time.Now().Add(-time.Minute)})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if _, err = s.store.Reset(resetID, "new-hash"); err != ErrResetUnavailable {
+		s.T().Errorf("expected %v, got %v", ErrResetUnavailable, err)
+	}
+}
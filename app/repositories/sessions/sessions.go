@@ -0,0 +1,171 @@
+package sessions
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Создает сессию и возвращает ее ID
+func (store *repository) Create(session *models.Session) (int64, error) {
+	row := store.db.QueryRow(
+		"insert into sessions(hash, user_id, data, ip, user_agent) values ($1,$2,$3,$4,$5) returning id",
+		session.Hash,
+		session.UserID,
+		session.Data,
+		session.IP,
+		session.UserAgent,
+	)
+
+	var sessionID int64
+	err := row.Scan(&sessionID)
+
+	return sessionID, err
+}
+
+// GetByHash Возвращает сессию по хешу токена
+func (store *repository) GetByHash(hash string) (*models.Session, error) {
+	query := "select id, hash, user_id, data, ip, user_agent, created_at, last_seen_at from sessions where hash=$1"
+
+	row := store.db.QueryRow(query, hash)
+
+	s := models.Session{}
+	if err := row.Scan(&s.ID, &s.Hash, &s.UserID, &s.Data, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetByUser Возвращает активные сессии пользователя, первой идет последняя использованная
+// Данные сессий не возвращаются
+func (store *repository) GetByUser(userID int64) ([]models.Session, error) {
+	query := `select id, hash, user_id, ip, user_agent, created_at, last_seen_at
+from sessions
+where user_id = $1
+order by last_seen_at desc, id desc`
+
+	rows, err := store.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var sessions []models.Session
+	for rows.Next() {
+		s := models.Session{}
+		if err := rows.Scan(&s.ID, &s.Hash, &s.UserID, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Update Сохраняет данные сессии по хешу токена и отмечает время последнего обращения
+// Если сессия уже удалена, возвращает ErrSessionNotFound
+func (store *repository) Update(session *models.Session) error {
+	res, err := store.db.Exec(
+		"update sessions set user_id = $1, data = $2, last_seen_at = now() where hash = $3",
+		session.UserID,
+		session.Data,
+		session.Hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Touch Отмечает время последнего обращения к сессии
+func (store *repository) Touch(sessionID int64) error {
+	res, err := store.db.Exec("update sessions set last_seen_at = now() where id = $1", sessionID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Delete Удаляет сессию по хешу токена
+func (store *repository) Delete(hash string) error {
+	_, err := store.db.Exec("delete from sessions where hash = $1", hash)
+	return err
+}
+
+// DeleteByUser Удаляет сессию пользователя
+// Чужую сессию удалить нельзя, в этом случае возвращается ErrSessionNotFound
+func (store *repository) DeleteByUser(sessionID, userID int64) error {
+	res, err := store.db.Exec("delete from sessions where id = $1 and user_id = $2", sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// DeleteAllByUser Удаляет все сессии пользователя и возвращает их количество
+func (store *repository) DeleteAllByUser(userID int64) (int64, error) {
+	res, err := store.db.Exec("delete from sessions where user_id = $1", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeleteExpired Удаляет сессии, созданные раньше createdBefore или не использованные после seenBefore
+// Возвращает количество удаленных сессий
+func (store *repository) DeleteExpired(createdBefore, seenBefore time.Time) (int64, error) {
+	res, err := store.db.Exec(
+		"delete from sessions where created_at < $1 or last_seen_at < $2",
+		createdBefore,
+		seenBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Проверяет, что запрос изменил хотя бы одну строку
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
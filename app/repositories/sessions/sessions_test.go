@@ -0,0 +1,145 @@
+package sessions
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from sessions; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000001, 'janedoe','qwerty', 'Jane Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestCreate() {
+	sessionID, err := s.store.Create(&models.Session{Hash: "hash", Data: []byte("data"), IP: "127.0.0.1", UserAgent: "Firefox"})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.GetByHash("hash")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(sessionID, act.ID)
+	s.Nil(act.UserID)
+	s.Equal([]byte("data"), act.Data)
+	s.Equal("Firefox", act.UserAgent)
+
+	// Сессия становится пользовательской после входа
+	userID := int64(10000000)
+	if err = s.store.Update(&models.Session{Hash: "hash", UserID: &userID, Data: []byte("authorized")}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	list, err := s.store.GetByUser(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Len(list, 1)
+	s.Equal(sessionID, list[0].ID)
+	s.Nil(list[0].Data)
+
+	s.NoError(s.store.Touch(sessionID))
+
+	s.NoError(s.store.Delete("hash"))
+	_, err = s.store.GetByHash("hash")
+	s.ErrorIs(err, sql.ErrNoRows)
+	s.ErrorIs(s.store.Touch(sessionID), ErrSessionNotFound)
+	s.ErrorIs(s.store.Update(&models.Session{Hash: "hash", Data: []byte{}}), ErrSessionNotFound)
+}
+
+func (s *storeSuite) TestDeleteByUser() {
+	jon, jane := int64(10000000), int64(10000001)
+
+	first, err := s.store.Create(&models.Session{Hash: "first", UserID: &jon, Data: []byte{}})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	if _, err = s.store.Create(&models.Session{Hash: "second", UserID: &jon, Data: []byte{}}); err != nil {
+		s.T().Fatal(err)
+	}
+	if _, err = s.store.Create(&models.Session{Hash: "third", UserID: &jane, Data: []byte{}}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Чужую сессию удалить нельзя
+	s.ErrorIs(s.store.DeleteByUser(first, jane), ErrSessionNotFound)
+	s.NoError(s.store.DeleteByUser(first, jon))
+
+	count, err := s.store.DeleteAllByUser(jon)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(int64(1), count)
+
+	list, err := s.store.GetByUser(jane)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Len(list, 1)
+}
+
+func (s *storeSuite) TestDeleteExpired() {
+	if _, err := s.store.Create(&models.Session{Hash: "fresh", Data: []byte{}}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err := s.db.Exec(`insert into sessions (hash, data, created_at, last_seen_at) values
+		('old', '', now() - interval '40 days', now()),
+		('idle', '', now(), now() - interval '2 days')`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	now := time.Now()
+	count, err := s.store.DeleteExpired(now.Add(-30*24*time.Hour), now.Add(-24*time.Hour))
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(int64(2), count)
+
+	_, err = s.store.GetByHash("fresh")
+	s.NoError(err)
+}
@@ -0,0 +1,104 @@
+package tokens
+
+import (
+	"database/sql"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Создает новый токен
+func (store *repository) Create(token *models.Token) (int64, error) {
+	row := store.db.QueryRow(
+		"insert into tokens(user_id, name, hash) values ($1,$2,$3) returning id",
+		token.UserID,
+		token.Name,
+		token.Hash,
+	)
+
+	var tokenID int64
+	err := row.Scan(&tokenID)
+
+	return tokenID, err
+}
+
+// Remove Удаляет токен пользователя по его ID
+func (store *repository) Remove(userID, tokenID int64) error {
+	res, err := store.db.Exec("delete from tokens where id = $1 and user_id = $2", tokenID, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, &models.NotFoundError{Entity: "token", ID: tokenID})
+}
+
+// Get Возвращает список токенов пользователя
+func (store *repository) Get(userID int64) ([]models.Token, error) {
+	query := "select id, user_id, name, hash, created_at from tokens where user_id=$1 order by created_at desc"
+
+	rows, err := store.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var tokens []models.Token
+	for rows.Next() {
+		t := models.Token{}
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Hash, &t.Created); err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetByHash Возвращает токен по его хешу
+// Токены заблокированных пользователей не возвращаются
+func (store *repository) GetByHash(hash string) (*models.Token, error) {
+	query := `select t.id, t.user_id, t.name, t.hash, t.created_at
+from tokens t join users u on u.id = t.user_id
+where t.hash=$1 and u.disabled_at is null`
+
+	row := store.db.QueryRow(query, hash)
+
+	t := models.Token{}
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Hash, &t.Created); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Проверяет, что запрос затронул запись пользователя, иначе возвращает переданную ошибку
+func checkAffected(res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return notFound
+	}
+
+	return nil
+}
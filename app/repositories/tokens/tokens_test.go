@@ -0,0 +1,127 @@
+package tokens
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestCreate() {
+	tokenID, err := s.store.Create(&models.Token{
+		UserID: 10000000,
+		Name:   "bot",
+		Hash:   "hash",
+	})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.GetByHash("hash")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.ID != tokenID {
+		s.T().Errorf("expected %v, got %v", tokenID, act.ID)
+	}
+
+	if act.UserID != 10000000 {
+		s.T().Errorf("expected %v, got %v", 10000000, act.UserID)
+	}
+
+	if act.Name != "bot" {
+		s.T().Errorf("expected %v, got %v", "bot", act.Name)
+	}
+}
+
+func (s *storeSuite) TestRemove() {
+	tokenID, err := s.store.Create(&models.Token{UserID: 10000000, Name: "bot", Hash: "hash"})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Чужой токен удалить нельзя
+	if err = s.store.Remove(20000000, tokenID); !errors.Is(err, models.ErrNotFound) {
+		s.T().Fatalf("expected not found, got %v", err)
+	}
+
+	tokens, err := s.store.Get(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(tokens) != 1 {
+		s.T().Fatalf("incorrect count, wanted 1, got %d", len(tokens))
+	}
+
+	if err = s.store.Remove(10000000, tokenID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	tokens, err = s.store.Get(10000000)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(tokens) != 0 {
+		s.T().Errorf("incorrect count, wanted 0, got %d", len(tokens))
+	}
+}
+
+func (s *storeSuite) TestGetByHash_Disabled() {
+	_, err := s.store.Create(&models.Token{UserID: 10000000, Name: "bot", Hash: "hash"})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec("update users set disabled_at = now() where id = 10000000")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Токен заблокированного пользователя не действует
+	if _, err = s.store.GetByHash("hash"); err != sql.ErrNoRows {
+		s.T().Errorf("expected %v, got %v", sql.ErrNoRows, err)
+	}
+}
@@ -0,0 +1,241 @@
+package twofactor
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+var (
+	ErrAlreadyEnabled = errors.New("two factor authentication already enabled")
+	ErrNotEnabled     = errors.New("two factor authentication not enabled")
+	ErrCodeUsed       = errors.New("code is already used or unknown")
+)
+
+type queryer interface {
+	Begin() (*sql.Tx, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Get Возвращает настройки двухфакторной аутентификации пользователя
+// Если пользователь не начинал подключение, возвращает sql.ErrNoRows
+func (store *repository) Get(userID int64) (*models.TwoFactor, error) {
+	query := "select user_id, secret, enabled_at, last_counter, failed_attempts, failed_at, created_at from two_factor where user_id=$1"
+
+	row := store.db.QueryRow(query, userID)
+
+	t := models.TwoFactor{}
+	if err := row.Scan(&t.UserID, &t.Secret, &t.Enabled, &t.LastCounter, &t.FailedAttempts, &t.FailedAt, &t.Created); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Begin Сохраняет новый секрет, ожидающий подтверждения
+// Секрет уже включенной аутентификации не перезаписывается
+func (store *repository) Begin(userID int64, secret string) error {
+	res, err := store.db.Exec(
+		`insert into two_factor(user_id, secret) values ($1, $2)
+		on conflict (user_id) do update set secret = excluded.secret, created_at = now()
+		where two_factor.enabled_at is null`,
+		userID,
+		secret,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, ErrAlreadyEnabled)
+}
+
+// Enable Включает двухфакторную аутентификацию и сохраняет хеши кодов восстановления
+// counter - шаг времени кода, которым подтверждено подключение, неудачные попытки подтверждения сбрасываются
+func (store *repository) Enable(userID, counter int64, hashes []string) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	res, err := tx.Exec(
+		"update two_factor set enabled_at = now(), last_counter = $2, failed_attempts = 0 where user_id = $1 and enabled_at is null",
+		userID,
+		counter,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res, ErrAlreadyEnabled); err != nil {
+		return err
+	}
+
+	if err = replaceCodes(tx, userID, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseCounter Запоминает шаг времени использованного кода и сбрасывает счетчик неудачных попыток
+// Код того же или более раннего шага повторно не принимается
+func (store *repository) UseCounter(userID, counter int64) error {
+	res, err := store.db.Exec(
+		`update two_factor set last_counter = $2, failed_attempts = 0
+		where user_id = $1 and enabled_at is not null and last_counter < $2`,
+		userID,
+		counter,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, ErrCodeUsed)
+}
+
+// UseRecoveryCode Гасит код восстановления по его хешу и сбрасывает счетчик неудачных попыток
+func (store *repository) UseRecoveryCode(userID int64, hash string) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	res, err := tx.Exec(
+		"update recovery_codes set used_at = now() where user_id = $1 and hash = $2 and used_at is null",
+		userID,
+		hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res, ErrCodeUsed); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("update two_factor set failed_attempts = 0 where user_id = $1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Fail Учитывает неудачную попытку ввода кода
+func (store *repository) Fail(userID int64) error {
+	_, err := store.db.Exec(
+		"update two_factor set failed_attempts = failed_attempts + 1, failed_at = now() where user_id = $1",
+		userID,
+	)
+
+	return err
+}
+
+// CountRecoveryCodes Возвращает количество неиспользованных кодов восстановления
+func (store *repository) CountRecoveryCodes(userID int64) (int64, error) {
+	row := store.db.QueryRow("select count(*) from recovery_codes where user_id = $1 and used_at is null", userID)
+
+	var count int64
+	err := row.Scan(&count)
+
+	return count, err
+}
+
+// ReplaceRecoveryCodes Заменяет все коды восстановления пользователя новыми
+func (store *repository) ReplaceRecoveryCodes(userID int64, hashes []string) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	row := tx.QueryRow("select 1 from two_factor where user_id = $1 and enabled_at is not null for update", userID)
+	var found int
+	if err = row.Scan(&found); err == sql.ErrNoRows {
+		return ErrNotEnabled
+	} else if err != nil {
+		return err
+	}
+
+	if err = replaceCodes(tx, userID, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Disable Отключает двухфакторную аутентификацию и удаляет коды восстановления
+func (store *repository) Disable(userID int64) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if _, err = tx.Exec("delete from recovery_codes where user_id = $1", userID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("delete from two_factor where user_id = $1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Удаляет старые коды восстановления и сохраняет новые
+func replaceCodes(db execer, userID int64, hashes []string) error {
+	if _, err := db.Exec("delete from recovery_codes where user_id = $1", userID); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if _, err := db.Exec("insert into recovery_codes(user_id, hash) values ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Возвращает ошибку, если запрос не затронул ни одной строки
+func checkAffected(res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return notFound
+	}
+
+	return nil
+}
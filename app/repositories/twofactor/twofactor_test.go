@@ -0,0 +1,162 @@
+package twofactor
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from recovery_codes; delete from two_factor; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestEnable() {
+	userID := int64(10000000)
+
+	if err := s.store.Begin(userID, "first"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Неподтвержденный секрет можно заменить
+	if err := s.store.Begin(userID, "second"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal("second", act.Secret)
+	s.False(act.IsEnabled())
+
+	// Неудачные попытки подтверждения сбрасываются при включении
+	if err = s.store.Fail(userID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.Enable(userID, 100, []string{"a", "b"}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err = s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.True(act.IsEnabled())
+	s.Equal(int64(100), act.LastCounter)
+	s.Equal(0, act.FailedAttempts)
+
+	// Включенную аутентификацию нельзя перезаписать новым секретом
+	s.ErrorIs(s.store.Begin(userID, "third"), ErrAlreadyEnabled)
+	s.ErrorIs(s.store.Enable(userID, 101, nil), ErrAlreadyEnabled)
+
+	count, err := s.store.CountRecoveryCodes(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(int64(2), count)
+}
+
+func (s *storeSuite) TestUseCodes() {
+	userID := int64(10000000)
+
+	if err := s.store.Begin(userID, "secret"); err != nil {
+		s.T().Fatal(err)
+	}
+	if err := s.store.Enable(userID, 100, []string{"a", "b"}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err := s.store.Fail(userID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(1, act.FailedAttempts)
+	s.NotNil(act.FailedAt)
+
+	// Код каждого шага принимается только один раз
+	s.ErrorIs(s.store.UseCounter(userID, 100), ErrCodeUsed)
+	s.NoError(s.store.UseCounter(userID, 101))
+	s.ErrorIs(s.store.UseCounter(userID, 101), ErrCodeUsed)
+
+	// Успешный ввод сбрасывает счетчик неудачных попыток
+	act, err = s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(0, act.FailedAttempts)
+
+	// Код восстановления одноразовый
+	s.NoError(s.store.UseRecoveryCode(userID, "a"))
+	s.ErrorIs(s.store.UseRecoveryCode(userID, "a"), ErrCodeUsed)
+	s.ErrorIs(s.store.UseRecoveryCode(userID, "unknown"), ErrCodeUsed)
+
+	if err := s.store.ReplaceRecoveryCodes(userID, []string{"c", "d", "e"}); err != nil {
+		s.T().Fatal(err)
+	}
+	s.ErrorIs(s.store.UseRecoveryCode(userID, "b"), ErrCodeUsed)
+
+	count, err := s.store.CountRecoveryCodes(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.Equal(int64(3), count)
+}
+
+func (s *storeSuite) TestDisable() {
+	userID := int64(10000000)
+
+	if err := s.store.Begin(userID, "secret"); err != nil {
+		s.T().Fatal(err)
+	}
+	if err := s.store.Enable(userID, 100, []string{"a"}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err := s.store.Disable(userID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err := s.store.Get(userID)
+	s.ErrorIs(err, sql.ErrNoRows)
+	s.ErrorIs(s.store.ReplaceRecoveryCodes(userID, []string{"b"}), ErrNotEnabled)
+}
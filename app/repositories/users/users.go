@@ -3,20 +3,51 @@ package users
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/outbox"
+)
+
+const (
+	// Название личной бухгалтерии, которая создается при регистрации
+	personalLedger = "Личный бюджет"
+	// Уникальный индекс адресов электронной почты
+	emailIndex = "users_email_idx"
+	// Уникальный индекс внешних учетных записей по издателю и subject
+	identityIndex = "user_identities_subject_idx"
+	// Уникальный индекс внешних учетных записей пользователя по издателю
+	identityUserIndex = "user_identities_user_idx"
+	// Колонки, из которых читается пользователь
+	userColumns = "id, login, password, coalesce(email, ''), email_verified_at, name, birth, coalesce(invited_by, 0), role, disabled_at, last_login_at, language, timezone, created_at"
 )
 
 var (
-	ErrDuplicateKey = errors.New("duplicate key value error")
+	ErrDuplicateKey      = errors.New("duplicate key value error")
+	ErrInviteUnavailable = errors.New("invite is used, revoked or expired")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrDuplicateEmail    = errors.New("duplicate email error")
+	ErrIdentityLinked    = errors.New("identity already linked error")
+	ErrSharedLedgers     = errors.New("user owns shared ledgers error")
 )
 
 type queryer interface {
+	Begin() (*sql.Tx, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 type repository struct {
 	db queryer
 }
@@ -26,18 +57,115 @@ func New(db queryer) *repository {
 	return &repository{db: db}
 }
 
-// Create Создает нового пользователя
+// Create Создает нового пользователя вместе с его личной бухгалтерией
 func (store *repository) Create(user *models.User) (int64, error) {
-	row := store.db.QueryRow(
-		"insert into users(login, password, name, birth) values ($1,$2,$3,$4) returning id",
+	return insertUser(store.db, user)
+}
+
+// CreateInvited Создает нового пользователя по приглашению
+// Приглашение помечается использованным в той же транзакции, поэтому зарегистрироваться по нему можно только один раз
+func (store *repository) CreateInvited(user *models.User, inviteID int64) (int64, error) {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	row := tx.QueryRow(
+		`update invites set used_at = now()
+where id = $1 and used_at is null and revoked_at is null and expires_at > now() returning inviter_id`,
+		inviteID,
+	)
+
+	invited := *user
+	err = row.Scan(&invited.InvitedBy)
+	if err == sql.ErrNoRows {
+		return 0, ErrInviteUnavailable
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	userID, err := insertUser(tx, &invited)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec("update invites set used_by = $1 where id = $2", userID, inviteID); err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+// CreateExternal Создает нового пользователя, вошедшего через внешнего провайдера, и привязывает к нему учетную запись
+// Если у пользователя заполнено EmailVerified, то адрес сразу считается подтвержденным
+func (store *repository) CreateExternal(user *models.User, identity *models.Identity) (int64, error) {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	userID, err := insertUser(tx, user)
+	if err != nil {
+		return 0, err
+	}
+
+	if user.Email != "" && user.EmailVerified != nil {
+		if _, err = tx.Exec("update users set email_verified_at = $1 where id = $2", user.EmailVerified, userID); err != nil {
+			return 0, err
+		}
+	}
+
+	linked := *identity
+	linked.UserID = userID
+	if err = insertIdentity(tx, &linked); err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+// LinkIdentity Привязывает внешнюю учетную запись к существующему пользователю
+func (store *repository) LinkIdentity(identity *models.Identity) error {
+	return insertIdentity(store.db, identity)
+}
+
+// GetByIdentity Возвращает пользователя по внешней учетной записи
+func (store *repository) GetByIdentity(issuer, subject string) (*models.User, error) {
+	query := "select " + userColumns + " from users where id = (select user_id from user_identities where issuer=$1 and subject=$2)"
+
+	return scanUser(store.db.QueryRow(query, issuer, subject))
+}
+
+// Добавляет пользователя и его личную бухгалтерию одним запросом
+func insertUser(db rowQueryer, user *models.User) (int64, error) {
+	row := db.QueryRow(
+		`with u as (insert into users(login, password, email, name, birth, invited_by) values ($1,$2,$3,$4,$5,$6) returning id),
+l as (insert into ledgers(name) values ($7) returning id)
+insert into ledger_members(ledger_id, user_id, role) select l.id, u.id, $8 from u, l returning user_id`,
 		user.Login,
 		user.Password,
+		nullString(user.Email),
 		user.Name,
 		user.Birth,
+		nullID(user.InvitedBy),
+		personalLedger,
+		models.RoleOwner,
 	)
 
 	var userID int64
 	err := row.Scan(&userID)
+	if isDuplicateErr(err) && strings.Contains(err.Error(), emailIndex) {
+		return 0, ErrDuplicateEmail
+	}
 	if isDuplicateErr(err) {
 		return 0, ErrDuplicateKey
 	}
@@ -45,34 +173,320 @@ func (store *repository) Create(user *models.User) (int64, error) {
 	return userID, err
 }
 
+// Добавляет внешнюю учетную запись пользователя
+func insertIdentity(db execer, identity *models.Identity) error {
+	_, err := db.Exec(
+		"insert into user_identities(user_id, issuer, subject, email) values ($1,$2,$3,$4)",
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		nullString(identity.Email),
+	)
+	if isDuplicateErr(err) && (strings.Contains(err.Error(), identityIndex) || strings.Contains(err.Error(), identityUserIndex)) {
+		return ErrIdentityLinked
+	}
+
+	return err
+}
+
 // Get Возвращает пользователя по его ID
 func (store *repository) Get(userID int64) (*models.User, error) {
-	query := "select id, login, password, name, birth, created_at from users where id=$1"
+	query := "select " + userColumns + " from users where id=$1"
 
-	row := store.db.QueryRow(query, userID)
+	return scanUser(store.db.QueryRow(query, userID))
+}
 
-	u := models.User{}
-	if err := row.Scan(&u.ID, &u.Login, &u.Password, &u.Name, &u.Birth, &u.Created); err != nil {
+// Auth Возвращает пользователя по его логину
+func (store *repository) Auth(login string) (*models.User, error) {
+	query := "select " + userColumns + " from users where login=$1"
+
+	return scanUser(store.db.QueryRow(query, login))
+}
+
+// GetByEmail Возвращает пользователя по адресу электронной почты без учета регистра
+func (store *repository) GetByEmail(email string) (*models.User, error) {
+	query := "select " + userColumns + " from users where lower(email)=lower($1)"
+
+	return scanUser(store.db.QueryRow(query, email))
+}
+
+// List Возвращает список всех пользователей с количеством их операций, начиная с последнего зарегистрированного
+func (store *repository) List(page, size int64) (*models.UserPaginator, error) {
+	query := `select id, login, name, role, disabled_at, last_login_at, language, timezone, created_at,
+(select count(*) from operations o where o.user_id = u.id)
+from users u order by created_at desc, id desc`
+	query = addPagination(query, page, size)
+
+	rows, err := store.db.Query(query)
+	if err != nil {
 		return nil, err
 	}
 
-	return &u, nil
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var list []models.User
+	for rows.Next() {
+		u := models.User{}
+		err := rows.Scan(&u.ID, &u.Login, &u.Name, &u.Role, &u.Disabled, &u.LastLogin, &u.Language, &u.Timezone, &u.Created, &u.Operations)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Если пагинация не нужна или количество объектов меньше размера страницы возвращаем все
+	if !needPagination(page, size) || len(list) <= int(size) {
+		return &models.UserPaginator{
+			Users: list,
+		}, nil
+	}
+
+	return &models.UserPaginator{
+		Users:   list[:size],
+		HasMore: true,
+	}, nil
 }
 
-// Auth Возвращает пользователя по его логину
-func (store *repository) Auth(login string) (*models.User, error) {
-	query := "select id, login, password, name, birth, created_at from users where login=$1"
+// SetLastLogin Запоминает время последнего входа пользователя
+func (store *repository) SetLastLogin(userID int64) error {
+	_, err := store.db.Exec("update users set last_login_at = now() where id = $1", userID)
+
+	return err
+}
+
+// SetEmailVerified Отмечает адрес пользователя подтвержденным
+// Если адрес пользователя с тех пор изменился, то ничего не подтверждается
+func (store *repository) SetEmailVerified(userID int64, email string) error {
+	res, err := store.db.Exec(
+		"update users set email_verified_at = coalesce(email_verified_at, now()) where id = $1 and lower(email) = lower($2)",
+		userID,
+		email,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// SetDisabled Блокирует или разблокирует аккаунт пользователя
+func (store *repository) SetDisabled(userID int64, disabled bool) error {
+	query := "update users set disabled_at = null where id = $1"
+	if disabled {
+		query = "update users set disabled_at = coalesce(disabled_at, now()) where id = $1"
+	}
+
+	res, err := store.db.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// UpdateProfile Изменяет настоящее имя, дату рождения и часовой пояс пользователя
+func (store *repository) UpdateProfile(userID int64, name string, birth time.Time, timezone string) error {
+	res, err := store.db.Exec("update users set name = $1, birth = $2, timezone = $3 where id = $4", name, birth, timezone, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// SetLanguage Сохраняет язык интерфейса пользователя
+func (store *repository) SetLanguage(userID int64, language string) error {
+	res, err := store.db.Exec("update users set language = $1 where id = $2", language, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// SetPassword Сохраняет новый хеш пароля пользователя
+func (store *repository) SetPassword(userID int64, hash string) error {
+	res, err := store.db.Exec("update users set password = $1 where id = $2", hash, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Delete Удаляет пользователя вместе со всеми его данными в одной транзакции
+// Бухгалтерии, которыми он владеет, удаляются вместе с операциями, и на каждую удаленную операцию в outbox
+// записывается событие. Его операции в чужих бухгалтериях остаются, а автор у них обезличивается
+// Если у его бухгалтерий есть другие участники, то ничего не удаляется и возвращается ErrSharedLedgers
+func (store *repository) Delete(userID int64) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	// Блокировка бухгалтерий не дает добавить в них участника, пока идет удаление
+	_, err = tx.Exec(
+		"select id from ledgers where id in (select ledger_id from ledger_members where user_id = $1 and role = $2) for update",
+		userID,
+		models.RoleOwner,
+	)
+	if err != nil {
+		return err
+	}
+
+	var shared bool
+	err = tx.QueryRow(
+		`select exists(
+    select 1 from ledger_members m
+    join ledger_members o on o.ledger_id = m.ledger_id and o.user_id <> m.user_id
+    where m.user_id = $1 and m.role = $2
+)`,
+		userID,
+		models.RoleOwner,
+	).Scan(&shared)
+	if err != nil {
+		return err
+	}
+	if shared {
+		return ErrSharedLedgers
+	}
+
+	removed, err := deleteOwnedOperations(tx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range removed {
+		if err = outbox.Add(tx, models.EventOperationDeleted, removed[i]); err != nil {
+			return err
+		}
+	}
+
+	queries := []string{
+		"delete from ledgers where id in (select ledger_id from ledger_members where user_id = $1 and role = 'owner')",
+		"update operations set user_id = null where user_id = $1",
+		"delete from tokens where user_id = $1",
+		"delete from webhooks where user_id = $1",
+	}
+	for _, query := range queries {
+		if _, err = tx.Exec(query, userID); err != nil {
+			return err
+		}
+	}
 
-	row := store.db.QueryRow(query, login)
+	res, err := tx.Exec("delete from users where id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Удаляет операции бухгалтерий, которыми владеет пользователь, и возвращает их
+func deleteOwnedOperations(tx *sql.Tx, userID int64) ([]models.Operation, error) {
+	rows, err := tx.Query(
+		`delete from operations
+where ledger_id in (select ledger_id from ledger_members where user_id = $1 and role = $2)
+returning id, ledger_id, coalesce(user_id, 0), subject, amount, type, message, created_at`,
+		userID,
+		models.RoleOwner,
+	)
+	if err != nil {
+		return nil, err
+	}
 
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var removed []models.Operation
+	for rows.Next() {
+		o := models.Operation{}
+		if err := rows.Scan(&o.ID, &o.LedgerID, &o.UserID, &o.Subject, &o.Amount, &o.Type, &o.Message, &o.Created); err != nil {
+			return nil, err
+		}
+
+		removed = append(removed, o)
+	}
+
+	return removed, rows.Err()
+}
+
+// Читает пользователя из строки результата запроса по колонкам userColumns
+func scanUser(row *sql.Row) (*models.User, error) {
 	u := models.User{}
-	if err := row.Scan(&u.ID, &u.Login, &u.Password, &u.Name, &u.Birth, &u.Created); err != nil {
+	err := row.Scan(
+		&u.ID, &u.Login, &u.Password, &u.Email, &u.EmailVerified, &u.Name, &u.Birth, &u.InvitedBy, &u.Role, &u.Disabled, &u.LastLogin, &u.Language, &u.Timezone, &u.Created,
+	)
+	if err != nil {
 		return nil, err
 	}
 
 	return &u, nil
 }
 
+// Проверяет, что запрос изменил хотя бы одну строку
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Добавляет к строке SQL запроса данные пагинации
+func addPagination(query string, page, size int64) string {
+	if !needPagination(page, size) {
+		return query
+	}
+
+	// Запрашиваем на 1 объект больше, что бы проверить есть ли еще данные не запрашивая дополнительное count
+	limit, offset := size+1, (page-1)*size
+	return fmt.Sprintf("%s limit %d offset %d", query, limit, offset)
+}
+
+// Определяет нужна ли в запросе пагинация
+func needPagination(page, size int64) bool {
+	return page != 0 && size != 0
+}
+
+// Конвертирует нулевой идентификатор в NULL
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
+
+// Конвертирует пустую строку в NULL
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+
+	return value
+}
+
 // Проверяет, является ли ошибка ошибкой дупликации
 func isDuplicateErr(err error) bool {
 	if err == nil {
@@ -27,7 +27,7 @@ func (s *storeSuite) SetupSuite() {
 }
 
 func (s *storeSuite) SetupTest() {
-	_, err := s.db.Query("delete from operations; delete from users;")
+	_, err := s.db.Query("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from invites; delete from users;")
 	if err != nil {
 		s.T().Fatal(err)
 	}
@@ -44,7 +44,7 @@ func TestStoreSuite(t *testing.T) {
 
 func (s *storeSuite) TestCreate() {
 	var (
-		_, err = s.store.Create(&models.User{
+		userID, err = s.store.Create(&models.User{
 			Login:    "jondoe",
 			Password: "qwerty",
 			Name:     "Jon Doe",
@@ -55,6 +55,17 @@ func (s *storeSuite) TestCreate() {
 		s.T().Fatal(err)
 	}
 
+	// Вместе с пользователем создается его личная бухгалтерия
+	var ledgers int
+	err = s.db.QueryRow(`select count(*) from ledger_members where user_id=$1 and role=$2`, userID, models.RoleOwner).Scan(&ledgers)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if ledgers != 1 {
+		s.T().Errorf("incorrect ledgers count, wanted 1, got %d", ledgers)
+	}
+
 	res, err := s.db.Query(`select count(*) from users where login='jondoe' and name='Jon Doe'`)
 	if err != nil {
 		s.T().Fatal(err)
@@ -73,8 +84,62 @@ func (s *storeSuite) TestCreate() {
 	}
 }
 
+func (s *storeSuite) TestCreateInvited() {
+	_, err := s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'inviter','qwerty', 'Inviter', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	var inviteID int64
+	err = s.db.QueryRow(`insert into invites (inviter_id, hash, expires_at) values(10000000, 'hash', now() + interval '1 hour') returning id`).Scan(&inviteID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	userID, err := s.store.CreateInvited(&models.User{
+		Login:    "jondoe",
+		Password: "qwerty",
+		Name:     "Jon Doe",
+		Birth:    time.Now(),
+	}, inviteID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.InvitedBy != 10000000 {
+		s.T().Errorf("expected inviter %v, got %v", 10000000, act.InvitedBy)
+	}
+
+	var usedBy int64
+	err = s.db.QueryRow(`select used_by from invites where id=$1 and used_at is not null`, inviteID).Scan(&usedBy)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if usedBy != userID {
+		s.T().Errorf("expected invite used by %v, got %v", userID, usedBy)
+	}
+
+	// Повторно по тому же приглашению зарегистрироваться нельзя
+	_, err = s.store.CreateInvited(&models.User{
+		Login:    "janedoe",
+		Password: "qwerty",
+		Name:     "Jane Doe",
+		Birth:    time.Now(),
+	}, inviteID)
+	if err != ErrInviteUnavailable {
+		s.T().Errorf("expected %v, got %v", ErrInviteUnavailable, err)
+	}
+}
+
 func (s *storeSuite) TestGet() {
-	_, err := s.db.Query(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	_, err := s.db.Query(`insert into users (id, login, password, name, birth, language, timezone)
+values(10000000, 'jondoe','qwerty', 'Jon Doe', now(), 'en', 'Asia/Tokyo')`)
 	if err != nil {
 		s.T().Fatal(err)
 	}
@@ -90,6 +155,8 @@ func (s *storeSuite) TestGet() {
 		Password: "qwerty",
 		Name:     "Jon Doe",
 		Birth:    time.Now(),
+		Language: "en",
+		Timezone: "Asia/Tokyo",
 	}
 
 	if act.ID != exp.ID {
@@ -107,6 +174,14 @@ func (s *storeSuite) TestGet() {
 	if act.Name != exp.Name {
 		s.T().Errorf("expected %v, got %v", exp.Name, act.Name)
 	}
+
+	if act.Language != exp.Language {
+		s.T().Errorf("expected %v, got %v", exp.Language, act.Language)
+	}
+
+	if act.Timezone != exp.Timezone {
+		s.T().Errorf("expected %v, got %v", exp.Timezone, act.Timezone)
+	}
 }
 
 func (s *storeSuite) TestLogin() {
@@ -144,3 +219,326 @@ func (s *storeSuite) TestLogin() {
 		s.T().Errorf("expected %v, got %v", exp.Name, act.Name)
 	}
 }
+
+func (s *storeSuite) TestList() {
+	userID, err := s.store.Create(&models.User{Login: "jondoe", Password: "qwerty", Name: "Jon Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into operations (ledger_id, user_id, subject, amount, type)
+select ledger_id, user_id, 'Таверна', 100, 2 from ledger_members where user_id=$1`, userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.SetLastLogin(userID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.SetLanguage(userID, "en"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.UpdateProfile(userID, "Jon Doe", time.Now(), "Asia/Tokyo"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	paginator, err := s.store.List(1, 10)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(paginator.Users) != 1 {
+		s.T().Fatalf("incorrect count, wanted 1, got %d", len(paginator.Users))
+	}
+
+	act := paginator.Users[0]
+	if act.Operations != 1 {
+		s.T().Errorf("expected %v operations, got %v", 1, act.Operations)
+	}
+
+	if act.LastLogin == nil {
+		s.T().Error("expected last login")
+	}
+
+	if act.Role != models.UserRoleUser {
+		s.T().Errorf("expected %v, got %v", models.UserRoleUser, act.Role)
+	}
+
+	if act.Language != "en" {
+		s.T().Errorf("expected language en, got %q", act.Language)
+	}
+
+	if act.Timezone != "Asia/Tokyo" {
+		s.T().Errorf("expected timezone Asia/Tokyo, got %q", act.Timezone)
+	}
+}
+
+func (s *storeSuite) TestSetDisabled() {
+	userID, err := s.store.Create(&models.User{Login: "jondoe", Password: "qwerty", Name: "Jon Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.SetDisabled(userID, true); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.Disabled == nil {
+		s.T().Error("expected disabled user")
+	}
+
+	if err = s.store.SetDisabled(userID, false); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err = s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.Disabled != nil {
+		s.T().Errorf("expected enabled user, got %v", act.Disabled)
+	}
+
+	if err = s.store.SetDisabled(20000000, true); err != ErrUserNotFound {
+		s.T().Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+}
+
+func (s *storeSuite) TestUpdateProfile() {
+	userID, err := s.store.Create(&models.User{Login: "jondoe", Password: "qwerty", Name: "Jon Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	birth := time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err = s.store.UpdateProfile(userID, "John Smith", birth, "Asia/Tokyo"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.SetPassword(userID, "hash"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.Name != "John Smith" || !act.Birth.Equal(birth) || act.Timezone != "Asia/Tokyo" || act.Password != "hash" {
+		s.T().Errorf("unexpected user %v", act)
+	}
+
+	if err = s.store.UpdateProfile(20000000, "John Smith", birth, ""); err != ErrUserNotFound {
+		s.T().Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+
+	if err = s.store.SetPassword(20000000, "hash"); err != ErrUserNotFound {
+		s.T().Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+}
+
+func (s *storeSuite) TestSetLanguage() {
+	userID, err := s.store.Create(&models.User{Login: "jondoe", Password: "qwerty", Name: "Jon Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.Get(userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.Language != "" {
+		s.T().Errorf("expected empty language, got %q", act.Language)
+	}
+
+	if err = s.store.SetLanguage(userID, "en"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act, err = s.store.Get(userID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.Language != "en" {
+		s.T().Errorf("expected language en, got %q", act.Language)
+	}
+
+	if err = s.store.SetLanguage(20000000, "en"); err != ErrUserNotFound {
+		s.T().Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+}
+
+func (s *storeSuite) TestDelete() {
+	userID, err := s.store.Create(&models.User{Login: "jondoe", Password: "qwerty", Name: "Jon Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into tokens (user_id, name, hash) values($1, 'bot', 'hash')`, userID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.Delete(userID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Вместе с пользователем удаляется его личная бухгалтерия
+	var count int
+	err = s.db.QueryRow(`select (select count(*) from users where id=$1) + (select count(*) from ledger_members where user_id=$1) + (select count(*) from tokens where user_id=$1)`, userID).Scan(&count)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if count != 0 {
+		s.T().Errorf("incorrect count, wanted 0, got %d", count)
+	}
+
+	if err = s.store.Delete(userID); err != ErrUserNotFound {
+		s.T().Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+}
+
+func (s *storeSuite) TestDelete_SharedLedgers() {
+	ownerID, err := s.store.Create(&models.User{Login: "jondoe", Password: "qwerty", Name: "Jon Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	memberID, err := s.store.Create(&models.User{Login: "janedoe", Password: "qwerty", Name: "Jane Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	var sharedID, personalID int64
+	if err = s.db.QueryRow(`select ledger_id from ledger_members where user_id = $1`, ownerID).Scan(&sharedID); err != nil {
+		s.T().Fatal(err)
+	}
+	if err = s.db.QueryRow(`select ledger_id from ledger_members where user_id = $1`, memberID).Scan(&personalID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into ledger_members (ledger_id, user_id, role) values ($1, $2, 'editor')`, sharedID, memberID)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	for _, ledgerID := range []int64{sharedID, personalID} {
+		_, err = s.db.Exec(`insert into operations (ledger_id, user_id, subject, amount, type, message) values ($1, $2, 'coffee', 100, 2, '')`, ledgerID, memberID)
+		if err != nil {
+			s.T().Fatal(err)
+		}
+	}
+
+	// Владелец общей бухгалтерии не удаляется, пока в ней есть другие участники
+	if err = s.store.Delete(ownerID); err != ErrSharedLedgers {
+		s.T().Fatalf("expected %v, got %v", ErrSharedLedgers, err)
+	}
+
+	// Участник удаляется: его операция в чужой бухгалтерии остается без автора,
+	// а об операциях его личной бухгалтерии в outbox записываются события удаления
+	if err = s.store.Delete(memberID); err != nil {
+		s.T().Fatal(err)
+	}
+
+	var anonymous, events int
+	err = s.db.QueryRow(`select count(*) from operations where ledger_id = $1 and user_id is null`, sharedID).Scan(&anonymous)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	err = s.db.QueryRow(`select count(*) from outbox where type = $1 and (payload->>'ledger_id')::bigint = $2`, models.EventOperationDeleted, personalID).Scan(&events)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if anonymous != 1 || events != 1 {
+		s.T().Errorf("expected 1 anonymous operation and 1 event, got %d and %d", anonymous, events)
+	}
+
+	// После ухода участника владелец удаляется вместе с бухгалтерией
+	if err = s.store.Delete(ownerID); err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TestSetEmailVerified() {
+	userID, err := s.store.Create(&models.User{Login: "jondoe", Password: "qwerty", Email: "JonDoe@example.com", Name: "Jon Doe", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Подтверждение устаревшего адреса не действует
+	if err = s.store.SetEmailVerified(userID, "old@example.com"); err != ErrUserNotFound {
+		s.T().Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+
+	if err = s.store.SetEmailVerified(userID, "jondoe@example.com"); err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.GetByEmail("jondoe@EXAMPLE.com")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if !act.HasVerifiedEmail() {
+		s.T().Errorf("expected verified email, got %v", act.EmailVerified)
+	}
+}
+
+func (s *storeSuite) TestIdentities() {
+	verified := time.Now()
+	identity := &models.Identity{Issuer: "https://sso.example.com", Subject: "42", Email: "jondoe@example.com"}
+	userID, err := s.store.CreateExternal(&models.User{
+		Login:         "jondoe",
+		Password:      "!",
+		Email:         "jondoe@example.com",
+		EmailVerified: &verified,
+		Name:          "Jon Doe",
+		Birth:         time.Now(),
+	}, identity)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	act, err := s.store.GetByIdentity("https://sso.example.com", "42")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if act.ID != userID || !act.HasVerifiedEmail() {
+		s.T().Errorf("unexpected user %+v", act)
+	}
+
+	// Одна внешняя учетная запись привязывается только к одному пользователю
+	otherID, err := s.store.Create(&models.User{Login: "other", Password: "qwerty", Name: "Other", Birth: time.Now()})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.LinkIdentity(&models.Identity{UserID: otherID, Issuer: identity.Issuer, Subject: identity.Subject}); err != ErrIdentityLinked {
+		s.T().Errorf("expected %v, got %v", ErrIdentityLinked, err)
+	}
+
+	if err = s.store.LinkIdentity(&models.Identity{UserID: otherID, Issuer: identity.Issuer, Subject: "43"}); err != nil {
+		s.T().Fatal(err)
+	}
+
+	// И у пользователя только одна учетная запись у каждого издателя
+	if err = s.store.LinkIdentity(&models.Identity{UserID: otherID, Issuer: identity.Issuer, Subject: "44"}); err != ErrIdentityLinked {
+		s.T().Errorf("expected %v, got %v", ErrIdentityLinked, err)
+	}
+
+	if _, err = s.store.GetByIdentity("https://other.example.com", "42"); err != sql.ErrNoRows {
+		s.T().Errorf("expected %v, got %v", sql.ErrNoRows, err)
+	}
+}
@@ -0,0 +1,227 @@
+package webhooks
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/lib/pq"
+)
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db queryer
+}
+
+// New Инициализирует экземпляр репозитория
+func New(db queryer) *repository {
+	return &repository{db: db}
+}
+
+// Create Создает новую подписку
+func (store *repository) Create(webhook *models.Webhook) (int64, error) {
+	row := store.db.QueryRow(
+		"insert into webhooks(user_id, url, events, secret) values ($1,$2,$3,$4) returning id",
+		webhook.UserID,
+		webhook.URL,
+		pq.Array(webhook.Events),
+		webhook.Secret,
+	)
+
+	var webhookID int64
+	err := row.Scan(&webhookID)
+
+	return webhookID, err
+}
+
+// Remove Удаляет подписку пользователя вместе с журналом доставок
+func (store *repository) Remove(userID, webhookID int64) error {
+	res, err := store.db.Exec("delete from webhooks where id = $1 and user_id = $2", webhookID, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, &models.NotFoundError{Entity: "webhook", ID: webhookID})
+}
+
+// Get Возвращает подписки пользователя
+func (store *repository) Get(userID int64) ([]models.Webhook, error) {
+	query := "select id, user_id, url, events, secret, created_at from webhooks where user_id=$1 order by created_at desc"
+
+	return store.getWebhooks(query, userID)
+}
+
+// GetByLedgerEvent Возвращает подписки всех участников бухгалтерии на указанное событие
+func (store *repository) GetByLedgerEvent(ledgerID int64, event string) ([]models.Webhook, error) {
+	query := `select w.id, w.user_id, w.url, w.events, w.secret, w.created_at from webhooks w
+join ledger_members m on m.user_id = w.user_id
+where m.ledger_id=$1 and $2 = any(w.events) order by w.id`
+
+	return store.getWebhooks(query, ledgerID, event)
+}
+
+// CreateDelivery Ставит событие в очередь на доставку
+func (store *repository) CreateDelivery(delivery *models.Delivery) (int64, error) {
+	row := store.db.QueryRow(
+		"insert into webhook_deliveries(webhook_id, event, payload, status, next_attempt_at) values ($1,$2,$3,$4,$5) returning id",
+		delivery.WebhookID,
+		delivery.Event,
+		string(delivery.Payload),
+		delivery.Status,
+		delivery.NextAttempt,
+	)
+
+	var deliveryID int64
+	err := row.Scan(&deliveryID)
+
+	return deliveryID, err
+}
+
+// GetDeliveries Возвращает последние доставки по подписке пользователя
+// Для чужой или несуществующей подписки возвращает models.NotFoundError
+func (store *repository) GetDeliveries(userID, webhookID int64, limit int64) ([]models.Delivery, error) {
+	var owned bool
+	row := store.db.QueryRow("select exists(select 1 from webhooks where id = $1 and user_id = $2)", webhookID, userID)
+	if err := row.Scan(&owned); err != nil {
+		return nil, err
+	}
+
+	if !owned {
+		return nil, &models.NotFoundError{Entity: "webhook", ID: webhookID}
+	}
+
+	query := `select d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+       d.response_code, d.last_error, d.created_at, d.delivered_at
+from webhook_deliveries d
+join webhooks w on w.id = d.webhook_id
+where w.user_id = $1 and d.webhook_id = $2
+order by d.created_at desc
+limit $3`
+
+	rows, err := store.db.Query(query, userID, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var deliveries []models.Delivery
+	for rows.Next() {
+		d := models.Delivery{}
+		err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt,
+			&d.ResponseCode, &d.LastError, &d.Created, &d.Delivered,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ClaimPending Забирает готовые к отправке доставки
+// Забранные доставки откладываются на время lease, что бы их не отправил параллельно другой экземпляр приложения
+func (store *repository) ClaimPending(limit int64, lease time.Duration) ([]models.Delivery, error) {
+	query := `update webhook_deliveries d
+set next_attempt_at = now() + make_interval(secs => $2)
+from webhooks w
+where w.id = d.webhook_id and d.id in (
+    select id from webhook_deliveries
+    where status = $3 and next_attempt_at <= now()
+    order by next_attempt_at
+    limit $1
+    for update skip locked
+)
+returning d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.created_at, w.id, w.user_id, w.url, w.secret`
+
+	rows, err := store.db.Query(query, limit, lease.Seconds(), models.DeliveryPending)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var deliveries []models.Delivery
+	for rows.Next() {
+		d := models.Delivery{}
+		err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.Created,
+			&d.Webhook.ID, &d.Webhook.UserID, &d.Webhook.URL, &d.Webhook.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// UpdateDelivery Сохраняет результат попытки доставки
+func (store *repository) UpdateDelivery(delivery *models.Delivery) error {
+	_, err := store.db.Exec(
+		`update webhook_deliveries
+set status = $2, attempts = $3, next_attempt_at = $4, response_code = $5, last_error = $6, delivered_at = $7
+where id = $1`,
+		delivery.ID,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttempt,
+		delivery.ResponseCode,
+		delivery.LastError,
+		delivery.Delivered,
+	)
+
+	return err
+}
+
+// Выполняет запрос и читает список подписок
+func (store *repository) getWebhooks(query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		w := models.Webhook{}
+		if err := rows.Scan(&w.ID, &w.UserID, &w.URL, pq.Array(&w.Events), &w.Secret, &w.Created); err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Проверяет, что запрос затронул запись пользователя, иначе возвращает переданную ошибку
+func checkAffected(res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return notFound
+	}
+
+	return nil
+}
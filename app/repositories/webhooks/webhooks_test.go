@@ -0,0 +1,174 @@
+package webhooks
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type storeSuite struct {
+	suite.Suite
+	store *repository
+	db    *sql.DB
+}
+
+func (s *storeSuite) SetupSuite() {
+	connString := "dbname=casher sslmode=disable"
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.store = &repository{db: db}
+}
+
+func (s *storeSuite) SetupTest() {
+	_, err := s.db.Exec("delete from webhooks; delete from tokens; delete from operations; delete from ledgers; delete from users;")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.db.Exec(`insert into users (id, login, password, name, birth) values(10000000, 'jondoe','qwerty', 'Jon Doe', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func TestStoreSuite(t *testing.T) {
+	s := new(storeSuite)
+	suite.Run(t, s)
+}
+
+func (s *storeSuite) TestGetByLedgerEvent() {
+	_, err := s.db.Exec(`insert into users (id, login, password, name, birth) values
+(20000000, 'janedoe','qwerty', 'Jane Doe', now()),
+(30000000, 'stranger','qwerty', 'Stranger', now())`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Посторонний пользователь не участвует в бухгалтерии и ее событий не получает
+	_, err = s.db.Exec(`insert into ledgers (id, name) values(10000000, 'Общий бюджет');
+insert into ledger_members (ledger_id, user_id, role) values(10000000, 10000000, 'owner'), (10000000, 20000000, 'viewer')`)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	for _, userID := range []int64{10000000, 20000000, 30000000} {
+		_, err = s.store.Create(&models.Webhook{
+			UserID: userID,
+			URL:    "https://example.com/hook",
+			Events: []string{models.EventOperationCreated},
+			Secret: "secret",
+		})
+		if err != nil {
+			s.T().Fatal(err)
+		}
+	}
+
+	created, err := s.store.GetByLedgerEvent(10000000, models.EventOperationCreated)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(created) != 2 {
+		s.T().Fatalf("incorrect count, wanted 2, got %d", len(created))
+	}
+
+	if created[0].UserID != 10000000 || created[1].UserID != 20000000 {
+		s.T().Errorf("expected webhooks of ledger members, got %v", created)
+	}
+
+	deleted, err := s.store.GetByLedgerEvent(10000000, models.EventOperationDeleted)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(deleted) != 0 {
+		s.T().Errorf("incorrect count, wanted 0, got %d", len(deleted))
+	}
+}
+
+func (s *storeSuite) TestRemove() {
+	webhookID, err := s.store.Create(&models.Webhook{
+		UserID: 10000000,
+		URL:    "https://example.com/hook",
+		Events: []string{models.EventOperationCreated},
+		Secret: "secret",
+	})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	// Чужую подписку нельзя ни посмотреть, ни удалить
+	if _, err = s.store.GetDeliveries(20000000, webhookID, 10); !errors.Is(err, models.ErrNotFound) {
+		s.T().Fatalf("expected not found, got %v", err)
+	}
+
+	if err = s.store.Remove(20000000, webhookID); !errors.Is(err, models.ErrNotFound) {
+		s.T().Fatalf("expected not found, got %v", err)
+	}
+
+	if _, err = s.store.GetDeliveries(10000000, webhookID, 10); err != nil {
+		s.T().Fatal(err)
+	}
+
+	if err = s.store.Remove(10000000, webhookID); err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *storeSuite) TestClaimPending() {
+	webhookID, err := s.store.Create(&models.Webhook{
+		UserID: 10000000,
+		URL:    "https://example.com/hook",
+		Events: []string{models.EventOperationCreated},
+		Secret: "secret",
+	})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	_, err = s.store.CreateDelivery(&models.Delivery{
+		WebhookID:   webhookID,
+		Event:       models.EventOperationCreated,
+		Payload:     []byte(`{"event":"operation.created"}`),
+		Status:      models.DeliveryPending,
+		NextAttempt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	claimed, err := s.store.ClaimPending(10, time.Minute)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(claimed) != 1 {
+		s.T().Fatalf("incorrect count, wanted 1, got %d", len(claimed))
+	}
+
+	if claimed[0].Webhook.Secret != "secret" {
+		s.T().Errorf("expected %v, got %v", "secret", claimed[0].Webhook.Secret)
+	}
+
+	// Повторно забрать зарезервированную доставку нельзя
+	claimed, err = s.store.ClaimPending(10, time.Minute)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+
+	if len(claimed) != 0 {
+		s.T().Errorf("incorrect count, wanted 0, got %d", len(claimed))
+	}
+}
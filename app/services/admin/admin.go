@@ -0,0 +1,124 @@
+//go:generate mockgen -source=admin.go -destination=./mocks.go -package=admin
+
+package admin
+
+import (
+	"errors"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/users"
+)
+
+const (
+	pageSize = 20
+)
+
+var (
+	ErrForbidden    = errors.New("admin access forbidden error")
+	ErrSelfAction   = errors.New("admin can not change own account error")
+	ErrUserNotFound = errors.New("user not found error")
+)
+
+type usersRepository interface {
+	Get(userID int64) (*models.User, error)
+	List(page, size int64) (*models.UserPaginator, error)
+	SetDisabled(userID int64, disabled bool) error
+	Delete(userID int64) error
+}
+
+// Service Сервис администрирования пользователей
+// Все методы доступны только пользователям с ролью администратора
+type Service struct {
+	usersRepo usersRepository
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+func New(usersRepo usersRepository) *Service {
+	return &Service{usersRepo: usersRepo}
+}
+
+// IsAdmin Проверяет, является ли пользователь активным администратором
+func (s *Service) IsAdmin(userID int64) bool {
+	return s.authorize(userID) == nil
+}
+
+// Users Возвращает список пользователей с пагинацией
+func (s *Service) Users(adminID, page int64) (*models.UserPaginator, error) {
+	if err := s.authorize(adminID); err != nil {
+		return nil, err
+	}
+
+	paginator, err := s.usersRepo.List(page, pageSize)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("list users error")
+		return nil, err
+	}
+
+	return paginator, nil
+}
+
+// Disable Блокирует аккаунт пользователя и возвращает его состояние до блокировки
+func (s *Service) Disable(adminID, userID int64) (*models.User, error) {
+	return s.change(adminID, userID, func() error {
+		return s.usersRepo.SetDisabled(userID, true)
+	})
+}
+
+// Enable Разблокирует аккаунт пользователя и возвращает его состояние до разблокировки
+func (s *Service) Enable(adminID, userID int64) (*models.User, error) {
+	return s.change(adminID, userID, func() error {
+		return s.usersRepo.SetDisabled(userID, false)
+	})
+}
+
+// Delete Удаляет аккаунт пользователя со всеми данными и возвращает удаленного пользователя
+func (s *Service) Delete(adminID, userID int64) (*models.User, error) {
+	return s.change(adminID, userID, func() error {
+		return s.usersRepo.Delete(userID)
+	})
+}
+
+// Проверяет права администратора и применяет изменение к чужому аккаунту
+// Свой аккаунт администратор изменить не может, что бы не потерять доступ к панели
+func (s *Service) change(adminID, userID int64, apply func() error) (*models.User, error) {
+	if err := s.authorize(adminID); err != nil {
+		return nil, err
+	}
+
+	if adminID == userID {
+		return nil, ErrSelfAction
+	}
+
+	user, err := s.usersRepo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get user error")
+		return nil, ErrUserNotFound
+	}
+
+	err = apply()
+	if err == users.ErrUserNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("adminID", adminID).WithField("userID", userID).Errorf("change user error")
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Проверяет, что пользователь является незаблокированным администратором
+func (s *Service) authorize(adminID int64) error {
+	user, err := s.usersRepo.Get(adminID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", adminID).Errorf("get admin error")
+		return ErrForbidden
+	}
+
+	if !user.IsAdmin() || user.Disabled != nil {
+		return ErrForbidden
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	admin  = models.User{ID: 1, Login: "admin", Role: models.UserRoleAdmin}
+	member = models.User{ID: 2, Login: "jondoe", Role: models.UserRoleUser}
+)
+
+func TestService_Users(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	exp := &models.UserPaginator{Users: []models.User{admin, member}}
+	repo.EXPECT().Get(admin.ID).Return(&admin, nil)
+	repo.EXPECT().List(int64(2), int64(pageSize)).Return(exp, nil)
+
+	service := New(repo)
+	act, err := service.Users(admin.ID, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, exp, act)
+}
+
+func TestService_Users_Forbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	repo.EXPECT().Get(member.ID).Return(&member, nil)
+
+	service := New(repo)
+	act, err := service.Users(member.ID, 1)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestService_IsAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	now := time.Now()
+	disabled := admin
+	disabled.Disabled = &now
+
+	repo.EXPECT().Get(admin.ID).Return(&admin, nil)
+	repo.EXPECT().Get(member.ID).Return(&member, nil)
+	repo.EXPECT().Get(int64(3)).Return(&disabled, nil)
+	repo.EXPECT().Get(int64(4)).Return(nil, errors.New("test error"))
+
+	service := New(repo)
+
+	assert.True(t, service.IsAdmin(admin.ID))
+	assert.False(t, service.IsAdmin(member.ID))
+	assert.False(t, service.IsAdmin(3))
+	assert.False(t, service.IsAdmin(4))
+}
+
+func TestService_Disable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	repo.EXPECT().Get(admin.ID).Return(&admin, nil)
+	repo.EXPECT().Get(member.ID).Return(&member, nil)
+	repo.EXPECT().SetDisabled(member.ID, true).Return(nil)
+
+	service := New(repo)
+	act, err := service.Disable(admin.ID, member.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &member, act)
+}
+
+func TestService_Enable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	repo.EXPECT().Get(admin.ID).Return(&admin, nil)
+	repo.EXPECT().Get(member.ID).Return(&member, nil)
+	repo.EXPECT().SetDisabled(member.ID, false).Return(nil)
+
+	service := New(repo)
+	act, err := service.Enable(admin.ID, member.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &member, act)
+}
+
+func TestService_Delete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	repo.EXPECT().Get(admin.ID).Return(&admin, nil)
+	repo.EXPECT().Get(member.ID).Return(&member, nil)
+	repo.EXPECT().Delete(member.ID).Return(nil)
+
+	service := New(repo)
+	act, err := service.Delete(admin.ID, member.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &member, act)
+}
+
+func TestService_Delete_Self(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	repo.EXPECT().Get(admin.ID).Return(&admin, nil)
+
+	service := New(repo)
+	act, err := service.Delete(admin.ID, admin.ID)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrSelfAction)
+}
+
+func TestService_Disable_Forbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockusersRepository(ctrl)
+
+	repo.EXPECT().Get(member.ID).Return(&member, nil)
+
+	service := New(repo)
+	act, err := service.Disable(member.ID, admin.ID)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
@@ -0,0 +1,93 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: admin.go
+
+// Package admin is a generated GoMock package.
+package admin
+
+import (
+	reflect "reflect"
+
+	models "github.com/bgoldovsky/casher/app/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockusersRepository is a mock of usersRepository interface.
+type MockusersRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockusersRepositoryMockRecorder
+}
+
+// MockusersRepositoryMockRecorder is the mock recorder for MockusersRepository.
+type MockusersRepositoryMockRecorder struct {
+	mock *MockusersRepository
+}
+
+// NewMockusersRepository creates a new mock instance.
+func NewMockusersRepository(ctrl *gomock.Controller) *MockusersRepository {
+	mock := &MockusersRepository{ctrl: ctrl}
+	mock.recorder = &MockusersRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockusersRepository) EXPECT() *MockusersRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockusersRepository) Delete(userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockusersRepositoryMockRecorder) Delete(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockusersRepository)(nil).Delete), userID)
+}
+
+// Get mocks base method.
+func (m *MockusersRepository) Get(userID int64) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", userID)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockusersRepositoryMockRecorder) Get(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockusersRepository)(nil).Get), userID)
+}
+
+// List mocks base method.
+func (m *MockusersRepository) List(page, size int64) (*models.UserPaginator, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", page, size)
+	ret0, _ := ret[0].(*models.UserPaginator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockusersRepositoryMockRecorder) List(page, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockusersRepository)(nil).List), page, size)
+}
+
+// SetDisabled mocks base method.
+func (m *MockusersRepository) SetDisabled(userID int64, disabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDisabled", userID, disabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDisabled indicates an expected call of SetDisabled.
+func (mr *MockusersRepositoryMockRecorder) SetDisabled(userID, disabled interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDisabled", reflect.TypeOf((*MockusersRepository)(nil).SetDisabled), userID, disabled)
+}
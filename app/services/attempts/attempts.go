@@ -0,0 +1,266 @@
+//go:generate mockgen -source=attempts.go -destination=./mocks.go -package=attempts
+
+package attempts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+const (
+	// Сколько хранятся счетчики без новых неудач, что бы владелец узнал о них при следующем входе
+	retention = 30 * 24 * time.Hour
+	// Предел удвоений задержки, дальше она не растет
+	maxShift = 30
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many login attempts error")
+)
+
+type repository interface {
+	Get(key string) (*models.LoginAttempts, error)
+	Reserve(key string, at, since time.Time, schedule models.LoginSchedule) (*models.LoginAttempts, error)
+	Release(key string) error
+	Lock(key string, at time.Time) error
+	Reset(key string) (*models.LoginAttempts, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+// Config Настройки защиты входа от перебора паролей
+type Config struct {
+	// Количество неудачных попыток подряд, которые не ограничиваются задержкой
+	FreeAttempts int64
+	// Задержка после первой попытки сверх бесплатных, с каждой следующей неудачей удваивается
+	BaseDelay time.Duration
+	// Максимальная задержка между попытками
+	MaxDelay time.Duration
+	// Количество неудачных попыток по логину, после которого вход временно блокируется
+	LoginLimit int64
+	// Количество неудачных попыток с одного адреса, после которого вход с него временно блокируется
+	IPLimit int64
+	// Время блокировки после исчерпания попыток
+	Lockout time.Duration
+	// Пауза без неудач, после которой серия попыток забывается
+	Window time.Duration
+}
+
+// Service Сервис защиты входа от перебора паролей
+// Неудачные попытки считаются отдельно по логину и по адресу клиента, после нескольких неудач
+// между попытками растет задержка, а после исчерпания лимита вход временно блокируется
+// Попытка учитывается как неудачная до проверки пароля и снимается, если пароль верный, поэтому параллельные
+// попытки не обходят задержку. Счетчики хранятся в базе, поэтому ограничения действуют на все экземпляры приложения
+type Service struct {
+	repo repository
+	cfg  Config
+	now  func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+func New(repo repository, cfg Config) *Service {
+	return &Service{
+		repo: repo,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Reserve Резервирует попытку входа с логином и адреса: учитывает ее как неудачную, если она сейчас разрешена
+// Если попытка запрещена, то возвращает ErrTooManyAttempts и время до следующей разрешенной попытки
+// При исчерпании лимита отмечает временную блокировку, о которой владелец узнает при следующем входе
+func (s *Service) Reserve(login, ip string) (time.Duration, error) {
+	now := s.now()
+
+	var reserved []string
+	for _, l := range s.limits(login, ip) {
+		a, err := s.repo.Reserve(l.key, now, now.Add(-s.cfg.Window), s.schedule(l.limit))
+		if err == sql.ErrNoRows {
+			s.release(reserved)
+			logger.Log.WithField("login", login).WithField("ip", ip).Errorf("too many login attempts error")
+			return s.wait(login, ip, now), ErrTooManyAttempts
+		}
+		if err != nil {
+			s.release(reserved)
+			logger.Log.WithError(err).WithField("key", l.key).Errorf("reserve login attempt error")
+			return 0, err
+		}
+		reserved = append(reserved, l.key)
+
+		if a.FailedAttempts != l.limit {
+			continue
+		}
+
+		logger.Log.WithField("key", l.key).Warn("login locked after too many failures")
+		if err = s.repo.Lock(l.key, now); err != nil {
+			logger.Log.WithError(err).WithField("key", l.key).Errorf("lock login error")
+		}
+	}
+
+	return 0, nil
+}
+
+// Release Снимает зарезервированную попытку, если пароль оказался верным
+func (s *Service) Release(login, ip string) error {
+	for _, l := range s.limits(login, ip) {
+		if err := s.repo.Release(l.key); err != nil {
+			logger.Log.WithError(err).WithField("key", l.key).Errorf("release login attempt error")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Succeed Сбрасывает счетчик логина после успешного входа и возвращает неудачи, случившиеся с прошлого входа
+// Если неудач не было, то возвращает nil
+// Счетчик адреса не сбрасывается, иначе вход в свой аккаунт позволял бы продолжать перебор чужих,
+// с него только снимается попытка успешного входа
+func (s *Service) Succeed(login, ip string) (*models.LoginAttempts, error) {
+	if err := s.repo.Release(addressKey(ip)); err != nil {
+		logger.Log.WithError(err).WithField("ip", ip).Errorf("release login attempt error")
+		return nil, err
+	}
+
+	a, err := s.repo.Reset(loginKey(login))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("login", login).Errorf("reset login attempts error")
+		return nil, err
+	}
+
+	// Успешная попытка тоже была учтена как неудачная
+	a.FailedAttempts--
+	a.TotalFailures--
+	if a.TotalFailures <= 0 {
+		return nil, nil
+	}
+
+	return a, nil
+}
+
+// Run Периодически удаляет устаревшие счетчики, пока не отменен контекст
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.DeleteExpired()
+		}
+	}
+}
+
+// DeleteExpired Удаляет счетчики, по которым давно не было неудачных попыток
+func (s *Service) DeleteExpired() error {
+	count, err := s.repo.DeleteExpired(s.now().Add(-retention))
+	if err != nil {
+		logger.Log.WithError(err).Errorf("delete expired login attempts error")
+		return err
+	}
+
+	if count > 0 {
+		logger.Log.WithField("count", count).Info("expired login attempts deleted")
+	}
+
+	return nil
+}
+
+// Счетчик попыток и его лимит
+type limit struct {
+	key   string
+	limit int64
+}
+
+// Возвращает ключи счетчиков попытки вместе с их лимитами
+func (s *Service) limits(login, ip string) []limit {
+	return []limit{
+		{key: loginKey(login), limit: s.cfg.LoginLimit},
+		{key: addressKey(ip), limit: s.cfg.IPLimit},
+	}
+}
+
+// Снимает попытки, зарезервированные до того, как по другому счетчику попытка оказалась запрещена
+func (s *Service) release(keys []string) {
+	for _, key := range keys {
+		if err := s.repo.Release(key); err != nil {
+			logger.Log.WithError(err).WithField("key", key).Errorf("release login attempt error")
+		}
+	}
+}
+
+// Возвращает время до следующей разрешенной попытки по всем счетчикам, но не меньше секунды,
+// так как запрет мог закончиться, пока читались счетчики
+func (s *Service) wait(login, ip string, now time.Time) time.Duration {
+	wait := time.Second
+	for _, l := range s.limits(login, ip) {
+		a, err := s.repo.Get(l.key)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				logger.Log.WithError(err).WithField("key", l.key).Errorf("get login attempts error")
+			}
+			continue
+		}
+
+		if a.FailedAt.Before(now.Add(-s.cfg.Window)) {
+			continue
+		}
+		if left := a.BlockedUntil.Sub(now); left > wait {
+			wait = left
+		}
+	}
+
+	return wait
+}
+
+// Возвращает расписание задержек после неудачных попыток: после бесплатных попыток задержка удваивается
+// с каждой неудачей до максимальной, а после исчерпания лимита действует блокировка
+func (s *Service) schedule(limit int64) models.LoginSchedule {
+	var delays []time.Duration
+	for n := int64(1); n <= limit; n++ {
+		delay := s.delay(n)
+		delays = append(delays, delay)
+		if delay >= s.cfg.MaxDelay {
+			break
+		}
+	}
+
+	return models.LoginSchedule{Delays: delays, Limit: limit, Lockout: s.cfg.Lockout}
+}
+
+// Возвращает задержку после неудачи с номером n в серии
+func (s *Service) delay(n int64) time.Duration {
+	extra := n - s.cfg.FreeAttempts
+	if extra <= 0 {
+		return 0
+	}
+
+	delay := s.cfg.MaxDelay
+	if extra <= maxShift {
+		if d := s.cfg.BaseDelay << uint(extra-1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	return delay
+}
+
+// Возвращает ключ счетчика логина, регистр и пробелы по краям не учитываются
+func loginKey(login string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+// Возвращает ключ счетчика адреса
+func addressKey(ip string) string {
+	return "ip:" + ip
+}
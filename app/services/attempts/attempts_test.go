@@ -0,0 +1,157 @@
+package attempts
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	login  = "JonDoe"
+	jonKey = "login:jondoe"
+	ip     = "10.0.0.1"
+	ipKey  = "ip:10.0.0.1"
+)
+
+var (
+	now = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	cfg = Config{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LoginLimit:   20,
+		IPLimit:      50,
+		Lockout:      15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+func newTestService(t *testing.T) (*Service, *Mockrepository) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	repo := NewMockrepository(ctrl)
+	service := New(repo, cfg)
+	service.now = func() time.Time { return now }
+
+	return service, repo
+}
+
+func TestService_Schedule(t *testing.T) {
+	service, _ := newTestService(t)
+
+	act := service.schedule(cfg.LoginLimit)
+
+	// Задержка удваивается после бесплатных попыток, пока не достигнет максимальной
+	assert.Equal(t, []time.Duration{
+		0, 0, 0,
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second,
+		time.Minute,
+	}, act.Delays)
+	assert.Equal(t, cfg.LoginLimit, act.Limit)
+	assert.Equal(t, cfg.Lockout, act.Lockout)
+
+	// Расписание не длиннее лимита
+	assert.Len(t, service.schedule(2).Delays, 2)
+}
+
+func TestService_Reserve(t *testing.T) {
+	service, repo := newTestService(t)
+
+	since := now.Add(-cfg.Window)
+	repo.EXPECT().Reserve(jonKey, now, since, service.schedule(cfg.LoginLimit)).Return(&models.LoginAttempts{Key: jonKey, FailedAttempts: cfg.LoginLimit}, nil)
+	repo.EXPECT().Reserve(ipKey, now, since, service.schedule(cfg.IPLimit)).Return(&models.LoginAttempts{Key: ipKey, FailedAttempts: 11}, nil)
+	// Блокировка отмечается только при исчерпании лимита
+	repo.EXPECT().Lock(jonKey, now).Return(nil)
+
+	wait, err := service.Reserve(login, ip)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestService_Reserve_Blocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		login    *models.LoginAttempts
+		ipFailed time.Duration
+		exp      time.Duration
+	}{
+		{name: "delay", login: &models.LoginAttempts{FailedAt: now, BlockedUntil: now.Add(4 * time.Second)}, exp: 4 * time.Second},
+		{name: "longest delay", login: &models.LoginAttempts{FailedAt: now, BlockedUntil: now.Add(4 * time.Second)}, ipFailed: 10 * time.Minute, exp: 10 * time.Minute},
+		{name: "delay elapsed", login: &models.LoginAttempts{FailedAt: now, BlockedUntil: now.Add(-time.Second)}, exp: time.Second},
+		{name: "outside window", login: &models.LoginAttempts{FailedAt: now.Add(-2 * time.Hour), BlockedUntil: now.Add(time.Hour)}, exp: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo := newTestService(t)
+
+			// Попытка по логину зарезервирована, а по адресу запрещена, поэтому резерв логина снимается
+			repo.EXPECT().Reserve(jonKey, now, now.Add(-cfg.Window), gomock.Any()).Return(&models.LoginAttempts{Key: jonKey, FailedAttempts: 5}, nil)
+			repo.EXPECT().Reserve(ipKey, now, now.Add(-cfg.Window), gomock.Any()).Return(nil, sql.ErrNoRows)
+			repo.EXPECT().Release(jonKey).Return(nil)
+
+			repo.EXPECT().Get(jonKey).Return(tt.login, nil)
+			repo.EXPECT().Get(ipKey).Return(&models.LoginAttempts{Key: ipKey, FailedAt: now, BlockedUntil: now.Add(tt.ipFailed)}, nil)
+
+			wait, err := service.Reserve(login, ip)
+			assert.ErrorIs(t, err, ErrTooManyAttempts)
+			assert.Equal(t, tt.exp, wait)
+		})
+	}
+}
+
+func TestService_Reserve_Error(t *testing.T) {
+	service, repo := newTestService(t)
+
+	expErr := errors.New("test error")
+	repo.EXPECT().Reserve(jonKey, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, expErr)
+
+	_, err := service.Reserve(login, ip)
+	assert.ErrorIs(t, err, expErr)
+}
+
+func TestService_Release(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().Release(jonKey).Return(nil)
+	repo.EXPECT().Release(ipKey).Return(nil)
+
+	require.NoError(t, service.Release(login, ip))
+}
+
+func TestService_Succeed(t *testing.T) {
+	service, repo := newTestService(t)
+
+	locked := now.Add(-time.Hour)
+	repo.EXPECT().Release(ipKey).Return(nil).Times(3)
+	repo.EXPECT().Reset(jonKey).Return(&models.LoginAttempts{Key: jonKey, FailedAttempts: 13, TotalFailures: 21, Locked: &locked}, nil)
+	repo.EXPECT().Reset(jonKey).Return(&models.LoginAttempts{Key: jonKey, FailedAttempts: 1, TotalFailures: 1}, nil)
+	repo.EXPECT().Reset(jonKey).Return(nil, sql.ErrNoRows)
+
+	// Успешная попытка не считается неудачей
+	act, err := service.Succeed(login, ip)
+	require.NoError(t, err)
+	assert.Equal(t, &models.LoginAttempts{Key: jonKey, FailedAttempts: 12, TotalFailures: 20, Locked: &locked}, act)
+
+	act, err = service.Succeed(login, ip)
+	require.NoError(t, err)
+	assert.Nil(t, act)
+
+	act, err = service.Succeed(login, ip)
+	require.NoError(t, err)
+	assert.Nil(t, act)
+}
+
+func TestService_DeleteExpired(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().DeleteExpired(now.Add(-retention)).Return(int64(2), nil)
+	assert.NoError(t, service.DeleteExpired())
+}
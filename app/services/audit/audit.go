@@ -0,0 +1,83 @@
+//go:generate mockgen -source=audit.go -destination=./mocks.go -package=audit
+
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+const (
+	pageSize = 20
+)
+
+type repository interface {
+	Create(entry *models.AuditEntry) (int64, error)
+	Get(userID, page, size int64) (*models.AuditPaginator, error)
+	GetByAction(prefix string, page, size int64) (*models.AuditPaginator, error)
+}
+
+// Service Сервис журнала аудита действий пользователей
+type Service struct {
+	repo repository
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+func New(repo repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Record Записывает действие в журнал аудита вместе со снимками объекта до и после изменения
+// Пустой снимок (nil) не сохраняется
+func (s *Service) Record(entry *models.AuditEntry, before, after interface{}) error {
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		logger.Log.WithError(err).WithField("action", entry.Action).Errorf("marshal audit snapshot error")
+		return err
+	}
+
+	if entry.After, err = snapshot(after); err != nil {
+		logger.Log.WithError(err).WithField("action", entry.Action).Errorf("marshal audit snapshot error")
+		return err
+	}
+
+	if _, err = s.repo.Create(entry); err != nil {
+		logger.Log.WithError(err).WithField("entry", entry).Errorf("create audit entry error")
+		return err
+	}
+
+	return nil
+}
+
+// Get Возвращает историю действий пользователя с пагинацией
+func (s *Service) Get(userID int64, page int64) (*models.AuditPaginator, error) {
+	paginator, err := s.repo.Get(userID, page, pageSize)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get audit log error")
+		return nil, err
+	}
+
+	return paginator, nil
+}
+
+// GetAdmin Возвращает журнал действий администраторов с пагинацией
+func (s *Service) GetAdmin(page int64) (*models.AuditPaginator, error) {
+	paginator, err := s.repo.GetByAction(models.AuditAdminPrefix, page, pageSize)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("get admin log error")
+		return nil, err
+	}
+
+	return paginator, nil
+}
+
+// Сериализует снимок объекта в JSON
+func snapshot(data interface{}) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(data)
+}
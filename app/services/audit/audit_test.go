@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Record(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	var saved *models.AuditEntry
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(e *models.AuditEntry) (int64, error) {
+		saved = e
+		return 1, nil
+	})
+
+	service := New(repo)
+	err := service.Record(&models.AuditEntry{
+		ActorID:    123,
+		Action:     models.AuditOperationDelete,
+		TargetType: models.AuditTargetOperation,
+		TargetID:   10,
+	}, &models.Operation{ID: 10, Subject: "test-subj"}, nil)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":10,"ledger_id":0,"user_id":0,"subject":"test-subj","amount":0,"type":0,"message":"","created_at":"0001-01-01T00:00:00Z"}`, string(saved.Before))
+	assert.Nil(t, saved.After)
+}
+
+func TestService_Record_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	expErr := errors.New("test error")
+	repo.EXPECT().Create(gomock.Any()).Return(int64(0), expErr)
+
+	service := New(repo)
+	err := service.Record(&models.AuditEntry{Action: models.AuditLogin}, nil, nil)
+
+	assert.ErrorIs(t, err, expErr)
+}
+
+func TestService_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	exp := &models.AuditPaginator{Entries: []models.AuditEntry{{ID: 1, Action: models.AuditLogin}}}
+	repo.EXPECT().Get(int64(123), int64(2), int64(pageSize)).Return(exp, nil)
+
+	service := New(repo)
+	act, err := service.Get(123, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, exp, act)
+}
+
+func TestService_GetAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	exp := &models.AuditPaginator{Entries: []models.AuditEntry{{ID: 1, Action: models.AuditAdminDisable}}}
+	repo.EXPECT().GetByAction(models.AuditAdminPrefix, int64(1), int64(pageSize)).Return(exp, nil)
+
+	service := New(repo)
+	act, err := service.GetAdmin(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, exp, act)
+}
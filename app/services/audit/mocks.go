@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: audit.go
+
+// Package audit is a generated GoMock package.
+package audit
+
+import (
+	reflect "reflect"
+
+	models "github.com/bgoldovsky/casher/app/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockrepository is a mock of repository interface.
+type Mockrepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockrepositoryMockRecorder
+}
+
+// MockrepositoryMockRecorder is the mock recorder for Mockrepository.
+type MockrepositoryMockRecorder struct {
+	mock *Mockrepository
+}
+
+// NewMockrepository creates a new mock instance.
+func NewMockrepository(ctrl *gomock.Controller) *Mockrepository {
+	mock := &Mockrepository{ctrl: ctrl}
+	mock.recorder = &MockrepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockrepository) EXPECT() *MockrepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *Mockrepository) Create(entry *models.AuditEntry) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", entry)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockrepositoryMockRecorder) Create(entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*Mockrepository)(nil).Create), entry)
+}
+
+// Get mocks base method.
+func (m *Mockrepository) Get(userID, page, size int64) (*models.AuditPaginator, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", userID, page, size)
+	ret0, _ := ret[0].(*models.AuditPaginator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockrepositoryMockRecorder) Get(userID, page, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*Mockrepository)(nil).Get), userID, page, size)
+}
+
+// GetByAction mocks base method.
+func (m *Mockrepository) GetByAction(prefix string, page, size int64) (*models.AuditPaginator, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByAction", prefix, page, size)
+	ret0, _ := ret[0].(*models.AuditPaginator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByAction indicates an expected call of GetByAction.
+func (mr *MockrepositoryMockRecorder) GetByAction(prefix, page, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByAction", reflect.TypeOf((*Mockrepository)(nil).GetByAction), prefix, page, size)
+}
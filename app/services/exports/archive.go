@@ -0,0 +1,182 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+// Файлы архива выгрузки
+const (
+	profileFile        = "profile.json"
+	operationsJSONFile = "operations.json"
+	operationsCSVFile  = "operations.csv"
+)
+
+// Профиль пользователя в выгрузке, хеш пароля в нее не попадает
+type profile struct {
+	ID            int64      `json:"id"`
+	Login         string     `json:"login"`
+	Email         string     `json:"email,omitempty"`
+	EmailVerified *time.Time `json:"email_verified_at,omitempty"`
+	Name          string     `json:"name"`
+	Birth         time.Time  `json:"birth"`
+	Role          string     `json:"role"`
+	InvitedBy     int64      `json:"invited_by,omitempty"`
+	LastLogin     *time.Time `json:"last_login_at,omitempty"`
+	Language      string     `json:"language,omitempty"`
+	Timezone      string     `json:"timezone,omitempty"`
+	Created       time.Time  `json:"created_at"`
+	Exported      time.Time  `json:"exported_at"`
+}
+
+// Операция в выгрузке, сумма записывается в рублях, а тип словом, что бы файл был понятен без документации
+type operation struct {
+	ID       int64     `json:"id"`
+	LedgerID int64     `json:"ledger_id"`
+	Type     string    `json:"type"`
+	Subject  string    `json:"subject"`
+	Amount   string    `json:"amount"`
+	Message  string    `json:"message"`
+	Created  time.Time `json:"created_at"`
+}
+
+// Собирает ZIP архив с профилем пользователя и его операциями в форматах JSON и CSV
+func buildArchive(user *models.User, list []models.Operation, now time.Time) ([]byte, error) {
+	operations := make([]operation, len(list))
+	for i, o := range list {
+		operations[i] = operation{
+			ID:       o.ID,
+			LedgerID: o.LedgerID,
+			Type:     operationType(o.Type),
+			Subject:  o.Subject,
+			Amount:   formatAmount(o.Amount),
+			Message:  o.Message,
+			Created:  o.Created.UTC(),
+		}
+	}
+
+	profileJSON, err := json.MarshalIndent(profile{
+		ID:            user.ID,
+		Login:         user.Login,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          user.Name,
+		Birth:         user.Birth,
+		Role:          string(user.Role),
+		InvitedBy:     user.InvitedBy,
+		LastLogin:     user.LastLogin,
+		Language:      user.Language,
+		Timezone:      user.Timezone,
+		Created:       user.Created,
+		Exported:      now.UTC(),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	operationsJSON, err := json.MarshalIndent(operations, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	operationsCSV, err := writeCSV(operations)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{name: profileFile, data: profileJSON},
+		{name: operationsJSONFile, data: operationsJSON},
+		{name: operationsCSVFile, data: operationsCSV},
+	}
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = w.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Записывает операции в CSV с заголовком
+func writeCSV(operations []operation) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+
+	if err := w.Write([]string{"id", "ledger_id", "created_at", "type", "subject", "amount", "message"}); err != nil {
+		return nil, err
+	}
+
+	for _, o := range operations {
+		record := []string{
+			strconv.FormatInt(o.ID, 10),
+			strconv.FormatInt(o.LedgerID, 10),
+			o.Created.Format(time.RFC3339),
+			o.Type,
+			escapeCSV(o.Subject),
+			o.Amount,
+			escapeCSV(o.Message),
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Экранирует текст, который табличный редактор принял бы за формулу, апострофом в начале ячейки
+// Так открытая в редакторе выгрузка не выполняет формулы, записанные в поля операций
+func escapeCSV(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+
+	return value
+}
+
+// Форматирует сумму в копейках как сумму в рублях с двумя знаками после точки
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// Возвращает название типа операции
+func operationType(t models.OperationType) string {
+	switch t {
+	case models.Deposit:
+		return "deposit"
+	case models.Withdraw:
+		return "withdraw"
+	}
+
+	return strconv.FormatInt(int64(t), 10)
+}
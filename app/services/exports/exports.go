@@ -0,0 +1,245 @@
+//go:generate mockgen -source=exports.go -destination=./mocks.go -package=exports
+
+package exports
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/exports"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid export token error")
+)
+
+type repository interface {
+	Create(userID int64) (*models.Export, error)
+	Get(exportID int64) (*models.Export, error)
+	Latest(userID int64) (*models.Export, error)
+	SetReady(exportID int64, data []byte, expires time.Time) error
+	Data(exportID, userID int64, now time.Time) ([]byte, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type usersRepository interface {
+	Get(userID int64) (*models.User, error)
+}
+
+type operationsRepository interface {
+	GetByUser(userID int64) ([]models.Operation, error)
+}
+
+// Service Сервис выгрузки персональных данных пользователя
+// Архив собирается в фоне по событию из outbox, поэтому запрос выгрузки не ждет обработки всей истории операций.
+// Ссылка на скачивание не хранится в базе: она подписана секретом и содержит ID выгрузки и срок действия,
+// а скачать архив может только его владелец
+type Service struct {
+	repo           repository
+	usersRepo      usersRepository
+	operationsRepo operationsRepository
+	secret         []byte
+	ttl            time.Duration
+	now            func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+// ttl определяет, сколько хранится собранный архив и действует ссылка на него
+func New(repo repository, usersRepo usersRepository, operationsRepo operationsRepository, secret []byte, ttl time.Duration) *Service {
+	return &Service{
+		repo:           repo,
+		usersRepo:      usersRepo,
+		operationsRepo: operationsRepo,
+		secret:         secret,
+		ttl:            ttl,
+		now:            time.Now,
+	}
+}
+
+// Request Запрашивает сборку архива с персональными данными пользователя
+// Пока предыдущий архив собирается, новый не запрашивается и возвращается текущая выгрузка
+func (s *Service) Request(userID int64) (*models.Export, error) {
+	latest, err := s.Latest(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest != nil && latest.Status == models.ExportPending {
+		return latest, nil
+	}
+
+	export, err := s.repo.Create(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("create export error")
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Latest Возвращает последнюю выгрузку пользователя, которая собирается или доступна для скачивания
+// Если таких выгрузок нет, то возвращает nil
+func (s *Service) Latest(userID int64) (*models.Export, error) {
+	export, err := s.repo.Latest(userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get latest export error")
+		return nil, err
+	}
+
+	if export.Status == models.ExportReady && !export.Available(s.now()) {
+		return nil, nil
+	}
+
+	return export, nil
+}
+
+// Link Возвращает ссылку на скачивание собранного архива, которая действует до истечения срока его хранения
+func (s *Service) Link(export *models.Export) string {
+	if export.Expires == nil {
+		return ""
+	}
+
+	payload := strconv.FormatInt(export.ID, 10) + "." + strconv.FormatInt(export.Expires.Unix(), 10)
+	token := payload + "." + s.sign(payload, export.UserID)
+
+	return "/profile/export/download?token=" + url.QueryEscape(token)
+}
+
+// Download Проверяет ссылку и возвращает архив выгрузки пользователя
+// Если ссылка подделана, истекла или ведет к чужой выгрузке, то возвращает ErrInvalidToken
+func (s *Service) Download(userID int64, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	exportID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !s.now().Before(time.Unix(expires, 0)) {
+		return nil, ErrInvalidToken
+	}
+
+	// Подпись вычисляется вместе с ID пользователя, поэтому чужая ссылка не проходит проверку
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(payload, userID))) {
+		return nil, ErrInvalidToken
+	}
+
+	data, err := s.repo.Data(exportID, userID, s.now())
+	if err == exports.ErrExportNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("exportID", exportID).Errorf("get export data error")
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// HandleRequested Обработчик событий о запросе выгрузки из outbox, собирает и сохраняет архив
+// Повторно доставленное событие уже собранной выгрузки пропускается
+func (s *Service) HandleRequested(event *models.Event) error {
+	var payload models.ExportEvent
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		// Повтор не исправит некорректное событие, поэтому оно пропускается
+		logger.Log.WithError(err).WithField("eventID", event.ID).Errorf("decode export event error")
+		return nil
+	}
+
+	export, err := s.repo.Get(payload.ExportID)
+	if err == sql.ErrNoRows {
+		// Выгрузка удалена вместе с аккаунтом
+		return nil
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("exportID", payload.ExportID).Errorf("get export error")
+		return err
+	}
+
+	if export.Status == models.ExportReady {
+		return nil
+	}
+
+	user, err := s.usersRepo.Get(export.UserID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", export.UserID).Errorf("get user error")
+		return err
+	}
+
+	operations, err := s.operationsRepo.GetByUser(export.UserID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", export.UserID).Errorf("get user operations error")
+		return err
+	}
+
+	now := s.now()
+	data, err := buildArchive(user, operations, now)
+	if err != nil {
+		logger.Log.WithError(err).WithField("exportID", export.ID).Errorf("build export archive error")
+		return err
+	}
+
+	if err = s.repo.SetReady(export.ID, data, now.Add(s.ttl)); err != nil {
+		logger.Log.WithError(err).WithField("exportID", export.ID).Errorf("save export archive error")
+		return err
+	}
+
+	logger.Log.WithField("exportID", export.ID).WithField("size", len(data)).Info("export archive ready")
+	return nil
+}
+
+// Run Периодически удаляет архивы с истекшим сроком хранения, пока не отменен контекст
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.DeleteExpired()
+		}
+	}
+}
+
+// DeleteExpired Удаляет архивы с истекшим сроком хранения
+func (s *Service) DeleteExpired() error {
+	count, err := s.repo.DeleteExpired(s.now())
+	if err != nil {
+		logger.Log.WithError(err).Errorf("delete expired exports error")
+		return err
+	}
+
+	if count > 0 {
+		logger.Log.WithField("count", count).Info("expired exports deleted")
+	}
+
+	return nil
+}
+
+// Подписывает данные ссылки вместе с ID владельца выгрузки
+func (s *Service) sign(payload string, userID int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload + "." + strconv.FormatInt(userID, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,248 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/exports"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ttl = 24 * time.Hour
+
+var (
+	now  = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	user = models.User{ID: 55, Login: "jondoe", Password: "hash", Email: "jondoe@example.com", Name: "Jon Doe"}
+)
+
+type testService struct {
+	repo       *Mockrepository
+	users      *MockusersRepository
+	operations *MockoperationsRepository
+	service    *Service
+}
+
+func newTestService(t *testing.T) *testService {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	ts := &testService{
+		repo:       NewMockrepository(ctrl),
+		users:      NewMockusersRepository(ctrl),
+		operations: NewMockoperationsRepository(ctrl),
+	}
+	ts.service = New(ts.repo, ts.users, ts.operations, []byte("secret"), ttl)
+	ts.service.now = func() time.Time { return now }
+
+	return ts
+}
+
+// Возвращает собранную выгрузку пользователя
+func readyExport() *models.Export {
+	expires := now.Add(ttl)
+	return &models.Export{ID: 7, UserID: user.ID, Status: models.ExportReady, Expires: &expires}
+}
+
+// Достает токен из ссылки на скачивание
+func linkToken(t *testing.T, link string) string {
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/profile/export/download", u.Path)
+
+	return u.Query().Get("token")
+}
+
+func TestService_Request(t *testing.T) {
+	ts := newTestService(t)
+
+	exp := &models.Export{ID: 7, UserID: user.ID, Status: models.ExportPending}
+	ts.repo.EXPECT().Latest(user.ID).Return(nil, sql.ErrNoRows)
+	ts.repo.EXPECT().Create(user.ID).Return(exp, nil)
+
+	act, err := ts.service.Request(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, exp, act)
+}
+
+func TestService_Request_Pending(t *testing.T) {
+	ts := newTestService(t)
+
+	// Пока архив собирается, новая выгрузка не создается
+	exp := &models.Export{ID: 7, UserID: user.ID, Status: models.ExportPending}
+	ts.repo.EXPECT().Latest(user.ID).Return(exp, nil)
+
+	act, err := ts.service.Request(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, exp, act)
+}
+
+func TestService_Latest_Expired(t *testing.T) {
+	ts := newTestService(t)
+
+	expired := readyExport()
+	expires := now.Add(-time.Minute)
+	expired.Expires = &expires
+	ts.repo.EXPECT().Latest(user.ID).Return(expired, nil)
+
+	act, err := ts.service.Latest(user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, act)
+}
+
+func TestService_Download(t *testing.T) {
+	ts := newTestService(t)
+
+	export := readyExport()
+	token := linkToken(t, ts.service.Link(export))
+
+	ts.repo.EXPECT().Data(export.ID, user.ID, now).Return([]byte("zip"), nil)
+
+	act, err := ts.service.Download(user.ID, token)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("zip"), act)
+}
+
+func TestService_Download_Invalid(t *testing.T) {
+	ts := newTestService(t)
+
+	export := readyExport()
+	token := linkToken(t, ts.service.Link(export))
+
+	expired := readyExport()
+	expires := now.Add(-time.Minute)
+	expired.Expires = &expires
+
+	tests := []struct {
+		name   string
+		userID int64
+		token  string
+	}{
+		{name: "foreign user", userID: 56, token: token},
+		{name: "expired", userID: user.ID, token: linkToken(t, ts.service.Link(expired))},
+		{name: "tampered", userID: user.ID, token: "8" + token[1:]},
+		{name: "malformed", userID: user.ID, token: "token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ts.service.Download(tt.userID, tt.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestService_Download_Removed(t *testing.T) {
+	ts := newTestService(t)
+
+	export := readyExport()
+	token := linkToken(t, ts.service.Link(export))
+
+	ts.repo.EXPECT().Data(export.ID, user.ID, now).Return(nil, exports.ErrExportNotFound)
+
+	_, err := ts.service.Download(user.ID, token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_HandleRequested(t *testing.T) {
+	ts := newTestService(t)
+
+	operations := []models.Operation{
+		{ID: 1, LedgerID: 10, UserID: user.ID, Subject: "Зарплата", Amount: 5000000, Type: models.Deposit, Created: now},
+		{ID: 2, LedgerID: 10, UserID: user.ID, Subject: "Кофе, \"большой\"", Amount: 25050, Type: models.Withdraw, Message: "=1+1", Created: now},
+	}
+
+	var archive []byte
+	ts.repo.EXPECT().Get(int64(7)).Return(&models.Export{ID: 7, UserID: user.ID, Status: models.ExportPending}, nil)
+	ts.users.EXPECT().Get(user.ID).Return(&user, nil)
+	ts.operations.EXPECT().GetByUser(user.ID).Return(operations, nil)
+	ts.repo.EXPECT().SetReady(int64(7), gomock.Any(), now.Add(ttl)).DoAndReturn(func(_ int64, data []byte, _ time.Time) error {
+		archive = data
+		return nil
+	})
+
+	payload, err := json.Marshal(models.ExportEvent{ExportID: 7, UserID: user.ID})
+	require.NoError(t, err)
+	require.NoError(t, ts.service.HandleRequested(&models.Event{ID: 1, Type: models.EventExportRequested, Payload: payload}))
+
+	files := unzip(t, archive)
+	require.Len(t, files, 3)
+
+	// Хеш пароля в выгрузку не попадает
+	var p map[string]interface{}
+	require.NoError(t, json.Unmarshal(files[profileFile], &p))
+	assert.Equal(t, "jondoe", p["login"])
+	assert.NotContains(t, string(files[profileFile]), user.Password)
+
+	var ops []operation
+	require.NoError(t, json.Unmarshal(files[operationsJSONFile], &ops))
+	require.Len(t, ops, 2)
+	assert.Equal(t, "50000.00", ops[0].Amount)
+	assert.Equal(t, "deposit", ops[0].Type)
+	assert.Equal(t, "=1+1", ops[1].Message)
+
+	records, err := csv.NewReader(bytes.NewReader(files[operationsCSVFile])).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"2", "10", "2021-09-01T12:00:00Z", "withdraw", "Кофе, \"большой\"", "250.50", "'=1+1"}, records[2])
+}
+
+func TestService_HandleRequested_Ready(t *testing.T) {
+	ts := newTestService(t)
+
+	// Повторно доставленное событие не пересобирает архив
+	ts.repo.EXPECT().Get(int64(7)).Return(readyExport(), nil)
+
+	payload, err := json.Marshal(models.ExportEvent{ExportID: 7, UserID: user.ID})
+	require.NoError(t, err)
+	assert.NoError(t, ts.service.HandleRequested(&models.Event{ID: 1, Type: models.EventExportRequested, Payload: payload}))
+}
+
+func TestService_DeleteExpired(t *testing.T) {
+	ts := newTestService(t)
+
+	ts.repo.EXPECT().DeleteExpired(now).Return(int64(2), nil)
+	assert.NoError(t, ts.service.DeleteExpired())
+}
+
+func Test_FormatAmount(t *testing.T) {
+	assert.Equal(t, "0.05", formatAmount(5))
+	assert.Equal(t, "1500.00", formatAmount(150000))
+	assert.Equal(t, "-12.30", formatAmount(-1230))
+}
+
+func Test_EscapeCSV(t *testing.T) {
+	assert.Equal(t, "'=HYPERLINK(\"http://evil\")", escapeCSV(`=HYPERLINK("http://evil")`))
+	assert.Equal(t, "'+1", escapeCSV("+1"))
+	assert.Equal(t, "'-1+2", escapeCSV("-1+2"))
+	assert.Equal(t, "'@SUM(A1)", escapeCSV("@SUM(A1)"))
+	assert.Equal(t, "Таверна = 100", escapeCSV("Таверна = 100"))
+	assert.Equal(t, "", escapeCSV(""))
+}
+
+// Распаковывает архив в словарь файлов по их именам
+func unzip(t *testing.T, data []byte) map[string][]byte {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		files[f.Name], err = ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+	}
+
+	return files
+}
@@ -0,0 +1,201 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: exports.go
+
+// Package exports is a generated GoMock package.
+package exports
+
+import (
+	reflect "reflect"
+	time "time"
+
+	models "github.com/bgoldovsky/casher/app/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockrepository is a mock of repository interface.
+type Mockrepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockrepositoryMockRecorder
+}
+
+// MockrepositoryMockRecorder is the mock recorder for Mockrepository.
+type MockrepositoryMockRecorder struct {
+	mock *Mockrepository
+}
+
+// NewMockrepository creates a new mock instance.
+func NewMockrepository(ctrl *gomock.Controller) *Mockrepository {
+	mock := &Mockrepository{ctrl: ctrl}
+	mock.recorder = &MockrepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockrepository) EXPECT() *MockrepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *Mockrepository) Create(userID int64) (*models.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", userID)
+	ret0, _ := ret[0].(*models.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockrepositoryMockRecorder) Create(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*Mockrepository)(nil).Create), userID)
+}
+
+// Data mocks base method.
+func (m *Mockrepository) Data(exportID, userID int64, now time.Time) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Data", exportID, userID, now)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Data indicates an expected call of Data.
+func (mr *MockrepositoryMockRecorder) Data(exportID, userID, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Data", reflect.TypeOf((*Mockrepository)(nil).Data), exportID, userID, now)
+}
+
+// DeleteExpired mocks base method.
+func (m *Mockrepository) DeleteExpired(before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockrepositoryMockRecorder) DeleteExpired(before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*Mockrepository)(nil).DeleteExpired), before)
+}
+
+// Get mocks base method.
+func (m *Mockrepository) Get(exportID int64) (*models.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", exportID)
+	ret0, _ := ret[0].(*models.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockrepositoryMockRecorder) Get(exportID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*Mockrepository)(nil).Get), exportID)
+}
+
+// Latest mocks base method.
+func (m *Mockrepository) Latest(userID int64) (*models.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Latest", userID)
+	ret0, _ := ret[0].(*models.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Latest indicates an expected call of Latest.
+func (mr *MockrepositoryMockRecorder) Latest(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Latest", reflect.TypeOf((*Mockrepository)(nil).Latest), userID)
+}
+
+// SetReady mocks base method.
+func (m *Mockrepository) SetReady(exportID int64, data []byte, expires time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetReady", exportID, data, expires)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetReady indicates an expected call of SetReady.
+func (mr *MockrepositoryMockRecorder) SetReady(exportID, data, expires interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReady", reflect.TypeOf((*Mockrepository)(nil).SetReady), exportID, data, expires)
+}
+
+// MockusersRepository is a mock of usersRepository interface.
+type MockusersRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockusersRepositoryMockRecorder
+}
+
+// MockusersRepositoryMockRecorder is the mock recorder for MockusersRepository.
+type MockusersRepositoryMockRecorder struct {
+	mock *MockusersRepository
+}
+
+// NewMockusersRepository creates a new mock instance.
+func NewMockusersRepository(ctrl *gomock.Controller) *MockusersRepository {
+	mock := &MockusersRepository{ctrl: ctrl}
+	mock.recorder = &MockusersRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockusersRepository) EXPECT() *MockusersRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockusersRepository) Get(userID int64) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", userID)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockusersRepositoryMockRecorder) Get(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockusersRepository)(nil).Get), userID)
+}
+
+// MockoperationsRepository is a mock of operationsRepository interface.
+type MockoperationsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockoperationsRepositoryMockRecorder
+}
+
+// MockoperationsRepositoryMockRecorder is the mock recorder for MockoperationsRepository.
+type MockoperationsRepositoryMockRecorder struct {
+	mock *MockoperationsRepository
+}
+
+// NewMockoperationsRepository creates a new mock instance.
+func NewMockoperationsRepository(ctrl *gomock.Controller) *MockoperationsRepository {
+	mock := &MockoperationsRepository{ctrl: ctrl}
+	mock.recorder = &MockoperationsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockoperationsRepository) EXPECT() *MockoperationsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetByUser mocks base method.
+func (m *MockoperationsRepository) GetByUser(userID int64) ([]models.Operation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByUser", userID)
+	ret0, _ := ret[0].([]models.Operation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByUser indicates an expected call of GetByUser.
+func (mr *MockoperationsRepositoryMockRecorder) GetByUser(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUser", reflect.TypeOf((*MockoperationsRepository)(nil).GetByUser), userID)
+}
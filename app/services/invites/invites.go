@@ -0,0 +1,183 @@
+//go:generate mockgen -source=invites.go -destination=./mocks.go -package=invites
+
+package invites
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+// Mode Режим регистрации новых пользователей
+type Mode string
+
+const (
+	// ModeOpen Зарегистрироваться может любой
+	ModeOpen Mode = "open"
+	// ModeInvite Зарегистрироваться можно только по приглашению
+	ModeInvite Mode = "invite"
+	// ModeClosed Регистрация закрыта
+	ModeClosed Mode = "closed"
+)
+
+const (
+	// Время жизни приглашения
+	inviteTTL = 72 * time.Hour
+	// Размер случайной части приглашения
+	nonceSize = 16
+)
+
+var (
+	ErrInvalidMode     = errors.New("invalid registration mode")
+	ErrInvitesDisabled = errors.New("invites are disabled")
+	ErrInvalidInvite   = errors.New("invalid invite error")
+)
+
+type repository interface {
+	Create(invite *models.Invite) (int64, error)
+	Get(inviterID int64) ([]models.Invite, error)
+	GetByHash(hash string) (*models.Invite, error)
+	Revoke(inviterID, inviteID int64) error
+}
+
+// Service Сервис приглашений на регистрацию
+type Service struct {
+	repo   repository
+	mode   Mode
+	secret []byte
+	now    func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+// Секрет используется для подписи ссылок приглашений
+func New(repo repository, mode Mode, secret []byte) *Service {
+	return &Service{
+		repo:   repo,
+		mode:   mode,
+		secret: secret,
+		now:    time.Now,
+	}
+}
+
+// ParseMode Разбирает режим регистрации из конфигурации
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(value); mode {
+	case ModeOpen, ModeInvite, ModeClosed:
+		return mode, nil
+	}
+
+	return "", ErrInvalidMode
+}
+
+// Mode Возвращает режим регистрации
+func (s *Service) Mode() Mode {
+	return s.mode
+}
+
+// Create Выпускает новое одноразовое приглашение пользователя
+// Возвращает подписанный токен приглашения, в базе сохраняется только его хеш
+func (s *Service) Create(userID int64) (string, error) {
+	if s.mode != ModeInvite {
+		return "", ErrInvitesDisabled
+	}
+
+	buf := make([]byte, nonceSize)
+	if _, err := rand.Read(buf); err != nil {
+		logger.Log.WithError(err).Errorf("generate invite error")
+		return "", err
+	}
+
+	expires := s.now().Add(inviteTTL)
+	payload := hex.EncodeToString(buf) + "." + strconv.FormatInt(expires.Unix(), 10)
+	token := payload + "." + s.sign(payload)
+
+	_, err := s.repo.Create(&models.Invite{
+		InviterID: userID,
+		Hash:      hashInvite(token),
+		Expires:   expires,
+	})
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("create invite error")
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Get Возвращает список приглашений пользователя
+func (s *Service) Get(userID int64) ([]models.Invite, error) {
+	invites, err := s.repo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get invites error")
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+// Revoke Отзывает приглашение пользователя
+func (s *Service) Revoke(userID, inviteID int64) error {
+	err := s.repo.Revoke(userID, inviteID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).WithField("inviteID", inviteID).Errorf("revoke invite error")
+		return err
+	}
+
+	return nil
+}
+
+// Verify Проверяет подпись и срок действия приглашения и возвращает его
+// Подделанные и просроченные приглашения отклоняются без обращения к базе
+func (s *Service) Verify(token string) (*models.Invite, error) {
+	if s.mode != ModeInvite {
+		return nil, ErrInvitesDisabled
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidInvite
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(payload))) {
+		return nil, ErrInvalidInvite
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !s.now().Before(time.Unix(expires, 0)) {
+		return nil, ErrInvalidInvite
+	}
+
+	invite, err := s.repo.GetByHash(hashInvite(token))
+	if err != nil {
+		logger.Log.WithError(err).Errorf("get invite error")
+		return nil, ErrInvalidInvite
+	}
+
+	if !invite.Active(s.now()) {
+		return nil, ErrInvalidInvite
+	}
+
+	return invite, nil
+}
+
+// Подписывает данные приглашения секретом сервиса
+func (s *Service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Берет хеш от приглашения
+func hashInvite(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package invites
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var secret = []byte("test-secret")
+
+func TestParseMode(t *testing.T) {
+	for _, val := range []Mode{ModeOpen, ModeInvite, ModeClosed} {
+		act, err := ParseMode(string(val))
+		assert.NoError(t, err)
+		assert.Equal(t, val, act)
+	}
+
+	_, err := ParseMode("private")
+	assert.ErrorIs(t, err, ErrInvalidMode)
+}
+
+func TestService_Create_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	var saved *models.Invite
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(invite *models.Invite) (int64, error) {
+		saved = invite
+		return 1, nil
+	})
+
+	service := New(repo, ModeInvite, secret)
+	act, err := service.Create(123)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), saved.InviterID)
+	assert.Equal(t, hashInvite(act), saved.Hash)
+	assert.WithinDuration(t, time.Now().Add(inviteTTL), saved.Expires, time.Minute)
+}
+
+func TestService_Create_Disabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	for _, mode := range []Mode{ModeOpen, ModeClosed} {
+		service := New(repo, mode, secret)
+		act, err := service.Create(123)
+
+		assert.Empty(t, act)
+		assert.ErrorIs(t, err, ErrInvitesDisabled)
+	}
+}
+
+func TestService_Create_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	expErr := errors.New("test error")
+
+	repo.EXPECT().Create(gomock.Any()).Return(int64(0), expErr)
+
+	service := New(repo, ModeInvite, secret)
+	act, err := service.Create(123)
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, expErr)
+}
+
+func TestService_Verify_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	var saved *models.Invite
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(invite *models.Invite) (int64, error) {
+		saved = invite
+		return 1, nil
+	})
+
+	service := New(repo, ModeInvite, secret)
+	token, err := service.Create(123)
+	assert.NoError(t, err)
+
+	repo.EXPECT().GetByHash(hashInvite(token)).Return(saved, nil)
+
+	act, err := service.Verify(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, saved, act)
+}
+
+func TestService_Verify_Tampered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Create(gomock.Any()).Return(int64(1), nil)
+
+	service := New(repo, ModeInvite, secret)
+	token, err := service.Create(123)
+	assert.NoError(t, err)
+
+	// Продление срока действия ломает подпись, поэтому в базу сервис не обращается
+	parts := strings.Split(token, ".")
+	extended := parts[0] + "." + strconv.FormatInt(time.Now().Add(100*inviteTTL).Unix(), 10) + "." + parts[2]
+	tokens := []string{
+		"",
+		"garbage",
+		token + "0",
+		extended,
+	}
+
+	for _, val := range tokens {
+		act, err := service.Verify(val)
+		assert.Nil(t, act)
+		assert.ErrorIs(t, err, ErrInvalidInvite)
+	}
+
+	// Ссылка, подписанная другим ключом, тоже недействительна
+	other := New(repo, ModeInvite, []byte("other-secret"))
+	act, err := other.Verify(token)
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrInvalidInvite)
+}
+
+func TestService_Verify_Expired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Create(gomock.Any()).Return(int64(1), nil)
+
+	service := New(repo, ModeInvite, secret)
+	token, err := service.Create(123)
+	assert.NoError(t, err)
+
+	service.now = func() time.Time { return time.Now().Add(inviteTTL + time.Minute) }
+	act, err := service.Verify(token)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrInvalidInvite)
+}
+
+func TestService_Verify_Used(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Create(gomock.Any()).Return(int64(1), nil)
+
+	service := New(repo, ModeInvite, secret)
+	token, err := service.Create(123)
+	assert.NoError(t, err)
+
+	used := time.Now()
+	repo.EXPECT().GetByHash(hashInvite(token)).Return(&models.Invite{
+		ID:      1,
+		Expires: time.Now().Add(time.Hour),
+		Used:    &used,
+	}, nil)
+
+	act, err := service.Verify(token)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrInvalidInvite)
+}
@@ -0,0 +1,187 @@
+//go:generate mockgen -source=ledgers.go -destination=./mocks.go -package=ledgers
+
+package ledgers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/ledgers"
+)
+
+var (
+	ErrForbidden    = errors.New("ledger access forbidden error")
+	ErrNoLedgers    = errors.New("user has no ledgers error")
+	ErrUserNotFound = errors.New("user not found error")
+	ErrMemberExists = errors.New("member already exists error")
+	ErrInvalidRole  = errors.New("invalid role error")
+	ErrInvalidName  = errors.New("invalid ledger name error")
+)
+
+type repository interface {
+	Create(userID int64, name string) (int64, error)
+	Get(userID int64) ([]models.Ledger, error)
+	GetRole(ledgerID, userID int64) (models.LedgerRole, error)
+	GetMembers(ledgerID int64) ([]models.LedgerMember, error)
+	AddMember(ledgerID int64, login string, role models.LedgerRole) error
+	RemoveMember(ledgerID, userID int64) error
+}
+
+// Service Сервис управления бухгалтериями и их участниками
+type Service struct {
+	repo repository
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+func New(repo repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Create Создает бухгалтерию, владельцем которой становится пользователь
+func (s *Service) Create(userID int64, name string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, ErrInvalidName
+	}
+
+	ledgerID, err := s.repo.Create(userID, name)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("create ledger error")
+		return 0, err
+	}
+
+	return ledgerID, nil
+}
+
+// Get Возвращает бухгалтерии, в которых состоит пользователь
+func (s *Service) Get(userID int64) ([]models.Ledger, error) {
+	list, err := s.repo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get ledgers error")
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// GetLedger Возвращает бухгалтерию вместе с ролью пользователя в ней
+// Если пользователь не состоит в бухгалтерии, то возвращает models.NotFoundError
+func (s *Service) GetLedger(userID, ledgerID int64) (*models.Ledger, error) {
+	list, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range list {
+		if l.ID == ledgerID {
+			return &l, nil
+		}
+	}
+
+	logger.Log.WithField("userID", userID).WithField("ledgerID", ledgerID).Error("ledger not found error")
+	return nil, &models.NotFoundError{Entity: "ledger", ID: ledgerID}
+}
+
+// Current Возвращает выбранную пользователем бухгалтерию
+// Если бухгалтерия не выбрана или пользователь в ней больше не состоит, то возвращает первую доступную
+func (s *Service) Current(userID, ledgerID int64) (*models.Ledger, error) {
+	list, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		logger.Log.WithField("userID", userID).Errorf("user has no ledgers error")
+		return nil, ErrNoLedgers
+	}
+
+	for _, l := range list {
+		if l.ID == ledgerID {
+			return &l, nil
+		}
+	}
+
+	return &list[0], nil
+}
+
+// Members Возвращает участников бухгалтерии
+// Список доступен любому участнику
+func (s *Service) Members(userID, ledgerID int64) ([]models.LedgerMember, error) {
+	if err := s.authorize(userID, ledgerID, models.LedgerRole.CanRead); err != nil {
+		return nil, err
+	}
+
+	members, err := s.repo.GetMembers(ledgerID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("get ledger members error")
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// AddMember Добавляет в бухгалтерию пользователя с ролью редактора или наблюдателя
+// Добавлять участников может только владелец
+func (s *Service) AddMember(userID, ledgerID int64, login string, role models.LedgerRole) error {
+	if role != models.RoleEditor && role != models.RoleViewer {
+		return ErrInvalidRole
+	}
+
+	if err := s.authorize(userID, ledgerID, models.LedgerRole.CanManage); err != nil {
+		return err
+	}
+
+	err := s.repo.AddMember(ledgerID, login, role)
+	if err == ledgers.ErrUserNotFound {
+		return ErrUserNotFound
+	}
+	if err == ledgers.ErrDuplicateKey {
+		return ErrMemberExists
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("add ledger member error")
+		return err
+	}
+
+	return nil
+}
+
+// RemoveMember Исключает участника из бухгалтерии
+// Исключать участников может только владелец, сами владельцы не исключаются
+func (s *Service) RemoveMember(userID, ledgerID, memberID int64) error {
+	if err := s.authorize(userID, ledgerID, models.LedgerRole.CanManage); err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveMember(ledgerID, memberID); err != nil {
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("remove ledger member error")
+		return err
+	}
+
+	return nil
+}
+
+// Проверяет, что роль пользователя в бухгалтерии дает нужное право
+// Если пользователь не состоит в бухгалтерии, то возвращает models.NotFoundError, иначе при нехватке прав ErrForbidden
+func (s *Service) authorize(userID, ledgerID int64, allowed func(models.LedgerRole) bool) error {
+	role, err := s.repo.GetRole(ledgerID, userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("get ledger role error")
+		return err
+	}
+
+	// Для постороннего пользователя бухгалтерия неотличима от несуществующей
+	if role == "" {
+		logger.Log.WithField("userID", userID).WithField("ledgerID", ledgerID).Error("ledger not found error")
+		return &models.NotFoundError{Entity: "ledger", ID: ledgerID}
+	}
+
+	if !allowed(role) {
+		logger.Log.WithField("userID", userID).WithField("ledgerID", ledgerID).Error("ledger access forbidden error")
+		return ErrForbidden
+	}
+
+	return nil
+}
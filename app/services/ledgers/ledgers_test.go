@@ -0,0 +1,185 @@
+package ledgers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/ledgers"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	personal = models.Ledger{ID: 1, Name: "Личный бюджет", Role: models.RoleOwner}
+	shared   = models.Ledger{ID: 2, Name: "Семейный бюджет", Role: models.RoleViewer}
+)
+
+func TestService_Create_InvalidName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	service := New(repo)
+	_, err := service.Create(123, "  ")
+
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestService_Create_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Create(int64(123), shared.Name).Return(shared.ID, nil)
+
+	service := New(repo)
+	act, err := service.Create(123, " "+shared.Name+" ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, shared.ID, act)
+}
+
+func TestService_GetLedger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Get(int64(123)).Return([]models.Ledger{personal, shared}, nil).Times(2)
+
+	service := New(repo)
+
+	act, err := service.GetLedger(123, shared.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, &shared, act)
+
+	// Чужая бухгалтерия неотличима от несуществующей
+	_, err = service.GetLedger(123, 100)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestService_Current(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Get(int64(123)).Return([]models.Ledger{personal, shared}, nil).Times(2)
+
+	service := New(repo)
+
+	act, err := service.Current(123, shared.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, &shared, act)
+
+	// Если пользователь не состоит в выбранной бухгалтерии, возвращается первая доступная
+	act, err = service.Current(123, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, &personal, act)
+}
+
+func TestService_Current_NoLedgers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Get(int64(123)).Return(nil, nil)
+
+	service := New(repo)
+	_, err := service.Current(123, 0)
+
+	assert.ErrorIs(t, err, ErrNoLedgers)
+}
+
+func TestService_Members_NotMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().GetRole(shared.ID, int64(123)).Return(models.LedgerRole(""), nil)
+
+	service := New(repo)
+	_, err := service.Members(123, shared.ID)
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestService_AddMember_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().GetRole(shared.ID, int64(123)).Return(models.RoleOwner, nil)
+	repo.EXPECT().AddMember(shared.ID, "janedoe", models.RoleEditor).Return(nil)
+
+	service := New(repo)
+	err := service.AddMember(123, shared.ID, "janedoe", models.RoleEditor)
+
+	assert.NoError(t, err)
+}
+
+func TestService_AddMember_Forbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().GetRole(shared.ID, int64(123)).Return(models.RoleEditor, nil)
+
+	service := New(repo)
+	err := service.AddMember(123, shared.ID, "janedoe", models.RoleViewer)
+
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestService_AddMember_InvalidRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	service := New(repo)
+	err := service.AddMember(123, shared.ID, "janedoe", models.RoleOwner)
+
+	assert.ErrorIs(t, err, ErrInvalidRole)
+}
+
+func TestService_AddMember_Errors(t *testing.T) {
+	cases := []struct {
+		repoErr error
+		expErr  error
+	}{
+		{repoErr: ledgers.ErrUserNotFound, expErr: ErrUserNotFound},
+		{repoErr: ledgers.ErrDuplicateKey, expErr: ErrMemberExists},
+		{repoErr: errors.New("test error"), expErr: nil},
+	}
+
+	for _, c := range cases {
+		ctrl := gomock.NewController(t)
+		repo := NewMockrepository(ctrl)
+
+		repo.EXPECT().GetRole(shared.ID, int64(123)).Return(models.RoleOwner, nil)
+		repo.EXPECT().AddMember(shared.ID, "janedoe", models.RoleViewer).Return(c.repoErr)
+
+		service := New(repo)
+		err := service.AddMember(123, shared.ID, "janedoe", models.RoleViewer)
+
+		if c.expErr == nil {
+			assert.ErrorIs(t, err, c.repoErr)
+		} else {
+			assert.ErrorIs(t, err, c.expErr)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestService_RemoveMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().GetRole(shared.ID, int64(123)).Return(models.RoleOwner, nil)
+	repo.EXPECT().RemoveMember(shared.ID, int64(456)).Return(nil)
+
+	service := New(repo)
+	err := service.RemoveMember(123, shared.ID, 456)
+
+	assert.NoError(t, err)
+}
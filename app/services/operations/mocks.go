@@ -35,11 +35,12 @@ func (m *Mockrepository) EXPECT() *MockrepositoryMockRecorder {
 }
 
 // Create mocks base method.
-func (m *Mockrepository) Create(operation *models.Operation) error {
+func (m *Mockrepository) Create(operation *models.Operation) (int64, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Create", operation)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
@@ -49,30 +50,69 @@ func (mr *MockrepositoryMockRecorder) Create(operation interface{}) *gomock.Call
 }
 
 // Get mocks base method.
-func (m *Mockrepository) Get(userID, page, size int64) (*models.OperationPaginator, error) {
+func (m *Mockrepository) Get(ledgerID, page, size int64) (*models.OperationPaginator, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", userID, page, size)
+	ret := m.ctrl.Call(m, "Get", ledgerID, page, size)
 	ret0, _ := ret[0].(*models.OperationPaginator)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get.
-func (mr *MockrepositoryMockRecorder) Get(userID, page, size interface{}) *gomock.Call {
+func (mr *MockrepositoryMockRecorder) Get(ledgerID, page, size interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*Mockrepository)(nil).Get), userID, page, size)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*Mockrepository)(nil).Get), ledgerID, page, size)
 }
 
 // Remove mocks base method.
-func (m *Mockrepository) Remove(operationID int64) error {
+func (m *Mockrepository) Remove(ledgerID, operationID int64) (*models.Operation, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Remove", operationID)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Remove", ledgerID, operationID)
+	ret0, _ := ret[0].(*models.Operation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Remove indicates an expected call of Remove.
-func (mr *MockrepositoryMockRecorder) Remove(operationID interface{}) *gomock.Call {
+func (mr *MockrepositoryMockRecorder) Remove(ledgerID, operationID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*Mockrepository)(nil).Remove), ledgerID, operationID)
+}
+
+// MockledgersRepository is a mock of ledgersRepository interface.
+type MockledgersRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockledgersRepositoryMockRecorder
+}
+
+// MockledgersRepositoryMockRecorder is the mock recorder for MockledgersRepository.
+type MockledgersRepositoryMockRecorder struct {
+	mock *MockledgersRepository
+}
+
+// NewMockledgersRepository creates a new mock instance.
+func NewMockledgersRepository(ctrl *gomock.Controller) *MockledgersRepository {
+	mock := &MockledgersRepository{ctrl: ctrl}
+	mock.recorder = &MockledgersRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockledgersRepository) EXPECT() *MockledgersRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetRole mocks base method.
+func (m *MockledgersRepository) GetRole(ledgerID, userID int64) (models.LedgerRole, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRole", ledgerID, userID)
+	ret0, _ := ret[0].(models.LedgerRole)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRole indicates an expected call of GetRole.
+func (mr *MockledgersRepositoryMockRecorder) GetRole(ledgerID, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*Mockrepository)(nil).Remove), operationID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRole", reflect.TypeOf((*MockledgersRepository)(nil).GetRole), ledgerID, userID)
 }
@@ -3,6 +3,8 @@
 package operations
 
 import (
+	"errors"
+
 	"github.com/bgoldovsky/casher/app/logger"
 	"github.com/bgoldovsky/casher/app/models"
 )
@@ -11,60 +13,135 @@ const (
 	pageSize = 5
 )
 
+var (
+	ErrForbidden = errors.New("operations access forbidden error")
+)
+
 type repository interface {
-	Create(operation *models.Operation) error
-	Remove(operationID int64) error
-	Get(userID, page, size int64) (*models.OperationPaginator, error)
+	Create(operation *models.Operation) (int64, error)
+	Remove(ledgerID, operationID int64) (*models.Operation, error)
+	Get(ledgerID, page, size int64) (*models.OperationPaginator, error)
+}
+
+type ledgersRepository interface {
+	GetRole(ledgerID, userID int64) (models.LedgerRole, error)
 }
 
 // Service Сервис управления финансовыми операциями
+// Операции принадлежат бухгалтерии, доступ к ним определяется ролью пользователя в бухгалтерии
+// События об изменении операций записываются репозиторием в outbox в одной транзакции с изменением
 type Service struct {
-	repo repository
+	repo        repository
+	ledgersRepo ledgersRepository
 }
 
 // New Возвращает инициализированный экземпляр сервиса
-func New(repo repository) *Service {
-	return &Service{repo: repo}
+func New(repo repository, ledgersRepo ledgersRepository) *Service {
+	return &Service{
+		repo:        repo,
+		ledgersRepo: ledgersRepo,
+	}
 }
 
-// Get Возвращает список операций с пагинацией
+// Get Возвращает список операций бухгалтерии с пагинацией
 // Если параметры пагинации не указаны, то вернет все операции
-func (s *Service) Get(userID int64, page int64) (*models.OperationPaginator, error) {
-	paginator, err := s.repo.Get(userID, page, pageSize)
+func (s *Service) Get(userID, ledgerID int64, page int64) (*models.OperationPaginator, error) {
+	if err := s.authorize(userID, ledgerID, models.LedgerRole.CanRead); err != nil {
+		return nil, err
+	}
+
+	paginator, err := s.repo.Get(ledgerID, page, pageSize)
 	if err != nil {
-		logger.Log.WithError(err).WithField("userID", userID).Errorf("get paginator error")
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("get paginator error")
 		return nil, err
 	}
 
 	return paginator, nil
 }
 
-// Create Создает новую операцию
-func (s *Service) Create(userID int64, subject string, amount int64, operationType models.OperationType, msg string) error {
+// Balance Возвращает баланс бухгалтерии
+func (s *Service) Balance(userID, ledgerID int64) (int64, error) {
+	if err := s.authorize(userID, ledgerID, models.LedgerRole.CanRead); err != nil {
+		return 0, err
+	}
+
+	paginator, err := s.repo.Get(ledgerID, 0, 0)
+	if err != nil {
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("get balance error")
+		return 0, err
+	}
+
+	balance := int64(0)
+	for _, o := range paginator.Operations {
+		if o.Type == models.Deposit {
+			balance += o.Amount
+		} else {
+			balance -= o.Amount
+		}
+	}
+
+	return balance, nil
+}
+
+// Create Создает новую операцию в бухгалтерии и возвращает ее ID
+func (s *Service) Create(userID, ledgerID int64, subject string, amount int64, operationType models.OperationType, msg string) (int64, error) {
+	if err := s.authorize(userID, ledgerID, models.LedgerRole.CanWrite); err != nil {
+		return 0, err
+	}
+
 	operation := &models.Operation{
-		UserID:  userID,
-		Subject: subject,
-		Amount:  amount,
-		Type:    operationType,
-		Message: msg,
+		LedgerID: ledgerID,
+		UserID:   userID,
+		Subject:  subject,
+		Amount:   amount,
+		Type:     operationType,
+		Message:  msg,
 	}
 
-	err := s.repo.Create(operation)
+	operationID, err := s.repo.Create(operation)
 	if err != nil {
 		logger.Log.WithError(err).WithField("operation", operation).Errorf("create operations error")
-		return err
+		return 0, err
 	}
 
-	return nil
+	return operationID, nil
 }
 
-// Remove Удаляет операцию
-func (s *Service) Remove(operationID int64) error {
-	err := s.repo.Remove(operationID)
+// Remove Удаляет операцию бухгалтерии и возвращает ее состояние до удаления
+// Если операции нет в бухгалтерии, то возвращает models.NotFoundError
+func (s *Service) Remove(userID, ledgerID, operationID int64) (*models.Operation, error) {
+	if err := s.authorize(userID, ledgerID, models.LedgerRole.CanWrite); err != nil {
+		return nil, err
+	}
+
+	operation, err := s.repo.Remove(ledgerID, operationID)
 	if err != nil {
 		logger.Log.WithError(err).WithField("operationID", operationID).Errorf("remove operations error")
+		return nil, err
+	}
+
+	return operation, nil
+}
+
+// Проверяет, что роль пользователя в бухгалтерии дает нужное право
+// Если пользователь не состоит в бухгалтерии, то возвращает models.NotFoundError, иначе при нехватке прав ErrForbidden
+func (s *Service) authorize(userID, ledgerID int64, allowed func(models.LedgerRole) bool) error {
+	role, err := s.ledgersRepo.GetRole(ledgerID, userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("get ledger role error")
 		return err
 	}
 
+	// Для постороннего пользователя бухгалтерия неотличима от несуществующей
+	if role == "" {
+		logger.Log.WithField("userID", userID).WithField("ledgerID", ledgerID).Error("ledger not found error")
+		return &models.NotFoundError{Entity: "ledger", ID: ledgerID}
+	}
+
+	if !allowed(role) {
+		logger.Log.WithField("userID", userID).WithField("ledgerID", ledgerID).Error("operations access forbidden error")
+		return ErrForbidden
+	}
+
 	return nil
 }
@@ -11,11 +11,12 @@ import (
 
 var (
 	operation = models.Operation{
-		UserID:  123,
-		Subject: "test-subj",
-		Amount:  1000,
-		Type:    1,
-		Message: "test-msg",
+		LedgerID: 7,
+		UserID:   123,
+		Subject:  "test-subj",
+		Amount:   1000,
+		Type:     1,
+		Message:  "test-msg",
 	}
 )
 
@@ -23,14 +24,17 @@ func TestService_Create_Error(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
 
 	expErr := errors.New("test error")
 
-	repo.EXPECT().Create(&operation).Return(expErr)
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleEditor, nil)
+	repo.EXPECT().Create(&operation).Return(int64(0), expErr)
 
-	service := New(repo)
-	err := service.Create(operation.UserID, operation.Subject, operation.Amount, operation.Type, operation.Message)
+	service := New(repo, ledgersRepo)
+	act, err := service.Create(operation.UserID, operation.LedgerID, operation.Subject, operation.Amount, operation.Type, operation.Message)
 
+	assert.Empty(t, act)
 	assert.ErrorIs(t, err, expErr)
 }
 
@@ -38,26 +42,47 @@ func TestService_Create_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
 
-	repo.EXPECT().Create(&operation).Return(nil)
+	expID := int64(55)
 
-	service := New(repo)
-	err := service.Create(operation.UserID, operation.Subject, operation.Amount, operation.Type, operation.Message)
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleOwner, nil)
+	repo.EXPECT().Create(&operation).Return(expID, nil)
 
+	service := New(repo, ledgersRepo)
+	act, err := service.Create(operation.UserID, operation.LedgerID, operation.Subject, operation.Amount, operation.Type, operation.Message)
+
+	assert.Equal(t, expID, act)
 	assert.NoError(t, err)
 }
 
+func TestService_Create_Forbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
+
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleViewer, nil)
+
+	service := New(repo, ledgersRepo)
+	_, err := service.Create(operation.UserID, operation.LedgerID, operation.Subject, operation.Amount, operation.Type, operation.Message)
+
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
 func TestService_Remove_Error(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
 
 	expErr := errors.New("test error")
 
-	repo.EXPECT().Remove(gomock.Any()).Return(expErr)
+	ledgersRepo.EXPECT().GetRole(gomock.Any(), gomock.Any()).Return(models.RoleEditor, nil)
+	repo.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(nil, expErr)
 
-	service := New(repo)
-	err := service.Remove(operation.ID)
+	service := New(repo, ledgersRepo)
+	_, err := service.Remove(operation.UserID, operation.LedgerID, operation.ID)
 
 	assert.ErrorIs(t, err, expErr)
 }
@@ -66,26 +91,75 @@ func TestService_Remove_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
 
-	repo.EXPECT().Remove(gomock.Any()).Return(nil)
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleEditor, nil)
+	repo.EXPECT().Remove(operation.LedgerID, operation.ID).Return(&operation, nil)
 
-	service := New(repo)
-	err := service.Remove(operation.ID)
+	service := New(repo, ledgersRepo)
+	act, err := service.Remove(operation.UserID, operation.LedgerID, operation.ID)
 
 	assert.NoError(t, err)
+	assert.Equal(t, &operation, act)
+}
+
+func TestService_Remove_Forbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
+
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleViewer, nil)
+
+	service := New(repo, ledgersRepo)
+	_, err := service.Remove(operation.UserID, operation.LedgerID, operation.ID)
+
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestService_Remove_Stranger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
+
+	// Операцию в чужой бухгалтерии нельзя удалить, даже зная ее идентификатор
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, int64(999)).Return(models.LedgerRole(""), nil)
+
+	service := New(repo, ledgersRepo)
+	_, err := service.Remove(999, operation.LedgerID, operation.ID)
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestService_Remove_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
+
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleEditor, nil)
+	repo.EXPECT().Remove(operation.LedgerID, operation.ID).Return(nil, &models.NotFoundError{Entity: "operation", ID: operation.ID})
+
+	service := New(repo, ledgersRepo)
+	_, err := service.Remove(operation.UserID, operation.LedgerID, operation.ID)
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
 }
 
 func TestService_Get_Error(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
 
 	expErr := errors.New("test error")
 
-	repo.EXPECT().Get(operation.ID, int64(1), int64(5)).Return(nil, expErr)
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleViewer, nil)
+	repo.EXPECT().Get(operation.LedgerID, int64(1), int64(5)).Return(nil, expErr)
 
-	service := New(repo)
-	paginator, err := service.Get(operation.ID, 1)
+	service := New(repo, ledgersRepo)
+	paginator, err := service.Get(operation.UserID, operation.LedgerID, 1)
 
 	assert.Nil(t, paginator)
 	assert.ErrorIs(t, err, expErr)
@@ -95,17 +169,56 @@ func TestService_Get_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
 
 	exp := &models.OperationPaginator{
 		Operations: []models.Operation{operation},
 		HasMore:    false,
 	}
 
-	repo.EXPECT().Get(operation.ID, int64(1), int64(5)).Return(exp, nil)
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleViewer, nil)
+	repo.EXPECT().Get(operation.LedgerID, int64(1), int64(5)).Return(exp, nil)
 
-	service := New(repo)
-	act, err := service.Get(operation.ID, 1)
+	service := New(repo, ledgersRepo)
+	act, err := service.Get(operation.UserID, operation.LedgerID, 1)
 
 	assert.Equal(t, exp, act)
 	assert.NoError(t, err)
 }
+
+func TestService_Get_NotMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
+
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.LedgerRole(""), nil)
+
+	service := New(repo, ledgersRepo)
+	_, err := service.Get(operation.UserID, operation.LedgerID, 1)
+
+	// Посторонний пользователь не узнает, существует ли бухгалтерия
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestService_Balance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	ledgersRepo := NewMockledgersRepository(ctrl)
+
+	withdraw := operation
+	withdraw.Type = models.Withdraw
+	withdraw.Amount = 300
+
+	ledgersRepo.EXPECT().GetRole(operation.LedgerID, operation.UserID).Return(models.RoleViewer, nil)
+	repo.EXPECT().Get(operation.LedgerID, int64(0), int64(0)).Return(&models.OperationPaginator{
+		Operations: []models.Operation{operation, withdraw},
+	}, nil)
+
+	service := New(repo, ledgersRepo)
+	act, err := service.Balance(operation.UserID, operation.LedgerID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(700), act)
+}
@@ -0,0 +1,93 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: outbox.go
+
+// Package outbox is a generated GoMock package.
+package outbox
+
+import (
+	reflect "reflect"
+	time "time"
+
+	models "github.com/bgoldovsky/casher/app/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockrepository is a mock of repository interface.
+type Mockrepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockrepositoryMockRecorder
+}
+
+// MockrepositoryMockRecorder is the mock recorder for Mockrepository.
+type MockrepositoryMockRecorder struct {
+	mock *Mockrepository
+}
+
+// NewMockrepository creates a new mock instance.
+func NewMockrepository(ctrl *gomock.Controller) *Mockrepository {
+	mock := &Mockrepository{ctrl: ctrl}
+	mock.recorder = &MockrepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockrepository) EXPECT() *MockrepositoryMockRecorder {
+	return m.recorder
+}
+
+// Claim mocks base method.
+func (m *Mockrepository) Claim(limit int64, lease time.Duration) ([]models.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Claim", limit, lease)
+	ret0, _ := ret[0].([]models.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Claim indicates an expected call of Claim.
+func (mr *MockrepositoryMockRecorder) Claim(limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*Mockrepository)(nil).Claim), limit, lease)
+}
+
+// Complete mocks base method.
+func (m *Mockrepository) Complete(eventID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", eventID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockrepositoryMockRecorder) Complete(eventID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*Mockrepository)(nil).Complete), eventID)
+}
+
+// Fail mocks base method.
+func (m *Mockrepository) Fail(eventID int64, lastError string, maxAttempts int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", eventID, lastError, maxAttempts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockrepositoryMockRecorder) Fail(eventID, lastError, maxAttempts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*Mockrepository)(nil).Fail), eventID, lastError, maxAttempts)
+}
+
+// MarkHandled mocks base method.
+func (m *Mockrepository) MarkHandled(eventID int64, handler string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkHandled", eventID, handler)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkHandled indicates an expected call of MarkHandled.
+func (mr *MockrepositoryMockRecorder) MarkHandled(eventID, handler interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkHandled", reflect.TypeOf((*Mockrepository)(nil).MarkHandled), eventID, handler)
+}
@@ -0,0 +1,163 @@
+//go:generate mockgen -source=outbox.go -destination=./mocks.go -package=outbox
+
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+const (
+	batchSize = 10
+	// Время, на которое событие забирается диспетчером, должно покрывать обработку всей пачки
+	eventLease = 5 * time.Minute
+	// Число неудачных попыток, после которого событие больше не обрабатывается и ждет разбора вручную
+	maxAttempts = 10
+)
+
+type repository interface {
+	Claim(limit int64, lease time.Duration) ([]models.Event, error)
+	MarkHandled(eventID int64, handler string) error
+	Complete(eventID int64) error
+	Fail(eventID int64, lastError string, maxAttempts int) error
+}
+
+// Handler Обработчик доменного события
+// Событие может быть доставлено повторно, если обработчик не успел отметить обработку или не уложился в аренду,
+// поэтому обработчик должен быть идемпотентным или терпимым к дублям
+type Handler func(event *models.Event) error
+
+// Обработчик вместе с именем, под которым отмечается выполненная обработка
+type namedHandler struct {
+	name   string
+	handle Handler
+}
+
+// Dispatcher Рассылает события из outbox зарегистрированным обработчикам
+type Dispatcher struct {
+	repo     repository
+	handlers map[string][]namedHandler
+}
+
+// New Возвращает инициализированный экземпляр диспетчера
+func New(repo repository) *Dispatcher {
+	return &Dispatcher{
+		repo:     repo,
+		handlers: map[string][]namedHandler{},
+	}
+}
+
+// Register Подписывает обработчик на события указанного типа
+// Имя отличает обработчик от остальных обработчиков события и не должно меняться между запусками
+// Обработчики регистрируются до запуска диспетчера
+func (d *Dispatcher) Register(eventType, name string, handler Handler) {
+	d.handlers[eventType] = append(d.handlers[eventType], namedHandler{name: name, handle: handler})
+}
+
+// Run Периодически рассылает новые события, пока не будет отменен контекст
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = d.Dispatch()
+		}
+	}
+}
+
+// Dispatch Рассылает готовые к обработке события, пока они не закончатся
+// События забираются в аренду и обрабатываются вне транзакции, успех каждого обработчика сохраняется отдельно,
+// поэтому ошибка одного обработчика повторяет только его, а не все обработчики события
+// Событие считается обработанным, только если его обработали все подписчики
+func (d *Dispatcher) Dispatch() error {
+	for {
+		events, err := d.repo.Claim(batchSize, eventLease)
+		if err != nil {
+			logger.Log.WithError(err).Errorf("claim outbox events error")
+			return err
+		}
+
+		for i := range events {
+			if err := d.publish(&events[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < batchSize {
+			return nil
+		}
+	}
+}
+
+// Передает событие обработчикам его типа, которые еще не обработали его, и сохраняет результат
+// Возвращает только ошибку сохранения, ошибка обработчика откладывает событие до следующей попытки
+func (d *Dispatcher) publish(event *models.Event) error {
+	var failures []string
+	for _, h := range d.handlers[event.Type] {
+		if handled(event, h.name) {
+			continue
+		}
+
+		if err := h.handle(event); err != nil {
+			logger.Log.WithError(err).WithField("eventID", event.ID).WithField("handler", h.name).Errorf("handle event error")
+			failures = append(failures, fmt.Sprintf("%s: %v", h.name, err))
+			continue
+		}
+
+		if err := d.repo.MarkHandled(event.ID, h.name); err != nil {
+			logger.Log.WithError(err).WithField("eventID", event.ID).WithField("handler", h.name).Errorf("mark event handled error")
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		lastError := strings.Join(failures, "; ")
+		if err := d.repo.Fail(event.ID, lastError, maxAttempts); err != nil {
+			logger.Log.WithError(err).WithField("eventID", event.ID).Errorf("postpone event error")
+			return err
+		}
+
+		if event.Attempts+1 >= maxAttempts {
+			logger.Log.WithField("eventID", event.ID).WithField("lastError", lastError).Errorf("event is dead after %d attempts", maxAttempts)
+		}
+		return nil
+	}
+
+	if err := d.repo.Complete(event.ID); err != nil {
+		logger.Log.WithError(err).WithField("eventID", event.ID).Errorf("complete event error")
+		return err
+	}
+
+	return nil
+}
+
+// Проверяет, что обработчик уже успешно обработал событие
+func handled(event *models.Event, name string) bool {
+	for _, val := range event.Handled {
+		if val == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Log Обработчик, записывающий событие в лог
+func Log(event *models.Event) error {
+	logger.Log.
+		WithField("eventID", event.ID).
+		WithField("type", event.Type).
+		WithField("payload", string(event.Payload)).
+		Info("domain event")
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	event = models.Event{
+		ID:      1,
+		Type:    models.EventOperationCreated,
+		Payload: []byte(`{"id":1}`),
+	}
+)
+
+func TestDispatcher_Dispatch_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().Claim(int64(batchSize), eventLease).Return([]models.Event{event}, nil)
+	repo.EXPECT().MarkHandled(event.ID, "first").Return(nil)
+	repo.EXPECT().MarkHandled(event.ID, "second").Return(nil)
+	repo.EXPECT().Complete(event.ID).Return(nil)
+
+	var first, second []int64
+	dispatcher := New(repo)
+	dispatcher.Register(models.EventOperationCreated, "first", func(e *models.Event) error {
+		first = append(first, e.ID)
+		return nil
+	})
+	dispatcher.Register(models.EventOperationCreated, "second", func(e *models.Event) error {
+		second = append(second, e.ID)
+		return nil
+	})
+	dispatcher.Register(models.EventOperationDeleted, "deleted", func(e *models.Event) error {
+		t.Error("unexpected handler call")
+		return nil
+	})
+
+	err := dispatcher.Dispatch()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, first)
+	assert.Equal(t, []int64{1}, second)
+}
+
+func TestDispatcher_Dispatch_HandlerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	expErr := errors.New("test error")
+
+	// Ошибка одного обработчика не мешает остальным, а событие откладывается до следующей попытки
+	repo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return([]models.Event{event}, nil)
+	repo.EXPECT().MarkHandled(event.ID, "second").Return(nil)
+	repo.EXPECT().Fail(event.ID, "first: test error", maxAttempts).Return(nil)
+
+	var second int
+	dispatcher := New(repo)
+	dispatcher.Register(models.EventOperationCreated, "first", func(*models.Event) error {
+		return expErr
+	})
+	dispatcher.Register(models.EventOperationCreated, "second", func(*models.Event) error {
+		second++
+		return nil
+	})
+
+	err := dispatcher.Dispatch()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, second)
+}
+
+func TestDispatcher_Dispatch_LastAttempt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	dead := event
+	dead.Attempts = maxAttempts - 1
+
+	// Последняя неудачная попытка тоже передается в репозиторий, который помечает событие мертвым
+	repo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return([]models.Event{dead}, nil)
+	repo.EXPECT().Fail(dead.ID, "first: test error", maxAttempts).Return(nil)
+
+	dispatcher := New(repo)
+	dispatcher.Register(models.EventOperationCreated, "first", func(*models.Event) error {
+		return errors.New("test error")
+	})
+
+	err := dispatcher.Dispatch()
+
+	assert.NoError(t, err)
+}
+
+func TestDispatcher_Dispatch_Retry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	// При повторной попытке вызывается только обработчик, который не справился в прошлый раз
+	retried := event
+	retried.Handled = []string{"first"}
+	repo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return([]models.Event{retried}, nil)
+	repo.EXPECT().MarkHandled(event.ID, "second").Return(nil)
+	repo.EXPECT().Complete(event.ID).Return(nil)
+
+	dispatcher := New(repo)
+	dispatcher.Register(models.EventOperationCreated, "first", func(*models.Event) error {
+		t.Error("unexpected handler call")
+		return nil
+	})
+	dispatcher.Register(models.EventOperationCreated, "second", func(*models.Event) error {
+		return nil
+	})
+
+	assert.NoError(t, dispatcher.Dispatch())
+}
+
+func TestDispatcher_Dispatch_FullBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	batch := make([]models.Event, batchSize)
+	for i := range batch {
+		batch[i] = models.Event{ID: int64(i + 1), Type: models.EventOperationCreated}
+	}
+
+	gomock.InOrder(
+		repo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return(batch, nil),
+		repo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return(nil, nil),
+	)
+	repo.EXPECT().Complete(gomock.Any()).Return(nil).Times(batchSize)
+
+	dispatcher := New(repo)
+	err := dispatcher.Dispatch()
+
+	assert.NoError(t, err)
+}
+
+func TestDispatcher_Dispatch_RepositoryError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	expErr := errors.New("test error")
+
+	repo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return(nil, expErr)
+
+	dispatcher := New(repo)
+	err := dispatcher.Dispatch()
+
+	assert.ErrorIs(t, err, expErr)
+}
+
+func TestDispatcher_Dispatch_MarkHandledError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	expErr := errors.New("test error")
+
+	repo.EXPECT().Claim(gomock.Any(), gomock.Any()).Return([]models.Event{event}, nil)
+	repo.EXPECT().MarkHandled(event.ID, "log").Return(expErr)
+
+	dispatcher := New(repo)
+	dispatcher.Register(models.EventOperationCreated, "log", Log)
+	err := dispatcher.Dispatch()
+
+	assert.ErrorIs(t, err, expErr)
+}
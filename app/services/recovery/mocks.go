@@ -0,0 +1,194 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: recovery.go
+
+// Package recovery is a generated GoMock package.
+package recovery
+
+import (
+	reflect "reflect"
+
+	mailer "github.com/bgoldovsky/casher/app/mailer"
+	models "github.com/bgoldovsky/casher/app/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockusersRepository is a mock of usersRepository interface.
+type MockusersRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockusersRepositoryMockRecorder
+}
+
+// MockusersRepositoryMockRecorder is the mock recorder for MockusersRepository.
+type MockusersRepositoryMockRecorder struct {
+	mock *MockusersRepository
+}
+
+// NewMockusersRepository creates a new mock instance.
+func NewMockusersRepository(ctrl *gomock.Controller) *MockusersRepository {
+	mock := &MockusersRepository{ctrl: ctrl}
+	mock.recorder = &MockusersRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockusersRepository) EXPECT() *MockusersRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetByEmail mocks base method.
+func (m *MockusersRepository) GetByEmail(email string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByEmail", email)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByEmail indicates an expected call of GetByEmail.
+func (mr *MockusersRepositoryMockRecorder) GetByEmail(email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockusersRepository)(nil).GetByEmail), email)
+}
+
+// MockresetsRepository is a mock of resetsRepository interface.
+type MockresetsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockresetsRepositoryMockRecorder
+}
+
+// MockresetsRepositoryMockRecorder is the mock recorder for MockresetsRepository.
+type MockresetsRepositoryMockRecorder struct {
+	mock *MockresetsRepository
+}
+
+// NewMockresetsRepository creates a new mock instance.
+func NewMockresetsRepository(ctrl *gomock.Controller) *MockresetsRepository {
+	mock := &MockresetsRepository{ctrl: ctrl}
+	mock.recorder = &MockresetsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockresetsRepository) EXPECT() *MockresetsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockresetsRepository) Create(reset *models.PasswordReset) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", reset)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockresetsRepositoryMockRecorder) Create(reset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockresetsRepository)(nil).Create), reset)
+}
+
+// GetByHash mocks base method.
+func (m *MockresetsRepository) GetByHash(hash string) (*models.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByHash", hash)
+	ret0, _ := ret[0].(*models.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByHash indicates an expected call of GetByHash.
+func (mr *MockresetsRepositoryMockRecorder) GetByHash(hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByHash", reflect.TypeOf((*MockresetsRepository)(nil).GetByHash), hash)
+}
+
+// Reset mocks base method.
+func (m *MockresetsRepository) Reset(resetID int64, password string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", resetID, password)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockresetsRepositoryMockRecorder) Reset(resetID, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockresetsRepository)(nil).Reset), resetID, password)
+}
+
+// Mocksender is a mock of sender interface.
+type Mocksender struct {
+	ctrl     *gomock.Controller
+	recorder *MocksenderMockRecorder
+}
+
+// MocksenderMockRecorder is the mock recorder for Mocksender.
+type MocksenderMockRecorder struct {
+	mock *Mocksender
+}
+
+// NewMocksender creates a new mock instance.
+func NewMocksender(ctrl *gomock.Controller) *Mocksender {
+	mock := &Mocksender{ctrl: ctrl}
+	mock.recorder = &MocksenderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mocksender) EXPECT() *MocksenderMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *Mocksender) Send(msg *mailer.Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", msg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MocksenderMockRecorder) Send(msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*Mocksender)(nil).Send), msg)
+}
+
+// Mockhasher is a mock of hasher interface.
+type Mockhasher struct {
+	ctrl     *gomock.Controller
+	recorder *MockhasherMockRecorder
+}
+
+// MockhasherMockRecorder is the mock recorder for Mockhasher.
+type MockhasherMockRecorder struct {
+	mock *Mockhasher
+}
+
+// NewMockhasher creates a new mock instance.
+func NewMockhasher(ctrl *gomock.Controller) *Mockhasher {
+	mock := &Mockhasher{ctrl: ctrl}
+	mock.recorder = &MockhasherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockhasher) EXPECT() *MockhasherMockRecorder {
+	return m.recorder
+}
+
+// Hash mocks base method.
+func (m *Mockhasher) Hash(password string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hash", password)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hash indicates an expected call of Hash.
+func (mr *MockhasherMockRecorder) Hash(password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hash", reflect.TypeOf((*Mockhasher)(nil).Hash), password)
+}
@@ -0,0 +1,184 @@
+//go:generate mockgen -source=recovery.go -destination=./mocks.go -package=recovery
+
+package recovery
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/mailer"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/resets"
+)
+
+const (
+	// Время жизни ссылки восстановления пароля
+	resetTTL = time.Hour
+	// Размер токена восстановления пароля
+	tokenSize = 32
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid password reset token error")
+)
+
+type usersRepository interface {
+	GetByEmail(email string) (*models.User, error)
+}
+
+type resetsRepository interface {
+	Create(reset *models.PasswordReset) (int64, error)
+	GetByHash(hash string) (*models.PasswordReset, error)
+	Reset(resetID int64, password string) (int64, error)
+}
+
+type sender interface {
+	Send(msg *mailer.Message) error
+}
+
+type hasher interface {
+	Hash(password string) (string, error)
+}
+
+// Service Сервис восстановления пароля по электронной почте
+type Service struct {
+	usersRepo  usersRepository
+	resetsRepo resetsRepository
+	mailer     sender
+	passwords  hasher
+	baseURL    string
+	now        func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+// Ссылка в письме строится от адреса приложения из конфигурации, а не от заголовка Host запроса,
+// что бы злоумышленник не мог подменить ее на свой сайт
+func New(usersRepo usersRepository, resetsRepo resetsRepository, mailer sender, passwords hasher, baseURL string) *Service {
+	return &Service{
+		usersRepo:  usersRepo,
+		resetsRepo: resetsRepo,
+		mailer:     mailer,
+		passwords:  passwords,
+		baseURL:    baseURL,
+		now:        time.Now,
+	}
+}
+
+// Request Отправляет пользователю письмо со ссылкой для смены пароля
+// Если адрес не найден, не подтвержден или аккаунт заблокирован, письмо не отправляется, а ошибка не возвращается,
+// что бы по ответу нельзя было узнать, зарегистрирован ли адрес
+func (s *Service) Request(email string) error {
+	user, err := s.usersRepo.GetByEmail(email)
+	if err != nil {
+		logger.Log.WithError(err).WithField("email", email).Errorf("get user by email error")
+		return nil
+	}
+
+	if user.Disabled != nil {
+		logger.Log.WithField("userID", user.ID).Errorf("password reset error: user disabled")
+		return nil
+	}
+
+	// Письмо отправляется только на подтвержденный адрес, иначе чужой адрес, указанный при регистрации,
+	// позволил бы перехватить управление аккаунтом
+	if !user.HasVerifiedEmail() {
+		logger.Log.WithField("userID", user.ID).Errorf("password reset error: email not verified")
+		return nil
+	}
+
+	buf := make([]byte, tokenSize)
+	if _, err = rand.Read(buf); err != nil {
+		logger.Log.WithError(err).Errorf("generate password reset token error")
+		return err
+	}
+	token := hex.EncodeToString(buf)
+
+	_, err = s.resetsRepo.Create(&models.PasswordReset{
+		UserID:  user.ID,
+		Hash:    hashToken(token),
+		Expires: s.now().Add(resetTTL),
+	})
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", user.ID).Errorf("create password reset error")
+		return err
+	}
+
+	link := fmt.Sprintf("%s/password/reset/?token=%s", s.baseURL, url.QueryEscape(token))
+	err = s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Восстановление пароля Casher",
+		Body: fmt.Sprintf(
+			"Здравствуйте, %s!\n\nДля смены пароля перейдите по ссылке:\n%s\n\nСсылка действует %d минут и может быть использована один раз.\nЕсли вы не запрашивали смену пароля, просто проигнорируйте это письмо.\n",
+			user.Name, link, int(resetTTL.Minutes()),
+		),
+	})
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", user.ID).Errorf("send password reset error")
+		return err
+	}
+
+	return nil
+}
+
+// Verify Проверяет, что токеном восстановления можно сменить пароль
+func (s *Service) Verify(token string) error {
+	_, err := s.get(token)
+	return err
+}
+
+// Reset Меняет пароль пользователя по токену восстановления и возвращает ID пользователя
+func (s *Service) Reset(token, password string) (int64, error) {
+	reset, err := s.get(token)
+	if err != nil {
+		return 0, err
+	}
+
+	hashedPassword, err := s.passwords.Hash(password)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("hash password error")
+		return 0, err
+	}
+
+	userID, err := s.resetsRepo.Reset(reset.ID, hashedPassword)
+	if err == resets.ErrResetUnavailable {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("resetID", reset.ID).Errorf("reset password error")
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Возвращает действующий токен восстановления
+func (s *Service) get(token string) (*models.PasswordReset, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	reset, err := s.resetsRepo.GetByHash(hashToken(token))
+	if err != nil {
+		logger.Log.WithError(err).Errorf("get password reset error")
+		return nil, ErrInvalidToken
+	}
+
+	if !reset.Active(s.now()) {
+		return nil, ErrInvalidToken
+	}
+
+	return reset, nil
+}
+
+// Берет хеш от токена
+// Токен содержит достаточно случайных данных, поэтому соль и медленный хеш не нужны
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,186 @@
+package recovery
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/mailer"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/passwords"
+	"github.com/bgoldovsky/casher/app/repositories/resets"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseURL = "https://casher.example.com"
+
+var (
+	// Легкие параметры, что бы тесты не тратили время на хеширование
+	testPasswords = passwords.New(passwords.NewArgon2id(passwords.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}))
+
+	verified = time.Now().Add(-time.Hour)
+	user     = models.User{ID: 55, Login: "jondoe", Email: "jondoe@example.com", EmailVerified: &verified, Name: "Jon Doe"}
+)
+
+type testService struct {
+	users   *MockusersRepository
+	resets  *MockresetsRepository
+	mailer  *Mocksender
+	service *Service
+}
+
+func newTestService(t *testing.T) *testService {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	ts := &testService{
+		users:  NewMockusersRepository(ctrl),
+		resets: NewMockresetsRepository(ctrl),
+		mailer: NewMocksender(ctrl),
+	}
+	ts.service = New(ts.users, ts.resets, ts.mailer, testPasswords, baseURL)
+
+	return ts
+}
+
+// Достает токен из ссылки в письме
+func tokenFromMail(t *testing.T, msg *mailer.Message) string {
+	idx := strings.Index(msg.Body, baseURL)
+	require.NotEqual(t, -1, idx)
+
+	link := strings.Fields(msg.Body[idx:])[0]
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/password/reset/", u.Path)
+
+	return u.Query().Get("token")
+}
+
+func TestService_Request(t *testing.T) {
+	ts := newTestService(t)
+
+	var saved *models.PasswordReset
+	var sent *mailer.Message
+	ts.users.EXPECT().GetByEmail(user.Email).Return(&user, nil)
+	ts.resets.EXPECT().Create(gomock.Any()).DoAndReturn(func(reset *models.PasswordReset) (int64, error) {
+		saved = reset
+		return 1, nil
+	})
+	ts.mailer.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg *mailer.Message) error {
+		sent = msg
+		return nil
+	})
+
+	err := ts.service.Request(user.Email)
+
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, sent.To)
+	assert.Equal(t, user.ID, saved.UserID)
+	assert.WithinDuration(t, time.Now().Add(resetTTL), saved.Expires, time.Minute)
+
+	// В базе хранится только хеш токена из письма
+	token := tokenFromMail(t, sent)
+	assert.Equal(t, hashToken(token), saved.Hash)
+	assert.NotContains(t, saved.Hash, token)
+}
+
+func TestService_Request_UnknownEmail(t *testing.T) {
+	ts := newTestService(t)
+
+	ts.users.EXPECT().GetByEmail("nobody@example.com").Return(nil, errors.New("no rows"))
+
+	err := ts.service.Request("nobody@example.com")
+
+	assert.NoError(t, err)
+}
+
+func TestService_Request_Disabled(t *testing.T) {
+	ts := newTestService(t)
+
+	disabled := time.Now()
+	stored := user
+	stored.Disabled = &disabled
+	ts.users.EXPECT().GetByEmail(user.Email).Return(&stored, nil)
+
+	err := ts.service.Request(user.Email)
+
+	assert.NoError(t, err)
+}
+
+func TestService_Request_Unverified(t *testing.T) {
+	ts := newTestService(t)
+
+	stored := user
+	stored.EmailVerified = nil
+	ts.users.EXPECT().GetByEmail(user.Email).Return(&stored, nil)
+
+	err := ts.service.Request(user.Email)
+
+	assert.NoError(t, err)
+}
+
+func TestService_Request_MailError(t *testing.T) {
+	ts := newTestService(t)
+
+	expErr := errors.New("smtp error")
+	ts.users.EXPECT().GetByEmail(user.Email).Return(&user, nil)
+	ts.resets.EXPECT().Create(gomock.Any()).Return(int64(1), nil)
+	ts.mailer.EXPECT().Send(gomock.Any()).Return(expErr)
+
+	err := ts.service.Request(user.Email)
+
+	assert.ErrorIs(t, err, expErr)
+}
+
+func TestService_Reset(t *testing.T) {
+	ts := newTestService(t)
+
+	token := "secret"
+	ts.resets.EXPECT().GetByHash(hashToken(token)).Return(&models.PasswordReset{ID: 7, Expires: time.Now().Add(time.Minute)}, nil)
+
+	var saved string
+	ts.resets.EXPECT().Reset(int64(7), gomock.Any()).DoAndReturn(func(_ int64, password string) (int64, error) {
+		saved = password
+		return user.ID, nil
+	})
+
+	act, err := ts.service.Reset(token, "Qwerty1!")
+
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, act)
+	ok, _ := testPasswords.Check("Qwerty1!", saved)
+	assert.True(t, ok)
+}
+
+func TestService_Reset_Invalid(t *testing.T) {
+	ts := newTestService(t)
+
+	used := time.Now()
+	ts.resets.EXPECT().GetByHash(hashToken("used")).Return(&models.PasswordReset{ID: 1, Expires: time.Now().Add(time.Minute), Used: &used}, nil)
+	ts.resets.EXPECT().GetByHash(hashToken("expired")).Return(&models.PasswordReset{ID: 2, Expires: time.Now().Add(-time.Minute)}, nil)
+	ts.resets.EXPECT().GetByHash(hashToken("unknown")).Return(nil, errors.New("no rows"))
+
+	for _, token := range []string{"", "used", "expired", "unknown"} {
+		act, err := ts.service.Reset(token, "Qwerty1!")
+
+		assert.Empty(t, act)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+}
+
+func TestService_Reset_Race(t *testing.T) {
+	ts := newTestService(t)
+
+	// Токен погасили между проверкой и сменой пароля
+	ts.resets.EXPECT().GetByHash(hashToken("secret")).Return(&models.PasswordReset{ID: 7, Expires: time.Now().Add(time.Minute)}, nil)
+	ts.resets.EXPECT().Reset(int64(7), gomock.Any()).Return(int64(0), resets.ErrResetUnavailable)
+
+	act, err := ts.service.Reset("secret", "Qwerty1!")
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
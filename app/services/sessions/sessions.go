@@ -0,0 +1,436 @@
+//go:generate mockgen -source=sessions.go -destination=./mocks.go -package=sessions
+
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/sessions"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// UserIDKey Ключ значения сессии с ID авторизованного пользователя
+// По нему сессия привязывается к пользователю в базе
+const UserIDKey = "user-id"
+
+const (
+	// Размер токена сессии
+	tokenSize = 32
+	// Как часто обновлять время последнего обращения, если данные сессии не менялись
+	touchInterval = time.Minute
+	// Минимальный размер ключа подписи куки
+	minAuthKeySize = 32
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid session key error")
+)
+
+type repository interface {
+	Create(session *models.Session) (int64, error)
+	GetByHash(hash string) (*models.Session, error)
+	GetByUser(userID int64) ([]models.Session, error)
+	Update(session *models.Session) error
+	Touch(sessionID int64) error
+	Delete(hash string) error
+	DeleteByUser(sessionID, userID int64) error
+	DeleteAllByUser(userID int64) (int64, error)
+	DeleteExpired(createdBefore, seenBefore time.Time) (int64, error)
+}
+
+// Device Сессия пользователя для списка устройств
+type Device struct {
+	ID        int64
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	// Сессия текущего запроса
+	Current bool
+}
+
+// Config Настройки сессий
+type Config struct {
+	// Абсолютное время жизни сессии и время жизни без активности
+	Absolute time.Duration
+	Idle     time.Duration
+	// Пары ключей подписи и шифрования куки, новые куки подписываются первой парой,
+	// остальные нужны, что бы во время смены ключей старые куки оставались действительными
+	KeyPairs [][]byte
+	// Передавать куки только по HTTPS
+	Secure bool
+	// Политика SameSite куки, со Strict браузер не пришлет куки при возврате от провайдера SSO
+	SameSite http.SameSite
+}
+
+// Service Хранилище сессий в базе, реализует sessions.Store
+// В куки лежит только подписанный токен сессии, поэтому сессию можно отозвать на сервере,
+// а сессия истекает через absolute после создания или через idle после последнего обращения
+type Service struct {
+	repo     repository
+	codecs   []securecookie.Codec
+	options  *gsessions.Options
+	absolute time.Duration
+	idle     time.Duration
+	now      func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+// Ключи подписывают и шифруют куки так же, как в sessions.NewCookieStore
+func New(repo repository, cfg Config) *Service {
+	codecs := securecookie.CodecsFromPairs(cfg.KeyPairs...)
+	for _, codec := range codecs {
+		if c, ok := codec.(*securecookie.SecureCookie); ok {
+			c.MaxAge(int(cfg.Absolute.Seconds()))
+		}
+	}
+
+	return &Service{
+		repo:   repo,
+		codecs: codecs,
+		options: &gsessions.Options{
+			Path:     "/",
+			MaxAge:   int(cfg.Absolute.Seconds()),
+			Secure:   cfg.Secure,
+			HttpOnly: true,
+			SameSite: cfg.SameSite,
+		},
+		absolute: cfg.Absolute,
+		idle:     cfg.Idle,
+		now:      time.Now,
+	}
+}
+
+// ParseKeys Разбирает список пар ключей вида auth:enc,auth:enc в hex
+// Ключ подписи должен быть не короче 32 байт, ключ шифрования необязателен и должен иметь длину 16, 24 или 32 байта
+func ParseKeys(value string) ([][]byte, error) {
+	var pairs [][]byte
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, ":", 2)
+		authKey, err := hex.DecodeString(parts[0])
+		if err != nil || len(authKey) < minAuthKeySize {
+			return nil, fmt.Errorf("%w: authentication key #%d must be at least %d hex encoded bytes", ErrInvalidKey, len(pairs)/2+1, minAuthKeySize)
+		}
+
+		var encKey []byte
+		if len(parts) == 2 {
+			encKey, err = hex.DecodeString(parts[1])
+			if err != nil || (len(encKey) != 16 && len(encKey) != 24 && len(encKey) != 32) {
+				return nil, fmt.Errorf("%w: encryption key #%d must be 16, 24 or 32 hex encoded bytes", ErrInvalidKey, len(pairs)/2+1)
+			}
+		}
+
+		pairs = append(pairs, authKey, encKey)
+	}
+
+	if len(pairs) == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	return pairs, nil
+}
+
+// GenerateKeys Возвращает случайную пару ключей для запуска без настроенных ключей
+// Сессии с такими ключами не переживают перезапуск приложения
+func GenerateKeys() [][]byte {
+	return [][]byte{securecookie.GenerateRandomKey(minAuthKeySize), securecookie.GenerateRandomKey(32)}
+}
+
+// ParseSameSite Разбирает политику SameSite куки: lax, strict или none
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+
+	return 0, fmt.Errorf("unknown SameSite mode %q", value)
+}
+
+// Get Возвращает сессию запроса, в пределах одного запроса сессия загружается один раз
+func (s *Service) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New Загружает сессию по токену из куки
+// Если куки нет, токен не подписан нашим ключом или сессия истекла, возвращает новую пустую сессию
+func (s *Service) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var token string
+	if err = securecookie.DecodeMulti(name, cookie.Value, &token, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	stored, err := s.repo.GetByHash(hash(token))
+	if err == sql.ErrNoRows {
+		return session, nil
+	}
+	if err != nil {
+		logger.Log.WithError(err).Errorf("get session error")
+		return session, err
+	}
+
+	now := s.now()
+	if !stored.Active(now, s.absolute, s.idle) {
+		if err = s.repo.Delete(stored.Hash); err != nil {
+			logger.Log.WithError(err).WithField("sessionID", stored.ID).Errorf("delete expired session error")
+		}
+		return session, nil
+	}
+
+	if err = gob.NewDecoder(bytes.NewReader(stored.Data)).Decode(&session.Values); err != nil {
+		logger.Log.WithError(err).WithField("sessionID", stored.ID).Errorf("decode session error")
+		return session, err
+	}
+
+	session.ID = token
+	session.IsNew = false
+
+	// Время обращения обновляется не чаще раза в минуту, что бы не писать в базу на каждый запрос
+	if now.Sub(stored.LastSeen) > touchInterval {
+		if err = s.repo.Touch(stored.ID); err != nil && err != sessions.ErrSessionNotFound {
+			logger.Log.WithError(err).WithField("sessionID", stored.ID).Errorf("touch session error")
+		}
+	}
+
+	return session, nil
+}
+
+// Save Сохраняет сессию в базе и выставляет куки с токеном
+// Сессия с отрицательным MaxAge удаляется вместе с куки
+func (s *Service) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.repo.Delete(hash(session.ID)); err != nil {
+				logger.Log.WithError(err).Errorf("delete session error")
+				return err
+			}
+		}
+
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(session.Values); err != nil {
+		logger.Log.WithError(err).Errorf("encode session error")
+		return err
+	}
+
+	stored := &models.Session{
+		Hash:      hash(session.ID),
+		UserID:    sessionUserID(session),
+		Data:      data.Bytes(),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	if session.ID == "" {
+		token, err := newToken()
+		if err != nil {
+			logger.Log.WithError(err).Errorf("generate session token error")
+			return err
+		}
+
+		stored.Hash = hash(token)
+		if _, err = s.repo.Create(stored); err != nil {
+			logger.Log.WithError(err).Errorf("create session error")
+			return err
+		}
+		session.ID = token
+	} else {
+		err := s.repo.Update(stored)
+		// Сессию отозвали во время запроса, восстанавливать ее нельзя
+		if err == sessions.ErrSessionNotFound {
+			http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &gsessions.Options{Path: session.Options.Path, MaxAge: -1}))
+			return nil
+		}
+		if err != nil {
+			logger.Log.WithError(err).Errorf("update session error")
+			return err
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("encode session cookie error")
+		return err
+	}
+
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// Renew Удаляет сессию из базы, при следующем сохранении данные запишутся под новым токеном
+// Вызывается при входе и выходе, что бы токен, известный до входа, не давал доступа после него
+func (s *Service) Renew(session *gsessions.Session) error {
+	if session.ID == "" {
+		return nil
+	}
+
+	if err := s.repo.Delete(hash(session.ID)); err != nil {
+		logger.Log.WithError(err).Errorf("delete session error")
+		return err
+	}
+
+	session.ID = ""
+	session.IsNew = true
+	return nil
+}
+
+// Devices Возвращает активные сессии пользователя и отмечает среди них текущую
+func (s *Service) Devices(userID int64, current *gsessions.Session) ([]Device, error) {
+	list, err := s.repo.GetByUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get user sessions error")
+		return nil, err
+	}
+
+	currentHash := ""
+	if current != nil && current.ID != "" {
+		currentHash = hash(current.ID)
+	}
+
+	now := s.now()
+	devices := make([]Device, 0, len(list))
+	for i := range list {
+		if !list[i].Active(now, s.absolute, s.idle) {
+			continue
+		}
+
+		devices = append(devices, Device{
+			ID:        list[i].ID,
+			IP:        list[i].IP,
+			UserAgent: list[i].UserAgent,
+			Created:   list[i].Created,
+			LastSeen:  list[i].LastSeen,
+			Current:   list[i].Hash == currentHash,
+		})
+	}
+
+	return devices, nil
+}
+
+// Revoke Завершает сессию пользователя на другом устройстве
+// Если сессии нет среди сессий пользователя, то возвращает models.NotFoundError
+func (s *Service) Revoke(userID, sessionID int64) error {
+	err := s.repo.DeleteByUser(sessionID, userID)
+	if err == sessions.ErrSessionNotFound {
+		return &models.NotFoundError{Entity: "session", ID: sessionID}
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).WithField("sessionID", sessionID).Errorf("revoke session error")
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAll Завершает все сессии пользователя, включая текущую, и возвращает их количество
+func (s *Service) RevokeAll(userID int64) (int64, error) {
+	count, err := s.repo.DeleteAllByUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("revoke all sessions error")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Run Периодически удаляет истекшие сессии, пока не отменен контекст
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.DeleteExpired()
+		}
+	}
+}
+
+// DeleteExpired Удаляет сессии, истекшие по абсолютному времени жизни или по времени простоя
+func (s *Service) DeleteExpired() error {
+	now := s.now()
+	count, err := s.repo.DeleteExpired(now.Add(-s.absolute), now.Add(-s.idle))
+	if err != nil {
+		logger.Log.WithError(err).Errorf("delete expired sessions error")
+		return err
+	}
+
+	if count > 0 {
+		logger.Log.WithField("count", count).Info("expired sessions deleted")
+	}
+
+	return nil
+}
+
+// Возвращает ID пользователя, к которому относится сессия, или nil для неавторизованного посетителя
+func sessionUserID(session *gsessions.Session) *int64 {
+	if id, ok := session.Values[UserIDKey].(int64); ok {
+		return &id
+	}
+
+	return nil
+}
+
+// Генерирует случайный токен сессии
+func newToken() (string, error) {
+	buf := make([]byte, tokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// Возвращает хеш токена для хранения в базе
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Возвращает адрес клиента запроса без порта
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
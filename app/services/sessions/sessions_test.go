@@ -0,0 +1,361 @@
+package sessions
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/sessions"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	name     = "session"
+	userID   = int64(55)
+	absolute = 30 * 24 * time.Hour
+	idle     = 24 * time.Hour
+)
+
+var (
+	now  = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	keys = GenerateKeys()
+)
+
+func newTestService(t *testing.T) (*Service, *Mockrepository) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	repo := NewMockrepository(ctrl)
+	service := New(repo, Config{
+		Absolute: absolute,
+		Idle:     idle,
+		KeyPairs: keys,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	service.now = func() time.Time { return now }
+
+	return service, repo
+}
+
+// Сохраняет сессию с ID пользователя и возвращает сохраненную запись и запрос с выданной куки
+func saveNew(t *testing.T, service *Service, repo *Mockrepository) (*models.Session, *http.Request) {
+	var stored *models.Session
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(s *models.Session) (int64, error) {
+		stored = s
+		return 1, nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("User-Agent", "Firefox")
+
+	session, err := service.New(r, name)
+	require.NoError(t, err)
+	assert.True(t, session.IsNew)
+
+	session.Values[UserIDKey] = userID
+	session.Values["ledger-id"] = int64(7)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, service.Save(r, w, session))
+
+	require.NotNil(t, stored)
+	assert.Equal(t, userID, *stored.UserID)
+	assert.Equal(t, "10.0.0.1", stored.IP)
+	assert.Equal(t, "Firefox", stored.UserAgent)
+	assert.Equal(t, hash(session.ID), stored.Hash)
+
+	// Токен в куки подписан и не совпадает с хешем в базе
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	assert.NotContains(t, cookies[0].Value, stored.Hash)
+
+	next := httptest.NewRequest(http.MethodGet, "/", nil)
+	next.AddCookie(cookies[0])
+
+	stored.ID = 1
+	stored.Created = now
+	stored.LastSeen = now
+	return stored, next
+}
+
+func TestService_SaveAndLoad(t *testing.T) {
+	service, repo := newTestService(t)
+	stored, r := saveNew(t, service, repo)
+
+	repo.EXPECT().GetByHash(stored.Hash).Return(stored, nil)
+
+	session, err := service.New(r, name)
+	require.NoError(t, err)
+	assert.False(t, session.IsNew)
+	assert.Equal(t, userID, session.Values[UserIDKey])
+	assert.Equal(t, int64(7), session.Values["ledger-id"])
+
+	// Выход отвязывает сессию от пользователя
+	delete(session.Values, UserIDKey)
+	repo.EXPECT().Update(gomock.Any()).DoAndReturn(func(s *models.Session) error {
+		assert.Equal(t, stored.Hash, s.Hash)
+		assert.Nil(t, s.UserID)
+		return nil
+	})
+	require.NoError(t, service.Save(r, httptest.NewRecorder(), session))
+}
+
+func TestService_New_Invalid(t *testing.T) {
+	service, repo := newTestService(t)
+	stored, r := saveNew(t, service, repo)
+
+	tests := []struct {
+		name    string
+		cookie  *http.Cookie
+		setup   func()
+		wantErr bool
+	}{
+		{
+			name:   "forged cookie",
+			cookie: &http.Cookie{Name: name, Value: "forged"},
+		},
+		{
+			name: "revoked",
+			setup: func() {
+				repo.EXPECT().GetByHash(stored.Hash).Return(nil, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "absolute timeout",
+			setup: func() {
+				expired := *stored
+				expired.Created = now.Add(-absolute - time.Second)
+				repo.EXPECT().GetByHash(stored.Hash).Return(&expired, nil)
+				repo.EXPECT().Delete(stored.Hash).Return(nil)
+			},
+		},
+		{
+			name: "idle timeout",
+			setup: func() {
+				expired := *stored
+				expired.LastSeen = now.Add(-idle - time.Second)
+				repo.EXPECT().GetByHash(stored.Hash).Return(&expired, nil)
+				repo.EXPECT().Delete(stored.Hash).Return(nil)
+			},
+		},
+		{
+			name: "repository error",
+			setup: func() {
+				repo.EXPECT().GetByHash(stored.Hash).Return(nil, errors.New("test error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := r
+			if tt.cookie != nil {
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(tt.cookie)
+			}
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			session, err := service.New(req, name)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.True(t, session.IsNew)
+			assert.Empty(t, session.Values)
+		})
+	}
+}
+
+func TestService_New_Touch(t *testing.T) {
+	service, repo := newTestService(t)
+	stored, r := saveNew(t, service, repo)
+
+	stored.LastSeen = now.Add(-2 * touchInterval)
+	repo.EXPECT().GetByHash(stored.Hash).Return(stored, nil)
+	repo.EXPECT().Touch(stored.ID).Return(nil)
+
+	session, err := service.New(r, name)
+	require.NoError(t, err)
+	assert.False(t, session.IsNew)
+}
+
+func TestService_Save_Revoked(t *testing.T) {
+	service, repo := newTestService(t)
+	stored, r := saveNew(t, service, repo)
+
+	repo.EXPECT().GetByHash(stored.Hash).Return(stored, nil)
+	session, err := service.New(r, name)
+	require.NoError(t, err)
+
+	// Сессию отозвали с другого устройства, пока шел запрос: новая сессия не создается
+	repo.EXPECT().Update(gomock.Any()).Return(sessions.ErrSessionNotFound)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, service.Save(r, w, session))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Empty(t, cookies[0].Value)
+	assert.True(t, cookies[0].MaxAge < 0)
+}
+
+func TestService_Renew(t *testing.T) {
+	service, repo := newTestService(t)
+	stored, r := saveNew(t, service, repo)
+
+	repo.EXPECT().GetByHash(stored.Hash).Return(stored, nil)
+	session, err := service.New(r, name)
+	require.NoError(t, err)
+
+	// После входа данные сохраняются под новым токеном, а старый удаляется
+	repo.EXPECT().Delete(stored.Hash).Return(nil)
+	require.NoError(t, service.Renew(session))
+	assert.Empty(t, session.ID)
+
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(s *models.Session) (int64, error) {
+		assert.NotEqual(t, stored.Hash, s.Hash)
+		return 2, nil
+	})
+	require.NoError(t, service.Save(r, httptest.NewRecorder(), session))
+	assert.NotEmpty(t, session.ID)
+
+	// Сессия с отрицательным MaxAge удаляется
+	repo.EXPECT().Delete(hash(session.ID)).Return(nil)
+	session.Options.MaxAge = -1
+
+	w := httptest.NewRecorder()
+	require.NoError(t, service.Save(r, w, session))
+	assert.True(t, w.Result().Cookies()[0].MaxAge < 0)
+}
+
+func TestService_Devices(t *testing.T) {
+	service, repo := newTestService(t)
+	stored, r := saveNew(t, service, repo)
+
+	repo.EXPECT().GetByHash(stored.Hash).Return(stored, nil)
+	session, err := service.New(r, name)
+	require.NoError(t, err)
+
+	repo.EXPECT().GetByUser(userID).Return([]models.Session{
+		*stored,
+		{ID: 2, Hash: "other", Created: now.Add(-time.Hour), LastSeen: now.Add(-time.Minute)},
+		{ID: 3, Hash: "idle", Created: now.Add(-48 * time.Hour), LastSeen: now.Add(-idle - time.Hour)},
+	}, nil)
+
+	devices, err := service.Devices(userID, session)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.True(t, devices[0].Current)
+	assert.False(t, devices[1].Current)
+	assert.Equal(t, int64(2), devices[1].ID)
+}
+
+func TestService_Revoke(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().DeleteByUser(int64(2), userID).Return(nil)
+	repo.EXPECT().DeleteByUser(int64(3), userID).Return(sessions.ErrSessionNotFound)
+	repo.EXPECT().DeleteAllByUser(userID).Return(int64(4), nil)
+
+	assert.NoError(t, service.Revoke(userID, 2))
+	assert.ErrorIs(t, service.Revoke(userID, 3), models.ErrNotFound)
+
+	count, err := service.RevokeAll(userID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+}
+
+func TestService_DeleteExpired(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().DeleteExpired(now.Add(-absolute), now.Add(-idle)).Return(int64(3), nil)
+	assert.NoError(t, service.DeleteExpired())
+}
+
+func TestService_KeyRotation(t *testing.T) {
+	service, repo := newTestService(t)
+	stored, r := saveNew(t, service, repo)
+
+	// Новый ключ добавлен первым, старый оставлен для проверки выданных куки
+	rotated := New(repo, Config{
+		Absolute: absolute,
+		Idle:     idle,
+		KeyPairs: append(GenerateKeys(), keys...),
+	})
+	rotated.now = service.now
+
+	repo.EXPECT().GetByHash(stored.Hash).Return(stored, nil)
+	session, err := rotated.New(r, name)
+	require.NoError(t, err)
+	assert.False(t, session.IsNew)
+
+	// После удаления старого ключа куки больше не принимается
+	removed := New(repo, Config{Absolute: absolute, Idle: idle, KeyPairs: GenerateKeys()})
+	session, err = removed.New(r, name)
+	require.NoError(t, err)
+	assert.True(t, session.IsNew)
+}
+
+func TestParseKeys(t *testing.T) {
+	auth := strings.Repeat("ab", 32)
+	enc := strings.Repeat("cd", 32)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{name: "auth only", value: auth, want: 2},
+		{name: "auth and enc", value: auth + ":" + enc, want: 2},
+		{name: "rotation", value: auth + ":" + enc + ", " + auth, want: 4},
+		{name: "empty", value: "", wantErr: true},
+		{name: "short auth", value: "abcd", wantErr: true},
+		{name: "not hex", value: strings.Repeat("zz", 32), wantErr: true},
+		{name: "bad enc size", value: auth + ":" + strings.Repeat("cd", 20), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			act, err := ParseKeys(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidKey)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, act, tt.want)
+		})
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	for value, exp := range map[string]http.SameSite{
+		"lax":    http.SameSiteLaxMode,
+		"Strict": http.SameSiteStrictMode,
+		"none":   http.SameSiteNoneMode,
+	} {
+		act, err := ParseSameSite(value)
+		assert.NoError(t, err)
+		assert.Equal(t, exp, act)
+	}
+
+	_, err := ParseSameSite("unknown")
+	assert.Error(t, err)
+}
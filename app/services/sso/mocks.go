@@ -0,0 +1,148 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: sso.go
+
+// Package sso is a generated GoMock package.
+package sso
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/bgoldovsky/casher/app/models"
+	oidc "github.com/bgoldovsky/casher/app/oidc"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockprovider is a mock of provider interface.
+type Mockprovider struct {
+	ctrl     *gomock.Controller
+	recorder *MockproviderMockRecorder
+}
+
+// MockproviderMockRecorder is the mock recorder for Mockprovider.
+type MockproviderMockRecorder struct {
+	mock *Mockprovider
+}
+
+// NewMockprovider creates a new mock instance.
+func NewMockprovider(ctrl *gomock.Controller) *Mockprovider {
+	mock := &Mockprovider{ctrl: ctrl}
+	mock.recorder = &MockproviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockprovider) EXPECT() *MockproviderMockRecorder {
+	return m.recorder
+}
+
+// AuthCodeURL mocks base method.
+func (m *Mockprovider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthCodeURL", ctx, state, nonce, verifier)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthCodeURL indicates an expected call of AuthCodeURL.
+func (mr *MockproviderMockRecorder) AuthCodeURL(ctx, state, nonce, verifier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthCodeURL", reflect.TypeOf((*Mockprovider)(nil).AuthCodeURL), ctx, state, nonce, verifier)
+}
+
+// Exchange mocks base method.
+func (m *Mockprovider) Exchange(ctx context.Context, code, verifier, nonce string) (*oidc.Claims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exchange", ctx, code, verifier, nonce)
+	ret0, _ := ret[0].(*oidc.Claims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exchange indicates an expected call of Exchange.
+func (mr *MockproviderMockRecorder) Exchange(ctx, code, verifier, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exchange", reflect.TypeOf((*Mockprovider)(nil).Exchange), ctx, code, verifier, nonce)
+}
+
+// Issuer mocks base method.
+func (m *Mockprovider) Issuer() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Issuer")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Issuer indicates an expected call of Issuer.
+func (mr *MockproviderMockRecorder) Issuer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Issuer", reflect.TypeOf((*Mockprovider)(nil).Issuer))
+}
+
+// MockusersRepository is a mock of usersRepository interface.
+type MockusersRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockusersRepositoryMockRecorder
+}
+
+// MockusersRepositoryMockRecorder is the mock recorder for MockusersRepository.
+type MockusersRepositoryMockRecorder struct {
+	mock *MockusersRepository
+}
+
+// NewMockusersRepository creates a new mock instance.
+func NewMockusersRepository(ctrl *gomock.Controller) *MockusersRepository {
+	mock := &MockusersRepository{ctrl: ctrl}
+	mock.recorder = &MockusersRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockusersRepository) EXPECT() *MockusersRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateExternal mocks base method.
+func (m *MockusersRepository) CreateExternal(user *models.User, identity *models.Identity) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExternal", user, identity)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExternal indicates an expected call of CreateExternal.
+func (mr *MockusersRepositoryMockRecorder) CreateExternal(user, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExternal", reflect.TypeOf((*MockusersRepository)(nil).CreateExternal), user, identity)
+}
+
+// GetByIdentity mocks base method.
+func (m *MockusersRepository) GetByIdentity(issuer, subject string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByIdentity", issuer, subject)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByIdentity indicates an expected call of GetByIdentity.
+func (mr *MockusersRepositoryMockRecorder) GetByIdentity(issuer, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIdentity", reflect.TypeOf((*MockusersRepository)(nil).GetByIdentity), issuer, subject)
+}
+
+// LinkIdentity mocks base method.
+func (m *MockusersRepository) LinkIdentity(identity *models.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkIdentity", identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkIdentity indicates an expected call of LinkIdentity.
+func (mr *MockusersRepositoryMockRecorder) LinkIdentity(identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkIdentity", reflect.TypeOf((*MockusersRepository)(nil).LinkIdentity), identity)
+}
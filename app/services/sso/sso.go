@@ -0,0 +1,272 @@
+//go:generate mockgen -source=sso.go -destination=./mocks.go -package=sso
+
+package sso
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/oidc"
+	"github.com/bgoldovsky/casher/app/repositories/users"
+)
+
+const (
+	// Пароль пользователя, созданного через SSO: не является хешем, поэтому войти по паролю нельзя,
+	// пока пользователь не задаст его через восстановление пароля
+	noPassword = "!"
+	// Максимальная длина логина
+	maxLoginLength = 64
+	// Количество попыток подобрать свободный логин
+	loginAttempts = 10
+)
+
+var (
+	ErrDisabled       = errors.New("sso is not configured error")
+	ErrInvalidState   = errors.New("invalid sso state error")
+	ErrInvalidLogin   = errors.New("sso login failed error")
+	ErrIdentityLinked = errors.New("identity linked to another user error")
+	ErrUserDisabled   = errors.New("user is disabled")
+)
+
+type provider interface {
+	Issuer() string
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (*oidc.Claims, error)
+}
+
+type usersRepository interface {
+	GetByIdentity(issuer, subject string) (*models.User, error)
+	CreateExternal(user *models.User, identity *models.Identity) (int64, error)
+	LinkIdentity(identity *models.Identity) error
+}
+
+// Flow Одноразовые значения входа, которые хранятся в сессии между переходом к провайдеру и возвратом
+type Flow struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// Result Результат входа через SSO
+type Result struct {
+	UserID int64
+	// Пользователь создан при первом входе
+	Created bool
+	// Учетная запись привязана к уже авторизованному пользователю
+	Linked bool
+}
+
+// Service Сервис входа через провайдера OpenID Connect
+// Пользователь определяется по паре издатель и subject, адрес почты для поиска не используется,
+// иначе провайдер, позволяющий указать любой адрес, дал бы доступ к чужим аккаунтам
+type Service struct {
+	provider  provider
+	usersRepo usersRepository
+	now       func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+// Если провайдер не передан, вход через SSO выключен
+func New(provider provider, usersRepo usersRepository) *Service {
+	return &Service{
+		provider:  provider,
+		usersRepo: usersRepo,
+		now:       time.Now,
+	}
+}
+
+// Enabled Сообщает, настроен ли вход через SSO
+func (s *Service) Enabled() bool {
+	return s != nil && s.provider != nil
+}
+
+// Begin Начинает вход: генерирует одноразовые значения и возвращает адрес страницы входа провайдера
+func (s *Service) Begin(ctx context.Context) (string, *Flow, error) {
+	if !s.Enabled() {
+		return "", nil, ErrDisabled
+	}
+
+	flow := &Flow{}
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			logger.Log.WithError(err).Errorf("generate sso flow error")
+			return "", nil, err
+		}
+		*value = random
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("build sso auth url error")
+		return "", nil, err
+	}
+
+	return authURL, flow, nil
+}
+
+// Complete Завершает вход по коду авторизации от провайдера
+// Если currentUserID не равен нулю, то внешняя учетная запись привязывается к этому пользователю,
+// иначе находится привязанный пользователь или создается новый
+func (s *Service) Complete(ctx context.Context, flow *Flow, state, code string, currentUserID int64) (*Result, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	if flow == nil || state == "" || subtle.ConstantTimeCompare([]byte(flow.State), []byte(state)) != 1 {
+		logger.Log.Errorf("sso error: state mismatch")
+		return nil, ErrInvalidState
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("sso exchange error")
+		return nil, ErrInvalidLogin
+	}
+
+	identity := &models.Identity{
+		UserID:  currentUserID,
+		Issuer:  s.provider.Issuer(),
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+
+	user, err := s.usersRepo.GetByIdentity(identity.Issuer, identity.Subject)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Log.WithError(err).WithField("subject", identity.Subject).Errorf("get user by identity error")
+		return nil, err
+	}
+
+	// Учетная запись уже привязана
+	if err == nil {
+		if currentUserID != 0 && user.ID != currentUserID {
+			logger.Log.WithField("userID", currentUserID).Errorf("sso error: identity linked to another user")
+			return nil, ErrIdentityLinked
+		}
+
+		if user.Disabled != nil {
+			logger.Log.WithField("userID", user.ID).Errorf("sso error: user disabled")
+			return nil, ErrUserDisabled
+		}
+
+		return &Result{UserID: user.ID}, nil
+	}
+
+	// Привязываем учетную запись к авторизованному пользователю
+	if currentUserID != 0 {
+		err = s.usersRepo.LinkIdentity(identity)
+		if err == users.ErrIdentityLinked {
+			return nil, ErrIdentityLinked
+		}
+		if err != nil {
+			logger.Log.WithError(err).WithField("userID", currentUserID).Errorf("link identity error")
+			return nil, err
+		}
+
+		return &Result{UserID: currentUserID, Linked: true}, nil
+	}
+
+	userID, err := s.create(claims, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{UserID: userID, Created: true}, nil
+}
+
+// Создает пользователя при первом входе, подбирая свободный логин
+func (s *Service) create(claims *oidc.Claims, identity *models.Identity) (int64, error) {
+	user := &models.User{
+		Password: noPassword,
+		Name:     strings.TrimSpace(claims.Name),
+		Birth:    s.now(),
+	}
+
+	if birth, err := time.Parse("2006-01-02", claims.Birthdate); err == nil {
+		user.Birth = birth
+	}
+
+	// Адрес сохраняется, только если провайдер его подтвердил
+	if claims.Email != "" && claims.EmailVerified {
+		verified := s.now()
+		user.Email = claims.Email
+		user.EmailVerified = &verified
+	}
+
+	base := loginBase(claims)
+	if user.Name == "" {
+		user.Name = base
+	}
+
+	for attempt := 1; attempt <= loginAttempts; attempt++ {
+		user.Login = base
+		if attempt > 1 {
+			user.Login = fmt.Sprintf("%s-%d", base, attempt)
+		}
+
+		userID, err := s.usersRepo.CreateExternal(user, identity)
+		switch err {
+		case nil:
+			return userID, nil
+		case users.ErrDuplicateKey:
+			continue
+		case users.ErrDuplicateEmail:
+			// Адрес уже занят другим пользователем, создаем аккаунт без адреса
+			user.Email, user.EmailVerified = "", nil
+			attempt--
+			continue
+		case users.ErrIdentityLinked:
+			// Учетную запись успел привязать параллельный вход
+			return 0, ErrIdentityLinked
+		default:
+			logger.Log.WithError(err).WithField("subject", identity.Subject).Errorf("create external user error")
+			return 0, err
+		}
+	}
+
+	logger.Log.WithField("subject", identity.Subject).Errorf("create external user error: no free login")
+	return 0, ErrInvalidLogin
+}
+
+// Возвращает желаемый логин: из preferred_username, из адреса или из хеша subject
+func loginBase(claims *oidc.Claims) string {
+	candidates := []string{claims.PreferredUsername}
+	if idx := strings.Index(claims.Email, "@"); idx > 0 {
+		candidates = append(candidates, claims.Email[:idx])
+	}
+
+	for _, candidate := range candidates {
+		if login := sanitizeLogin(candidate); login != "" {
+			return login
+		}
+	}
+
+	sum := sha256.Sum256([]byte(claims.Issuer + " " + claims.Subject))
+	return "sso-" + hex.EncodeToString(sum[:])[:10]
+}
+
+// Оставляет в логине только буквы, цифры, точку, дефис и подчеркивание
+func sanitizeLogin(value string) string {
+	login := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, value)
+
+	if runes := []rune(login); len(runes) > maxLoginLength {
+		login = string(runes[:maxLoginLength])
+	}
+
+	return login
+}
@@ -0,0 +1,226 @@
+package sso
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/oidc"
+	"github.com/bgoldovsky/casher/app/oidc/oidctest"
+	"github.com/bgoldovsky/casher/app/repositories/users"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testService struct {
+	issuer  *oidctest.Issuer
+	users   *MockusersRepository
+	service *Service
+}
+
+func newTestService(t *testing.T) *testService {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	issuer := oidctest.NewIssuer("casher", "secret")
+	t.Cleanup(issuer.Close)
+
+	provider := oidc.New(oidc.Config{
+		Issuer:       issuer.URL,
+		ClientID:     "casher",
+		ClientSecret: "secret",
+		RedirectURL:  "https://casher.example.com/auth/sso/callback",
+	}, issuer.Client())
+
+	ts := &testService{
+		issuer: issuer,
+		users:  NewMockusersRepository(ctrl),
+	}
+	ts.service = New(provider, ts.users)
+
+	return ts
+}
+
+// Проходит вход у провайдера и возвращает состояние входа, state и код из обратного перенаправления
+func (ts *testService) login(t *testing.T, user map[string]interface{}) (*Flow, string, string) {
+	ts.issuer.SetUser(user)
+
+	authURL, flow, err := ts.service.Begin(context.Background())
+	require.NoError(t, err)
+
+	client := ts.issuer.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	return flow, location.Query().Get("state"), location.Query().Get("code")
+}
+
+func TestService_Disabled(t *testing.T) {
+	service := New(nil, nil)
+
+	assert.False(t, service.Enabled())
+
+	_, _, err := service.Begin(context.Background())
+	assert.ErrorIs(t, err, ErrDisabled)
+}
+
+func TestService_Complete_FirstLogin(t *testing.T) {
+	ts := newTestService(t)
+	flow, state, code := ts.login(t, map[string]interface{}{
+		"sub":                "42",
+		"preferred_username": "jon doe",
+		"email":              "jondoe@example.com",
+		"email_verified":     true,
+		"name":               "Jon Doe",
+		"birthdate":          "1986-04-19",
+	})
+
+	var created *models.User
+	ts.users.EXPECT().GetByIdentity(ts.issuer.URL, "42").Return(nil, sql.ErrNoRows)
+	gomock.InOrder(
+		// Логин занят, подбирается следующий
+		ts.users.EXPECT().CreateExternal(gomock.Any(), gomock.Any()).Return(int64(0), users.ErrDuplicateKey),
+		ts.users.EXPECT().CreateExternal(gomock.Any(), gomock.Any()).DoAndReturn(func(user *models.User, identity *models.Identity) (int64, error) {
+			created = user
+			assert.Equal(t, ts.issuer.URL, identity.Issuer)
+			assert.Equal(t, "42", identity.Subject)
+			return 55, nil
+		}),
+	)
+
+	act, err := ts.service.Complete(context.Background(), flow, state, code, 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, &Result{UserID: 55, Created: true}, act)
+	assert.Equal(t, "jondoe-2", created.Login)
+	assert.Equal(t, "Jon Doe", created.Name)
+	assert.Equal(t, "jondoe@example.com", created.Email)
+	assert.True(t, created.HasVerifiedEmail())
+	assert.Equal(t, time.Date(1986, 4, 19, 0, 0, 0, 0, time.UTC), created.Birth)
+	assert.Equal(t, noPassword, created.Password)
+}
+
+func TestService_Complete_UnverifiedEmail(t *testing.T) {
+	ts := newTestService(t)
+	flow, state, code := ts.login(t, map[string]interface{}{"sub": "42", "email": "jondoe@example.com"})
+
+	var created *models.User
+	ts.users.EXPECT().GetByIdentity(ts.issuer.URL, "42").Return(nil, sql.ErrNoRows)
+	ts.users.EXPECT().CreateExternal(gomock.Any(), gomock.Any()).DoAndReturn(func(user *models.User, _ *models.Identity) (int64, error) {
+		created = user
+		return 55, nil
+	})
+
+	_, err := ts.service.Complete(context.Background(), flow, state, code, 0)
+
+	// Неподтвержденный провайдером адрес не сохраняется
+	require.NoError(t, err)
+	assert.Equal(t, "jondoe", created.Login)
+	assert.Empty(t, created.Email)
+}
+
+func TestService_Complete_ExistingUser(t *testing.T) {
+	ts := newTestService(t)
+	flow, state, code := ts.login(t, map[string]interface{}{"sub": "42"})
+
+	ts.users.EXPECT().GetByIdentity(ts.issuer.URL, "42").Return(&models.User{ID: 55}, nil)
+
+	act, err := ts.service.Complete(context.Background(), flow, state, code, 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, &Result{UserID: 55}, act)
+}
+
+func TestService_Complete_DisabledUser(t *testing.T) {
+	ts := newTestService(t)
+	flow, state, code := ts.login(t, map[string]interface{}{"sub": "42"})
+
+	disabled := time.Now()
+	ts.users.EXPECT().GetByIdentity(ts.issuer.URL, "42").Return(&models.User{ID: 55, Disabled: &disabled}, nil)
+
+	act, err := ts.service.Complete(context.Background(), flow, state, code, 0)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrUserDisabled)
+}
+
+func TestService_Complete_Link(t *testing.T) {
+	ts := newTestService(t)
+	flow, state, code := ts.login(t, map[string]interface{}{"sub": "42", "email": "jondoe@example.com"})
+
+	ts.users.EXPECT().GetByIdentity(ts.issuer.URL, "42").Return(nil, sql.ErrNoRows)
+	ts.users.EXPECT().LinkIdentity(&models.Identity{UserID: 7, Issuer: ts.issuer.URL, Subject: "42", Email: "jondoe@example.com"}).Return(nil)
+
+	act, err := ts.service.Complete(context.Background(), flow, state, code, 7)
+
+	require.NoError(t, err)
+	assert.Equal(t, &Result{UserID: 7, Linked: true}, act)
+}
+
+func TestService_Complete_LinkedToAnother(t *testing.T) {
+	ts := newTestService(t)
+	flow, state, code := ts.login(t, map[string]interface{}{"sub": "42"})
+
+	ts.users.EXPECT().GetByIdentity(ts.issuer.URL, "42").Return(&models.User{ID: 55}, nil)
+
+	act, err := ts.service.Complete(context.Background(), flow, state, code, 7)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrIdentityLinked)
+}
+
+func TestService_Complete_InvalidState(t *testing.T) {
+	ts := newTestService(t)
+	flow, _, code := ts.login(t, map[string]interface{}{"sub": "42"})
+
+	// Ответ провайдера на чужой запрос входа не принимается
+	act, err := ts.service.Complete(context.Background(), flow, "forged", code, 0)
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	act, err = ts.service.Complete(context.Background(), nil, "", code, 0)
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestService_Complete_CodeReuse(t *testing.T) {
+	ts := newTestService(t)
+	flow, state, code := ts.login(t, map[string]interface{}{"sub": "42"})
+
+	ts.users.EXPECT().GetByIdentity(ts.issuer.URL, "42").Return(&models.User{ID: 55}, nil)
+
+	_, err := ts.service.Complete(context.Background(), flow, state, code, 0)
+	require.NoError(t, err)
+
+	// Код авторизации одноразовый
+	act, err := ts.service.Complete(context.Background(), flow, state, code, 0)
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+}
+
+func TestLoginBase(t *testing.T) {
+	tests := []struct {
+		claims oidc.Claims
+		exp    string
+	}{
+		{oidc.Claims{PreferredUsername: "jon.doe"}, "jon.doe"},
+		{oidc.Claims{PreferredUsername: "<script>", Email: "jd@example.com"}, "script"},
+		{oidc.Claims{PreferredUsername: "!!!", Email: "jd@example.com"}, "jd"},
+		{oidc.Claims{Issuer: "https://sso.example.com", Subject: "42"}, "sso-"},
+	}
+
+	for _, tt := range tests {
+		act := loginBase(&tt.claims)
+		assert.Contains(t, act, tt.exp)
+	}
+}
@@ -0,0 +1,107 @@
+//go:generate mockgen -source=tokens.go -destination=./mocks.go -package=tokens
+
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+const (
+	tokenPrefix = "csh_"
+	tokenSize   = 32
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token error")
+)
+
+type repository interface {
+	Create(token *models.Token) (int64, error)
+	Remove(userID, tokenID int64) error
+	Get(userID int64) ([]models.Token, error)
+	GetByHash(hash string) (*models.Token, error)
+}
+
+// Service Сервис управления токенами доступа к API
+type Service struct {
+	repo repository
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+func New(repo repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Create Выпускает новый токен пользователя
+// Возвращает сам токен, в базе сохраняется только его хеш
+func (s *Service) Create(userID int64, name string) (string, error) {
+	buf := make([]byte, tokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		logger.Log.WithError(err).Errorf("generate token error")
+		return "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(buf)
+
+	_, err := s.repo.Create(&models.Token{
+		UserID: userID,
+		Name:   name,
+		Hash:   hashToken(token),
+	})
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("create token error")
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Get Возвращает список токенов пользователя
+func (s *Service) Get(userID int64) ([]models.Token, error) {
+	tokens, err := s.repo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get tokens error")
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Remove Отзывает токен пользователя
+func (s *Service) Remove(userID, tokenID int64) error {
+	err := s.repo.Remove(userID, tokenID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).WithField("tokenID", tokenID).Errorf("remove token error")
+		return err
+	}
+
+	return nil
+}
+
+// Auth Возвращает ID пользователя, которому принадлежит токен
+func (s *Service) Auth(token string) (int64, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return 0, ErrInvalidToken
+	}
+
+	t, err := s.repo.GetByHash(hashToken(token))
+	if err != nil {
+		logger.Log.WithError(err).Errorf("get token error")
+		return 0, ErrInvalidToken
+	}
+
+	return t.UserID, nil
+}
+
+// Берет хеш от токена
+// Токен содержит достаточно случайных данных, поэтому соль и медленный хеш не нужны
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package tokens
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Create_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	var saved *models.Token
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(token *models.Token) (int64, error) {
+		saved = token
+		return 1, nil
+	})
+
+	service := New(repo)
+	act, err := service.Create(123, "bot")
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(act, tokenPrefix))
+	assert.Equal(t, int64(123), saved.UserID)
+	assert.Equal(t, "bot", saved.Name)
+	assert.Equal(t, hashToken(act), saved.Hash)
+	assert.NotContains(t, saved.Hash, act)
+}
+
+func TestService_Create_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	expErr := errors.New("test error")
+
+	repo.EXPECT().Create(gomock.Any()).Return(int64(0), expErr)
+
+	service := New(repo)
+	act, err := service.Create(123, "bot")
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, expErr)
+}
+
+func TestService_Auth_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	token := tokenPrefix + "secret"
+
+	repo.EXPECT().GetByHash(hashToken(token)).Return(&models.Token{UserID: 123}, nil)
+
+	service := New(repo)
+	act, err := service.Auth(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), act)
+}
+
+func TestService_Auth_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().GetByHash(gomock.Any()).Return(nil, errors.New("test error"))
+
+	service := New(repo)
+	act, err := service.Auth(tokenPrefix + "secret")
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_Auth_InvalidFormat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	service := New(repo)
+	act, err := service.Auth("secret")
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,163 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: twofactor.go
+
+// Package twofactor is a generated GoMock package.
+package twofactor
+
+import (
+	reflect "reflect"
+
+	models "github.com/bgoldovsky/casher/app/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockrepository is a mock of repository interface.
+type Mockrepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockrepositoryMockRecorder
+}
+
+// MockrepositoryMockRecorder is the mock recorder for Mockrepository.
+type MockrepositoryMockRecorder struct {
+	mock *Mockrepository
+}
+
+// NewMockrepository creates a new mock instance.
+func NewMockrepository(ctrl *gomock.Controller) *Mockrepository {
+	mock := &Mockrepository{ctrl: ctrl}
+	mock.recorder = &MockrepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockrepository) EXPECT() *MockrepositoryMockRecorder {
+	return m.recorder
+}
+
+// Begin mocks base method.
+func (m *Mockrepository) Begin(userID int64, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin", userID, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockrepositoryMockRecorder) Begin(userID, secret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*Mockrepository)(nil).Begin), userID, secret)
+}
+
+// CountRecoveryCodes mocks base method.
+func (m *Mockrepository) CountRecoveryCodes(userID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountRecoveryCodes", userID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountRecoveryCodes indicates an expected call of CountRecoveryCodes.
+func (mr *MockrepositoryMockRecorder) CountRecoveryCodes(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountRecoveryCodes", reflect.TypeOf((*Mockrepository)(nil).CountRecoveryCodes), userID)
+}
+
+// Disable mocks base method.
+func (m *Mockrepository) Disable(userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Disable", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Disable indicates an expected call of Disable.
+func (mr *MockrepositoryMockRecorder) Disable(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Disable", reflect.TypeOf((*Mockrepository)(nil).Disable), userID)
+}
+
+// Enable mocks base method.
+func (m *Mockrepository) Enable(userID, counter int64, hashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enable", userID, counter, hashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enable indicates an expected call of Enable.
+func (mr *MockrepositoryMockRecorder) Enable(userID, counter, hashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enable", reflect.TypeOf((*Mockrepository)(nil).Enable), userID, counter, hashes)
+}
+
+// Fail mocks base method.
+func (m *Mockrepository) Fail(userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockrepositoryMockRecorder) Fail(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*Mockrepository)(nil).Fail), userID)
+}
+
+// Get mocks base method.
+func (m *Mockrepository) Get(userID int64) (*models.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", userID)
+	ret0, _ := ret[0].(*models.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockrepositoryMockRecorder) Get(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*Mockrepository)(nil).Get), userID)
+}
+
+// ReplaceRecoveryCodes mocks base method.
+func (m *Mockrepository) ReplaceRecoveryCodes(userID int64, hashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceRecoveryCodes", userID, hashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceRecoveryCodes indicates an expected call of ReplaceRecoveryCodes.
+func (mr *MockrepositoryMockRecorder) ReplaceRecoveryCodes(userID, hashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceRecoveryCodes", reflect.TypeOf((*Mockrepository)(nil).ReplaceRecoveryCodes), userID, hashes)
+}
+
+// UseCounter mocks base method.
+func (m *Mockrepository) UseCounter(userID, counter int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseCounter", userID, counter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseCounter indicates an expected call of UseCounter.
+func (mr *MockrepositoryMockRecorder) UseCounter(userID, counter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseCounter", reflect.TypeOf((*Mockrepository)(nil).UseCounter), userID, counter)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *Mockrepository) UseRecoveryCode(userID int64, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", userID, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockrepositoryMockRecorder) UseRecoveryCode(userID, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*Mockrepository)(nil).UseRecoveryCode), userID, hash)
+}
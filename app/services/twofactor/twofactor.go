@@ -0,0 +1,312 @@
+//go:generate mockgen -source=twofactor.go -destination=./mocks.go -package=twofactor
+
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/twofactor"
+	"github.com/bgoldovsky/casher/app/totp"
+	"rsc.io/qr"
+)
+
+const (
+	// Издатель, под которым аккаунт показывается в приложении-аутентификаторе
+	issuer = "Casher"
+	// Количество кодов восстановления
+	recoveryCount = 10
+	// Размер кода восстановления в байтах
+	recoverySize = 10
+	// Количество неудачных попыток подряд, после которого ввод кода блокируется
+	maxFailures = 5
+	// Время блокировки после исчерпания попыток
+	lockout = 15 * time.Minute
+)
+
+var (
+	ErrInvalidCode    = errors.New("invalid two factor code error")
+	ErrAlreadyEnabled = errors.New("two factor authentication already enabled error")
+	ErrNotEnrolled    = errors.New("two factor enrollment not started error")
+	ErrTooManyTries   = errors.New("too many two factor attempts error")
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type repository interface {
+	Get(userID int64) (*models.TwoFactor, error)
+	Begin(userID int64, secret string) error
+	Enable(userID, counter int64, hashes []string) error
+	UseCounter(userID, counter int64) error
+	UseRecoveryCode(userID int64, hash string) error
+	CountRecoveryCodes(userID int64) (int64, error)
+	ReplaceRecoveryCodes(userID int64, hashes []string) error
+	Fail(userID int64) error
+	Disable(userID int64) error
+}
+
+// Service Сервис двухфакторной аутентификации по одноразовым кодам TOTP (RFC 6238)
+type Service struct {
+	repo repository
+	now  func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+func New(repo repository) *Service {
+	return &Service{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// Enabled Проверяет, включена ли у пользователя двухфакторная аутентификация
+// При ошибке чтения настроек считается включенной, что бы не пропустить второй шаг входа
+func (s *Service) Enabled(userID int64) (bool, error) {
+	settings, err := s.repo.Get(userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get two factor error")
+		return true, err
+	}
+
+	return settings.IsEnabled(), nil
+}
+
+// Begin Начинает подключение: генерирует новый секрет, ожидающий подтверждения кодом
+func (s *Service) Begin(userID int64) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logger.Log.WithError(err).Errorf("generate totp secret error")
+		return "", err
+	}
+
+	err = s.repo.Begin(userID, secret)
+	if err == twofactor.ErrAlreadyEnabled {
+		return "", ErrAlreadyEnabled
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("begin two factor error")
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// Pending Возвращает секрет, ожидающий подтверждения
+func (s *Service) Pending(userID int64) (string, error) {
+	settings, err := s.pending(userID)
+	if err != nil {
+		return "", err
+	}
+
+	return settings.Secret, nil
+}
+
+// Возвращает настройки с секретом, ожидающим подтверждения
+func (s *Service) pending(userID int64) (*models.TwoFactor, error) {
+	settings, err := s.repo.Get(userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get two factor error")
+		return nil, err
+	}
+
+	if settings.IsEnabled() {
+		return nil, ErrAlreadyEnabled
+	}
+
+	return settings, nil
+}
+
+// QR Возвращает PNG с QR кодом ссылки otpauth:// для секрета, ожидающего подтверждения
+// Подтвержденный секрет больше не показывается
+func (s *Service) QR(userID int64, account string) ([]byte, error) {
+	secret, err := s.Pending(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := qr.Encode(totp.URI(secret, issuer, account), qr.M)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("encode qr code error")
+		return nil, err
+	}
+
+	return code.PNG(), nil
+}
+
+// Confirm Подтверждает подключение кодом из приложения и возвращает коды восстановления
+// Коды восстановления показываются пользователю один раз, в базе хранятся только их хеши
+// Неудачные попытки ограничены так же, как при входе, что бы код нельзя было подобрать перебором
+func (s *Service) Confirm(userID int64, code string) ([]string, error) {
+	settings, err := s.pending(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.locked(settings) {
+		logger.Log.WithField("userID", userID).Errorf("confirm two factor error: too many attempts")
+		return nil, ErrTooManyTries
+	}
+
+	counter, ok := totp.Validate(settings.Secret, code, s.now())
+	if !ok {
+		if err = s.repo.Fail(userID); err != nil {
+			logger.Log.WithError(err).WithField("userID", userID).Errorf("register two factor failure error")
+		}
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		logger.Log.WithError(err).Errorf("generate recovery codes error")
+		return nil, err
+	}
+
+	err = s.repo.Enable(userID, counter, hashes)
+	if err == twofactor.ErrAlreadyEnabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("enable two factor error")
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Verify Проверяет второй фактор при входе: код из приложения или одноразовый код восстановления
+// Каждый код принимается только один раз
+// После maxFailures неудачных попыток подряд следующая попытка возможна только через lockout,
+// счетчик хранится в базе, поэтому не сбрасывается новой сессией
+func (s *Service) Verify(userID int64, code string) error {
+	settings, err := s.repo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get two factor error")
+		return ErrInvalidCode
+	}
+
+	if !settings.IsEnabled() {
+		return ErrInvalidCode
+	}
+
+	if s.locked(settings) {
+		logger.Log.WithField("userID", userID).Errorf("two factor error: too many attempts")
+		return ErrTooManyTries
+	}
+
+	code = normalizeCode(code)
+	if counter, ok := totp.Validate(settings.Secret, code, s.now()); ok {
+		err = s.repo.UseCounter(userID, counter)
+	} else {
+		err = s.repo.UseRecoveryCode(userID, hashCode(code))
+	}
+
+	if err == twofactor.ErrCodeUsed {
+		logger.Log.WithField("userID", userID).Errorf("two factor error: code rejected")
+		if err = s.repo.Fail(userID); err != nil {
+			logger.Log.WithError(err).WithField("userID", userID).Errorf("register two factor failure error")
+		}
+		return ErrInvalidCode
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("verify two factor error")
+		return err
+	}
+
+	return nil
+}
+
+// Проверяет, что после maxFailures неудачных попыток подряд еще не прошло время блокировки
+func (s *Service) locked(settings *models.TwoFactor) bool {
+	return settings.FailedAttempts >= maxFailures && settings.FailedAt != nil && s.now().Sub(*settings.FailedAt) < lockout
+}
+
+// RecoveryCodesLeft Возвращает количество неиспользованных кодов восстановления
+func (s *Service) RecoveryCodesLeft(userID int64) (int64, error) {
+	count, err := s.repo.CountRecoveryCodes(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("count recovery codes error")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RegenerateCodes Заменяет коды восстановления новыми после проверки текущего кода
+func (s *Service) RegenerateCodes(userID int64, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		logger.Log.WithError(err).Errorf("generate recovery codes error")
+		return nil, err
+	}
+
+	if err = s.repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("replace recovery codes error")
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable Отключает двухфакторную аутентификацию после проверки текущего кода
+func (s *Service) Disable(userID int64, code string) error {
+	if err := s.Verify(userID, code); err != nil {
+		return err
+	}
+
+	if err := s.repo.Disable(userID); err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("disable two factor error")
+		return err
+	}
+
+	return nil
+}
+
+// Генерирует коды восстановления вида xxxx-xxxx-xxxx-xxxx и их хеши
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCount)
+	hashes := make([]string, recoveryCount)
+
+	buf := make([]byte, recoverySize)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(buf))
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		hashes[i] = hashCode(raw)
+	}
+
+	return codes, hashes, nil
+}
+
+// Приводит введенный код к каноническому виду: без пробелов и дефисов, в нижнем регистре
+func normalizeCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// Возвращает хеш кода восстановления
+// Коды случайные и длинные, поэтому достаточно быстрого хеша
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,210 @@
+package twofactor
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/repositories/twofactor"
+	"github.com/bgoldovsky/casher/app/totp"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	userID = int64(55)
+	secret = "JBSWY3DPEHPK3PXP"
+)
+
+var now = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*Service, *Mockrepository) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	repo := NewMockrepository(ctrl)
+	service := New(repo)
+	service.now = func() time.Time { return now }
+
+	return service, repo
+}
+
+func currentCode(t *testing.T) string {
+	code, err := totp.Code(secret, totp.Counter(now))
+	require.NoError(t, err)
+	return code
+}
+
+func TestService_Enabled(t *testing.T) {
+	service, repo := newTestService(t)
+
+	enabled := now
+	repo.EXPECT().Get(userID).Return(nil, sql.ErrNoRows)
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret}, nil)
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret, Enabled: &enabled}, nil)
+	repo.EXPECT().Get(userID).Return(nil, errors.New("test error"))
+
+	for _, exp := range []bool{false, false, true} {
+		act, err := service.Enabled(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, exp, act)
+	}
+
+	// При ошибке второй шаг входа не пропускается
+	act, err := service.Enabled(userID)
+	assert.Error(t, err)
+	assert.True(t, act)
+}
+
+func TestService_Begin_AlreadyEnabled(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().Begin(userID, gomock.Any()).Return(twofactor.ErrAlreadyEnabled)
+
+	act, err := service.Begin(userID)
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrAlreadyEnabled)
+}
+
+func TestService_QR(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret}, nil)
+
+	act, err := service.QR(userID, "jondoe")
+
+	require.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(act))
+	assert.NoError(t, err)
+}
+
+func TestService_QR_Enabled(t *testing.T) {
+	service, repo := newTestService(t)
+
+	// Секрет подключенной аутентификации больше не показывается
+	enabled := now
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret, Enabled: &enabled}, nil)
+
+	act, err := service.QR(userID, "jondoe")
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrAlreadyEnabled)
+}
+
+func TestService_Confirm(t *testing.T) {
+	service, repo := newTestService(t)
+
+	var saved []string
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret}, nil)
+	repo.EXPECT().Enable(userID, totp.Counter(now), gomock.Any()).DoAndReturn(func(_, _ int64, hashes []string) error {
+		saved = hashes
+		return nil
+	})
+
+	codes, err := service.Confirm(userID, currentCode(t))
+
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCount)
+	require.Len(t, saved, recoveryCount)
+
+	// В базу попадают только хеши кодов
+	for idx, code := range codes {
+		assert.Len(t, code, 19)
+		assert.NotEqual(t, code, saved[idx])
+		assert.Equal(t, hashCode(code), saved[idx])
+	}
+}
+
+func TestService_Confirm_InvalidCode(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret}, nil)
+	repo.EXPECT().Fail(userID).Return(nil)
+
+	codes, err := service.Confirm(userID, "000000")
+
+	assert.Nil(t, codes)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestService_Confirm_TooManyTries(t *testing.T) {
+	service, repo := newTestService(t)
+
+	failed := now.Add(-time.Minute)
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret, FailedAttempts: maxFailures, FailedAt: &failed}, nil)
+
+	// Во время блокировки не принимается даже верный код
+	codes, err := service.Confirm(userID, currentCode(t))
+
+	assert.Nil(t, codes)
+	assert.ErrorIs(t, err, ErrTooManyTries)
+}
+
+func TestService_Verify(t *testing.T) {
+	service, repo := newTestService(t)
+
+	enabled := now
+	settings := &models.TwoFactor{UserID: userID, Secret: secret, Enabled: &enabled}
+	repo.EXPECT().Get(userID).Return(settings, nil).Times(3)
+
+	// Код из приложения
+	repo.EXPECT().UseCounter(userID, totp.Counter(now)).Return(nil)
+	assert.NoError(t, service.Verify(userID, currentCode(t)))
+
+	// Повторное использование того же кода
+	repo.EXPECT().UseCounter(userID, totp.Counter(now)).Return(twofactor.ErrCodeUsed)
+	repo.EXPECT().Fail(userID).Return(nil)
+	assert.ErrorIs(t, service.Verify(userID, currentCode(t)), ErrInvalidCode)
+
+	// Код восстановления вводится в любом регистре и с дефисами
+	repo.EXPECT().UseRecoveryCode(userID, hashCode("abcdabcdabcdabcd")).Return(nil)
+	assert.NoError(t, service.Verify(userID, "ABCD-abcd-ABCD-abcd"))
+}
+
+func TestService_Verify_NotEnabled(t *testing.T) {
+	service, repo := newTestService(t)
+
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret}, nil)
+
+	err := service.Verify(userID, currentCode(t))
+
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestService_Disable_InvalidCode(t *testing.T) {
+	service, repo := newTestService(t)
+
+	enabled := now
+	repo.EXPECT().Get(userID).Return(&models.TwoFactor{UserID: userID, Secret: secret, Enabled: &enabled}, nil)
+	repo.EXPECT().UseRecoveryCode(userID, gomock.Any()).Return(twofactor.ErrCodeUsed)
+	repo.EXPECT().Fail(userID).Return(nil)
+
+	err := service.Disable(userID, "123")
+
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestService_Verify_TooManyTries(t *testing.T) {
+	service, repo := newTestService(t)
+
+	enabled := now
+	failed := now.Add(-time.Minute)
+	settings := &models.TwoFactor{UserID: userID, Secret: secret, Enabled: &enabled, FailedAttempts: maxFailures, FailedAt: &failed}
+	repo.EXPECT().Get(userID).Return(settings, nil).Times(2)
+
+	// Во время блокировки не принимается даже верный код
+	assert.ErrorIs(t, service.Verify(userID, currentCode(t)), ErrTooManyTries)
+
+	// После блокировки снова дается попытка
+	service.now = func() time.Time { return now.Add(lockout) }
+	code, err := totp.Code(secret, totp.Counter(now.Add(lockout)))
+	require.NoError(t, err)
+	repo.EXPECT().UseCounter(userID, totp.Counter(now.Add(lockout))).Return(nil)
+	assert.NoError(t, service.Verify(userID, code))
+}
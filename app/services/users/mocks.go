@@ -6,6 +6,7 @@ package users
 
 import (
 	reflect "reflect"
+	time "time"
 
 	models "github.com/bgoldovsky/casher/app/models"
 	gomock "github.com/golang/mock/gomock"
@@ -64,6 +65,35 @@ func (mr *MockusersRepositoryMockRecorder) Create(user interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockusersRepository)(nil).Create), user)
 }
 
+// CreateInvited mocks base method.
+func (m *MockusersRepository) CreateInvited(user *models.User, inviteID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateInvited", user, inviteID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateInvited indicates an expected call of CreateInvited.
+func (mr *MockusersRepositoryMockRecorder) CreateInvited(user, inviteID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInvited", reflect.TypeOf((*MockusersRepository)(nil).CreateInvited), user, inviteID)
+}
+
+// Delete mocks base method.
+func (m *MockusersRepository) Delete(userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockusersRepositoryMockRecorder) Delete(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockusersRepository)(nil).Delete), userID)
+}
+
 // Get mocks base method.
 func (m *MockusersRepository) Get(userID int64) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -79,40 +109,111 @@ func (mr *MockusersRepositoryMockRecorder) Get(userID interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockusersRepository)(nil).Get), userID)
 }
 
-// MockoperationsRepository is a mock of operationsRepository interface.
-type MockoperationsRepository struct {
+// SetLanguage mocks base method.
+func (m *MockusersRepository) SetLanguage(userID int64, language string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLanguage", userID, language)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLanguage indicates an expected call of SetLanguage.
+func (mr *MockusersRepositoryMockRecorder) SetLanguage(userID, language interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLanguage", reflect.TypeOf((*MockusersRepository)(nil).SetLanguage), userID, language)
+}
+
+// SetLastLogin mocks base method.
+func (m *MockusersRepository) SetLastLogin(userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLastLogin", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLastLogin indicates an expected call of SetLastLogin.
+func (mr *MockusersRepositoryMockRecorder) SetLastLogin(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLastLogin", reflect.TypeOf((*MockusersRepository)(nil).SetLastLogin), userID)
+}
+
+// SetPassword mocks base method.
+func (m *MockusersRepository) SetPassword(userID int64, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPassword", userID, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPassword indicates an expected call of SetPassword.
+func (mr *MockusersRepositoryMockRecorder) SetPassword(userID, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassword", reflect.TypeOf((*MockusersRepository)(nil).SetPassword), userID, hash)
+}
+
+// UpdateProfile mocks base method.
+func (m *MockusersRepository) UpdateProfile(userID int64, name string, birth time.Time, timezone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfile", userID, name, birth, timezone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockusersRepositoryMockRecorder) UpdateProfile(userID, name, birth, timezone interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockusersRepository)(nil).UpdateProfile), userID, name, birth, timezone)
+}
+
+// Mockhasher is a mock of hasher interface.
+type Mockhasher struct {
 	ctrl     *gomock.Controller
-	recorder *MockoperationsRepositoryMockRecorder
+	recorder *MockhasherMockRecorder
 }
 
-// MockoperationsRepositoryMockRecorder is the mock recorder for MockoperationsRepository.
-type MockoperationsRepositoryMockRecorder struct {
-	mock *MockoperationsRepository
+// MockhasherMockRecorder is the mock recorder for Mockhasher.
+type MockhasherMockRecorder struct {
+	mock *Mockhasher
 }
 
-// NewMockoperationsRepository creates a new mock instance.
-func NewMockoperationsRepository(ctrl *gomock.Controller) *MockoperationsRepository {
-	mock := &MockoperationsRepository{ctrl: ctrl}
-	mock.recorder = &MockoperationsRepositoryMockRecorder{mock}
+// NewMockhasher creates a new mock instance.
+func NewMockhasher(ctrl *gomock.Controller) *Mockhasher {
+	mock := &Mockhasher{ctrl: ctrl}
+	mock.recorder = &MockhasherMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockoperationsRepository) EXPECT() *MockoperationsRepositoryMockRecorder {
+func (m *Mockhasher) EXPECT() *MockhasherMockRecorder {
 	return m.recorder
 }
 
-// Get mocks base method.
-func (m *MockoperationsRepository) Get(userID, page, size int64) (*models.OperationPaginator, error) {
+// Check mocks base method.
+func (m *Mockhasher) Check(password, hash string) (bool, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", password, hash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockhasherMockRecorder) Check(password, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*Mockhasher)(nil).Check), password, hash)
+}
+
+// Hash mocks base method.
+func (m *Mockhasher) Hash(password string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", userID, page, size)
-	ret0, _ := ret[0].(*models.OperationPaginator)
+	ret := m.ctrl.Call(m, "Hash", password)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Get indicates an expected call of Get.
-func (mr *MockoperationsRepositoryMockRecorder) Get(userID, page, size interface{}) *gomock.Call {
+// Hash indicates an expected call of Hash.
+func (mr *MockhasherMockRecorder) Hash(password interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockoperationsRepository)(nil).Get), userID, page, size)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hash", reflect.TypeOf((*Mockhasher)(nil).Hash), password)
 }
@@ -8,39 +8,58 @@ import (
 
 	"github.com/bgoldovsky/casher/app/logger"
 	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/passwords"
 	"github.com/bgoldovsky/casher/app/repositories/users"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrInvalidPassword = errors.New("invalid user or password error")
 	ErrLoginExists     = errors.New("login already exists")
+	ErrInvalidInvite   = errors.New("invite is used, revoked or expired")
+	ErrUserDisabled    = errors.New("user is disabled")
+	ErrEmailExists     = errors.New("email already exists")
+	ErrSharedLedgers   = errors.New("user owns shared ledgers")
 )
 
 type usersRepository interface {
 	Create(user *models.User) (int64, error)
+	CreateInvited(user *models.User, inviteID int64) (int64, error)
 	Get(userID int64) (*models.User, error)
 	Auth(login string) (*models.User, error)
+	SetLastLogin(userID int64) error
+	UpdateProfile(userID int64, name string, birth time.Time, timezone string) error
+	SetLanguage(userID int64, language string) error
+	SetPassword(userID int64, hash string) error
+	Delete(userID int64) error
 }
 
-type operationsRepository interface {
-	Get(userID, page, size int64) (*models.OperationPaginator, error)
+type hasher interface {
+	Hash(password string) (string, error)
+	Check(password, hash string) (ok, rehash bool)
 }
 
 // Service Сервис управления пользователями
 type Service struct {
-	usersRepo      usersRepository
-	operationsRepo operationsRepository
+	usersRepo usersRepository
+	passwords hasher
+	policy    passwords.Policy
 }
 
 // New Возвращает инициализированный экземпляр сервиса
-func New(usersRepo usersRepository, operationRepo operationsRepository) *Service {
+// policy задает требования к паролям, которые пользователи выбирают при регистрации, смене и восстановлении пароля
+func New(usersRepo usersRepository, passwords hasher, policy passwords.Policy) *Service {
 	return &Service{
-		usersRepo:      usersRepo,
-		operationsRepo: operationRepo,
+		usersRepo: usersRepo,
+		passwords: passwords,
+		policy:    policy,
 	}
 }
 
+// PasswordPolicy Возвращает требования к новым паролям
+func (s *Service) PasswordPolicy() passwords.Policy {
+	return s.policy
+}
+
 // GetUser Возвращает пользователя по его идентификатору
 func (s *Service) GetUser(userID int64) (*models.User, error) {
 	user, err := s.usersRepo.Get(userID)
@@ -49,12 +68,6 @@ func (s *Service) GetUser(userID int64) (*models.User, error) {
 		return nil, err
 	}
 
-	balance, err := s.getBalance(userID)
-	if err != nil {
-		return nil, err
-	}
-
-	user.Balance = balance
 	return user, nil
 }
 
@@ -66,25 +79,58 @@ func (s *Service) Auth(login, password string) (*models.User, error) {
 		return nil, ErrInvalidPassword
 	}
 
-	equals := checkPasswordHash(password, user.Password)
+	equals, rehash := s.passwords.Check(password, user.Password)
 	if !equals {
-		logger.Log.WithField("user", user).Errorf("invalid password error")
+		logger.Log.WithField("userID", user.ID).Errorf("invalid password error")
 		return nil, ErrInvalidPassword
 	}
 
-	balance, err := s.getBalance(user.ID)
-	if err != nil {
-		return nil, err
+	// Заблокированный пользователь не может войти даже с верным паролем
+	if user.Disabled != nil {
+		logger.Log.WithField("userID", user.ID).Errorf("auth error: user disabled")
+		return nil, ErrUserDisabled
+	}
+
+	// Хеш устаревшего алгоритма или с устаревшими параметрами пересчитываем, пока известен пароль
+	// Ошибка пересчета не мешает авторизации, хеш будет пересчитан при следующем входе
+	if rehash {
+		s.rehash(user, password)
+	}
+
+	// Ошибка записи времени входа не мешает авторизации
+	if err = s.usersRepo.SetLastLogin(user.ID); err != nil {
+		logger.Log.WithError(err).WithField("userID", user.ID).Errorf("set last login error")
 	}
 
-	user.Balance = balance
 	return user, nil
 }
 
+// IsActive Проверяет, что пользователь существует и не заблокирован
+func (s *Service) IsActive(userID int64) bool {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return false
+	}
+
+	return user.Disabled == nil
+}
+
+// GetUserID Возвращает идентификатор пользователя по его логину
+func (s *Service) GetUserID(login string) (int64, error) {
+	user, err := s.usersRepo.Auth(login)
+	if err != nil {
+		logger.Log.WithError(err).WithField("login", login).Errorf("get user by login error")
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
 // Create Создает нового пользователя
-func (s *Service) Create(login, password, name string, birth time.Time) (int64, error) {
+// Если указан идентификатор приглашения, то приглашение используется при регистрации
+func (s *Service) Create(login, password, email, name string, birth time.Time, inviteID int64) (int64, error) {
 	// В базу сохраняется хеш пароля
-	hashedPassword, err := hashPassword(password)
+	hashedPassword, err := s.passwords.Hash(password)
 	if err != nil {
 		logger.Log.WithError(err).Errorf("hash password error")
 		return 0, err
@@ -93,11 +139,26 @@ func (s *Service) Create(login, password, name string, birth time.Time) (int64,
 	user := &models.User{
 		Login:    login,
 		Password: hashedPassword,
+		Email:    email,
 		Name:     name,
 		Birth:    birth,
 	}
 
-	userID, err := s.usersRepo.Create(user)
+	var userID int64
+	if inviteID == 0 {
+		userID, err = s.usersRepo.Create(user)
+	} else {
+		userID, err = s.usersRepo.CreateInvited(user, inviteID)
+	}
+
+	if err == users.ErrInviteUnavailable {
+		logger.Log.WithError(err).WithField("inviteID", inviteID).Errorf("create user error: invite unavailable")
+		return 0, ErrInvalidInvite
+	}
+	if err == users.ErrDuplicateEmail {
+		logger.Log.WithError(err).Errorf("create user error: email already exists")
+		return 0, ErrEmailExists
+	}
 	if err == users.ErrDuplicateKey {
 		logger.Log.WithError(err).Errorf("create user error: login already exists")
 		return 0, ErrLoginExists
@@ -110,38 +171,103 @@ func (s *Service) Create(login, password, name string, birth time.Time) (int64,
 	return userID, nil
 }
 
-// Считает баланс указанного пользователя
-func (s *Service) getBalance(userID int64) (int64, error) {
-	paginator, err := s.operationsRepo.Get(userID, 0, 0)
+// UpdateProfile Изменяет настоящее имя, дату рождения и часовой пояс пользователя
+// Пустой часовой пояс означает часовой пояс по умолчанию
+func (s *Service) UpdateProfile(userID int64, name string, birth time.Time, timezone string) error {
+	err := s.usersRepo.UpdateProfile(userID, name, birth, timezone)
 	if err != nil {
-		logger.Log.WithError(err).WithField("userID", userID).Errorf("get balance error")
-		return 0, err
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("update profile error")
+		return err
+	}
+
+	return nil
+}
+
+// SetLanguage Изменяет язык интерфейса пользователя, пустой язык включает выбор по настройкам браузера
+func (s *Service) SetLanguage(userID int64, language string) error {
+	err := s.usersRepo.SetLanguage(userID, language)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("set language error")
+		return err
+	}
+
+	return nil
+}
+
+// ChangePassword Меняет пароль пользователя после проверки текущего пароля
+// Если текущий пароль неверный, то возвращает ErrInvalidPassword
+func (s *Service) ChangePassword(userID int64, current, password string) error {
+	if err := s.checkPassword(userID, current); err != nil {
+		return err
 	}
 
-	if len(paginator.Operations) == 0 {
-		return 0, nil
+	hashedPassword, err := s.passwords.Hash(password)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("hash password error")
+		return err
 	}
 
-	balance := int64(0)
-	for _, o := range paginator.Operations {
-		if o.Type == models.Deposit {
-			balance += o.Amount
-		} else {
-			balance -= o.Amount
-		}
+	err = s.usersRepo.SetPassword(userID, hashedPassword)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("set password error")
+		return err
 	}
 
-	return balance, nil
+	return nil
 }
 
-// Берет хеш от пароля
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+// Delete Удаляет аккаунт пользователя со всеми его данными после проверки текущего пароля
+// Если пароль неверный, то возвращает ErrInvalidPassword
+// Если в бухгалтериях пользователя есть другие участники, то возвращает ErrSharedLedgers:
+// сначала их нужно исключить, что бы вместе с аккаунтом не пропали их операции
+func (s *Service) Delete(userID int64, password string) error {
+	if err := s.checkPassword(userID, password); err != nil {
+		return err
+	}
+
+	err := s.usersRepo.Delete(userID)
+	if err == users.ErrSharedLedgers {
+		logger.Log.WithField("userID", userID).Errorf("delete user with shared ledgers error")
+		return ErrSharedLedgers
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("delete user error")
+		return err
+	}
+
+	return nil
 }
 
-// Сравнивает пароль с его хешем
-func checkPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// Проверяет, что пароль совпадает с текущим паролем пользователя
+// У пользователей, вошедших через внешнего провайдера, пароля нет и проверка всегда неуспешна
+func (s *Service) checkPassword(userID int64, password string) error {
+	user, err := s.usersRepo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get user error")
+		return err
+	}
+
+	if ok, _ := s.passwords.Check(password, user.Password); !ok {
+		logger.Log.WithField("userID", userID).Errorf("invalid password error")
+		return ErrInvalidPassword
+	}
+
+	return nil
+}
+
+// Пересчитывает хеш пароля пользователя текущим алгоритмом
+func (s *Service) rehash(user *models.User, password string) {
+	hashedPassword, err := s.passwords.Hash(password)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", user.ID).Errorf("rehash password error")
+		return
+	}
+
+	if err = s.usersRepo.SetPassword(user.ID, hashedPassword); err != nil {
+		logger.Log.WithError(err).WithField("userID", user.ID).Errorf("save rehashed password error")
+		return
+	}
+
+	user.Password = hashedPassword
+	logger.Log.WithField("userID", user.ID).Info("password rehashed")
 }
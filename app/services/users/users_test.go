@@ -2,42 +2,41 @@ package users
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/passwords"
+	repository "github.com/bgoldovsky/casher/app/repositories/users"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
+	// Легкие параметры, что бы тесты не тратили время на хеширование
+	argon2Params  = passwords.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	testPasswords = passwords.New(passwords.NewArgon2id(argon2Params), passwords.NewBcrypt(bcrypt.MinCost))
+
 	user = models.User{
 		Login:    "test-user",
 		Password: "qwerty",
 		Name:     "jon doe",
 		Birth:    time.Now(),
 	}
-
-	operation = models.Operation{
-		UserID:  123,
-		Subject: "test-subj",
-		Amount:  1000,
-		Type:    1,
-		Message: "test-msg",
-	}
 )
 
 func TestService_Get_UsersError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	usersRepo := NewMockusersRepository(ctrl)
-	operationsRepo := NewMockoperationsRepository(ctrl)
 
 	expErr := errors.New("test error")
 
 	usersRepo.EXPECT().Get(gomock.Any()).Return(nil, expErr)
 
-	service := New(usersRepo, operationsRepo)
+	service := New(usersRepo, testPasswords, passwords.Policy{})
 
 	act, err := service.GetUser(user.ID)
 
@@ -45,78 +44,175 @@ func TestService_Get_UsersError(t *testing.T) {
 	assert.ErrorIs(t, err, expErr)
 }
 
-func TestService_Get_OperationsError(t *testing.T) {
+func TestService_Get_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	usersRepo := NewMockusersRepository(ctrl)
-	operationsRepo := NewMockoperationsRepository(ctrl)
-
-	expErr := errors.New("test error")
 
 	usersRepo.EXPECT().Get(gomock.Any()).Return(&user, nil)
-	operationsRepo.EXPECT().Get(gomock.Any(), int64(0), int64(0)).Return(nil, expErr)
 
-	service := New(usersRepo, operationsRepo)
+	service := New(usersRepo, testPasswords, passwords.Policy{})
 
 	act, err := service.GetUser(user.ID)
 
+	assert.NoError(t, err)
+	assert.Equal(t, &user, act)
+}
+
+func TestService_Login_UsersError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	expErr := errors.New("test error")
+
+	usersRepo.EXPECT().Auth(user.Login).Return(nil, expErr)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Auth(user.Login, user.Password)
+
 	assert.Nil(t, act)
-	assert.ErrorIs(t, err, expErr)
+	assert.ErrorIs(t, err, ErrInvalidPassword)
 }
 
-func TestService_Get_Success(t *testing.T) {
+func TestService_Login_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	usersRepo := NewMockusersRepository(ctrl)
-	operationsRepo := NewMockoperationsRepository(ctrl)
 
-	operations := models.OperationPaginator{
-		Operations: []models.Operation{operation},
-		HasMore:    false,
-	}
+	hash, err := testPasswords.Hash(user.Password)
+	assert.NoError(t, err)
 
-	usersRepo.EXPECT().Get(gomock.Any()).Return(&user, nil)
-	operationsRepo.EXPECT().Get(gomock.Any(), int64(0), int64(0)).Return(&operations, nil)
+	stored := user
+	stored.ID = 55
+	stored.Password = hash
 
-	service := New(usersRepo, operationsRepo)
+	usersRepo.EXPECT().Auth(user.Login).Return(&stored, nil)
+	usersRepo.EXPECT().SetLastLogin(stored.ID).Return(nil)
 
-	act, err := service.GetUser(user.ID)
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Auth(user.Login, user.Password)
 
 	assert.NoError(t, err)
-	assert.Equal(t, &user, act)
+	assert.Equal(t, &stored, act)
 }
 
-func TestService_Login_UsersError(t *testing.T) {
+func TestService_Login_Rehash(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	usersRepo := NewMockusersRepository(ctrl)
-	operationsRepo := NewMockoperationsRepository(ctrl)
 
-	expErr := errors.New("test error")
+	// Хеш bcrypt, сохраненный до перехода на argon2id
+	hash, err := passwords.NewBcrypt(bcrypt.MinCost).Hash(user.Password)
+	assert.NoError(t, err)
 
-	usersRepo.EXPECT().Auth(user.Login).Return(nil, expErr)
+	stored := user
+	stored.ID = 55
+	stored.Password = hash
+
+	var rehashed string
+	usersRepo.EXPECT().Auth(user.Login).Return(&stored, nil)
+	usersRepo.EXPECT().SetPassword(stored.ID, gomock.Any()).DoAndReturn(func(_ int64, newHash string) error {
+		rehashed = newHash
+		return nil
+	})
+	usersRepo.EXPECT().SetLastLogin(stored.ID).Return(nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Auth(user.Login, user.Password)
+
+	assert.NoError(t, err)
+	assert.Equal(t, rehashed, act.Password)
+	assert.True(t, strings.HasPrefix(rehashed, "$argon2id$"))
+
+	ok, rehash := testPasswords.Check(user.Password, rehashed)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestService_Login_RehashError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	hash, err := passwords.NewBcrypt(bcrypt.MinCost).Hash(user.Password)
+	assert.NoError(t, err)
+
+	stored := user
+	stored.ID = 55
+	stored.Password = hash
+
+	// Ошибка сохранения нового хеша не мешает входу
+	usersRepo.EXPECT().Auth(user.Login).Return(&stored, nil)
+	usersRepo.EXPECT().SetPassword(stored.ID, gomock.Any()).Return(errors.New("test error"))
+	usersRepo.EXPECT().SetLastLogin(stored.ID).Return(nil)
 
-	service := New(usersRepo, operationsRepo)
+	service := New(usersRepo, testPasswords, passwords.Policy{})
 
 	act, err := service.Auth(user.Login, user.Password)
 
+	assert.NoError(t, err)
+	assert.Equal(t, hash, act.Password)
+}
+
+func TestService_Login_NoPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	// У пользователя, созданного через SSO, пароля нет, и никакой пароль к нему не подходит
+	stored := user
+	stored.ID = 55
+	stored.Password = "!"
+
+	usersRepo.EXPECT().Auth(user.Login).Return(&stored, nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Auth(user.Login, "!")
+
 	assert.Nil(t, act)
 	assert.ErrorIs(t, err, ErrInvalidPassword)
 }
 
+func TestService_Login_Disabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	hash, err := testPasswords.Hash(user.Password)
+	assert.NoError(t, err)
+
+	disabled := time.Now()
+	stored := user
+	stored.Password = hash
+	stored.Disabled = &disabled
+
+	usersRepo.EXPECT().Auth(user.Login).Return(&stored, nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Auth(user.Login, user.Password)
+
+	assert.Nil(t, act)
+	assert.ErrorIs(t, err, ErrUserDisabled)
+}
+
 func TestService_Create_Error(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	usersRepo := NewMockusersRepository(ctrl)
-	operationsRepo := NewMockoperationsRepository(ctrl)
 
 	expErr := errors.New("test error")
 
 	usersRepo.EXPECT().Create(gomock.Any()).Return(int64(0), expErr)
 
-	service := New(usersRepo, operationsRepo)
+	service := New(usersRepo, testPasswords, passwords.Policy{})
 
-	act, err := service.Create(user.Login, user.Password, user.Name, user.Birth)
+	act, err := service.Create(user.Login, user.Password, user.Email, user.Name, user.Birth, 0)
 
 	assert.Empty(t, act)
 	assert.ErrorIs(t, err, expErr)
@@ -126,16 +222,191 @@ func TestService_Create_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	usersRepo := NewMockusersRepository(ctrl)
-	operationsRepo := NewMockoperationsRepository(ctrl)
 
 	expID := int64(55)
 
 	usersRepo.EXPECT().Create(gomock.Any()).Return(expID, nil)
 
-	service := New(usersRepo, operationsRepo)
+	service := New(usersRepo, testPasswords, passwords.Policy{})
 
-	act, err := service.Create(user.Login, user.Password, user.Name, user.Birth)
+	act, err := service.Create(user.Login, user.Password, user.Email, user.Name, user.Birth, 0)
 
 	assert.Equal(t, act, expID)
 	assert.NoError(t, err)
 }
+
+func TestService_Create_EmailExists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	usersRepo.EXPECT().Create(gomock.Any()).Return(int64(0), repository.ErrDuplicateEmail)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Create(user.Login, user.Password, "jondoe@example.com", user.Name, user.Birth, 0)
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrEmailExists)
+}
+
+func TestService_Create_Invited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	expID := int64(55)
+
+	usersRepo.EXPECT().CreateInvited(gomock.Any(), int64(7)).Return(expID, nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Create(user.Login, user.Password, user.Email, user.Name, user.Birth, 7)
+
+	assert.Equal(t, act, expID)
+	assert.NoError(t, err)
+}
+
+func TestService_Create_InviteUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	usersRepo.EXPECT().CreateInvited(gomock.Any(), int64(7)).Return(int64(0), repository.ErrInviteUnavailable)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.Create(user.Login, user.Password, user.Email, user.Name, user.Birth, 7)
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrInvalidInvite)
+}
+
+func TestService_GetUserID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	exp := user
+	exp.ID = 123
+
+	usersRepo.EXPECT().Auth(exp.Login).Return(&exp, nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	act, err := service.GetUserID(exp.Login)
+
+	assert.NoError(t, err)
+	assert.Equal(t, exp.ID, act)
+}
+
+func TestService_UpdateProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	usersRepo.EXPECT().UpdateProfile(int64(55), user.Name, user.Birth, "Asia/Tokyo").Return(nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	assert.NoError(t, service.UpdateProfile(55, user.Name, user.Birth, "Asia/Tokyo"))
+}
+
+func TestService_SetLanguage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	usersRepo.EXPECT().SetLanguage(int64(55), "en").Return(nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	assert.NoError(t, service.SetLanguage(55, "en"))
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	hash, err := testPasswords.Hash(user.Password)
+	assert.NoError(t, err)
+
+	stored := user
+	stored.ID = 55
+	stored.Password = hash
+
+	usersRepo.EXPECT().Get(stored.ID).Return(&stored, nil)
+	usersRepo.EXPECT().SetPassword(stored.ID, gomock.Any()).DoAndReturn(func(_ int64, newHash string) error {
+		ok, _ := testPasswords.Check("Qwerty1!", newHash)
+		assert.True(t, ok)
+		return nil
+	})
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	assert.NoError(t, service.ChangePassword(stored.ID, user.Password, "Qwerty1!"))
+}
+
+func TestService_ChangePassword_InvalidPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	hash, err := testPasswords.Hash(user.Password)
+	assert.NoError(t, err)
+
+	stored := user
+	stored.ID = 55
+	stored.Password = hash
+
+	usersRepo.EXPECT().Get(stored.ID).Return(&stored, nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	err = service.ChangePassword(stored.ID, "wrong", "Qwerty1!")
+
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+}
+
+func TestService_Delete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	hash, err := testPasswords.Hash(user.Password)
+	assert.NoError(t, err)
+
+	stored := user
+	stored.ID = 55
+	stored.Password = hash
+
+	usersRepo.EXPECT().Get(stored.ID).Return(&stored, nil).Times(2)
+	usersRepo.EXPECT().Delete(stored.ID).Return(nil)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	// Без верного пароля аккаунт не удаляется
+	assert.ErrorIs(t, service.Delete(stored.ID, "wrong"), ErrInvalidPassword)
+	assert.NoError(t, service.Delete(stored.ID, user.Password))
+}
+
+func TestService_Delete_SharedLedgers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := NewMockusersRepository(ctrl)
+
+	hash, err := testPasswords.Hash(user.Password)
+	assert.NoError(t, err)
+
+	stored := user
+	stored.ID = 55
+	stored.Password = hash
+
+	usersRepo.EXPECT().Get(stored.ID).Return(&stored, nil)
+	usersRepo.EXPECT().Delete(stored.ID).Return(repository.ErrSharedLedgers)
+
+	service := New(usersRepo, testPasswords, passwords.Policy{})
+
+	assert.ErrorIs(t, service.Delete(stored.ID, user.Password), ErrSharedLedgers)
+}
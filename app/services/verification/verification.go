@@ -0,0 +1,159 @@
+//go:generate mockgen -source=verification.go -destination=./mocks.go -package=verification
+
+package verification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/mailer"
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+const (
+	// Время жизни ссылки подтверждения адреса
+	verifyTTL = 48 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid email verification token error")
+	ErrNoEmail      = errors.New("user has no email error")
+)
+
+type usersRepository interface {
+	Get(userID int64) (*models.User, error)
+	SetEmailVerified(userID int64, email string) error
+}
+
+type sender interface {
+	Send(msg *mailer.Message) error
+}
+
+// Service Сервис подтверждения адресов электронной почты
+// Токен подтверждения не хранится в базе: он подписан секретом и содержит ID пользователя и срок действия,
+// а подпись вычисляется вместе с адресом, поэтому после смены адреса старые ссылки перестают действовать
+type Service struct {
+	usersRepo usersRepository
+	mailer    sender
+	secret    []byte
+	baseURL   string
+	required  bool
+	now       func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+// required определяет, обязателен ли адрес почты при регистрации
+func New(usersRepo usersRepository, mailer sender, secret []byte, baseURL string, required bool) *Service {
+	return &Service{
+		usersRepo: usersRepo,
+		mailer:    mailer,
+		secret:    secret,
+		baseURL:   baseURL,
+		required:  required,
+		now:       time.Now,
+	}
+}
+
+// Required Сообщает, обязателен ли адрес почты при регистрации
+func (s *Service) Required() bool {
+	return s.required
+}
+
+// Unverified Возвращает адрес пользователя, если он указан, но еще не подтвержден
+func (s *Service) Unverified(userID int64) string {
+	user, err := s.usersRepo.Get(userID)
+	if err != nil || user.HasVerifiedEmail() {
+		return ""
+	}
+
+	return user.Email
+}
+
+// Send Отправляет пользователю письмо со ссылкой для подтверждения адреса
+// Если адрес уже подтвержден, письмо не отправляется
+func (s *Service) Send(userID int64) error {
+	user, err := s.usersRepo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get user error")
+		return err
+	}
+
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+
+	if user.HasVerifiedEmail() {
+		return nil
+	}
+
+	payload := strconv.FormatInt(user.ID, 10) + "." + strconv.FormatInt(s.now().Add(verifyTTL).Unix(), 10)
+	token := payload + "." + s.sign(payload, user.Email)
+
+	link := fmt.Sprintf("%s/email/verify/?token=%s", s.baseURL, url.QueryEscape(token))
+	err = s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Подтверждение адреса Casher",
+		Body: fmt.Sprintf(
+			"Здравствуйте, %s!\n\nДля подтверждения адреса перейдите по ссылке:\n%s\n\nСсылка действует %d часов.\n",
+			user.Name, link, int(verifyTTL.Hours()),
+		),
+	})
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("send email verification error")
+		return err
+	}
+
+	return nil
+}
+
+// Verify Проверяет токен из ссылки и отмечает адрес пользователя подтвержденным
+// Возвращает ID пользователя, адрес которого подтвержден
+func (s *Service) Verify(token string) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !s.now().Before(time.Unix(expires, 0)) {
+		return 0, ErrInvalidToken
+	}
+
+	user, err := s.usersRepo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get user error")
+		return 0, ErrInvalidToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if user.Email == "" || !hmac.Equal([]byte(parts[2]), []byte(s.sign(payload, user.Email))) {
+		return 0, ErrInvalidToken
+	}
+
+	if err = s.usersRepo.SetEmailVerified(user.ID, user.Email); err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("set email verified error")
+		return 0, ErrInvalidToken
+	}
+
+	return user.ID, nil
+}
+
+// Подписывает данные токена вместе с адресом пользователя
+func (s *Service) sign(payload, email string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload + "." + strings.ToLower(email)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
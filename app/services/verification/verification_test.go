@@ -0,0 +1,175 @@
+package verification
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/mailer"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseURL = "https://casher.example.com"
+
+var user = models.User{ID: 55, Login: "jondoe", Email: "jondoe@example.com", Name: "Jon Doe"}
+
+type testService struct {
+	users   *MockusersRepository
+	mailer  *Mocksender
+	service *Service
+}
+
+func newTestService(t *testing.T) *testService {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	ts := &testService{
+		users:  NewMockusersRepository(ctrl),
+		mailer: NewMocksender(ctrl),
+	}
+	ts.service = New(ts.users, ts.mailer, []byte("secret"), baseURL, false)
+
+	return ts
+}
+
+// Отправляет письмо и достает токен из ссылки в нем
+func sendToken(t *testing.T, ts *testService) string {
+	var sent *mailer.Message
+	ts.users.EXPECT().Get(user.ID).Return(&user, nil)
+	ts.mailer.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg *mailer.Message) error {
+		sent = msg
+		return nil
+	})
+
+	require.NoError(t, ts.service.Send(user.ID))
+	assert.Equal(t, user.Email, sent.To)
+
+	idx := strings.Index(sent.Body, baseURL)
+	require.NotEqual(t, -1, idx)
+
+	u, err := url.Parse(strings.Fields(sent.Body[idx:])[0])
+	require.NoError(t, err)
+	assert.Equal(t, "/email/verify/", u.Path)
+
+	return u.Query().Get("token")
+}
+
+func TestService_Verify(t *testing.T) {
+	ts := newTestService(t)
+	token := sendToken(t, ts)
+
+	ts.users.EXPECT().Get(user.ID).Return(&user, nil)
+	ts.users.EXPECT().SetEmailVerified(user.ID, user.Email).Return(nil)
+
+	act, err := ts.service.Verify(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, act)
+}
+
+func TestService_Verify_EmailChanged(t *testing.T) {
+	ts := newTestService(t)
+	token := sendToken(t, ts)
+
+	// После смены адреса старая ссылка не подтверждает новый адрес
+	changed := user
+	changed.Email = "other@example.com"
+	ts.users.EXPECT().Get(user.ID).Return(&changed, nil)
+
+	act, err := ts.service.Verify(token)
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_Verify_Expired(t *testing.T) {
+	ts := newTestService(t)
+	token := sendToken(t, ts)
+
+	ts.service.now = func() time.Time { return time.Now().Add(verifyTTL + time.Minute) }
+
+	act, err := ts.service.Verify(token)
+
+	assert.Empty(t, act)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_Verify_Invalid(t *testing.T) {
+	ts := newTestService(t)
+	token := sendToken(t, ts)
+
+	tests := []string{
+		"",
+		"garbage",
+		"55.abc.def",
+		token + "0",
+		strings.Replace(token, "55.", "56.", 1),
+	}
+
+	ts.users.EXPECT().Get(gomock.Any()).Return(&user, nil).AnyTimes()
+
+	for _, tt := range tests {
+		act, err := ts.service.Verify(tt)
+		assert.Empty(t, act)
+		assert.ErrorIs(t, err, ErrInvalidToken, tt)
+	}
+}
+
+func TestService_Send_AlreadyVerified(t *testing.T) {
+	ts := newTestService(t)
+
+	verified := time.Now()
+	stored := user
+	stored.EmailVerified = &verified
+	ts.users.EXPECT().Get(user.ID).Return(&stored, nil)
+
+	err := ts.service.Send(user.ID)
+
+	assert.NoError(t, err)
+}
+
+func TestService_Send_NoEmail(t *testing.T) {
+	ts := newTestService(t)
+
+	stored := user
+	stored.Email = ""
+	ts.users.EXPECT().Get(user.ID).Return(&stored, nil)
+
+	err := ts.service.Send(user.ID)
+
+	assert.ErrorIs(t, err, ErrNoEmail)
+}
+
+func TestService_Send_MailError(t *testing.T) {
+	ts := newTestService(t)
+
+	expErr := errors.New("test error")
+	ts.users.EXPECT().Get(user.ID).Return(&user, nil)
+	ts.mailer.EXPECT().Send(gomock.Any()).Return(expErr)
+
+	err := ts.service.Send(user.ID)
+
+	assert.ErrorIs(t, err, expErr)
+}
+
+func TestService_Unverified(t *testing.T) {
+	ts := newTestService(t)
+
+	verified := time.Now()
+	stored := user
+	stored.EmailVerified = &verified
+	gomock.InOrder(
+		ts.users.EXPECT().Get(user.ID).Return(&user, nil),
+		ts.users.EXPECT().Get(user.ID).Return(&stored, nil),
+		ts.users.EXPECT().Get(user.ID).Return(nil, errors.New("test error")),
+	)
+
+	assert.Equal(t, user.Email, ts.service.Unverified(user.ID))
+	assert.Empty(t, ts.service.Unverified(user.ID))
+	assert.Empty(t, ts.service.Unverified(user.ID))
+}
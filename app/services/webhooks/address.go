@@ -0,0 +1,130 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+var (
+	ErrForbiddenAddress = errors.New("forbidden webhook address error")
+)
+
+// Сети, в которые события не отправляются, что бы подписка не открывала доступ к внутренним сервисам от имени сервера:
+// локальные, частные, служебные и link-local адреса, включая адрес метаданных облака 169.254.169.254
+var forbiddenNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/3",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// NewClient Возвращает HTTP клиент для отправки событий с ограничением времени запроса
+// Клиент не соединяется с запрещенными адресами, даже если имя подписки стало указывать на них после проверки,
+// не ходит через прокси из окружения и не следует перенаправлениям
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		// Адрес проверяется после разрешения имени, непосредственно перед соединением
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if forbiddenIP(net.ParseIP(host)) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: requestTimeout,
+			MaxIdleConns:        batchSize,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Проверяет, что адрес подписки ведет в интернет: схема http или https, а все адреса хоста разрешены
+// Иначе возвращает ошибку, содержащую ErrForbiddenAddress
+func (s *Service) checkURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrForbiddenAddress
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if forbiddenIP(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	// Имя, которое не разрешается, тоже не принимается: подписка на него все равно не работала бы
+	addrs, err := s.lookup(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("lookup %s: %v: %w", u.Hostname(), err, ErrForbiddenAddress)
+	}
+	if len(addrs) == 0 {
+		return ErrForbiddenAddress
+	}
+
+	for _, addr := range addrs {
+		if forbiddenIP(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// Проверяет, что адрес входит в запрещенные сети
+func forbiddenIP(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Разбирает список сетей CIDR
+func parseNetworks(list ...string) []*net.IPNet {
+	res := make([]*net.IPNet, len(list))
+	for i, val := range list {
+		_, network, err := net.ParseCIDR(val)
+		if err != nil {
+			panic(err)
+		}
+		res[i] = network
+	}
+
+	return res
+}
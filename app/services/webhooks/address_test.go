@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Subscribe_ForbiddenAddress(t *testing.T) {
+	urls := []string{
+		"http://127.0.0.1/hook",
+		"http://[::1]:8080/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://[fd00::1]/hook",
+		"ftp://example.com/hook",
+		"http://internal.example.com/hook",
+		"http://unknown.example.com/hook",
+	}
+
+	for _, url := range urls {
+		t.Run(url, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Имя может разрешаться во внутренний адрес, а может не разрешаться вовсе
+			service := newService(NewMockrepository(ctrl), nil)
+			service.lookup = func(_ context.Context, host string) ([]net.IPAddr, error) {
+				if host == "internal.example.com" {
+					return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.1.2.3")}}, nil
+				}
+				return nil, errors.New("no such host")
+			}
+
+			_, err := service.Subscribe(webhook.UserID, url, webhook.Events)
+			assert.ErrorIs(t, err, ErrForbiddenAddress)
+		})
+	}
+}
+
+func TestNewClient_ForbiddenAddress(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	// Адрес проверяется при соединении, поэтому смена адреса имени после подписки не помогает
+	_, err := NewClient().Get(receiver.URL)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+}
+
+func TestNewClient_Redirect(t *testing.T) {
+	client := NewClient()
+	require.NotNil(t, client.CheckRedirect)
+
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+	assert.Equal(t, http.ErrUseLastResponse, client.CheckRedirect(req, []*http.Request{req}))
+}
@@ -0,0 +1,327 @@
+//go:generate mockgen -source=webhooks.go -destination=./mocks.go -package=webhooks
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+)
+
+const (
+	// Заголовки запроса с событием
+	HeaderEvent     = "X-Casher-Event"
+	HeaderDelivery  = "X-Casher-Delivery"
+	HeaderSignature = "X-Casher-Signature"
+
+	secretSize     = 32
+	deliveriesSize = 50
+	batchSize      = 20
+	maxAttempts    = 8
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+	requestTimeout = 10 * time.Second
+	// Время, на которое доставка резервируется за отправителем
+	deliveryLease = 2 * requestTimeout
+)
+
+var (
+	ErrUnknownEvent = errors.New("unknown event error")
+)
+
+type repository interface {
+	Create(webhook *models.Webhook) (int64, error)
+	Remove(userID, webhookID int64) error
+	Get(userID int64) ([]models.Webhook, error)
+	GetByLedgerEvent(ledgerID int64, event string) ([]models.Webhook, error)
+	CreateDelivery(delivery *models.Delivery) (int64, error)
+	GetDeliveries(userID, webhookID int64, limit int64) ([]models.Delivery, error)
+	ClaimPending(limit int64, lease time.Duration) ([]models.Delivery, error)
+	UpdateDelivery(delivery *models.Delivery) error
+}
+
+type httpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Service Сервис управления подписками на события и их доставкой
+type Service struct {
+	repo   repository
+	client httpClient
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+	now    func() time.Time
+}
+
+// New Возвращает инициализированный экземпляр сервиса
+func New(repo repository, client httpClient) *Service {
+	return &Service{
+		repo:   repo,
+		client: client,
+		lookup: net.DefaultResolver.LookupIPAddr,
+		now:    time.Now,
+	}
+}
+
+// payload Тело запроса с событием
+type payload struct {
+	Event   string      `json:"event"`
+	Created time.Time   `json:"created_at"`
+	Data    interface{} `json:"data"`
+}
+
+// Subscribe Создает подписку пользователя на события
+// Секрет для подписи запросов генерируется автоматически
+// Если адрес не разрешается или ведет во внутреннюю сеть, то возвращает ошибку, содержащую ErrForbiddenAddress
+func (s *Service) Subscribe(userID int64, url string, events []string) (int64, error) {
+	for _, event := range events {
+		if !isKnownEvent(event) {
+			return 0, ErrUnknownEvent
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := s.checkURL(ctx, url); err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).WithField("url", url).Errorf("check webhook url error")
+		return 0, err
+	}
+
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		logger.Log.WithError(err).Errorf("generate webhook secret error")
+		return 0, err
+	}
+
+	webhookID, err := s.repo.Create(&models.Webhook{
+		UserID: userID,
+		URL:    url,
+		Events: events,
+		Secret: hex.EncodeToString(buf),
+	})
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("create webhook error")
+		return 0, err
+	}
+
+	return webhookID, nil
+}
+
+// Get Возвращает подписки пользователя
+func (s *Service) Get(userID int64) ([]models.Webhook, error) {
+	webhooks, err := s.repo.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Errorf("get webhooks error")
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Remove Удаляет подписку пользователя
+func (s *Service) Remove(userID, webhookID int64) error {
+	err := s.repo.Remove(userID, webhookID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("webhookID", webhookID).Errorf("remove webhook error")
+		return err
+	}
+
+	return nil
+}
+
+// Deliveries Возвращает журнал последних доставок по подписке пользователя
+func (s *Service) Deliveries(userID, webhookID int64) ([]models.Delivery, error) {
+	deliveries, err := s.repo.GetDeliveries(userID, webhookID, deliveriesSize)
+	if err != nil {
+		logger.Log.WithError(err).WithField("webhookID", webhookID).Errorf("get deliveries error")
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Notify Ставит событие бухгалтерии в очередь на доставку подписчикам всех ее участников
+func (s *Service) Notify(ledgerID int64, event string, data interface{}) error {
+	webhooks, err := s.repo.GetByLedgerEvent(ledgerID, event)
+	if err != nil {
+		logger.Log.WithError(err).WithField("ledgerID", ledgerID).Errorf("get webhooks by event error")
+		return err
+	}
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	now := s.now()
+	body, err := json.Marshal(payload{Event: event, Created: now, Data: data})
+	if err != nil {
+		logger.Log.WithError(err).WithField("event", event).Errorf("marshal webhook payload error")
+		return err
+	}
+
+	for _, w := range webhooks {
+		_, err := s.repo.CreateDelivery(&models.Delivery{
+			WebhookID:   w.ID,
+			Event:       event,
+			Payload:     body,
+			Status:      models.DeliveryPending,
+			NextAttempt: now,
+		})
+		if err != nil {
+			logger.Log.WithError(err).WithField("webhookID", w.ID).Errorf("create delivery error")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HandleOperationEvent Обработчик событий операций из outbox
+// Ставит событие в очередь доставки подписчикам бухгалтерии операции, а не только ее автора:
+// операции общей бухгалтерии видят все ее участники
+func (s *Service) HandleOperationEvent(event *models.Event) error {
+	var operation models.Operation
+	if err := json.Unmarshal(event.Payload, &operation); err != nil {
+		// Повтор не исправит некорректное событие, поэтому оно пропускается
+		logger.Log.WithError(err).WithField("eventID", event.ID).Errorf("unmarshal operation event error")
+		return nil
+	}
+
+	return s.Notify(operation.LedgerID, event.Type, &operation)
+}
+
+// Run Периодически отправляет события из очереди, пока не будет отменен контекст
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.DeliverPending()
+		}
+	}
+}
+
+// DeliverPending Отправляет готовые к отправке события
+// Неудачные попытки повторяются с экспоненциально растущей задержкой
+func (s *Service) DeliverPending() error {
+	deliveries, err := s.repo.ClaimPending(batchSize, deliveryLease)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("claim deliveries error")
+		return err
+	}
+
+	for i := range deliveries {
+		d := &deliveries[i]
+		s.deliver(d)
+
+		if err := s.repo.UpdateDelivery(d); err != nil {
+			logger.Log.WithError(err).WithField("deliveryID", d.ID).Errorf("update delivery error")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Выполняет попытку доставки и записывает ее результат в модель
+func (s *Service) deliver(d *models.Delivery) {
+	d.Attempts++
+
+	code, err := s.send(d)
+	d.ResponseCode = code
+
+	if err == nil {
+		now := s.now()
+		d.Status = models.DeliverySucceeded
+		d.LastError = ""
+		d.Delivered = &now
+		return
+	}
+
+	logger.Log.WithError(err).WithField("deliveryID", d.ID).Warn("webhook delivery error")
+	d.LastError = err.Error()
+
+	if d.Attempts >= maxAttempts {
+		d.Status = models.DeliveryFailed
+		return
+	}
+
+	d.Status = models.DeliveryPending
+	d.NextAttempt = s.now().Add(backoff(d.Attempts))
+}
+
+// Отправляет подписанный запрос с событием
+func (s *Service) send(d *models.Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.Webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, fmt.Sprint(d.ID))
+	req.Header.Set(HeaderSignature, Sign(d.Webhook.Secret, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func(body io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, body)
+		_ = body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign Возвращает подпись тела запроса HMAC-SHA256 в формате sha256=<hex>
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Рассчитывает задержку перед следующей попыткой доставки
+func backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return delay
+}
+
+// Проверяет, что на событие можно подписаться
+func isKnownEvent(event string) bool {
+	for _, e := range models.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
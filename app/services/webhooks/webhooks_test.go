@@ -0,0 +1,303 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	now = time.Date(2021, 9, 14, 12, 0, 0, 0, time.UTC)
+
+	webhook = models.Webhook{
+		ID:     1,
+		UserID: 123,
+		URL:    "https://example.com/hook",
+		Events: []string{models.EventOperationCreated},
+		Secret: "secret",
+	}
+
+	operation = models.Operation{
+		ID:       10,
+		LedgerID: 20,
+		UserID:   123,
+		Subject:  "test-subj",
+		Amount:   1000,
+		Type:     models.Deposit,
+		Message:  "test-msg",
+	}
+)
+
+// Возвращает сервис с зафиксированным временем, имена хостов разрешаются в публичный адрес
+func newService(repo repository, client httpClient) *Service {
+	service := New(repo, client)
+	service.now = func() time.Time { return now }
+	service.lookup = func(context.Context, string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+	}
+	return service
+}
+
+func TestService_Subscribe_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	var saved *models.Webhook
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(w *models.Webhook) (int64, error) {
+		saved = w
+		return 1, nil
+	})
+
+	service := newService(repo, nil)
+	act, err := service.Subscribe(webhook.UserID, webhook.URL, webhook.Events)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), act)
+	assert.Equal(t, webhook.URL, saved.URL)
+	assert.Len(t, saved.Secret, secretSize*2)
+}
+
+func TestService_Subscribe_UnknownEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	service := newService(repo, nil)
+	_, err := service.Subscribe(webhook.UserID, webhook.URL, []string{"user.created"})
+
+	assert.ErrorIs(t, err, ErrUnknownEvent)
+}
+
+func TestService_Notify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	var saved *models.Delivery
+	repo.EXPECT().GetByLedgerEvent(operation.LedgerID, models.EventOperationCreated).Return([]models.Webhook{webhook}, nil)
+	repo.EXPECT().CreateDelivery(gomock.Any()).DoAndReturn(func(d *models.Delivery) (int64, error) {
+		saved = d
+		return 1, nil
+	})
+
+	service := newService(repo, nil)
+	err := service.Notify(operation.LedgerID, models.EventOperationCreated, &operation)
+
+	require.NoError(t, err)
+	assert.Equal(t, webhook.ID, saved.WebhookID)
+	assert.Equal(t, models.DeliveryPending, saved.Status)
+	assert.Equal(t, now, saved.NextAttempt)
+
+	var body struct {
+		Event string           `json:"event"`
+		Data  models.Operation `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(saved.Payload, &body))
+	assert.Equal(t, models.EventOperationCreated, body.Event)
+	assert.Equal(t, operation.Subject, body.Data.Subject)
+}
+
+func TestService_Notify_NoSubscribers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	repo.EXPECT().GetByLedgerEvent(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	service := newService(repo, nil)
+	err := service.Notify(operation.LedgerID, models.EventOperationDeleted, &operation)
+
+	assert.NoError(t, err)
+}
+
+func TestService_HandleOperationEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	payload, err := json.Marshal(operation)
+	require.NoError(t, err)
+
+	// Событие получают подписчики всех участников бухгалтерии, а не только автор операции
+	member := webhook
+	member.ID, member.UserID = 2, 456
+	repo.EXPECT().GetByLedgerEvent(operation.LedgerID, models.EventOperationDeleted).Return([]models.Webhook{webhook, member}, nil)
+	repo.EXPECT().CreateDelivery(gomock.Any()).Return(int64(1), nil).Times(2)
+
+	service := newService(repo, nil)
+	err = service.HandleOperationEvent(&models.Event{
+		ID:      1,
+		Type:    models.EventOperationDeleted,
+		Payload: payload,
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestService_HandleOperationEvent_InvalidPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	// Некорректное событие пропускается, иначе outbox повторял бы его бесконечно
+	service := newService(repo, nil)
+	err := service.HandleOperationEvent(&models.Event{
+		ID:      1,
+		Type:    models.EventOperationCreated,
+		Payload: []byte("{"),
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestService_DeliverPending_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	payload := []byte(`{"event":"operation.created"}`)
+
+	var received *http.Request
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	hook := webhook
+	hook.URL = receiver.URL
+
+	var updated *models.Delivery
+	repo.EXPECT().ClaimPending(gomock.Any(), gomock.Any()).Return([]models.Delivery{{
+		ID:      5,
+		Event:   models.EventOperationCreated,
+		Payload: payload,
+		Status:  models.DeliveryPending,
+		Webhook: hook,
+	}}, nil)
+	repo.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(d *models.Delivery) error {
+		updated = d
+		return nil
+	})
+
+	service := newService(repo, receiver.Client())
+	err := service.DeliverPending()
+
+	require.NoError(t, err)
+	require.NotNil(t, received)
+	assert.Equal(t, payload, receivedBody)
+	assert.Equal(t, models.EventOperationCreated, received.Header.Get(HeaderEvent))
+	assert.Equal(t, "5", received.Header.Get(HeaderDelivery))
+	assert.Equal(t, Sign(hook.Secret, payload), received.Header.Get(HeaderSignature))
+
+	assert.Equal(t, models.DeliverySucceeded, updated.Status)
+	assert.Equal(t, 1, updated.Attempts)
+	assert.Equal(t, http.StatusNoContent, updated.ResponseCode)
+	assert.Equal(t, &now, updated.Delivered)
+}
+
+func TestService_DeliverPending_Retry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	hook := webhook
+	hook.URL = receiver.URL
+
+	var updated *models.Delivery
+	repo.EXPECT().ClaimPending(gomock.Any(), gomock.Any()).Return([]models.Delivery{{
+		ID:       5,
+		Attempts: 2,
+		Status:   models.DeliveryPending,
+		Webhook:  hook,
+	}}, nil)
+	repo.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(d *models.Delivery) error {
+		updated = d
+		return nil
+	})
+
+	service := newService(repo, receiver.Client())
+	err := service.DeliverPending()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryPending, updated.Status)
+	assert.Equal(t, 3, updated.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, updated.ResponseCode)
+	assert.Equal(t, now.Add(4*initialBackoff), updated.NextAttempt)
+	assert.NotEmpty(t, updated.LastError)
+	assert.Nil(t, updated.Delivered)
+}
+
+func TestService_DeliverPending_GiveUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	hook := webhook
+	hook.URL = receiver.URL
+
+	var updated *models.Delivery
+	repo.EXPECT().ClaimPending(gomock.Any(), gomock.Any()).Return([]models.Delivery{{
+		ID:       5,
+		Attempts: maxAttempts - 1,
+		Status:   models.DeliveryPending,
+		Webhook:  hook,
+	}}, nil)
+	repo.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(d *models.Delivery) error {
+		updated = d
+		return nil
+	})
+
+	service := newService(repo, receiver.Client())
+	err := service.DeliverPending()
+
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryFailed, updated.Status)
+	assert.Equal(t, maxAttempts, updated.Attempts)
+}
+
+func TestService_DeliverPending_ClaimError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	expErr := errors.New("test error")
+
+	repo.EXPECT().ClaimPending(gomock.Any(), gomock.Any()).Return(nil, expErr)
+
+	service := newService(repo, nil)
+	err := service.DeliverPending()
+
+	assert.ErrorIs(t, err, expErr)
+}
+
+func Test_Backoff(t *testing.T) {
+	assert.Equal(t, initialBackoff, backoff(1))
+	assert.Equal(t, 2*initialBackoff, backoff(2))
+	assert.Equal(t, 8*initialBackoff, backoff(4))
+	assert.Equal(t, maxBackoff, backoff(100))
+}
@@ -0,0 +1,105 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Размер секрета в байтах, рекомендованный RFC 4226
+	secretSize = 20
+	// Шаг времени
+	period = 30
+	// Количество цифр в коде
+	digits = 6
+	// Допустимое расхождение часов в шагах в каждую сторону
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret Генерирует случайный секрет в base32 для приложения-аутентификатора
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(buf), nil
+}
+
+// Counter Возвращает номер шага времени для момента t
+func Counter(t time.Time) int64 {
+	return t.Unix() / period
+}
+
+// Code Вычисляет код для секрета и номера шага времени по RFC 6238 (HMAC-SHA1)
+func Code(secret string, counter int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// Динамическое усечение из RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod), nil
+}
+
+// Validate Проверяет код с учетом расхождения часов
+// Возвращает номер шага времени, которому соответствует код, что бы код нельзя было использовать повторно
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != digits {
+		return 0, false
+	}
+
+	current := Counter(t)
+	for counter := current - skew; counter <= current+skew; counter++ {
+		exp, err := Code(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(exp), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI Возвращает ссылку otpauth:// для добавления аккаунта в приложение-аутентификатор
+func URI(secret, issuer, account string) string {
+	label := url.PathEscape(issuer + ":" + account)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(digits))
+	params.Set("period", fmt.Sprint(period))
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
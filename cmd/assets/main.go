@@ -0,0 +1,87 @@
+// Скачивает сторонние статические файлы, которые приложение раздает само из static/assets
+// Содержимое каждого файла сверяется с опубликованным хешем SRI, поэтому подмена на CDN не попадет в приложение
+//
+//	go run ./cmd/assets
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Таймаут скачивания одного файла
+const timeout = 30 * time.Second
+
+// Сторонний статический файл
+type asset struct {
+	url       string
+	path      string
+	integrity string
+}
+
+var assets = []asset{
+	{
+		url:       "https://cdn.jsdelivr.net/npm/bootstrap@5.1.0/dist/css/bootstrap.min.css",
+		path:      "static/assets/css/bootstrap.min.css",
+		integrity: "sha384-KyZXEAg3QhqLMpG8r+8fhAXLRk2vvoC2f3B09zVXn8CA5QIVfZOJ3BCsw2P0p/We",
+	},
+}
+
+func main() {
+	client := &http.Client{Timeout: timeout}
+
+	for _, a := range assets {
+		if err := download(client, a); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", a.path, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// Скачивает файл, если его еще нет или он отличается от опубликованного
+func download(client *http.Client, a asset) error {
+	if data, err := ioutil.ReadFile(a.path); err == nil && integrity(data) == a.integrity {
+		fmt.Printf("%s: up to date\n", a.path)
+		return nil
+	}
+
+	resp, err := client.Get(a.url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if actual := integrity(data); actual != a.integrity {
+		return fmt.Errorf("integrity mismatch: expected %s, got %s", a.integrity, actual)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d bytes\n", a.path, len(data))
+	return ioutil.WriteFile(a.path, data, 0644)
+}
+
+// Вычисляет хеш SRI содержимого файла
+func integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,60 @@
+// Собирает фильтр Блума из списка утекших паролей, по одному паролю в строке
+// Пароли приводятся к нижнему регистру, пустые строки пропускаются
+//
+//	go run ./cmd/bloom -in top.txt -out app/passwords/breached.bloom
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bgoldovsky/casher/app/passwords"
+)
+
+func main() {
+	in := flag.String("in", "", "файл со списком паролей")
+	out := flag.String("out", "", "файл фильтра")
+	falsePositive := flag.Float64("fp", 0.0001, "вероятность ложного срабатывания")
+	flag.Parse()
+
+	if err := build(*in, *out, *falsePositive); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Читает список паролей и записывает фильтр, собранный из него
+func build(in, out string, falsePositive float64) error {
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+
+	var list []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			list = append(list, strings.ToLower(password))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	bloom := passwords.NewBloom(len(list), falsePositive)
+	for _, password := range list {
+		bloom.Add(password)
+	}
+
+	filter, err := bloom.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d passwords, %d bytes\n", len(list), len(filter))
+	return ioutil.WriteFile(out, filter, 0644)
+}
@@ -1,29 +1,293 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/mailer"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/oidc"
+	"github.com/bgoldovsky/casher/app/passwords"
+	attemptsRepo "github.com/bgoldovsky/casher/app/repositories/attempts"
+	auditRepo "github.com/bgoldovsky/casher/app/repositories/audit"
+	exportsRepo "github.com/bgoldovsky/casher/app/repositories/exports"
+	invitesRepo "github.com/bgoldovsky/casher/app/repositories/invites"
+	ledgersRepo "github.com/bgoldovsky/casher/app/repositories/ledgers"
 	operationsRepo "github.com/bgoldovsky/casher/app/repositories/operations"
+	outboxRepo "github.com/bgoldovsky/casher/app/repositories/outbox"
+	resetsRepo "github.com/bgoldovsky/casher/app/repositories/resets"
+	sessionsRepo "github.com/bgoldovsky/casher/app/repositories/sessions"
+	tokensRepo "github.com/bgoldovsky/casher/app/repositories/tokens"
+	twofactorRepo "github.com/bgoldovsky/casher/app/repositories/twofactor"
 	usersRepo "github.com/bgoldovsky/casher/app/repositories/users"
+	webhooksRepo "github.com/bgoldovsky/casher/app/repositories/webhooks"
+	"github.com/bgoldovsky/casher/app/services/admin"
+	"github.com/bgoldovsky/casher/app/services/attempts"
+	"github.com/bgoldovsky/casher/app/services/audit"
+	"github.com/bgoldovsky/casher/app/services/exports"
+	"github.com/bgoldovsky/casher/app/services/invites"
+	"github.com/bgoldovsky/casher/app/services/ledgers"
 	"github.com/bgoldovsky/casher/app/services/operations"
+	"github.com/bgoldovsky/casher/app/services/outbox"
+	"github.com/bgoldovsky/casher/app/services/recovery"
+	"github.com/bgoldovsky/casher/app/services/sessions"
+	"github.com/bgoldovsky/casher/app/services/sso"
+	"github.com/bgoldovsky/casher/app/services/tokens"
+	"github.com/bgoldovsky/casher/app/services/twofactor"
 	"github.com/bgoldovsky/casher/app/services/users"
+	"github.com/bgoldovsky/casher/app/services/verification"
+	"github.com/bgoldovsky/casher/app/services/webhooks"
 	"github.com/bgoldovsky/casher/config"
 	"github.com/bgoldovsky/casher/handlers"
+	"github.com/bgoldovsky/casher/middleware"
+	"github.com/bgoldovsky/casher/rpc"
+	"github.com/bgoldovsky/casher/static"
+	"github.com/bgoldovsky/casher/templates"
 	_ "github.com/lib/pq"
 )
 
 // TODO: Сделать все красивым HTML+CSS
 
+const (
+	// Период опроса outbox
+	outboxInterval = time.Second
+	// Период опроса очереди доставки событий
+	webhooksInterval = 5 * time.Second
+	// Период удаления истекших сессий
+	sessionsInterval = time.Hour
+	// Период удаления устаревших счетчиков неудачных попыток входа
+	attemptsInterval = time.Hour
+	// Период удаления выгрузок персональных данных с истекшим сроком хранения
+	exportsInterval = time.Hour
+	// Длина соли и хеша argon2id в байтах
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// Длина временного ключа подписи ссылок в байтах
+	secretLength = 32
+	// Таймаут запросов к провайдеру SSO
+	ssoTimeout = 10 * time.Second
+)
+
 // Запускаем сервер
 func handleRequest(handler *handlers.PageHandler, port string) {
 	addr := fmt.Sprintf(":%s", port)
-	if err := http.ListenAndServe(addr, handler.Router()); err != nil {
+	// Адрес клиента за прокси нужен для лимитов попыток входа и журнала аудита
+	proxies, err := middleware.ParseProxies(config.TrustedProxies())
+	if err != nil {
+		panic(err)
+	}
+
+	// HSTS включаем вместе с передачей куки только по HTTPS
+	if err := http.ListenAndServe(addr, middleware.RealIP(middleware.Security(handler.Router(), config.CookieSecure()), proxies)); err != nil {
 		panic(err)
 	}
 }
 
+// Запускаем gRPC сервер
+func handleRPC(server *rpc.Server, port string) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		panic(err)
+	}
+
+	if err := server.GRPCServer().Serve(lis); err != nil {
+		panic(err)
+	}
+}
+
+// Создаем отправителя писем по конфигурации
+func newMailer() mailer.Mailer {
+	switch config.Mailer() {
+	case "smtp":
+		addr, username, password := config.SMTP()
+		m, err := mailer.NewSMTP(addr, username, password, config.MailFrom())
+		if err != nil {
+			panic(err)
+		}
+		return m
+	case "file":
+		return mailer.NewFile(config.MailDir(), config.MailFrom())
+	case "log":
+		return mailer.NewLog()
+	}
+
+	panic(fmt.Sprintf("unknown mailer %q", config.Mailer()))
+}
+
+// Создаем провайдера SSO, если он настроен
+func newOIDC() *oidc.Provider {
+	issuer, clientID, clientSecret := config.OIDC()
+	if issuer == "" {
+		return nil
+	}
+
+	return oidc.New(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  config.BaseURL() + "/auth/sso/callback",
+	}, &http.Client{Timeout: ssoTimeout})
+}
+
+// Собираем настройки сессий по конфигурации
+// В боевом режиме без ключей не запускаемся, иначе генерируем временные ключи
+func newSessionsConfig() sessions.Config {
+	cfg := sessions.Config{Secure: config.CookieSecure()}
+	cfg.Absolute, cfg.Idle = config.SessionTimeouts()
+
+	sameSite, err := sessions.ParseSameSite(config.CookieSameSite())
+	if err != nil {
+		panic(err)
+	}
+	// Браузеры отбрасывают куки SameSite=None без Secure
+	if sameSite == http.SameSiteNoneMode && !cfg.Secure {
+		panic("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+	}
+	cfg.SameSite = sameSite
+
+	if config.SessionKeys() == "" && !config.Production() {
+		logger.Log.Warn("SESSION_KEYS is not set, using random keys: sessions will not survive restart")
+		cfg.KeyPairs = sessions.GenerateKeys()
+		return cfg
+	}
+
+	cfg.KeyPairs, err = sessions.ParseKeys(config.SessionKeys())
+	if err != nil {
+		panic(fmt.Sprintf("SESSION_KEYS: %v", err))
+	}
+
+	return cfg
+}
+
+// Получаем ключ подписи ссылок из переменной окружения name
+// В боевом режиме без ключа не запускаемся, иначе генерируем временный ключ
+func newSecret(name, value string) []byte {
+	if value != "" {
+		return []byte(value)
+	}
+	if config.Production() {
+		panic(fmt.Sprintf("%s is not set", name))
+	}
+
+	logger.Log.Warnf("%s is not set, using random key: signed links will not survive restart", name)
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+// Собираем настройки защиты входа от перебора паролей по конфигурации
+func newAttemptsConfig() attempts.Config {
+	cfg := attempts.Config{}
+	cfg.FreeAttempts, cfg.LoginLimit, cfg.IPLimit = config.LoginLimits()
+	cfg.BaseDelay, cfg.MaxDelay, cfg.Lockout, cfg.Window = config.LoginDelays()
+
+	return cfg
+}
+
+// Создаем хеширование паролей по конфигурации
+// Хеши другого алгоритма продолжают проверяться и пересчитываются текущим алгоритмом при входе
+func newPasswords() *passwords.Passwords {
+	memory, iterations, parallelism := config.Argon2Params()
+	argon := passwords.NewArgon2id(passwords.Argon2Params{
+		Memory:      uint32(memory),
+		Iterations:  uint32(iterations),
+		Parallelism: uint8(parallelism),
+		SaltLength:  argon2SaltLength,
+		KeyLength:   argon2KeyLength,
+	})
+	bcrypt := passwords.NewBcrypt(int(config.BcryptCost()))
+
+	switch config.PasswordHasher() {
+	case "argon2id":
+		return passwords.New(argon, bcrypt)
+	case "bcrypt":
+		return passwords.New(bcrypt, argon)
+	}
+
+	panic(fmt.Sprintf("unknown password hasher %q", config.PasswordHasher()))
+}
+
+// Создаем политику новых паролей по конфигурации
+func newPasswordPolicy() passwords.Policy {
+	min, max := config.PasswordLength()
+	policy := passwords.Policy{
+		MinLength:  int(min),
+		MaxLength:  int(max),
+		MinEntropy: float64(config.PasswordEntropy()),
+	}
+
+	for _, class := range config.PasswordClasses() {
+		switch strings.TrimSpace(class) {
+		case "digit":
+			policy.Digit = true
+		case "upper":
+			policy.Upper = true
+		case "lower":
+			policy.Lower = true
+		case "special":
+			policy.Special = true
+		default:
+			panic(fmt.Sprintf("unknown password class %q", class))
+		}
+	}
+
+	if config.PasswordBreachedCheck() {
+		breached, err := passwords.Breached()
+		if err != nil {
+			panic(err)
+		}
+		policy.Breached = breached
+	}
+
+	return policy
+}
+
+// Загружаем часовой пояс по умолчанию
+// База часовых поясов встроена в бинарный файл, поэтому не зависит от пакета tzdata в системе
+func newLocation() *time.Location {
+	location, err := time.LoadLocation(config.Timezone())
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
+
+// Создаем реестр шаблонов и файловую систему статических файлов
+// В режиме разработки они читаются с диска, иначе из бинарного файла, и приложение не зависит от рабочего каталога
+// Без сторонних статических файлов не запускаемся, чтобы не отдавать страницы без стилей
+func newAssets() (*templates.Registry, http.FileSystem) {
+	if config.Development() {
+		registry, err := handlers.NewTemplates(os.DirFS("templates"), true)
+		if err != nil {
+			panic(err)
+		}
+		if err = static.Check(os.DirFS("static")); err != nil {
+			panic(err)
+		}
+		return registry, http.Dir("static")
+	}
+
+	registry, err := handlers.NewTemplates(templates.Embedded(), false)
+	if err != nil {
+		panic(err)
+	}
+	if err = static.Check(static.Embedded()); err != nil {
+		panic(err)
+	}
+	return registry, http.FS(static.Embedded())
+}
+
 func main() {
 	// Инициализируем БД
 	connString := config.ConnectionString()
@@ -40,15 +304,68 @@ func main() {
 	// Repositories
 	operationsRepository := operationsRepo.New(db)
 	usersRepository := usersRepo.New(db)
+	tokensRepository := tokensRepo.New(db)
+	webhooksRepository := webhooksRepo.New(db)
+	outboxRepository := outboxRepo.New(db)
+	auditRepository := auditRepo.New(db)
+	ledgersRepository := ledgersRepo.New(db)
+	invitesRepository := invitesRepo.New(db)
+	resetsRepository := resetsRepo.New(db)
+	twofactorRepository := twofactorRepo.New(db)
+	sessionsRepository := sessionsRepo.New(db)
+	attemptsRepository := attemptsRepo.New(db)
+	exportsRepository := exportsRepo.New(db)
 
 	// Services
-	usersSrv := users.New(usersRepository, operationsRepository)
-	operationsSrv := operations.New(operationsRepository)
+	passwordsSrv := newPasswords()
+	usersSrv := users.New(usersRepository, passwordsSrv, newPasswordPolicy())
+	webhooksSrv := webhooks.New(webhooksRepository, webhooks.NewClient())
+	operationsSrv := operations.New(operationsRepository, ledgersRepository)
+	ledgersSrv := ledgers.New(ledgersRepository)
+	tokensSrv := tokens.New(tokensRepository)
+	auditSrv := audit.New(auditRepository)
+	adminSrv := admin.New(usersRepository)
+	twofactorSrv := twofactor.New(twofactorRepository)
+	sessionsSrv := sessions.New(sessionsRepository, newSessionsConfig())
+	attemptsSrv := attempts.New(attemptsRepository, newAttemptsConfig())
+	exportsSrv := exports.New(exportsRepository, usersRepository, operationsRepository, newSecret("EXPORT_SECRET", config.ExportSecret()), config.ExportTTL())
+
+	mailSender := newMailer()
+	recoverySrv := recovery.New(usersRepository, resetsRepository, mailSender, passwordsSrv, config.BaseURL())
+	ssoSrv := sso.New(nil, usersRepository)
+	if provider := newOIDC(); provider != nil {
+		ssoSrv = sso.New(provider, usersRepository)
+	}
+	verificationSrv := verification.New(usersRepository, mailSender, newSecret("EMAIL_SECRET", config.EmailSecret()), config.BaseURL(), config.EmailRequired())
+
+	registrationMode, err := invites.ParseMode(config.RegistrationMode())
+	if err != nil {
+		panic(err)
+	}
+	invitesSrv := invites.New(invitesRepository, registrationMode, newSecret("INVITE_SECRET", config.InviteSecret()))
 
 	// Handlers
-	htmlHandler := handlers.New(usersSrv, operationsSrv)
+	// Шаблоны разбираются и проверяются при запуске, ошибка в шаблоне не дает приложению стартовать
+	registry, staticFS := newAssets()
+	htmlHandler := handlers.New(usersSrv, operationsSrv, tokensSrv, webhooksSrv, auditSrv, ledgersSrv, invitesSrv, adminSrv, recoverySrv, verificationSrv, twofactorSrv, ssoSrv, sessionsSrv, attemptsSrv, exportsSrv, registry, staticFS, newLocation())
+	rpcServer := rpc.New(operationsSrv, ledgersSrv, tokensSrv, auditSrv)
+
+	// Подписываем обработчики на доменные события
+	dispatcher := outbox.New(outboxRepository)
+	for _, event := range []string{models.EventOperationCreated, models.EventOperationDeleted} {
+		dispatcher.Register(event, "log", outbox.Log)
+		dispatcher.Register(event, "webhooks", webhooksSrv.HandleOperationEvent)
+	}
+	dispatcher.Register(models.EventExportRequested, "exports", exportsSrv.HandleRequested)
+
+	// Запуск обработки событий и серверов
+	go dispatcher.Run(context.Background(), outboxInterval)
+	go webhooksSrv.Run(context.Background(), webhooksInterval)
+	go sessionsSrv.Run(context.Background(), sessionsInterval)
+	go attemptsSrv.Run(context.Background(), attemptsInterval)
+	go exportsSrv.Run(context.Background(), exportsInterval)
+	go handleRPC(rpcServer, config.GRPCPort())
 
-	// Запуск сервера
 	port := config.Port()
 	handleRequest(htmlHandler, port)
 }
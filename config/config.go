@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Port Получает порт для запуска приложения
 // Или подставляет значение по умолчанию, если он не указан
@@ -12,6 +17,16 @@ func Port() string {
 	return port
 }
 
+// GRPCPort Получает порт для запуска gRPC сервера
+// Или подставляет значение по умолчанию, если он не указан
+func GRPCPort() string {
+	port := os.Getenv("GRPC_PORT")
+	if port == "" {
+		port = "9090"
+	}
+	return port
+}
+
 // ConnectionString Получает строку подключения к БД
 // Или подставляет значение по умолчанию, если она не указана
 func ConnectionString() string {
@@ -21,3 +36,249 @@ func ConnectionString() string {
 	}
 	return cs
 }
+
+// RegistrationMode Получает режим регистрации: open, invite или closed
+// Или подставляет значение по умолчанию, если он не указан
+func RegistrationMode() string {
+	mode := os.Getenv("REGISTRATION_MODE")
+	if mode == "" {
+		mode = "open"
+	}
+	return mode
+}
+
+// InviteSecret Получает ключ для подписи ссылок приглашений
+// В боевом режиме приложение не запускается без ключа
+func InviteSecret() string {
+	return os.Getenv("INVITE_SECRET")
+}
+
+// EmailSecret Получает ключ для подписи ссылок подтверждения адреса
+// В боевом режиме приложение не запускается без ключа
+func EmailSecret() string {
+	return os.Getenv("EMAIL_SECRET")
+}
+
+// ExportSecret Получает ключ для подписи ссылок на скачивание выгрузок персональных данных
+// В боевом режиме приложение не запускается без ключа
+func ExportSecret() string {
+	return os.Getenv("EXPORT_SECRET")
+}
+
+// ExportTTL Получает время хранения собранной выгрузки персональных данных и действия ссылки на нее
+// Или подставляет значение по умолчанию (сутки), если оно не указано или указано неверно
+func ExportTTL() time.Duration {
+	return duration("EXPORT_TTL", 24*time.Hour)
+}
+
+// EmailRequired Получает признак обязательности адреса почты при регистрации
+// Или подставляет значение по умолчанию (адрес не обязателен), если он не указан
+func EmailRequired() bool {
+	return os.Getenv("EMAIL_REQUIRED") == "true"
+}
+
+// OIDC Получает адрес издателя, идентификатор и секрет клиента провайдера OpenID Connect
+// Если издатель не указан, вход через SSO выключен
+func OIDC() (issuer, clientID, clientSecret string) {
+	return os.Getenv("OIDC_ISSUER"), os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET")
+}
+
+// Production Сообщает, запущено ли приложение в боевом режиме (APP_ENV=production)
+// В боевом режиме приложение не запускается без ключей сессий и ключей подписи ссылок
+func Production() bool {
+	return os.Getenv("APP_ENV") == "production"
+}
+
+// Development Сообщает, запущено ли приложение в режиме разработки (APP_ENV=development)
+// В режиме разработки шаблоны и статические файлы читаются с диска, а не из бинарного файла,
+// и измененные шаблоны подхватываются без перезапуска
+func Development() bool {
+	return os.Getenv("APP_ENV") == "development"
+}
+
+// Timezone Получает часовой пояс IANA для пользователей, которые не выбрали свой
+// Или подставляет значение по умолчанию, если он не указан
+func Timezone() string {
+	timezone := os.Getenv("APP_TIMEZONE")
+	if timezone == "" {
+		timezone = "Europe/Moscow"
+	}
+	return timezone
+}
+
+// SessionKeys Получает список пар ключей подписи и шифрования куки сессии в hex вида auth:enc,auth:enc
+// Первая пара используется для новых куки, остальные только для проверки старых во время смены ключей
+func SessionKeys() string {
+	return os.Getenv("SESSION_KEYS")
+}
+
+// CookieSecure Получает признак передачи куки сессии только по HTTPS
+// Или подставляет значение по умолчанию: в боевом режиме включено, иначе выключено
+func CookieSecure() bool {
+	secure := os.Getenv("COOKIE_SECURE")
+	if secure == "" {
+		return Production()
+	}
+	return secure == "true"
+}
+
+// CookieSameSite Получает политику SameSite куки сессии: lax, strict или none
+// Или подставляет значение по умолчанию, если она не указана
+func CookieSameSite() string {
+	mode := os.Getenv("COOKIE_SAMESITE")
+	if mode == "" {
+		mode = "lax"
+	}
+	return mode
+}
+
+// TrustedProxies Получает подсети CIDR или адреса доверенных прокси через запятую, например 10.0.0.0/8 для маршрутизатора Heroku
+// Адрес клиента берется из X-Forwarded-For только для запросов от этих прокси, по умолчанию заголовок игнорируется
+func TrustedProxies() []string {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return nil
+	}
+	return strings.Split(proxies, ",")
+}
+
+// SessionTimeouts Получает абсолютное время жизни сессии и время жизни без активности
+// Или подставляет значения по умолчанию (30 дней и сутки), если они не указаны или указаны неверно
+func SessionTimeouts() (absolute, idle time.Duration) {
+	return duration("SESSION_ABSOLUTE_TIMEOUT", 30*24*time.Hour), duration("SESSION_IDLE_TIMEOUT", 24*time.Hour)
+}
+
+// LoginLimits Получает количество неудачных попыток входа без задержки и лимиты попыток по логину и с одного адреса,
+// после которых вход временно блокируется
+// Или подставляет значения по умолчанию (3, 10 и 100), если они не указаны или указаны неверно
+func LoginLimits() (free, login, ip int64) {
+	return integer("LOGIN_FREE_ATTEMPTS", 3), integer("LOGIN_MAX_FAILURES", 10), integer("LOGIN_IP_MAX_FAILURES", 100)
+}
+
+// LoginDelays Получает начальную и максимальную задержку между неудачными попытками входа, время блокировки
+// и паузу, после которой серия неудач забывается
+// Или подставляет значения по умолчанию (1 секунда, минута, 15 минут и час), если они не указаны или указаны неверно
+func LoginDelays() (base, max, lockout, window time.Duration) {
+	return duration("LOGIN_BASE_DELAY", time.Second),
+		duration("LOGIN_MAX_DELAY", time.Minute),
+		duration("LOGIN_LOCKOUT", 15*time.Minute),
+		duration("LOGIN_FAILURE_WINDOW", time.Hour)
+}
+
+// PasswordHasher Получает алгоритм хеширования новых паролей: argon2id или bcrypt
+// Или подставляет значение по умолчанию, если он не указан
+func PasswordHasher() string {
+	hasher := os.Getenv("PASSWORD_HASHER")
+	if hasher == "" {
+		hasher = "argon2id"
+	}
+	return hasher
+}
+
+// Argon2Params Получает объем памяти в КиБ, количество проходов и потоков argon2id
+// Или подставляет значения по умолчанию (19 МиБ, 2 прохода, 1 поток), если они не указаны или указаны неверно
+func Argon2Params() (memory, iterations, parallelism int64) {
+	return integer("ARGON2_MEMORY", 19*1024), integer("ARGON2_ITERATIONS", 2), integer("ARGON2_PARALLELISM", 1)
+}
+
+// BcryptCost Получает сложность bcrypt, если он выбран для хеширования новых паролей
+// Или подставляет значение по умолчанию, если она не указана или указана неверно
+func BcryptCost() int64 {
+	return integer("BCRYPT_COST", 12)
+}
+
+// PasswordLength Получает минимальную и максимальную длину нового пароля
+// Или подставляет значения по умолчанию (от 7 до 64 символов), если они не указаны или указаны неверно
+func PasswordLength() (min, max int64) {
+	return integer("PASSWORD_MIN_LENGTH", 7), integer("PASSWORD_MAX_LENGTH", 64)
+}
+
+// PasswordClasses Получает обязательные классы символов нового пароля через запятую: digit, upper, lower, special
+// Или подставляет значение по умолчанию, если они не указаны. Значение none отключает проверку классов
+func PasswordClasses() []string {
+	classes := os.Getenv("PASSWORD_CLASSES")
+	if classes == "" {
+		classes = "digit,upper,special"
+	}
+	if classes == "none" {
+		return nil
+	}
+	return strings.Split(classes, ",")
+}
+
+// PasswordEntropy Получает минимальную оценку энтропии нового пароля в битах
+// Или подставляет значение по умолчанию, если она не указана или указана неверно
+func PasswordEntropy() int64 {
+	return integer("PASSWORD_MIN_ENTROPY", 40)
+}
+
+// PasswordBreachedCheck Получает признак проверки новых паролей по встроенному списку утекших паролей
+// Или подставляет значение по умолчанию (проверка включена), если он не указан
+func PasswordBreachedCheck() bool {
+	return os.Getenv("PASSWORD_BREACHED_CHECK") != "false"
+}
+
+// BaseURL Получает внешний адрес приложения для ссылок в письмах
+// Или подставляет значение по умолчанию, если он не указан
+func BaseURL() string {
+	url := os.Getenv("BASE_URL")
+	if url == "" {
+		url = "http://localhost:" + Port()
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// Mailer Получает способ отправки писем: smtp, file или log
+// Или подставляет значение по умолчанию, если он не указан
+func Mailer() string {
+	mailer := os.Getenv("MAILER")
+	if mailer == "" {
+		mailer = "log"
+	}
+	return mailer
+}
+
+// MailFrom Получает адрес отправителя писем
+// Или подставляет значение по умолчанию, если он не указан
+func MailFrom() string {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "casher@localhost"
+	}
+	return from
+}
+
+// MailDir Получает каталог для сохранения писем при отправке в файлы
+// Или подставляет значение по умолчанию, если он не указан
+func MailDir() string {
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = "mail"
+	}
+	return dir
+}
+
+// SMTP Получает адрес SMTP сервера host:port и учетные данные для него
+func SMTP() (addr, username, password string) {
+	return os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")
+}
+
+// Читает длительность в формате time.ParseDuration, например 12h
+// Или подставляет значение по умолчанию, если она не указана или указана неверно
+func duration(name string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// Читает положительное целое число
+// Или подставляет значение по умолчанию, если оно не указано или указано неверно
+func integer(name string, def int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
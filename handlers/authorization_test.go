@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/passwords"
+	sessionsRepo "github.com/bgoldovsky/casher/app/repositories/sessions"
+	"github.com/bgoldovsky/casher/app/services/admin"
+	"github.com/bgoldovsky/casher/app/services/audit"
+	"github.com/bgoldovsky/casher/app/services/invites"
+	"github.com/bgoldovsky/casher/app/services/ledgers"
+	"github.com/bgoldovsky/casher/app/services/operations"
+	sessionstore "github.com/bgoldovsky/casher/app/services/sessions"
+	"github.com/bgoldovsky/casher/app/services/tokens"
+	"github.com/bgoldovsky/casher/app/services/users"
+	"github.com/bgoldovsky/casher/app/services/webhooks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Владелец объектов, к которым обращается посторонний пользователь
+	ownerID       = int64(1)
+	ownerLedgerID = int64(10)
+	// Посторонний пользователь со своей бухгалтерией
+	strangerID       = int64(2)
+	strangerLedgerID = int64(20)
+	// ID объекта владельца
+	foreignID = int64(101)
+)
+
+// Моки репозиториев, через которые настоящие сервисы обращаются к данным
+// Моки строгие: неожиданный вызов, например изменение чужих данных или запись в журнал аудита, валит тест
+type authorizationMocks struct {
+	operations  *operations.Mockrepository
+	ledgerRoles *operations.MockledgersRepository
+	ledgers     *ledgers.Mockrepository
+	tokens      *tokens.Mockrepository
+	webhooks    *webhooks.Mockrepository
+	invites     *invites.Mockrepository
+	sessions    *sessionstore.Mockrepository
+}
+
+// Возвращает обработчик с настоящими сервисами поверх моков репозиториев
+func newAuthorizationTestHandler(t *testing.T) (*PageHandler, *authorizationMocks) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	stranger := &models.User{ID: strangerID, Login: "stranger", Role: models.UserRoleUser}
+	usersRepo := users.NewMockusersRepository(ctrl)
+	usersRepo.EXPECT().Get(strangerID).Return(stranger, nil).AnyTimes()
+	adminRepo := admin.NewMockusersRepository(ctrl)
+	adminRepo.EXPECT().Get(strangerID).Return(stranger, nil).AnyTimes()
+
+	m := &authorizationMocks{
+		operations:  operations.NewMockrepository(ctrl),
+		ledgerRoles: operations.NewMockledgersRepository(ctrl),
+		ledgers:     ledgers.NewMockrepository(ctrl),
+		tokens:      tokens.NewMockrepository(ctrl),
+		webhooks:    webhooks.NewMockrepository(ctrl),
+		invites:     invites.NewMockrepository(ctrl),
+	}
+	m.ledgers.EXPECT().Get(strangerID).Return([]models.Ledger{{ID: strangerLedgerID, Role: models.RoleOwner}}, nil).AnyTimes()
+
+	var sessionsSrv *sessionstore.Service
+	sessionsSrv, m.sessions = newMemorySessions(t)
+
+	handler := New(
+		users.New(usersRepo, nil, passwords.Policy{}),
+		operations.New(m.operations, m.ledgerRoles),
+		tokens.New(m.tokens),
+		webhooks.New(m.webhooks, nil),
+		audit.New(audit.NewMockrepository(ctrl)),
+		ledgers.New(m.ledgers),
+		invites.New(m.invites, invites.ModeInvite, []byte("secret")),
+		admin.New(adminRepo),
+		nil,
+		nil,
+		nil,
+		nil,
+		sessionsSrv,
+		nil,
+		nil,
+		newTestTemplates(t),
+		nil,
+		time.UTC,
+	)
+
+	return handler, m
+}
+
+// Создает сессию с токеном CSRF и возвращает ее куки
+// Если userID не нулевой, то сессия принадлежит авторизованному пользователю
+func newTestSession(t *testing.T, handler *PageHandler, userID int64, token string) *http.Cookie {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := handler.sessionsSrv.New(r, sessionName)
+	require.NoError(t, err)
+
+	session.Values[csrfTokenKey] = token
+	if userID != 0 {
+		session.Values[userIDKey] = userID
+	}
+
+	w := httptest.NewRecorder()
+	require.NoError(t, session.Save(r, w))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+// Выполняет запрос через роутер с куки сессии и токеном CSRF
+func serve(handler *PageHandler, method, path string, form url.Values, cookie *http.Cookie, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set(csrfHeaderName, token)
+	r.AddCookie(cookie)
+
+	w := httptest.NewRecorder()
+	handler.Router().ServeHTTP(w, r)
+	return w
+}
+
+func notFoundErr(entity string, id int64) error {
+	return &models.NotFoundError{Entity: entity, ID: id}
+}
+
+func Test_RoutesRequireAuth(t *testing.T) {
+	handler, _ := newAuthorizationTestHandler(t)
+	cookie := newTestSession(t, handler, 0, "token")
+	pathParam := regexp.MustCompile(`\{[^}]+\}`)
+
+	// Анонимная сессия с верным токеном CSRF не дает доступа ни к одному закрытому маршруту
+	for _, rt := range handler.routes() {
+		if !rt.auth {
+			continue
+		}
+
+		for _, method := range rt.methods {
+			path := pathParam.ReplaceAllString(rt.path, "1")
+			w := serve(handler, method, path, url.Values{}, cookie, "token")
+
+			// Не HTML ответы не перенаправляются на страницу входа
+			if rt.contentType != "" {
+				assert.Equalf(t, http.StatusUnauthorized, w.Code, "route %s %s", method, rt.path)
+				continue
+			}
+
+			assert.Equalf(t, http.StatusTemporaryRedirect, w.Code, "route %s %s", method, rt.path)
+			assert.Equalf(t, "/auth/", w.Header().Get("Location"), "route %s %s", method, rt.path)
+		}
+	}
+}
+
+func Test_RoutesForeignIDs(t *testing.T) {
+	tests := []struct {
+		route    string
+		method   string
+		path     string
+		form     url.Values
+		setup    func(m *authorizationMocks)
+		exp      int
+		location string
+	}{
+		{
+			route: "RevokeSession",
+			path:  "/sessions/101/revoke",
+			setup: func(m *authorizationMocks) {
+				m.sessions.EXPECT().DeleteByUser(foreignID, strangerID).Return(sessionsRepo.ErrSessionNotFound)
+			},
+			exp: http.StatusNotFound,
+		},
+		{
+			route: "DeleteOperation",
+			path:  "/operations/delete/101",
+			setup: func(m *authorizationMocks) {
+				m.ledgerRoles.EXPECT().GetRole(strangerLedgerID, strangerID).Return(models.RoleOwner, nil)
+				m.operations.EXPECT().Remove(strangerLedgerID, foreignID).Return(nil, notFoundErr("operation", foreignID))
+			},
+			exp: http.StatusNotFound,
+		},
+		{
+			route: "DeleteToken",
+			path:  "/tokens/delete/101",
+			setup: func(m *authorizationMocks) {
+				m.tokens.EXPECT().Remove(strangerID, foreignID).Return(notFoundErr("token", foreignID))
+			},
+			exp: http.StatusNotFound,
+		},
+		{
+			route: "DeleteWebhook",
+			path:  "/webhooks/delete/101",
+			setup: func(m *authorizationMocks) {
+				m.webhooks.EXPECT().Remove(strangerID, foreignID).Return(notFoundErr("webhook", foreignID))
+			},
+			exp: http.StatusNotFound,
+		},
+		{
+			route: "Deliveries",
+			path:  "/webhooks/101/deliveries/",
+			setup: func(m *authorizationMocks) {
+				m.webhooks.EXPECT().GetDeliveries(strangerID, foreignID, gomock.Any()).Return(nil, notFoundErr("webhook", foreignID))
+			},
+			exp: http.StatusNotFound,
+		},
+		{
+			route: "SwitchLedger",
+			path:  "/ledgers/switch/",
+			form:  url.Values{"ledger_id": {"10"}},
+			exp:   http.StatusNotFound,
+		},
+		{
+			route: "LedgerMembers",
+			path:  "/ledgers/10/members/",
+			exp:   http.StatusNotFound,
+		},
+		{
+			route:  "LedgerMembers",
+			method: http.MethodPost,
+			path:   "/ledgers/10/members/",
+			form:   url.Values{"login": {"stranger"}, "role": {"editor"}},
+			exp:    http.StatusNotFound,
+		},
+		{
+			route: "DeleteLedgerMember",
+			path:  "/ledgers/10/members/delete/1",
+			setup: func(m *authorizationMocks) {
+				m.ledgers.EXPECT().GetRole(ownerLedgerID, strangerID).Return(models.LedgerRole(""), nil)
+			},
+			exp: http.StatusNotFound,
+		},
+		{
+			// Владелец своей бухгалтерии не может исключить из нее того, кто в ней не состоит
+			route: "DeleteLedgerMember",
+			path:  "/ledgers/20/members/delete/1",
+			setup: func(m *authorizationMocks) {
+				m.ledgers.EXPECT().GetRole(strangerLedgerID, strangerID).Return(models.RoleOwner, nil)
+				m.ledgers.EXPECT().RemoveMember(strangerLedgerID, ownerID).Return(notFoundErr("ledger member", ownerID))
+			},
+			exp: http.StatusNotFound,
+		},
+		{
+			route: "RevokeInvite",
+			path:  "/invites/revoke/101",
+			setup: func(m *authorizationMocks) {
+				m.invites.EXPECT().Revoke(strangerID, foreignID).Return(notFoundErr("invite", foreignID))
+			},
+			exp: http.StatusNotFound,
+		},
+		// Панель администратора закрыта для обычного пользователя, аккаунт владельца не изменяется
+		{route: "AdminDisableUser", path: "/admin/users/1/disable", exp: http.StatusTemporaryRedirect, location: "/error/"},
+		{route: "AdminEnableUser", path: "/admin/users/1/enable", exp: http.StatusTemporaryRedirect, location: "/error/"},
+		{route: "AdminDeleteUser", path: "/admin/users/1/delete", exp: http.StatusTemporaryRedirect, location: "/error/"},
+	}
+
+	// Каждый маршрут с ID объекта должен быть проверен
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		covered[tt.route] = true
+	}
+	for _, rt := range New(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil).routes() {
+		if !rt.auth {
+			continue
+		}
+
+		for _, p := range rt.params {
+			if p.typ == typeInteger && strings.HasSuffix(strings.ToLower(p.name), "id") {
+				assert.Truef(t, covered[rt.name], "route %s %s is not covered by cross-user test", rt.name, rt.path)
+			}
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.route+" "+tt.path, func(t *testing.T) {
+			handler, m := newAuthorizationTestHandler(t)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+
+			method := tt.method
+			if method == "" {
+				for _, rt := range handler.routes() {
+					if rt.name == tt.route {
+						method = rt.methods[0]
+					}
+				}
+			}
+
+			cookie := newTestSession(t, handler, strangerID, "token")
+			w := serve(handler, method, tt.path, tt.form, cookie, "token")
+
+			assert.Equal(t, tt.exp, w.Code)
+			if tt.location != "" {
+				assert.Equal(t, tt.location, w.Header().Get("Location"))
+			}
+		})
+	}
+}
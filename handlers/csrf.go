@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/bgoldovsky/casher/app/i18n"
+	"github.com/bgoldovsky/casher/app/logger"
+)
+
+const (
+	// Ключ токена CSRF в сессии
+	csrfTokenKey = "csrf-token"
+	// Поле формы и заголовок, в которых передается токен
+	csrfFieldName  = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+	// Размер токена CSRF
+	csrfTokenSize = 32
+)
+
+// Ключ токена CSRF в контексте запроса
+type csrfContextKey struct{}
+
+// Middleware защиты от CSRF по схеме synchronizer token
+// Для каждой сессии выпускается свой токен, который подставляется в формы через функцию шаблона csrfField,
+// а запрос, изменяющий состояние, принимается только с этим токеном в поле формы или в заголовке
+func (h *PageHandler) csrf(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := h.csrfToken(w, r)
+		if err != nil {
+			logger.Log.WithError(err).Error("csrf middleware error")
+		}
+
+		if !isSafeMethod(r.Method) {
+			sent := r.PostFormValue(csrfFieldName)
+			if sent == "" {
+				sent = r.Header.Get(csrfHeaderName)
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(sent)) != 1 {
+				logger.Log.WithField("path", r.URL.Path).Error("csrf middleware error: invalid token")
+				h.csrfRejected(w, r)
+				return
+			}
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token)))
+	}
+}
+
+// Возвращает токен CSRF текущей сессии, при отсутствии выпускает новый и сохраняет сессию
+func (h *PageHandler) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return "", err
+	}
+
+	if token, ok := session.Values[csrfTokenKey].(string); ok && token != "" {
+		return token, nil
+	}
+
+	token, err := newCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	session.Values[csrfTokenKey] = token
+	if err = session.Save(r, w); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Отдает страницу отказа, если токен CSRF не передан или не совпал
+func (h *PageHandler) csrfRejected(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("csrf.html", csrfFuncs(r))
+	w.WriteHeader(http.StatusForbidden)
+	if err == nil {
+		err = tmpl.ExecuteTemplate(w, "csrf", nil)
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("csrf rejected handler error")
+		_, _ = w.Write([]byte("<h1>Forbidden</h1>"))
+	}
+}
+
+// Возвращает функции шаблона для страниц неавторизованного пользователя
+// Язык страницы выбирается только по заголовку Accept-Language
+func csrfFuncs(r *http.Request) template.FuncMap {
+	funcs := localeFuncs(func() *i18n.Localizer {
+		return i18n.Negotiate("", r.Header.Get("Accept-Language"))
+	})
+	funcs["csrfField"] = csrfField(r)
+
+	return funcs
+}
+
+// Возвращает функцию шаблона, которая выводит скрытое поле формы с токеном CSRF запроса
+// Поле возвращается как готовая разметка, иначе html/template экранирует его в текст
+func csrfField(r *http.Request) func() template.HTML {
+	return func() template.HTML {
+		token, _ := r.Context().Value(csrfContextKey{}).(string)
+		return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, csrfFieldName, template.HTMLEscapeString(token)))
+	}
+}
+
+// Генерирует случайный токен CSRF
+func newCSRFToken() (string, error) {
+	buf := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// Проверяет, что метод не изменяет состояние
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	sessionstore "github.com/bgoldovsky/casher/app/services/sessions"
+	"github.com/bgoldovsky/casher/templates"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Возвращает обработчик с хранилищем сессий в памяти
+func newCSRFTestHandler(t *testing.T) *PageHandler {
+	sessionsSrv, _ := newMemorySessions(t)
+
+	return &PageHandler{sessionsSrv: sessionsSrv, templates: newTestTemplates(t)}
+}
+
+// Возвращает сервис сессий, который хранит сессии в памяти, и мок его репозитория для дополнительных ожиданий
+func newMemorySessions(t *testing.T) (*sessionstore.Service, *sessionstore.Mockrepository) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	stored := map[string]*models.Session{}
+	repo := sessionstore.NewMockrepository(ctrl)
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(s *models.Session) (int64, error) {
+		copied := *s
+		copied.Created, copied.LastSeen = time.Now(), time.Now()
+		stored[s.Hash] = &copied
+		return int64(len(stored)), nil
+	}).AnyTimes()
+	repo.EXPECT().Update(gomock.Any()).DoAndReturn(func(s *models.Session) error {
+		stored[s.Hash].Data = s.Data
+		return nil
+	}).AnyTimes()
+	repo.EXPECT().GetByHash(gomock.Any()).DoAndReturn(func(hash string) (*models.Session, error) {
+		if s, ok := stored[hash]; ok {
+			return s, nil
+		}
+		return nil, sql.ErrNoRows
+	}).AnyTimes()
+
+	sessionsSrv := sessionstore.New(repo, sessionstore.Config{
+		Absolute: time.Hour,
+		Idle:     time.Hour,
+		KeyPairs: sessionstore.GenerateKeys(),
+	})
+
+	return sessionsSrv, repo
+}
+
+// Возвращает реестр шаблонов, встроенных в бинарный файл
+func newTestTemplates(t *testing.T) *templates.Registry {
+	registry, err := NewTemplates(templates.Embedded(), false)
+	require.NoError(t, err)
+	return registry
+}
+
+func Test_CSRF(t *testing.T) {
+	handler := newCSRFTestHandler(t)
+
+	var token string
+	protected := handler.csrf(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Context().Value(csrfContextKey{}).(string)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// GET выдает токен и куки сессии
+	w := httptest.NewRecorder()
+	protected(w, httptest.NewRequest(http.MethodGet, "/operations/", nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.NotEmpty(t, token)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	post := func(form url.Values, header string, withCookie bool) int {
+		r := httptest.NewRequest(http.MethodPost, "/operations/delete/1", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set(csrfHeaderName, header)
+		}
+		if withCookie {
+			r.AddCookie(cookies[0])
+		}
+
+		w := httptest.NewRecorder()
+		protected(w, r)
+		return w.Code
+	}
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		header     string
+		withCookie bool
+		exp        int
+	}{
+		{name: "form token", form: url.Values{csrfFieldName: {token}}, withCookie: true, exp: http.StatusNoContent},
+		{name: "header token", form: url.Values{}, header: token, withCookie: true, exp: http.StatusNoContent},
+		{name: "no token", form: url.Values{}, withCookie: true, exp: http.StatusForbidden},
+		{name: "wrong token", form: url.Values{csrfFieldName: {strings.Repeat("0", len(token))}}, withCookie: true, exp: http.StatusForbidden},
+		// Чужая страница не знает куки жертвы, а без сессии токен не совпадет
+		{name: "cross site", form: url.Values{csrfFieldName: {token}}, exp: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, post(tt.form, tt.header, tt.withCookie))
+		})
+	}
+}
+
+func Test_CSRFField(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler := newCSRFTestHandler(t)
+
+	var field template.HTML
+	handler.csrf(func(_ http.ResponseWriter, r *http.Request) {
+		field = csrfField(r)()
+	})(httptest.NewRecorder(), r)
+
+	assert.Regexp(t, `^<input type="hidden" name="csrf_token" value="[0-9a-f]{64}">$`, string(field))
+}
+
+func Test_RoutesCSRFProtected(t *testing.T) {
+	handler := New(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	// Изменяющие маршруты должны быть HTML формами, иначе они не проходят через проверку токена
+	for _, rt := range handler.routes() {
+		for _, method := range rt.methods {
+			if isSafeMethod(method) {
+				continue
+			}
+
+			assert.Emptyf(t, rt.contentType, "route %s %s is not protected from CSRF", method, rt.path)
+			assert.Falsef(t, rt.prefix, "route %s %s is not protected from CSRF", method, rt.path)
+		}
+	}
+
+	for _, rt := range handler.routes() {
+		if rt.name == "Logout" {
+			assert.Equal(t, []string{http.MethodPost}, rt.methods)
+		}
+	}
+}
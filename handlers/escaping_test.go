@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/services/verification"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// Разметка, которую пользователь пытается сохранить в полях операции
+	injectedSubject = `<script>alert("subject")</script>`
+	injectedMessage = `"><img src=x onerror=alert(1)>`
+)
+
+// Возвращает обработчик, который рендерит страницы авторизованного пользователя целиком вместе с шапкой
+func newEscapingTestHandler(t *testing.T) (*PageHandler, *authorizationMocks) {
+	handler, m := newAuthorizationTestHandler(t)
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	usersRepo := verification.NewMockusersRepository(ctrl)
+	usersRepo.EXPECT().Get(strangerID).Return(&models.User{ID: strangerID, Login: "stranger"}, nil).AnyTimes()
+	handler.verifySrv = verification.New(usersRepo, nil, []byte("secret"), "http://localhost", false)
+
+	return handler, m
+}
+
+func Test_OperationsEscaped(t *testing.T) {
+	handler, m := newEscapingTestHandler(t)
+	cookie := newTestSession(t, handler, strangerID, "token")
+
+	m.ledgerRoles.EXPECT().GetRole(strangerLedgerID, strangerID).Return(models.RoleOwner, nil)
+	m.operations.EXPECT().Get(strangerLedgerID, int64(1), gomock.Any()).Return(&models.OperationPaginator{
+		Operations: []models.Operation{
+			{ID: 1, LedgerID: strangerLedgerID, Subject: injectedSubject, Message: injectedMessage, Amount: 100, Type: models.Withdraw, Created: time.Now()},
+		},
+	}, nil)
+
+	w := serve(handler, http.MethodGet, "/operations/", url.Values{}, cookie, "token")
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, body, injectedSubject)
+	assert.NotContains(t, body, injectedMessage)
+	assert.Contains(t, body, `&lt;script&gt;alert(&#34;subject&#34;)&lt;/script&gt;`)
+	assert.Contains(t, body, `&#34;&gt;&lt;img src=x onerror=alert(1)&gt;`)
+
+	// Токен CSRF по-прежнему выводится разметкой, а не текстом
+	assert.Contains(t, body, `<input type="hidden" name="csrf_token" value="token">`)
+}
+
+func Test_CreateFormEscaped(t *testing.T) {
+	handler, _ := newEscapingTestHandler(t)
+	cookie := newTestSession(t, handler, strangerID, "token")
+
+	// Форма с нулевой суммой не проходит валидацию и рендерится снова с введенными значениями
+	form := url.Values{"subject": {injectedMessage}, "amount": {"0"}, "type": {"2"}, "message": {injectedSubject}}
+	w := serve(handler, http.MethodPost, "/operations/create/", form, cookie, "token")
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, body, injectedSubject)
+	assert.NotContains(t, body, injectedMessage)
+	assert.Contains(t, body, `value="&#34;&gt;&lt;img src=x onerror=alert(1)&gt;"`)
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"fmt"
+	"net/mail"
+	"net/url"
 	"strings"
 	"time"
-	"unicode"
 
+	"github.com/bgoldovsky/casher/app/i18n"
 	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/passwords"
 )
 
 type operationForm struct {
@@ -15,6 +17,8 @@ type operationForm struct {
 	Type    int64
 	Message string
 	Errors  map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
 }
 
 // Validate Валидирует поля формы
@@ -23,15 +27,15 @@ func (f *operationForm) Validate() bool {
 	f.Errors = map[string]string{}
 
 	if strings.TrimSpace(f.Subject) == "" {
-		f.Errors["Subject"] = "введите тему"
+		f.Errors["Subject"] = f.Localizer.T("operation.error.subject")
 	}
 
 	if f.Amount <= 0 {
-		f.Errors["Amount"] = "введите сумму"
+		f.Errors["Amount"] = f.Localizer.T("operation.error.amount")
 	}
 
 	if f.Type != int64(models.Deposit) && f.Type != int64(models.Withdraw) {
-		f.Errors["Type"] = "выберите тип операции"
+		f.Errors["Type"] = f.Localizer.T("operation.error.type")
 	}
 
 	return len(f.Errors) == 0
@@ -40,7 +44,11 @@ func (f *operationForm) Validate() bool {
 type authForm struct {
 	Login    string
 	Password string
-	Errors   map[string]string
+	// Показывать ли кнопку входа через SSO
+	SSO    bool
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
 }
 
 // Validate Валидирует поля формы
@@ -48,11 +56,29 @@ func (f *authForm) Validate() bool {
 	f.Errors = map[string]string{}
 
 	if strings.TrimSpace(f.Login) == "" {
-		f.Errors["Login"] = "введите имя пользователя"
+		f.Errors["Login"] = f.Localizer.T("form.error.login")
 	}
 
 	if strings.TrimSpace(f.Password) == "" {
-		f.Errors["Password"] = "введите пароль"
+		f.Errors["Password"] = f.Localizer.T("form.error.password")
+	}
+
+	return len(f.Errors) == 0
+}
+
+type secondFactorForm struct {
+	Code   string
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+func (f *secondFactorForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if strings.TrimSpace(f.Code) == "" {
+		f.Errors["Code"] = f.Localizer.T("twofactor.error.code")
 	}
 
 	return len(f.Errors) == 0
@@ -62,9 +88,21 @@ type registrationForm struct {
 	Login           string
 	Password        string
 	ConfirmPassword string
-	Name            string
-	Birth           time.Time
-	Errors          map[string]string
+	// Адрес электронной почты нужен для восстановления пароля
+	// Обязателен, только если это включено в конфигурации
+	Email         string
+	EmailRequired bool
+	Name          string
+	Birth         time.Time
+	// Подписанный токен приглашения из ссылки
+	Invite string
+	// Причина, по которой регистрация недоступна
+	Closed string
+	// Требования к паролю
+	Policy passwords.Policy
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
 }
 
 // Validate Валидирует поля формы
@@ -72,54 +110,291 @@ func (f *registrationForm) Validate() bool {
 	f.Errors = map[string]string{}
 
 	if strings.TrimSpace(f.Login) == "" {
-		f.Errors["Login"] = "введите имя пользователя"
+		f.Errors["Login"] = f.Localizer.T("form.error.login")
+	}
+
+	if strings.TrimSpace(f.ConfirmPassword) == "" {
+		f.Errors["ConfirmPassword"] = f.Localizer.T("registration.error.confirm")
 	}
 
 	if strings.TrimSpace(f.Password) == "" {
-		f.Errors["Password"] = "введите пароль"
+		f.Errors["Password"] = f.Localizer.T("form.error.password")
+	} else if message := passwordErrors(f.Localizer, f.Policy, f.Password); message != "" {
+		f.Errors["Password"] = message
 	}
 
-	if strings.TrimSpace(f.ConfirmPassword) == "" {
-		f.Errors["ConfirmPassword"] = "введите пароль еще раз"
+	if strings.TrimSpace(f.Name) == "" {
+		f.Errors["Name"] = f.Localizer.T("registration.error.name")
+	}
+
+	if f.EmailRequired && f.Email == "" {
+		f.Errors["Email"] = f.Localizer.T("registration.error.email")
+	} else if f.Email != "" && !validEmail(f.Email) {
+		f.Errors["Email"] = f.Localizer.T("registration.error.email.invalid")
 	}
 
-	hasSevenOrMore, hasNumber, hasUpper, hasSpecial := verifyPassword(f.Password)
+	return len(f.Errors) == 0
+}
+
+// Проверяет, что строка является одиночным адресом электронной почты без имени
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// Проверяет новый пароль по политике и возвращает сообщения обо всех нарушенных правилах на языке l
+// Если пароль соответствует политике, то возвращает пустую строку
+func passwordErrors(l *i18n.Localizer, policy passwords.Policy, password string) string {
+	var messages []string
+	for _, rule := range policy.Check(password) {
+		switch rule {
+		case passwords.RuleMinLength:
+			messages = append(messages, l.N("password.error.min_length", int64(policy.MinLength)))
+		case passwords.RuleMaxLength:
+			messages = append(messages, l.N("password.error.max_length", int64(policy.MaxLength)))
+		default:
+			// Остальные правила не имеют параметров, ключ сообщения совпадает с названием правила
+			messages = append(messages, l.T("password.error."+string(rule)))
+		}
+	}
+
+	return strings.Join(messages, "; ")
+}
 
-	fmt.Println("VERIFY:", hasSevenOrMore, hasNumber, hasUpper, hasSpecial)
+type forgotForm struct {
+	Email  string
+	Sent   bool
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
 
-	if !hasSevenOrMore || !hasUpper || !hasNumber || !hasSpecial {
-		f.Errors["Password"] = "введенный пароль не надежен"
+// Validate Валидирует поля формы
+func (f *forgotForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if !validEmail(strings.TrimSpace(f.Email)) {
+		f.Errors["Email"] = f.Localizer.T("forgot.error.email")
 	}
 
+	return len(f.Errors) == 0
+}
+
+type resetForm struct {
+	Token           string
+	Password        string
+	ConfirmPassword string
+	// Ссылка недействительна: устарела, уже использована или подделана
+	Invalid bool
+	// Требования к паролю
+	Policy passwords.Policy
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+// Новый пароль должен соответствовать тем же требованиям, что и при регистрации
+func (f *resetForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if message := passwordErrors(f.Localizer, f.Policy, f.Password); message != "" {
+		f.Errors["Password"] = message
+	}
+
+	if f.Password != f.ConfirmPassword {
+		f.Errors["ConfirmPassword"] = f.Localizer.T("password.error.mismatch")
+	}
+
+	return len(f.Errors) == 0
+}
+
+type profileForm struct {
+	Name  string
+	Birth time.Time
+	// Часовой пояс IANA, пустой для часового пояса по умолчанию
+	Timezone string
+	Errors   map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+func (f *profileForm) Validate() bool {
+	f.Errors = map[string]string{}
+
 	if strings.TrimSpace(f.Name) == "" {
-		f.Errors["Name"] = "введите настоящее имя"
+		f.Errors["Name"] = f.Localizer.T("profile.error.name")
+	}
+
+	if f.Birth.IsZero() {
+		f.Errors["Birth"] = f.Localizer.T("profile.error.birth")
+	}
+
+	// Local означает часовой пояс сервера, а не пользователя
+	if f.Timezone != "" {
+		if _, err := time.LoadLocation(f.Timezone); err != nil || f.Timezone == "Local" {
+			f.Errors["Timezone"] = f.Localizer.T("profile.error.timezone")
+		}
 	}
 
 	return len(f.Errors) == 0
 }
 
-// Проверяет требования сложности пароля
-// 1. Не менее 7 символов
-// 2. Не менее 1 числа
-// 2. Не менее 1 заглавной буквы
-// 3. Не менее 1 специального символа
-func verifyPassword(s string) (sevenOrMore, number, upper, special bool) {
-	letters := 0
-	for _, c := range s {
-		switch {
-		case unicode.IsNumber(c):
-			number = true
-			letters++
-		case unicode.IsUpper(c):
-			upper = true
-			letters++
-		case unicode.IsPunct(c) || unicode.IsSymbol(c):
-			special = true
-			letters++
-		case unicode.IsLetter(c) || c == ' ':
-			letters++
+type passwordForm struct {
+	CurrentPassword string
+	Password        string
+	ConfirmPassword string
+	// Требования к паролю
+	Policy passwords.Policy
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+// Новый пароль должен соответствовать тем же требованиям, что и при регистрации
+func (f *passwordForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if f.CurrentPassword == "" {
+		f.Errors["CurrentPassword"] = f.Localizer.T("password.error.current")
+	}
+
+	if message := passwordErrors(f.Localizer, f.Policy, f.Password); message != "" {
+		f.Errors["Password"] = message
+	}
+
+	if f.Password != f.ConfirmPassword {
+		f.Errors["ConfirmPassword"] = f.Localizer.T("password.error.mismatch")
+	}
+
+	return len(f.Errors) == 0
+}
+
+type deleteAccountForm struct {
+	// Логин, который пользователь вводит для подтверждения удаления
+	Login    string
+	Confirm  string
+	Password string
+	Errors   map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+func (f *deleteAccountForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if strings.TrimSpace(f.Confirm) != f.Login {
+		f.Errors["Confirm"] = f.Localizer.T("profile.error.confirm")
+	}
+
+	if f.Password == "" {
+		f.Errors["Password"] = f.Localizer.T("password.error.current")
+	}
+
+	return len(f.Errors) == 0
+}
+
+type tokenForm struct {
+	Name   string
+	Token  string
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+func (f *tokenForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if strings.TrimSpace(f.Name) == "" {
+		f.Errors["Name"] = f.Localizer.T("tokens.error.name")
+	}
+
+	return len(f.Errors) == 0
+}
+
+type webhookForm struct {
+	URL    string
+	Events []string
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+func (f *webhookForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	u, err := url.ParseRequestURI(strings.TrimSpace(f.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		f.Errors["URL"] = f.Localizer.T("webhooks.error.url")
+	}
+
+	if len(f.Events) == 0 {
+		f.Errors["Events"] = f.Localizer.T("webhooks.error.events")
+	}
+
+	for _, event := range f.Events {
+		if !isKnownEvent(event) {
+			f.Errors["Events"] = f.Localizer.T("webhooks.error.events.unknown")
+		}
+	}
+
+	return len(f.Errors) == 0
+}
+
+// Проверяет, что на событие можно подписаться
+func isKnownEvent(event string) bool {
+	for _, e := range models.Events {
+		if e == event {
+			return true
 		}
 	}
-	sevenOrMore = letters >= 7
-	return
+
+	return false
+}
+
+type ledgerForm struct {
+	Name   string
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+func (f *ledgerForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if strings.TrimSpace(f.Name) == "" {
+		f.Errors["Name"] = f.Localizer.T("ledgers.error.name")
+	}
+
+	return len(f.Errors) == 0
+}
+
+type memberForm struct {
+	Login  string
+	Role   string
+	Errors map[string]string
+	// Язык сообщений об ошибках
+	Localizer *i18n.Localizer
+}
+
+// Validate Валидирует поля формы
+// Через форму можно выдать только роль редактора или наблюдателя
+func (f *memberForm) Validate() bool {
+	f.Errors = map[string]string{}
+
+	if strings.TrimSpace(f.Login) == "" {
+		f.Errors["Login"] = f.Localizer.T("members.error.login")
+	}
+
+	if f.Role != string(models.RoleEditor) && f.Role != string(models.RoleViewer) {
+		f.Errors["Role"] = f.Localizer.T("members.error.role")
+	}
+
+	return len(f.Errors) == 0
 }
@@ -1,61 +1,147 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
-	"text/template"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/bgoldovsky/casher/app/i18n"
 	"github.com/bgoldovsky/casher/app/logger"
 	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/services/admin"
+	"github.com/bgoldovsky/casher/app/services/attempts"
+	"github.com/bgoldovsky/casher/app/services/audit"
+	"github.com/bgoldovsky/casher/app/services/exports"
+	"github.com/bgoldovsky/casher/app/services/invites"
+	"github.com/bgoldovsky/casher/app/services/ledgers"
 	"github.com/bgoldovsky/casher/app/services/operations"
+	"github.com/bgoldovsky/casher/app/services/recovery"
+	sessionstore "github.com/bgoldovsky/casher/app/services/sessions"
+	"github.com/bgoldovsky/casher/app/services/sso"
+	"github.com/bgoldovsky/casher/app/services/tokens"
+	"github.com/bgoldovsky/casher/app/services/twofactor"
 	"github.com/bgoldovsky/casher/app/services/users"
+	"github.com/bgoldovsky/casher/app/services/verification"
+	"github.com/bgoldovsky/casher/app/services/webhooks"
 	"github.com/bgoldovsky/casher/middleware"
+	"github.com/bgoldovsky/casher/templates"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 )
 
 const (
-	userIDKey = "user-id"
+	sessionName = "cookie-name"
+	userIDKey   = sessionstore.UserIDKey
+	ledgerIDKey = "ledger-id"
+	// Пользователь, прошедший проверку пароля и ожидающий второго шага входа
+	pendingUserIDKey = "pending-user-id"
+	pendingAtKey     = "pending-at"
+	// Одноразовые значения входа через SSO
+	ssoStateKey    = "sso-state"
+	ssoNonceKey    = "sso-nonce"
+	ssoVerifierKey = "sso-verifier"
+	ssoAtKey       = "sso-at"
+	// Сообщение о неудачных попытках входа, которое показывается после входа
+	loginNoticeKey = "login-notice"
+	// Сообщение, которое показывается на странице профиля после перенаправления на нее
+	profileNoticeKey = "profile-notice"
+)
+
+const (
+	// Время на ввод кода второго шага входа
+	secondFactorTTL = 5 * time.Minute
+	// Время на вход у провайдера SSO
+	ssoTTL = 10 * time.Minute
 )
 
 type PageHandler struct {
 	usersSrv      *users.Service
 	operationsSrv *operations.Service
-	router        *mux.Router
-	store         *sessions.CookieStore
+	tokensSrv     *tokens.Service
+	webhooksSrv   *webhooks.Service
+	auditSrv      *audit.Service
+	ledgersSrv    *ledgers.Service
+	invitesSrv    *invites.Service
+	adminSrv      *admin.Service
+	recoverySrv   *recovery.Service
+	verifySrv     *verification.Service
+	twofactorSrv  *twofactor.Service
+	ssoSrv        *sso.Service
+	sessionsSrv   *sessionstore.Service
+	attemptsSrv   *attempts.Service
+	exportsSrv    *exports.Service
+	templates     *templates.Registry
+	static        http.FileSystem
+	// Часовой пояс пользователей, которые не выбрали свой
+	location *time.Location
+	router   *mux.Router
 }
 
-func New(usersSrv *users.Service, operationsSrv *operations.Service) *PageHandler {
-	// Создаем фейковый ключ для хранилища куки
-	key := []byte("33446a9dcf9ea060a0a6532b166da32f304af0de")
-
+func New(
+	usersSrv *users.Service,
+	operationsSrv *operations.Service,
+	tokensSrv *tokens.Service,
+	webhooksSrv *webhooks.Service,
+	auditSrv *audit.Service,
+	ledgersSrv *ledgers.Service,
+	invitesSrv *invites.Service,
+	adminSrv *admin.Service,
+	recoverySrv *recovery.Service,
+	verifySrv *verification.Service,
+	twofactorSrv *twofactor.Service,
+	ssoSrv *sso.Service,
+	sessionsSrv *sessionstore.Service,
+	attemptsSrv *attempts.Service,
+	exportsSrv *exports.Service,
+	templates *templates.Registry,
+	static http.FileSystem,
+	location *time.Location,
+) *PageHandler {
 	handler := &PageHandler{
 		usersSrv:      usersSrv,
 		operationsSrv: operationsSrv,
-		store:         sessions.NewCookieStore(key),
+		tokensSrv:     tokensSrv,
+		webhooksSrv:   webhooksSrv,
+		auditSrv:      auditSrv,
+		ledgersSrv:    ledgersSrv,
+		invitesSrv:    invitesSrv,
+		adminSrv:      adminSrv,
+		recoverySrv:   recoverySrv,
+		verifySrv:     verifySrv,
+		twofactorSrv:  twofactorSrv,
+		ssoSrv:        ssoSrv,
+		sessionsSrv:   sessionsSrv,
+		attemptsSrv:   attemptsSrv,
+		exportsSrv:    exportsSrv,
+		templates:     templates,
+		static:        static,
+		location:      location,
 	}
 
-	// Инициализируем и настраиваем роутер
+	// Инициализируем и настраиваем роутер по каталогу маршрутов
 	r := mux.NewRouter()
+	for _, rt := range handler.routes() {
+		if rt.prefix {
+			r.PathPrefix(rt.path).Handler(rt.handler).Methods(rt.methods...)
+			continue
+		}
+
+		// HTML страницы и формы защищаем от CSRF
+		handlerFunc := rt.handler
+		if rt.contentType == "" {
+			handlerFunc = handler.csrf(handlerFunc)
+		}
 
-	// Добавляем доступ к статическим файлам
-	fs := http.FileServer(http.Dir("./static/"))
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
-
-	// Настраиваем роутер
-	// Роуты авторизации
-	r.HandleFunc("/", middleware.Logging(handler.Index)).Methods("GET", "POST")
-	r.HandleFunc("/auth/", middleware.Logging(handler.Auth)).Methods("GET", "POST")
-	r.HandleFunc("/logout/", middleware.Logging(handler.Logout)).Methods("GET", "POST")
-	r.HandleFunc("/registration/", middleware.Logging(handler.Registration)).Methods("GET", "POST")
-	// Роуты для работы с операциями
-	r.HandleFunc("/operations/", middleware.Logging(handler.Operations)).Methods("GET", "POST")
-	r.HandleFunc("/operations/create/", middleware.Logging(handler.Create)).Methods("GET", "POST")
-	r.HandleFunc("/operations/delete/{id:[0-9]+}", middleware.Logging(handler.Delete)).Methods("POST")
-	// Роуты для обработки ошибок
-	r.HandleFunc("/error/", middleware.Logging(handler.Error)).Methods("GET", "POST")
-	r.HandleFunc("/error/unauthorized", middleware.Logging(handler.ErrorUnauthorized)).Methods("GET", "POST")
+		r.HandleFunc(rt.path, middleware.Logging(handlerFunc)).Methods(rt.methods...)
+	}
 
 	handler.router = r
 	return handler
@@ -86,16 +172,38 @@ func (h *PageHandler) Index(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Парсим шаблон
-	// Если хотим паниковать при ошибках парсинга шаблона используем wrapper-функцию template.Must
-	tmpl := template.Must(template.ParseFiles(
-		"templates/index.html",
-		"templates/header.html",
-		"templates/footer.html",
-	))
+	// Баланс показываем по текущей бухгалтерии
+	ledger, err := h.getCurrentLedger(r, userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("index handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	u.Balance, err = h.operationsSrv.Balance(userID, ledger.ID)
+	if err != nil {
+		logger.Log.WithError(err).Error("index handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Сообщение о неудачных попытках входа показываем один раз
+	view := userToView(u)
+	view.Notice, err = h.takeNotice(w, r, loginNoticeKey)
+	if err != nil {
+		logger.Log.WithError(err).Error("index handler error")
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("index.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("index handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
 
 	// Рендерим шаблон
-	err = tmpl.ExecuteTemplate(w, "index", userToView(u))
+	err = tmpl.ExecuteTemplate(w, "index", view)
 	if err != nil {
 		logger.Log.WithError(err).Error("index handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
@@ -113,27 +221,16 @@ func (h *PageHandler) Operations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Определяем функции для пагинации
-	// После парсинга ими можно будет пользоваться внутри шаблона
-	funcMap := template.FuncMap{
-		"inc": func(i int64) int64 {
-			return i + 1
-		},
-		"dec": func(i int64) int64 {
-			return i - 1
-		},
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("operations.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("operations handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
 	}
 
-	// Парсим шаблон вместе с функциями
-	tmpl := template.Must(template.New("wrapper").Funcs(funcMap).ParseFiles(
-		"templates/operations.html",
-		"templates/header.html",
-		"templates/footer.html",
-	))
-
 	// Получаем страницу пагинации из запроса
 	var nextPage int64 = 1
-	var err error
 	pageStr := r.URL.Query().Get("page")
 	if pageStr != "" {
 		nextPage, err = strconv.ParseInt(pageStr, 10, 0)
@@ -144,16 +241,27 @@ func (h *PageHandler) Operations(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Получаем список операций
-	paginator, err := h.operationsSrv.Get(userID, nextPage)
+	// Получаем список операций текущей бухгалтерии
+	ledger, err := h.getCurrentLedger(r, userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("operations handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	paginator, err := h.operationsSrv.Get(userID, ledger.ID, nextPage)
 	if err != nil {
 		logger.Log.WithError(err).Error("operations handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
 
+	// Наблюдателю не показываем кнопки изменения операций
+	view := toPagingView(h.localizer(r, userID), nextPage, paginator)
+	view.CanEdit = ledger.Role.CanWrite()
+
 	// Рендерим ответ
-	err = tmpl.ExecuteTemplate(w, "operations", toPagingView(nextPage, paginator))
+	err = tmpl.ExecuteTemplate(w, "operations", view)
 	if err != nil {
 		logger.Log.WithError(err).Error("operations handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
@@ -171,12 +279,13 @@ func (h *PageHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Парсим шаблон
-	tmpl := template.Must(template.ParseFiles(
-		"templates/create.html",
-		"templates/header.html",
-		"templates/footer.html",
-	))
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("create.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("create handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
 
 	// Если пришел GET запрос, только рендерим шаблон
 	if r.Method != http.MethodPost {
@@ -206,10 +315,11 @@ func (h *PageHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := operationForm{
-		Subject: r.FormValue("subject"),
-		Amount:  amount,
-		Type:    operationType,
-		Message: r.FormValue("message"),
+		Subject:   r.FormValue("subject"),
+		Amount:    amount,
+		Type:      operationType,
+		Message:   r.FormValue("message"),
+		Localizer: h.localizer(r, userID),
 	}
 
 	// Валидируем данные формы
@@ -223,26 +333,50 @@ func (h *PageHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Сохраняем операцию в БД
-	err = h.operationsSrv.Create(
-		userID,
-		form.Subject,
-		int64(form.Amount*100),
-		models.OperationType(form.Type),
-		form.Message,
-	)
+	// Сохраняем операцию в текущую бухгалтерию
+	ledger, err := h.getCurrentLedger(r, userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("create handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	created := models.Operation{
+		LedgerID: ledger.ID,
+		UserID:   userID,
+		Subject:  form.Subject,
+		Amount:   int64(form.Amount * 100),
+		Type:     models.OperationType(form.Type),
+		Message:  form.Message,
+	}
+	created.ID, err = h.operationsSrv.Create(userID, ledger.ID, created.Subject, created.Amount, created.Type, created.Message)
 	if err != nil {
 		logger.Log.WithError(err).Error("create handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
 
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditOperationCreate,
+		TargetType: models.AuditTargetOperation,
+		TargetID:   created.ID,
+	}, nil, &created)
+
 	// Редиректим на список операций
 	http.Redirect(w, r, "/operations/", http.StatusTemporaryRedirect)
 }
 
 // Delete Обработчик страницы удаления операции
 func (h *PageHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("delete handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
 	vars := mux.Vars(r)
 	operationIDStr, ok := vars["id"]
 	if !ok {
@@ -258,259 +392,2302 @@ func (h *PageHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.operationsSrv.Remove(operationID)
+	// Удаляем операцию только из текущей бухгалтерии
+	ledger, err := h.getCurrentLedger(r, userID)
 	if err != nil {
-		logger.Log.WithError(err).Error("create handler error")
+		logger.Log.WithError(err).Error("delete handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	http.Redirect(w, r, "/operations/", http.StatusTemporaryRedirect)
-}
+	removed, err := h.operationsSrv.Remove(userID, ledger.ID, operationID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("delete handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
 
-// Auth handlers
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditOperationDelete,
+		TargetType: models.AuditTargetOperation,
+		TargetID:   removed.ID,
+	}, removed, nil)
 
-// Auth Обработчик страницы авторизации пользователя
-func (h *PageHandler) Auth(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.ParseFiles(
-		"templates/auth.html",
-		"templates/header_unauthorized.html",
-		"templates/footer.html",
-	))
+	http.Redirect(w, r, "/operations/", http.StatusTemporaryRedirect)
+}
 
-	form := authForm{}
+// Token handlers
 
-	// Если пришел GET запрос, только рендерим шаблон и выходим
-	if r.Method != http.MethodPost {
-		err := tmpl.ExecuteTemplate(w, "auth", form)
-		if err != nil {
-			logger.Log.WithError(err).Error("auth handler error")
-			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
-			return
-		}
+// Tokens Обработчик страницы токенов доступа к API
+func (h *PageHandler) Tokens(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("tokens handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	form.Login = r.FormValue("login")
-	form.Password = r.FormValue("password")
-
-	// Валидируем данные формы
-	if !form.Validate() {
-		err := tmpl.ExecuteTemplate(w, "auth", form)
-		if err != nil {
-			logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
-			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
-			return
-		}
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("tokens.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("tokens handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Получаем пользователя по логину и паролю
-	u, err := h.usersSrv.Auth(form.Login, form.Password)
-	// Если пользователь не найден или пароль не валидирован, то отправляем сообщение пользователю
-	if err == users.ErrInvalidPassword {
-		form.Errors["Password"] = "Неверное имя пользователя или пароль"
-		err := tmpl.ExecuteTemplate(w, "auth", form)
-		if err != nil {
-			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
-			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
-			return
+	// Если пришел POST запрос, то выпускаем новый токен
+	form := tokenForm{Localizer: h.localizer(r, userID)}
+	if r.Method == http.MethodPost {
+		form.Name = r.FormValue("name")
+
+		if form.Validate() {
+			token, err := h.tokensSrv.Create(userID, form.Name)
+			if err != nil {
+				logger.Log.WithError(err).Error("tokens handler error")
+				http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+				return
+			}
+
+			h.audit(r, models.AuditEntry{
+				ActorID:    userID,
+				Action:     models.AuditTokenCreate,
+				TargetType: models.AuditTargetToken,
+			}, nil, map[string]string{"name": form.Name})
+
+			// Токен показывается пользователю только один раз
+			form = tokenForm{Token: token}
 		}
-		return
 	}
-	// Иначе рендерим страницу с ошибкой
+
+	// Получаем список токенов пользователя
+	list, err := h.tokensSrv.Get(userID)
 	if err != nil {
-		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+		logger.Log.WithError(err).Error("tokens handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Авторизуем пользователя
-	err = h.authorizeUser(u.ID, w, r)
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "tokens", tokensPage{Form: form, Tokens: tokensToView(list)})
 	if err != nil {
+		logger.Log.WithError(err).Error("tokens handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
-		logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
 		return
 	}
-
-	// Редиректим пользователя на главную страницу
-	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 }
 
-// Registration Обработчик страницы регистрации нового пользователя
-func (h *PageHandler) Registration(w http.ResponseWriter, r *http.Request) {
-	// Создаем регистрационную форму
-	local, err := time.LoadLocation("Europe/Moscow")
+// DeleteToken Обработчик отзыва токена доступа к API
+func (h *PageHandler) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("delete token handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
 	if err != nil {
-		logger.Log.WithError(err).Error("registration handler error")
+		logger.Log.WithError(err).Error("delete token handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
-	birth := time.Date(1986, 4, 19, 16, 15, 0, 0, local)
-	form := registrationForm{
-		Birth: birth,
+
+	err = h.tokensSrv.Remove(userID, tokenID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("delete token handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
 	}
 
-	// Парсим шаблон страницы
-	tmpl := template.Must(template.ParseFiles(
-		"templates/registration.html",
-		"templates/header_unauthorized.html",
-		"templates/footer.html",
-	))
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditTokenDelete,
+		TargetType: models.AuditTargetToken,
+		TargetID:   tokenID,
+	}, nil, nil)
 
-	// Если пришел GET запрос, только рендерим форму и выходим
-	if r.Method != http.MethodPost {
-		err = tmpl.ExecuteTemplate(w, "registration", form)
-		if err != nil {
-			logger.Log.WithError(err).Error("registration handler error")
-			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
-			return
-		}
+	http.Redirect(w, r, "/tokens/", http.StatusSeeOther)
+}
+
+// Webhook handlers
+
+// Webhooks Обработчик страницы подписок на события
+func (h *PageHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("webhooks handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Если пришел POST запрос, то обрабатываем форму
-	// Загружаем данные из формы в объект
-	form.Login = r.FormValue("login")
-	form.Password = r.FormValue("password")
-	form.ConfirmPassword = r.FormValue("confirm-password")
-	form.Name = r.FormValue("name")
-	// Отдельно парсим и обрабатываем дату рождения
-	birth, err = time.Parse("2006-01-02", r.FormValue("birth"))
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("webhooks.html", h.headerFuncs(r, userID))
 	if err != nil {
-		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+		logger.Log.WithError(err).Error("webhooks handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
-	form.Birth = birth
 
-	// Валидируем данные формы
-	if !form.Validate() {
-		err := tmpl.ExecuteTemplate(w, "registration", form)
-		if err != nil {
-			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+	// Если пришел POST запрос, то создаем подписку
+	form := webhookForm{Localizer: h.localizer(r, userID)}
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			logger.Log.WithError(err).Error("webhooks handler error")
 			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 			return
 		}
-		return
-	}
 
-	// Создаем пользователя
-	userID, err := h.usersSrv.Create(form.Login, form.Password, form.Name, form.Birth)
-	// Если пользователь с таким логином уже существует сообщаем об этом
-	if err == users.ErrLoginExists {
-		form.Errors["Login"] = "Пользователь с таким именем уже существует"
-		err = tmpl.ExecuteTemplate(w, "registration", form)
-		if err != nil {
-			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
-			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
-			return
+		form.URL = r.FormValue("url")
+		form.Events = r.Form["events"]
+
+		if form.Validate() {
+			webhookID, err := h.webhooksSrv.Subscribe(userID, form.URL, form.Events)
+			// Адрес во внутренней сети показываем как ошибку формы
+			if errors.Is(err, webhooks.ErrForbiddenAddress) {
+				form.Errors["URL"] = form.Localizer.T("webhooks.error.address")
+			} else if err != nil {
+				logger.Log.WithError(err).Error("webhooks handler error")
+				http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+				return
+			} else {
+				// Секрет подписи в журнал не попадает
+				h.audit(r, models.AuditEntry{
+					ActorID:    userID,
+					Action:     models.AuditWebhookCreate,
+					TargetType: models.AuditTargetWebhook,
+					TargetID:   webhookID,
+				}, nil, map[string]interface{}{"url": form.URL, "events": form.Events})
+
+				http.Redirect(w, r, "/webhooks/", http.StatusSeeOther)
+				return
+			}
 		}
-		return
 	}
-	// Иначе просто переходим на страницу с ошибкой
+
+	// Получаем список подписок пользователя
+	list, err := h.webhooksSrv.Get(userID)
 	if err != nil {
-		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+		logger.Log.WithError(err).Error("webhooks handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Авторизуем пользователя
-	if err = h.authorizeUser(userID, w, r); err != nil {
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "webhooks", toWebhooksPage(form, list))
+	if err != nil {
+		logger.Log.WithError(err).Error("webhooks handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
-		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
 		return
 	}
-
-	// Перекидываем пользователя на главную страницу
-	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 }
 
-// Logout Обработчик нажатия кнопки выхода из системы
-func (h *PageHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	// Сбрасываем сессию пользователя
-	err := h.logoutUser(w, r)
+// DeleteWebhook Обработчик удаления подписки на события
+func (h *PageHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("delete webhook handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	webhookID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
 	if err != nil {
-		logger.Log.WithError(err).Error("logout handler error")
+		logger.Log.WithError(err).Error("delete webhook handler error")
 		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Переходим на страницу авторизации
-	http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
-}
+	err = h.webhooksSrv.Remove(userID, webhookID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("delete webhook handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
 
-// Error handlers
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditWebhookDelete,
+		TargetType: models.AuditTargetWebhook,
+		TargetID:   webhookID,
+	}, nil, nil)
 
-// Error Обработчик страницы ошибки для авторизованного пользователя
-func (h *PageHandler) Error(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/webhooks/", http.StatusSeeOther)
+}
+
+// Deliveries Обработчик страницы журнала доставок по подписке
+func (h *PageHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
 	// Проверяем аутентификацию
-	// Если пользователь не авторизован то перекидываем его на страницу ошибки для неавторизованных пользователей
-	if _, isAuth := h.getAuthorizedUserID(r); !isAuth {
-		logger.Log.Error("error page handler error: access forbidden")
-		http.Redirect(w, r, "/error/unauthorized", http.StatusTemporaryRedirect)
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("deliveries handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Парсим шаблон
-	tmpl := template.Must(template.ParseFiles(
-		"templates/error.html",
-		"templates/header.html",
-		"templates/footer.html",
-	))
-
-	// Рендерим шаблон
-	err := tmpl.ExecuteTemplate(w, "error", nil)
+	webhookID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = w.Write([]byte("<h1>Internal server error</h1>"))
+		logger.Log.WithError(err).Error("deliveries handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
-}
-
-// ErrorUnauthorized Обработчик страницы ошибки для неавторизованного пользователя
-func (h *PageHandler) ErrorUnauthorized(w http.ResponseWriter, _ *http.Request) {
-	tmpl := template.Must(template.ParseFiles(
-		"templates/error_unauthorized.html",
-		"templates/header_unauthorized.html",
-		"templates/footer.html",
-	))
 
-	err := tmpl.ExecuteTemplate(w, "errorUnauthorized", nil)
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("deliveries.html", h.headerFuncs(r, userID))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = w.Write([]byte("<h1>Internal server error</h1>"))
+		logger.Log.WithError(err).Error("deliveries handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
 		return
 	}
-}
 
-// Common methods
-
-func (h *PageHandler) getAuthorizedUserID(r *http.Request) (int64, bool) {
-	// Получаем сессию
-	session, err := h.store.Get(r, "cookie-name")
+	// Получаем журнал доставок, чужая подписка неотличима от несуществующей
+	list, err := h.webhooksSrv.Deliveries(userID, webhookID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
 	if err != nil {
-		return 0, false
+		logger.Log.WithError(err).Error("deliveries handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
 	}
 
-	// Читаем из сессии ID пользователя
-	userID, ok := session.Values[userIDKey].(int64)
-	if !ok {
-		return 0, false
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "deliveries", deliveriesToView(list))
+	if err != nil {
+		logger.Log.WithError(err).Error("deliveries handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
 	}
-
-	return userID, true
 }
 
-func (h *PageHandler) authorizeUser(userID int64, w http.ResponseWriter, r *http.Request) error {
-	// Получаем сессию
-	session, err := h.store.Get(r, "cookie-name")
-	if err != nil {
-		return err
-	}
+// Ledger handlers
 
-	// Сохраняем в сессию ID пользователя
+// Ledgers Обработчик страницы бухгалтерий пользователя
+func (h *PageHandler) Ledgers(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("ledgers handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("ledgers.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("ledgers handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Если пришел POST запрос, то создаем бухгалтерию
+	form := ledgerForm{Localizer: h.localizer(r, userID)}
+	if r.Method == http.MethodPost {
+		form.Name = r.FormValue("name")
+
+		if form.Validate() {
+			ledgerID, err := h.ledgersSrv.Create(userID, form.Name)
+			if err != nil {
+				logger.Log.WithError(err).Error("ledgers handler error")
+				http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+				return
+			}
+
+			h.audit(r, models.AuditEntry{
+				ActorID:    userID,
+				Action:     models.AuditLedgerCreate,
+				TargetType: models.AuditTargetLedger,
+				TargetID:   ledgerID,
+			}, nil, map[string]string{"name": form.Name})
+
+			http.Redirect(w, r, "/ledgers/", http.StatusSeeOther)
+			return
+		}
+	}
+
+	// Получаем бухгалтерии пользователя
+	current, err := h.getCurrentLedger(r, userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("ledgers handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	list, err := h.ledgersSrv.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("ledgers handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "ledgers", ledgersPage{Form: form, Ledgers: ledgersToView(form.Localizer, list, current.ID)})
+	if err != nil {
+		logger.Log.WithError(err).Error("ledgers handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// SwitchLedger Обработчик переключения текущей бухгалтерии
+func (h *PageHandler) SwitchLedger(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("switch ledger handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	ledgerID, err := strconv.ParseInt(r.FormValue("ledger_id"), 10, 0)
+	if err != nil {
+		logger.Log.WithError(err).Error("switch ledger handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Переключиться можно только на бухгалтерию, в которой состоит пользователь
+	ledger, err := h.ledgersSrv.GetLedger(userID, ledgerID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("switch ledger handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if err = h.setCurrentLedger(ledger.ID, w, r); err != nil {
+		logger.Log.WithError(err).Error("switch ledger handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	http.Redirect(w, r, "/operations/", http.StatusSeeOther)
+}
+
+// LedgerMembers Обработчик страницы участников бухгалтерии
+func (h *PageHandler) LedgerMembers(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("ledger members handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	ledgerID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		logger.Log.WithError(err).Error("ledger members handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Страница доступна только участникам бухгалтерии
+	ledger, err := h.ledgersSrv.GetLedger(userID, ledgerID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("ledger members handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("members.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("ledger members handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Если пришел POST запрос, то добавляем участника
+	form := memberForm{Localizer: h.localizer(r, userID)}
+	if r.Method == http.MethodPost {
+		form.Login = r.FormValue("login")
+		form.Role = r.FormValue("role")
+
+		if form.Validate() {
+			err := h.ledgersSrv.AddMember(userID, ledgerID, form.Login, models.LedgerRole(form.Role))
+			switch err {
+			case nil:
+				h.audit(r, models.AuditEntry{
+					ActorID:    userID,
+					Action:     models.AuditMemberAdd,
+					TargetType: models.AuditTargetLedger,
+					TargetID:   ledgerID,
+				}, nil, map[string]string{"login": form.Login, "role": form.Role})
+
+				http.Redirect(w, r, fmt.Sprintf("/ledgers/%d/members/", ledgerID), http.StatusSeeOther)
+				return
+			case ledgers.ErrUserNotFound:
+				form.Errors["Login"] = form.Localizer.T("members.error.not_found")
+			case ledgers.ErrMemberExists:
+				form.Errors["Login"] = form.Localizer.T("members.error.exists")
+			default:
+				logger.Log.WithError(err).Error("ledger members handler error")
+				http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+				return
+			}
+		}
+	}
+
+	// Получаем участников бухгалтерии
+	list, err := h.ledgersSrv.Members(userID, ledgerID)
+	if err != nil {
+		logger.Log.WithError(err).Error("ledger members handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "members", toMembersPage(form.Localizer, ledger, form, list))
+	if err != nil {
+		logger.Log.WithError(err).Error("ledger members handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// DeleteLedgerMember Обработчик исключения участника из бухгалтерии
+func (h *PageHandler) DeleteLedgerMember(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("delete ledger member handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	vars := mux.Vars(r)
+	ledgerID, err := strconv.ParseInt(vars["id"], 10, 0)
+	if err != nil {
+		logger.Log.WithError(err).Error("delete ledger member handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	memberID, err := strconv.ParseInt(vars["userID"], 10, 0)
+	if err != nil {
+		logger.Log.WithError(err).Error("delete ledger member handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	err = h.ledgersSrv.RemoveMember(userID, ledgerID, memberID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("delete ledger member handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditMemberRemove,
+		TargetType: models.AuditTargetLedger,
+		TargetID:   ledgerID,
+	}, map[string]int64{"user_id": memberID}, nil)
+
+	http.Redirect(w, r, fmt.Sprintf("/ledgers/%d/members/", ledgerID), http.StatusSeeOther)
+}
+
+// Invite handlers
+
+// Invites Обработчик страницы приглашений на регистрацию
+func (h *PageHandler) Invites(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("invites handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("invites.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("invites handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Приглашения нужны только в режиме регистрации по приглашениям
+	page := invitesPage{Disabled: h.invitesSrv.Mode() != invites.ModeInvite}
+	if page.Disabled {
+		err := tmpl.ExecuteTemplate(w, "invites", page)
+		if err != nil {
+			logger.Log.WithError(err).Error("invites handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		}
+		return
+	}
+
+	// Если пришел POST запрос, то выпускаем новое приглашение
+	if r.Method == http.MethodPost {
+		token, err := h.invitesSrv.Create(userID)
+		if err != nil {
+			logger.Log.WithError(err).Error("invites handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+
+		h.audit(r, models.AuditEntry{
+			ActorID:    userID,
+			Action:     models.AuditInviteCreate,
+			TargetType: models.AuditTargetInvite,
+		}, nil, nil)
+
+		// Ссылка показывается пользователю только один раз
+		page.Link = inviteLink(r, token)
+	}
+
+	// Получаем список приглашений пользователя
+	list, err := h.invitesSrv.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("invites handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+	page.Invites = invitesToView(h.localizer(r, userID), list, time.Now())
+
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "invites", page)
+	if err != nil {
+		logger.Log.WithError(err).Error("invites handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// RevokeInvite Обработчик отзыва приглашения на регистрацию
+func (h *PageHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("revoke invite handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	inviteID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		logger.Log.WithError(err).Error("revoke invite handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	err = h.invitesSrv.Revoke(userID, inviteID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("revoke invite handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditInviteRevoke,
+		TargetType: models.AuditTargetInvite,
+		TargetID:   inviteID,
+	}, nil, nil)
+
+	http.Redirect(w, r, "/invites/", http.StatusSeeOther)
+}
+
+// Two factor handlers
+
+// TwoFactor Обработчик страницы настроек двухфакторной аутентификации
+func (h *PageHandler) TwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("two factor handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.renderTwoFactor(w, r, userID, twoFactorPage{})
+}
+
+// TwoFactorEnroll Обработчик начала подключения двухфакторной аутентификации
+func (h *PageHandler) TwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("two factor enroll handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Повторное подключение заменяет неподтвержденный секрет новым
+	_, err := h.twofactorSrv.Begin(userID)
+	if err != nil && err != twofactor.ErrAlreadyEnabled {
+		logger.Log.WithError(err).Error("two factor enroll handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	http.Redirect(w, r, "/2fa/", http.StatusSeeOther)
+}
+
+// TwoFactorQR Обработчик картинки с QR кодом секрета, ожидающего подтверждения
+func (h *PageHandler) TwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.usersSrv.GetUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor qr handler error")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	image, err := h.twofactorSrv.QR(userID, user.Login)
+	if err == twofactor.ErrNotEnrolled || err == twofactor.ErrAlreadyEnabled {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor qr handler error")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Картинка содержит секрет, поэтому не кешируется
+	w.Header().Set("Content-Type", contentTypePNG)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(image)
+}
+
+// TwoFactorConfirm Обработчик подтверждения подключения кодом из приложения
+func (h *PageHandler) TwoFactorConfirm(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("two factor confirm handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	codes, err := h.twofactorSrv.Confirm(userID, r.FormValue("code"))
+	if err == twofactor.ErrInvalidCode {
+		h.renderTwoFactor(w, r, userID, twoFactorPage{Error: h.localizer(r, userID).T("twofactor.error.confirm")})
+		return
+	}
+	// Если попытки временно исчерпаны, то сообщаем об этом так же, как на втором шаге входа
+	if err == twofactor.ErrTooManyTries {
+		h.renderTwoFactor(w, r, userID, twoFactorPage{Error: h.localizer(r, userID).T("twofactor.error.attempts")})
+		return
+	}
+	if err == twofactor.ErrNotEnrolled || err == twofactor.ErrAlreadyEnabled {
+		http.Redirect(w, r, "/2fa/", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor confirm handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditTwoFactorEnable,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, nil)
+
+	h.renderTwoFactor(w, r, userID, twoFactorPage{Codes: codes})
+}
+
+// TwoFactorCodes Обработчик выпуска новых кодов восстановления
+func (h *PageHandler) TwoFactorCodes(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("two factor codes handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	codes, err := h.twofactorSrv.RegenerateCodes(userID, r.FormValue("code"))
+	if err == twofactor.ErrInvalidCode || err == twofactor.ErrTooManyTries {
+		h.renderTwoFactor(w, r, userID, twoFactorPage{Error: h.localizer(r, userID).T("twofactor.error.invalid")})
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor codes handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditTwoFactorCodes,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, nil)
+
+	h.renderTwoFactor(w, r, userID, twoFactorPage{Codes: codes})
+}
+
+// TwoFactorDisable Обработчик отключения двухфакторной аутентификации
+func (h *PageHandler) TwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("two factor disable handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	err := h.twofactorSrv.Disable(userID, r.FormValue("code"))
+	if err == twofactor.ErrInvalidCode || err == twofactor.ErrTooManyTries {
+		h.renderTwoFactor(w, r, userID, twoFactorPage{Error: h.localizer(r, userID).T("twofactor.error.invalid")})
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor disable handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditTwoFactorOff,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, nil)
+
+	http.Redirect(w, r, "/2fa/", http.StatusSeeOther)
+}
+
+// Дополняет страницу настроек двухфакторной аутентификации текущим состоянием и рендерит ее
+func (h *PageHandler) renderTwoFactor(w http.ResponseWriter, r *http.Request, userID int64, page twoFactorPage) {
+	tmpl, err := h.templates.Page("twofactor.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	enabled, err := h.twofactorSrv.Enabled(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+	page.Enabled = enabled
+
+	if enabled {
+		page.CodesLeft, err = h.twofactorSrv.RecoveryCodesLeft(userID)
+	} else {
+		page.Secret, err = h.twofactorSrv.Pending(userID)
+		if err == twofactor.ErrNotEnrolled {
+			err = nil
+		}
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	err = tmpl.ExecuteTemplate(w, "twoFactor", page)
+	if err != nil {
+		logger.Log.WithError(err).Error("two factor handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// Session handlers
+
+// Sessions Обработчик страницы устройств, на которых выполнен вход
+func (h *PageHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("sessions handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("sessions.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("sessions handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		logger.Log.WithError(err).Error("sessions handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	devices, err := h.sessionsSrv.Devices(userID, session)
+	if err != nil {
+		logger.Log.WithError(err).Error("sessions handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "sessions", devicesToView(devices))
+	if err != nil {
+		logger.Log.WithError(err).Error("sessions handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// RevokeSession Обработчик завершения сеанса на другом устройстве
+func (h *PageHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("revoke session handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		logger.Log.WithError(err).Error("revoke session handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Завершать можно только свои сеансы, чужой сеанс неотличим от несуществующего
+	err = h.sessionsSrv.Revoke(userID, sessionID)
+	if errors.Is(err, models.ErrNotFound) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("revoke session handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditSessionRevoke,
+		TargetType: models.AuditTargetSession,
+		TargetID:   sessionID,
+	}, nil, nil)
+
+	http.Redirect(w, r, "/sessions/", http.StatusSeeOther)
+}
+
+// RevokeAllSessions Обработчик выхода на всех устройствах, включая текущее
+func (h *PageHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("revoke all sessions handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	count, err := h.sessionsSrv.RevokeAll(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("revoke all sessions handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditSessionsRevoke,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, map[string]int64{"sessions": count})
+
+	// Текущая сессия уже удалена, осталось сбросить куки
+	if err = h.logoutUser(w, r); err != nil {
+		logger.Log.WithError(err).Error("revoke all sessions handler error")
+	}
+
+	http.Redirect(w, r, "/auth/", http.StatusSeeOther)
+}
+
+// Profile handlers
+
+// Profile Обработчик страницы профиля и изменения настоящего имени и даты рождения
+func (h *PageHandler) Profile(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("profile handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Если пришел GET запрос, только рендерим страницу с текущими данными и сообщением после перенаправления
+	if r.Method != http.MethodPost {
+		notice, err := h.takeNotice(w, r, profileNoticeKey)
+		if err != nil {
+			logger.Log.WithError(err).Error("profile handler error")
+		}
+
+		h.renderProfile(w, r, userID, profilePage{Saved: notice})
+		return
+	}
+
+	// Некорректная дата не прерывает обработку, а показывается как ошибка формы
+	form := profileForm{
+		Name:      strings.TrimSpace(r.FormValue("name")),
+		Timezone:  strings.TrimSpace(r.FormValue("timezone")),
+		Localizer: h.localizer(r, userID),
+	}
+	form.Birth, _ = time.Parse("2006-01-02", r.FormValue("birth"))
+
+	if !form.Validate() {
+		h.renderProfile(w, r, userID, profilePage{Profile: form})
+		return
+	}
+
+	before, err := h.usersSrv.GetUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("profile handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if err = h.usersSrv.UpdateProfile(userID, form.Name, form.Birth, form.Timezone); err != nil {
+		logger.Log.WithError(err).Error("profile handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditProfileUpdate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, map[string]interface{}{"name": before.Name, "birth": before.Birth, "timezone": before.Timezone}, map[string]interface{}{"name": form.Name, "birth": form.Birth, "timezone": form.Timezone})
+
+	h.renderProfile(w, r, userID, profilePage{Saved: h.localizer(r, userID).T("profile.saved")})
+}
+
+// ChangeLanguage Обработчик смены языка интерфейса из переключателя в шапке страницы
+// Пустой язык возвращает выбор языка по настройкам браузера
+func (h *PageHandler) ChangeLanguage(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("change language handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	language := r.FormValue("language")
+	if language != "" && !i18n.Supported(language) {
+		logger.Log.WithField("language", language).Error("change language handler error: unsupported language")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	before, err := h.usersSrv.GetUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("change language handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if err = h.usersSrv.SetLanguage(userID, language); err != nil {
+		logger.Log.WithError(err).Error("change language handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditProfileUpdate,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, map[string]string{"language": before.Language}, map[string]string{"language": language})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ChangePassword Обработчик смены пароля с подтверждением текущим паролем
+func (h *PageHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("change password handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	form := passwordForm{
+		CurrentPassword: r.FormValue("current-password"),
+		Password:        r.FormValue("password"),
+		ConfirmPassword: r.FormValue("confirm-password"),
+		Policy:          h.usersSrv.PasswordPolicy(),
+		Localizer:       h.localizer(r, userID),
+	}
+
+	if !form.Validate() {
+		h.renderProfile(w, r, userID, profilePage{Password: form})
+		return
+	}
+
+	user, err := h.usersSrv.GetUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("change password handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	message, err := h.confirmPassword(r, form.Localizer, user.Login, func() error {
+		return h.usersSrv.ChangePassword(userID, form.CurrentPassword, form.Password)
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("change password handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+	if message != "" {
+		form.Errors["CurrentPassword"] = message
+		h.renderProfile(w, r, userID, profilePage{Password: form})
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditPasswordChange,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, nil)
+
+	// Старый пароль мог быть известен злоумышленнику, поэтому завершаем сеансы на остальных устройствах,
+	// а текущий сеанс продолжаем под новым токеном
+	if _, err = h.sessionsSrv.RevokeAll(userID); err != nil {
+		logger.Log.WithError(err).Error("change password handler error")
+	}
+	if err = h.setNotice(r, profileNoticeKey, form.Localizer.T("profile.password.changed")); err != nil {
+		logger.Log.WithError(err).Error("change password handler error")
+	}
+	if err = h.authorizeUser(userID, w, r); err != nil {
+		logger.Log.WithError(err).Error("change password handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Форма профиля рендерится заново отдельным запросом, так как токен CSRF уже заменен при входе
+	http.Redirect(w, r, "/profile/", http.StatusSeeOther)
+}
+
+// DeleteAccount Обработчик удаления аккаунта вместе с бухгалтериями, которыми владеет пользователь
+func (h *PageHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("delete account handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, err := h.usersSrv.GetUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("delete account handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	form := deleteAccountForm{
+		Login:     user.Login,
+		Confirm:   r.FormValue("confirm"),
+		Password:  r.FormValue("password"),
+		Localizer: h.userLocalizer(r, user),
+	}
+
+	if !form.Validate() {
+		h.renderProfile(w, r, userID, profilePage{Delete: form})
+		return
+	}
+
+	message, err := h.confirmPassword(r, form.Localizer, user.Login, func() error {
+		return h.usersSrv.Delete(userID, form.Password)
+	})
+	// Общие бухгалтерии не удаляются вместе с аккаунтом, сначала из них нужно исключить участников
+	if err == users.ErrSharedLedgers {
+		form.Errors["Confirm"] = form.Localizer.T("profile.delete.shared")
+		h.renderProfile(w, r, userID, profilePage{Delete: form})
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("delete account handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+	if message != "" {
+		form.Errors["Password"] = message
+		h.renderProfile(w, r, userID, profilePage{Delete: form})
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditAccountDelete,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, map[string]string{"login": user.Login, "email": user.Email}, nil)
+
+	// Сессии удалены вместе с пользователем, осталось сбросить куки
+	if err = h.logoutUser(w, r); err != nil {
+		logger.Log.WithError(err).Error("delete account handler error")
+	}
+
+	http.Redirect(w, r, "/auth/", http.StatusSeeOther)
+}
+
+// RequestExport Обработчик запроса выгрузки всех персональных данных пользователя
+func (h *PageHandler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("request export handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Архив собирается в фоне, о готовности пользователь узнает на странице профиля
+	export, err := h.exportsSrv.Request(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("request export handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditDataExport,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, map[string]int64{"export_id": export.ID})
+
+	http.Redirect(w, r, "/profile/", http.StatusSeeOther)
+}
+
+// DownloadExport Обработчик скачивания архива с персональными данными по ссылке со страницы профиля
+func (h *PageHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	data, err := h.exportsSrv.Download(userID, r.FormValue("token"))
+	if err == exports.ErrInvalidToken {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("download export handler error")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Архив содержит персональные данные, поэтому не кешируется
+	w.Header().Set("Content-Type", contentTypeZIP)
+	w.Header().Set("Content-Disposition", `attachment; filename="casher-export.zip"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(data)
+}
+
+// Выполняет действие, подтвержденное текущим паролем, с той же защитой от перебора, что и вход
+// Если пароль неверный или попытки исчерпаны, то возвращает сообщение для формы
+func (h *PageHandler) confirmPassword(r *http.Request, l *i18n.Localizer, login string, apply func() error) (string, error) {
+	ip := clientIP(r)
+	wait, err := h.attemptsSrv.Reserve(login, ip)
+	if err == attempts.ErrTooManyAttempts {
+		return l.T("password.error.attempts", waitText(l, wait)), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Неверный пароль остается учтенным, а верный снимает попытку
+	// Счетчик не сбрасывается после верного пароля, что бы владелец узнал о неудачах при следующем входе
+	err = apply()
+	if err == users.ErrInvalidPassword {
+		return l.T("password.error.invalid"), nil
+	}
+	if releaseErr := h.attemptsSrv.Release(login, ip); releaseErr != nil {
+		logger.Log.WithError(releaseErr).WithField("login", login).Error("confirm password error")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// Дополняет страницу профиля текущими данными пользователя и рендерит ее
+func (h *PageHandler) renderProfile(w http.ResponseWriter, r *http.Request, userID int64, page profilePage) {
+	tmpl, err := h.templates.Page("profile.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("profile handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, err := h.usersSrv.GetUser(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("profile handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+	page.Login = user.Login
+
+	export, err := h.exportsSrv.Latest(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("profile handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+	page.Export = exportToView(h.userLocalizer(r, user), export, h.exportsSrv.Link)
+
+	// Форма профиля с ошибками показывает введенные данные, иначе сохраненные
+	if page.Profile.Errors == nil {
+		page.Profile.Name = user.Name
+		page.Profile.Birth = user.Birth
+		page.Profile.Timezone = user.Timezone
+	}
+
+	err = tmpl.ExecuteTemplate(w, "profile", page)
+	if err != nil {
+		logger.Log.WithError(err).Error("profile handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// Audit handlers
+
+// Audit Обработчик страницы истории действий пользователя
+func (h *PageHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("audit handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("audit.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("audit handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем страницу пагинации из запроса
+	var page int64 = 1
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		page, err = strconv.ParseInt(pageStr, 10, 0)
+		if err != nil {
+			logger.Log.WithError(err).Error("audit handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	// Получаем историю действий пользователя
+	paginator, err := h.auditSrv.Get(userID, page)
+	if err != nil {
+		logger.Log.WithError(err).Error("audit handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "audit", toPagingAudit(h.localizer(r, userID), page, paginator))
+	if err != nil {
+		logger.Log.WithError(err).Error("audit handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// Admin handlers
+
+// Admin Обработчик панели администратора со списком пользователей
+func (h *PageHandler) Admin(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("admin handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("admin.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("admin handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем страницу пагинации из запроса
+	var page int64 = 1
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		page, err = strconv.ParseInt(pageStr, 10, 0)
+		if err != nil {
+			logger.Log.WithError(err).Error("admin handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	// Получаем список пользователей, если пользователь не администратор, то получаем ошибку
+	paginator, err := h.adminSrv.Users(userID, page)
+	if err != nil {
+		logger.Log.WithError(err).Error("admin handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "admin", toAdminPage(page, paginator, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("admin handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// AdminDisableUser Обработчик блокировки аккаунта пользователя администратором
+func (h *PageHandler) AdminDisableUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUser(w, r, models.AuditAdminDisable, h.adminSrv.Disable)
+}
+
+// AdminEnableUser Обработчик разблокировки аккаунта пользователя администратором
+func (h *PageHandler) AdminEnableUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUser(w, r, models.AuditAdminEnable, h.adminSrv.Enable)
+}
+
+// AdminDeleteUser Обработчик удаления аккаунта пользователя администратором
+func (h *PageHandler) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	h.changeUser(w, r, models.AuditAdminDelete, h.adminSrv.Delete)
+}
+
+// AdminLog Обработчик журнала действий администраторов
+func (h *PageHandler) AdminLog(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("admin log handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Журнал доступен только администраторам
+	if !h.adminSrv.IsAdmin(userID) {
+		logger.Log.WithField("userID", userID).Error("admin log handler error: not admin")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("admin_log.html", h.headerFuncs(r, userID))
+	if err != nil {
+		logger.Log.WithError(err).Error("admin log handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем страницу пагинации из запроса
+	var page int64 = 1
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		page, err = strconv.ParseInt(pageStr, 10, 0)
+		if err != nil {
+			logger.Log.WithError(err).Error("admin log handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	paginator, err := h.auditSrv.GetAdmin(page)
+	if err != nil {
+		logger.Log.WithError(err).Error("admin log handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Рендерим шаблон
+	err = tmpl.ExecuteTemplate(w, "admin_log", toPagingAudit(h.localizer(r, userID), page, paginator))
+	if err != nil {
+		logger.Log.WithError(err).Error("admin log handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// Применяет действие администратора к пользователю из пути запроса и записывает его в журнал
+// Права администратора проверяются в сервисе
+func (h *PageHandler) changeUser(
+	w http.ResponseWriter,
+	r *http.Request,
+	action string,
+	apply func(adminID, userID int64) (*models.User, error),
+) {
+	// Проверяем аутентификацию
+	adminID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.WithField("action", action).Error("admin handler error: access forbidden")
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		logger.Log.WithError(err).WithField("action", action).Error("admin handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, err := apply(adminID, userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("action", action).Error("admin handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    adminID,
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, map[string]interface{}{"login": user.Login, "disabled": user.Disabled != nil}, nil)
+
+	http.Redirect(w, r, "/admin/", http.StatusSeeOther)
+}
+
+// Auth handlers
+
+// Auth Обработчик страницы авторизации пользователя
+func (h *PageHandler) Auth(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("auth.html", csrfFuncs(r))
+	if err != nil {
+		logger.Log.WithError(err).Error("auth handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	form := authForm{SSO: h.ssoSrv.Enabled(), Localizer: h.localizer(r, 0)}
+
+	// Если пришел GET запрос, только рендерим шаблон и выходим
+	if r.Method != http.MethodPost {
+		err := tmpl.ExecuteTemplate(w, "auth", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("auth handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	form.Login = r.FormValue("login")
+	form.Password = r.FormValue("password")
+
+	// Валидируем данные формы
+	if !form.Validate() {
+		err := tmpl.ExecuteTemplate(w, "auth", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	// При переборе паролей пароль не проверяем, пока не истечет задержка или блокировка
+	// Попытка резервируется как неудачная до проверки пароля, поэтому параллельные запросы не обходят задержку
+	ip := clientIP(r)
+	wait, err := h.attemptsSrv.Reserve(form.Login, ip)
+	if err == attempts.ErrTooManyAttempts {
+		form.Errors["Password"] = form.Localizer.T("auth.error.attempts", waitText(form.Localizer, wait))
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		err := tmpl.ExecuteTemplate(w, "auth", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+		}
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем пользователя по логину и паролю
+	u, err := h.usersSrv.Auth(form.Login, form.Password)
+	// Если пользователь не найден или пароль не валидирован, то отправляем сообщение пользователю
+	// Зарезервированная попытка остается неудачей и для несуществующего логина, что бы по ответу нельзя было проверить его наличие
+	if err == users.ErrInvalidPassword {
+		// Неудачная попытка попадает в историю пользователя, если такой логин существует
+		targetID, _ := h.usersSrv.GetUserID(form.Login)
+		h.audit(r, models.AuditEntry{
+			Action:     models.AuditLoginFailed,
+			TargetType: models.AuditTargetUser,
+			TargetID:   targetID,
+		}, nil, map[string]string{"login": form.Login})
+
+		form.Errors["Password"] = form.Localizer.T("auth.error.invalid")
+		err := tmpl.ExecuteTemplate(w, "auth", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+	// Заблокированному пользователю сообщаем о блокировке, пароль при этом верный
+	if err == users.ErrUserDisabled {
+		if err := h.attemptsSrv.Release(form.Login, ip); err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+		}
+
+		targetID, _ := h.usersSrv.GetUserID(form.Login)
+		h.audit(r, models.AuditEntry{
+			Action:     models.AuditLoginFailed,
+			TargetType: models.AuditTargetUser,
+			TargetID:   targetID,
+		}, nil, map[string]string{"login": form.Login, "reason": "disabled"})
+
+		form.Errors["Password"] = form.Localizer.T("auth.error.disabled")
+		err := tmpl.ExecuteTemplate(w, "auth", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+	// Иначе рендерим страницу с ошибкой, а попытку не учитываем
+	if err != nil {
+		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+		if err := h.attemptsSrv.Release(form.Login, ip); err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+		}
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Неудачные попытки сбрасываем, а владельцу аккаунта покажем их после входа
+	failures, err := h.attemptsSrv.Succeed(form.Login, ip)
+	if err != nil {
+		logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+	}
+	// Сообщение показывается после входа, поэтому сразу формируется на языке пользователя
+	if failures != nil {
+		if err = h.setLoginNotice(r, h.userLocalizer(r, u), failures); err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("auth handler error")
+		}
+	}
+
+	// Завершаем вход: запрашиваем второй фактор или выдаем сессию
+	h.completeLogin(w, r, u.ID, nil)
+}
+
+// SSOLogin Обработчик перехода на страницу входа провайдера OpenID Connect
+func (h *PageHandler) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.ssoSrv.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	authURL, flow, err := h.ssoSrv.Begin(r.Context())
+	if err != nil {
+		logger.Log.WithError(err).Error("sso login handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Одноразовые значения остаются в сессии до возврата от провайдера
+	if err = h.saveSSOFlow(flow, w, r); err != nil {
+		logger.Log.WithError(err).Error("sso login handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// SSOCallback Обработчик возврата от провайдера OpenID Connect
+// Авторизованному пользователю привязывает учетную запись, остальных авторизует, создавая пользователя при первом входе
+func (h *PageHandler) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.ssoSrv.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	tmpl, err := h.templates.Page("auth.html", csrfFuncs(r))
+	if err != nil {
+		logger.Log.WithError(err).Error("sso callback handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Значения входа одноразовые, поэтому сразу удаляются из сессии
+	flow, err := h.takeSSOFlow(w, r)
+	if err != nil {
+		logger.Log.WithError(err).Error("sso callback handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	currentUserID, _ := h.getAuthorizedUserID(r)
+
+	var result *sso.Result
+	if providerErr := r.FormValue("error"); providerErr != "" {
+		logger.Log.WithField("error", providerErr).Error("sso callback handler error: provider error")
+		err = sso.ErrInvalidLogin
+	} else {
+		result, err = h.ssoSrv.Complete(r.Context(), flow, r.FormValue("state"), r.FormValue("code"), currentUserID)
+	}
+
+	// Ошибки входа показываем на странице авторизации
+	if err == sso.ErrInvalidState || err == sso.ErrInvalidLogin || err == sso.ErrIdentityLinked || err == sso.ErrUserDisabled {
+		l := h.localizer(r, currentUserID)
+		form := authForm{SSO: true, Errors: map[string]string{}, Localizer: l}
+		switch err {
+		case sso.ErrIdentityLinked:
+			form.Errors["SSO"] = l.T("auth.error.sso.linked")
+		case sso.ErrUserDisabled:
+			form.Errors["SSO"] = l.T("auth.error.disabled")
+		default:
+			form.Errors["SSO"] = l.T("auth.error.sso.failed")
+		}
+
+		err = tmpl.ExecuteTemplate(w, "auth", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("sso callback handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		}
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("sso callback handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	switch {
+	case result.Linked:
+		h.audit(r, models.AuditEntry{
+			ActorID:    result.UserID,
+			Action:     models.AuditSSOLink,
+			TargetType: models.AuditTargetUser,
+			TargetID:   result.UserID,
+		}, nil, nil)
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	case result.Created:
+		h.audit(r, models.AuditEntry{
+			ActorID:    result.UserID,
+			Action:     models.AuditRegistration,
+			TargetType: models.AuditTargetUser,
+			TargetID:   result.UserID,
+		}, nil, map[string]string{"method": "sso"})
+	}
+
+	// Завершаем вход: запрашиваем второй фактор или выдаем сессию
+	h.completeLogin(w, r, result.UserID, map[string]string{"method": "sso"})
+}
+
+// Завершает вход после проверки пароля или входа через SSO
+// Если у пользователя включена двухфакторная аутентификация, то сессия выдается только после проверки кода
+func (h *PageHandler) completeLogin(w http.ResponseWriter, r *http.Request, userID int64, details interface{}) {
+	enabled, err := h.twofactorSrv.Enabled(userID)
+	if err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Error("login error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if enabled {
+		if err = h.beginSecondFactor(userID, w, r); err != nil {
+			logger.Log.WithError(err).WithField("userID", userID).Error("login error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+
+		http.Redirect(w, r, "/auth/2fa/", http.StatusSeeOther)
+		return
+	}
+
+	// Авторизуем пользователя
+	if err = h.authorizeUser(userID, w, r); err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Error("login error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditLogin,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, details)
+
+	// Редиректим пользователя на главную страницу
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// SecondFactor Обработчик второго шага авторизации по коду из приложения или коду восстановления
+func (h *PageHandler) SecondFactor(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("auth_2fa.html", csrfFuncs(r))
+	if err != nil {
+		logger.Log.WithError(err).Error("second factor handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Без проверенного пароля второй шаг недоступен
+	userID, ok := h.getPendingUserID(r)
+	if !ok {
+		http.Redirect(w, r, "/auth/", http.StatusSeeOther)
+		return
+	}
+
+	form := secondFactorForm{Localizer: h.localizer(r, 0)}
+
+	// Если пришел GET запрос, только рендерим шаблон и выходим
+	if r.Method != http.MethodPost {
+		err := tmpl.ExecuteTemplate(w, "secondFactor", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("second factor handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	form.Code = r.FormValue("code")
+
+	// Валидируем данные формы
+	if !form.Validate() {
+		err := tmpl.ExecuteTemplate(w, "secondFactor", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("second factor handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	err = h.twofactorSrv.Verify(userID, form.Code)
+	// Если код не подошел или попытки временно исчерпаны, то сообщаем об ошибке
+	if err == twofactor.ErrInvalidCode || err == twofactor.ErrTooManyTries {
+		h.audit(r, models.AuditEntry{
+			Action:     models.AuditLoginFailed,
+			TargetType: models.AuditTargetUser,
+			TargetID:   userID,
+		}, nil, map[string]string{"reason": "2fa"})
+
+		form.Errors["Code"] = form.Localizer.T("twofactor.error.invalid")
+		if err == twofactor.ErrTooManyTries {
+			form.Errors["Code"] = form.Localizer.T("twofactor.error.attempts")
+		}
+
+		err = tmpl.ExecuteTemplate(w, "secondFactor", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("second factor handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("second factor handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Авторизуем пользователя
+	if err = h.authorizeUser(userID, w, r); err != nil {
+		logger.Log.WithError(err).Error("second factor handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditLogin,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, map[string]bool{"second_factor": true})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Registration Обработчик страницы регистрации нового пользователя
+func (h *PageHandler) Registration(w http.ResponseWriter, r *http.Request) {
+	// Создаем регистрационную форму
+	// Дата рождения - календарная дата без часового пояса, поэтому хранится как полночь UTC, как и введенная в форме
+	birth := time.Date(1986, 4, 19, 0, 0, 0, 0, time.UTC)
+	form := registrationForm{
+		Birth:         birth,
+		Invite:        r.FormValue("invite"),
+		EmailRequired: h.verifySrv.Required(),
+		Policy:        h.usersSrv.PasswordPolicy(),
+		Localizer:     h.localizer(r, 0),
+	}
+
+	// Получаем шаблон страницы
+	tmpl, err := h.templates.Page("registration.html", csrfFuncs(r))
+	if err != nil {
+		logger.Log.WithError(err).Error("registration handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Проверяем, доступна ли регистрация в текущем режиме
+	var invite *models.Invite
+	switch h.invitesSrv.Mode() {
+	case invites.ModeClosed:
+		form.Closed = form.Localizer.T("registration.closed")
+	case invites.ModeInvite:
+		invite, err = h.invitesSrv.Verify(form.Invite)
+		if err != nil {
+			form.Closed = form.Localizer.T("registration.invite")
+		}
+	}
+
+	// Если пришел GET запрос или регистрация недоступна, только рендерим форму и выходим
+	if r.Method != http.MethodPost || form.Closed != "" {
+		err = tmpl.ExecuteTemplate(w, "registration", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("registration handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	// Если пришел POST запрос, то обрабатываем форму
+	// Загружаем данные из формы в объект
+	form.Login = r.FormValue("login")
+	form.Password = r.FormValue("password")
+	form.ConfirmPassword = r.FormValue("confirm-password")
+	form.Email = strings.TrimSpace(r.FormValue("email"))
+	form.Name = r.FormValue("name")
+	// Отдельно парсим и обрабатываем дату рождения
+	birth, err = time.Parse("2006-01-02", r.FormValue("birth"))
+	if err != nil {
+		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+	form.Birth = birth
+
+	// Валидируем данные формы
+	if !form.Validate() {
+		err := tmpl.ExecuteTemplate(w, "registration", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	// Создаем пользователя, приглашение при этом используется
+	var inviteID, inviterID int64
+	if invite != nil {
+		inviteID, inviterID = invite.ID, invite.InviterID
+	}
+
+	userID, err := h.usersSrv.Create(form.Login, form.Password, form.Email, form.Name, form.Birth, inviteID)
+	// Если приглашение успели использовать или отозвать, сообщаем об этом
+	if err == users.ErrInvalidInvite {
+		form.Closed = form.Localizer.T("registration.invite")
+		err = tmpl.ExecuteTemplate(w, "registration", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+	// Если адрес уже привязан к другому пользователю сообщаем об этом
+	if err == users.ErrEmailExists {
+		form.Errors["Email"] = form.Localizer.T("registration.error.email.exists")
+		err = tmpl.ExecuteTemplate(w, "registration", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+	// Если пользователь с таким логином уже существует сообщаем об этом
+	if err == users.ErrLoginExists {
+		form.Errors["Login"] = form.Localizer.T("registration.error.login.exists")
+		err = tmpl.ExecuteTemplate(w, "registration", form)
+		if err != nil {
+			logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+	// Иначе просто переходим на страницу с ошибкой
+	if err != nil {
+		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditRegistration,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, map[string]interface{}{"login": form.Login, "email": form.Email, "name": form.Name, "birth": form.Birth, "invited_by": inviterID})
+
+	// Отправляем письмо для подтверждения адреса, ошибка отправки не мешает регистрации,
+	// так как письмо можно запросить повторно
+	if form.Email != "" {
+		if err = h.verifySrv.Send(userID); err != nil {
+			logger.Log.WithError(err).WithField("userID", userID).Error("registration handler error: send verification")
+		}
+	}
+
+	// Авторизуем пользователя
+	if err = h.authorizeUser(userID, w, r); err != nil {
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		logger.Log.WithError(err).WithField("form", form).Error("registration handler error")
+		return
+	}
+
+	// Перекидываем пользователя на главную страницу
+	// Главная открывается GET запросом, так как токен CSRF формы уже заменен при входе
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ForgotPassword Обработчик страницы запроса ссылки для смены пароля
+func (h *PageHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("forgot.html", csrfFuncs(r))
+	if err != nil {
+		logger.Log.WithError(err).Error("forgot password handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	form := forgotForm{Localizer: h.localizer(r, 0)}
+
+	// Если пришел POST запрос, то отправляем письмо со ссылкой
+	if r.Method == http.MethodPost {
+		form.Email = strings.TrimSpace(r.FormValue("email"))
+
+		if form.Validate() {
+			err := h.recoverySrv.Request(form.Email)
+			if err != nil {
+				logger.Log.WithError(err).Error("forgot password handler error")
+				http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+				return
+			}
+
+			// Ответ не зависит от того, зарегистрирован ли адрес
+			form = forgotForm{Sent: true}
+		}
+	}
+
+	err = tmpl.ExecuteTemplate(w, "forgot", form)
+	if err != nil {
+		logger.Log.WithError(err).Error("forgot password handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// ResetPassword Обработчик страницы смены пароля по ссылке из письма
+func (h *PageHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("reset.html", csrfFuncs(r))
+	if err != nil {
+		logger.Log.WithError(err).Error("reset password handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	form := resetForm{Token: r.FormValue("token"), Policy: h.usersSrv.PasswordPolicy(), Localizer: h.localizer(r, 0)}
+
+	// Если пришел GET запрос, проверяем ссылку и рендерим форму
+	if r.Method != http.MethodPost {
+		form.Invalid = h.recoverySrv.Verify(form.Token) != nil
+		err := tmpl.ExecuteTemplate(w, "reset", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("reset password handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	form.Password = r.FormValue("password")
+	form.ConfirmPassword = r.FormValue("confirm-password")
+
+	// Валидируем данные формы
+	if !form.Validate() {
+		err := tmpl.ExecuteTemplate(w, "reset", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("reset password handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+
+	userID, err := h.recoverySrv.Reset(form.Token, form.Password)
+	// Если ссылка недействительна, сообщаем об этом
+	if err == recovery.ErrInvalidToken {
+		form.Invalid = true
+		err = tmpl.ExecuteTemplate(w, "reset", form)
+		if err != nil {
+			logger.Log.WithError(err).Error("reset password handler error")
+			http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+			return
+		}
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("reset password handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	h.audit(r, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditPasswordReset,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+	}, nil, nil)
+
+	// Пароль могли сменить из-за кражи аккаунта, поэтому завершаем все сессии пользователя
+	if _, err = h.sessionsSrv.RevokeAll(userID); err != nil {
+		logger.Log.WithError(err).Error("reset password handler error")
+	}
+
+	// После смены пароля пользователь входит с новым паролем
+	http.Redirect(w, r, "/auth/", http.StatusSeeOther)
+}
+
+// VerifyEmail Обработчик перехода по ссылке подтверждения адреса из письма
+func (h *PageHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("verify_email.html", csrfFuncs(r))
+	if err != nil {
+		logger.Log.WithError(err).Error("verify email handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	userID, err := h.verifySrv.Verify(r.FormValue("token"))
+	if err == nil {
+		h.audit(r, models.AuditEntry{
+			ActorID:    userID,
+			Action:     models.AuditEmailVerify,
+			TargetType: models.AuditTargetUser,
+			TargetID:   userID,
+		}, nil, nil)
+	}
+
+	err = tmpl.ExecuteTemplate(w, "verifyEmail", verifyEmailPage{Verified: err == nil})
+	if err != nil {
+		logger.Log.WithError(err).Error("verify email handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// ResendVerification Обработчик повторной отправки письма для подтверждения адреса
+func (h *PageHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		http.Redirect(w, r, "/auth/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if err := h.verifySrv.Send(userID); err != nil {
+		logger.Log.WithError(err).WithField("userID", userID).Error("resend verification handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Logout Обработчик нажатия кнопки выхода из системы
+func (h *PageHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// Сбрасываем сессию пользователя
+	userID, isAuth := h.getAuthorizedUserID(r)
+	err := h.logoutUser(w, r)
+	if err != nil {
+		logger.Log.WithError(err).Error("logout handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if isAuth {
+		h.audit(r, models.AuditEntry{
+			ActorID:    userID,
+			Action:     models.AuditLogout,
+			TargetType: models.AuditTargetUser,
+			TargetID:   userID,
+		}, nil, nil)
+	}
+
+	// Переходим на страницу авторизации
+	http.Redirect(w, r, "/auth/", http.StatusSeeOther)
+}
+
+// Error handlers
+
+// Error Обработчик страницы ошибки для авторизованного пользователя
+func (h *PageHandler) Error(w http.ResponseWriter, r *http.Request) {
+	// Проверяем аутентификацию
+	// Если пользователь не авторизован то перекидываем его на страницу ошибки для неавторизованных пользователей
+	userID, isAuth := h.getAuthorizedUserID(r)
+	if !isAuth {
+		logger.Log.Error("error page handler error: access forbidden")
+		http.Redirect(w, r, "/error/unauthorized", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// Получаем шаблон страницы
+	// Со страницы ошибки некуда перенаправить, поэтому при ошибке отдаем текст без шаблона
+	tmpl, err := h.templates.Page("error.html", h.headerFuncs(r, userID))
+	if err == nil {
+		// Рендерим шаблон
+		err = tmpl.ExecuteTemplate(w, "error", nil)
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("error page handler error")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("<h1>Internal server error</h1>"))
+		return
+	}
+}
+
+// ErrorUnauthorized Обработчик страницы ошибки для неавторизованного пользователя
+func (h *PageHandler) ErrorUnauthorized(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("error_unauthorized.html", csrfFuncs(r))
+	if err == nil {
+		err = tmpl.ExecuteTemplate(w, "errorUnauthorized", nil)
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("error page handler error")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("<h1>Internal server error</h1>"))
+		return
+	}
+}
+
+// Отдает страницу 404, если объект не найден среди объектов пользователя
+// Для чужих объектов ответ тот же, что бы по нему нельзя было узнать о существовании чужих данных
+func (h *PageHandler) notFound(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.templates.Page("not_found.html", csrfFuncs(r))
+	w.WriteHeader(http.StatusNotFound)
+	if err == nil {
+		err = tmpl.ExecuteTemplate(w, "notFound", nil)
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("not found handler error")
+		_, _ = w.Write([]byte("<h1>Not found</h1>"))
+	}
+}
+
+// Common methods
+
+func (h *PageHandler) getAuthorizedUserID(r *http.Request) (int64, bool) {
+	// Получаем сессию
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return 0, false
+	}
+
+	// Читаем из сессии ID пользователя
+	userID, ok := session.Values[userIDKey].(int64)
+	if !ok {
+		return 0, false
+	}
+
+	// Сессия заблокированного или удаленного пользователя больше не действует
+	if !h.usersSrv.IsActive(userID) {
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func (h *PageHandler) authorizeUser(userID int64, w http.ResponseWriter, r *http.Request) error {
+	// Получаем сессию
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return err
+	}
+
+	// Выдаем новый токен, что бы токен, известный до входа, не давал доступа к аккаунту
+	if err = h.sessionsSrv.Renew(session); err != nil {
+		return err
+	}
+
+	// Токен CSRF тоже выпускаем заново
+	token, err := newCSRFToken()
+	if err != nil {
+		return err
+	}
+	session.Values[csrfTokenKey] = token
+
+	// Сохраняем в сессию ID пользователя, бухгалтерия выбирается заново
 	session.Values[userIDKey] = userID
+	delete(session.Values, ledgerIDKey)
+	clearSecondFactor(session)
 	err = session.Save(r, w)
 	if err != nil {
 		return err
@@ -521,13 +2698,13 @@ func (h *PageHandler) authorizeUser(userID int64, w http.ResponseWriter, r *http
 
 func (h *PageHandler) logoutUser(w http.ResponseWriter, r *http.Request) error {
 	// Получаем сессию
-	session, err := h.store.Get(r, "cookie-name")
+	session, err := h.sessionsSrv.Get(r, sessionName)
 	if err != nil {
 		return err
 	}
 
-	// Сохраняем в сессию ID пользователя
-	session.Values[userIDKey] = nil
+	// Удаляем сессию на сервере вместе с куки
+	session.Options.MaxAge = -1
 	err = session.Save(r, w)
 	if err != nil {
 		return err
@@ -535,3 +2712,279 @@ func (h *PageHandler) logoutUser(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+// Запоминает в сессии пользователя, прошедшего проверку пароля, до ввода второго фактора
+// Сам пользователь при этом не авторизуется
+func (h *PageHandler) beginSecondFactor(userID int64, w http.ResponseWriter, r *http.Request) error {
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return err
+	}
+
+	session.Values[userIDKey] = nil
+	session.Values[pendingUserIDKey] = userID
+	session.Values[pendingAtKey] = time.Now().Unix()
+	return session.Save(r, w)
+}
+
+// Возвращает пользователя, ожидающего второго шага входа, если время на ввод кода еще не истекло
+// Неудачные попытки считаются в базе, так как куки сессии можно подменить более старой
+func (h *PageHandler) getPendingUserID(r *http.Request) (int64, bool) {
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return 0, false
+	}
+
+	userID, ok := session.Values[pendingUserIDKey].(int64)
+	if !ok {
+		return 0, false
+	}
+
+	at, _ := session.Values[pendingAtKey].(int64)
+	if time.Since(time.Unix(at, 0)) > secondFactorTTL {
+		return 0, false
+	}
+
+	return userID, h.usersSrv.IsActive(userID)
+}
+
+// Сохраняет в сессии одноразовые значения входа через SSO
+func (h *PageHandler) saveSSOFlow(flow *sso.Flow, w http.ResponseWriter, r *http.Request) error {
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return err
+	}
+
+	session.Values[ssoStateKey] = flow.State
+	session.Values[ssoNonceKey] = flow.Nonce
+	session.Values[ssoVerifierKey] = flow.Verifier
+	session.Values[ssoAtKey] = time.Now().Unix()
+	return session.Save(r, w)
+}
+
+// Достает из сессии и удаляет одноразовые значения входа через SSO
+// Если вход начат слишком давно или не начат вовсе, возвращает nil
+func (h *PageHandler) takeSSOFlow(w http.ResponseWriter, r *http.Request) (*sso.Flow, error) {
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, _ := session.Values[ssoStateKey].(string)
+	nonce, _ := session.Values[ssoNonceKey].(string)
+	verifier, _ := session.Values[ssoVerifierKey].(string)
+	at, _ := session.Values[ssoAtKey].(int64)
+
+	for _, key := range []string{ssoStateKey, ssoNonceKey, ssoVerifierKey, ssoAtKey} {
+		delete(session.Values, key)
+	}
+	if err = session.Save(r, w); err != nil {
+		return nil, err
+	}
+
+	if state == "" || time.Since(time.Unix(at, 0)) > ssoTTL {
+		return nil, nil
+	}
+
+	return &sso.Flow{State: state, Nonce: nonce, Verifier: verifier}, nil
+}
+
+// Удаляет из сессии данные второго шага входа
+func clearSecondFactor(session *sessions.Session) {
+	delete(session.Values, pendingUserIDKey)
+	delete(session.Values, pendingAtKey)
+}
+
+// Возвращает текущую бухгалтерию пользователя, выбранную в сессии
+// Если бухгалтерия не выбрана или пользователь в ней больше не состоит, то возвращает первую доступную
+func (h *PageHandler) getCurrentLedger(r *http.Request, userID int64) (*models.Ledger, error) {
+	var ledgerID int64
+	if session, err := h.sessionsSrv.Get(r, sessionName); err == nil {
+		ledgerID, _ = session.Values[ledgerIDKey].(int64)
+	}
+
+	return h.ledgersSrv.Current(userID, ledgerID)
+}
+
+func (h *PageHandler) setCurrentLedger(ledgerID int64, w http.ResponseWriter, r *http.Request) error {
+	// Получаем сессию
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return err
+	}
+
+	// Сохраняем в сессию ID текущей бухгалтерии
+	session.Values[ledgerIDKey] = ledgerID
+	return session.Save(r, w)
+}
+
+// Сохраняет в сессии сообщение о неудачных попытках входа с прошлого визита
+// Сессия сохраняется дальше при завершении входа, в том числе после второго шага
+func (h *PageHandler) setLoginNotice(r *http.Request, l *i18n.Localizer, failures *models.LoginAttempts) error {
+	return h.setNotice(r, loginNoticeKey, loginNotice(l, failures))
+}
+
+// Сохраняет в сессии сообщение под ключом key, которое показывается один раз на следующей странице
+// Сессию сохраняет вызывающий код
+func (h *PageHandler) setNotice(r *http.Request, key, notice string) error {
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return err
+	}
+
+	session.Values[key] = notice
+	return nil
+}
+
+// Возвращает сообщение под ключом key и удаляет его из сессии
+func (h *PageHandler) takeNotice(w http.ResponseWriter, r *http.Request, key string) (string, error) {
+	session, err := h.sessionsSrv.Get(r, sessionName)
+	if err != nil {
+		return "", err
+	}
+
+	notice, ok := session.Values[key].(string)
+	if !ok {
+		return "", nil
+	}
+
+	delete(session.Values, key)
+	return notice, session.Save(r, w)
+}
+
+// Возвращает функции шаблона для шапки страницы авторизованного пользователя
+// ledgers возвращает данные для переключателя бухгалтерий, при ошибке переключатель не показывается
+// invites сообщает, нужно ли показывать ссылку на приглашения
+// admin сообщает, нужно ли показывать ссылку на панель администратора
+// unverifiedEmail возвращает неподтвержденный адрес пользователя для напоминания о подтверждении
+// csrfField возвращает скрытое поле формы с токеном CSRF
+// languages возвращает данные для переключателя языка, остальные функции перевода описаны в localeFuncs
+func (h *PageHandler) headerFuncs(r *http.Request, userID int64) template.FuncMap {
+	// Настройки пользователя читаются один раз за рендеринг, а не при каждом переводе
+	var (
+		once sync.Once
+		user *models.User
+	)
+	settings := func() *models.User {
+		once.Do(func() {
+			user = h.settingsUser(userID)
+		})
+		return user
+	}
+	localizer := func() *i18n.Localizer {
+		return h.userLocalizer(r, settings())
+	}
+
+	funcs := template.FuncMap{
+		"ledgers": func() *ledgerSwitcher {
+			current, err := h.getCurrentLedger(r, userID)
+			if err != nil {
+				return nil
+			}
+
+			list, err := h.ledgersSrv.Get(userID)
+			if err != nil {
+				return nil
+			}
+
+			return &ledgerSwitcher{Ledgers: ledgersToView(localizer(), list, current.ID)}
+		},
+		"invites": func() bool {
+			return h.invitesSrv.Mode() == invites.ModeInvite
+		},
+		"admin": func() bool {
+			return h.adminSrv.IsAdmin(userID)
+		},
+		"unverifiedEmail": func() string {
+			return h.verifySrv.Unverified(userID)
+		},
+		"csrfField": csrfField(r),
+		"languages": func() []languageOption {
+			var preferred string
+			if u := settings(); u != nil {
+				preferred = u.Language
+			}
+			return languagesToView(localizer(), preferred)
+		},
+	}
+	for name, f := range localeFuncs(localizer) {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
+// Возвращает пользователя, по настройкам которого выбираются язык и часовой пояс страницы
+// Для неавторизованного пользователя или при ошибке возвращает nil, тогда используются настройки по умолчанию
+func (h *PageHandler) settingsUser(userID int64) *models.User {
+	if userID == 0 {
+		return nil
+	}
+
+	user, err := h.usersSrv.GetUser(userID)
+	if err != nil {
+		return nil
+	}
+
+	return user
+}
+
+// Возвращает переводчик на язык пользователя: язык из его настроек или из заголовка Accept-Language
+// Для неавторизованного пользователя userID равен 0 и язык выбирается только по заголовку
+func (h *PageHandler) localizer(r *http.Request, userID int64) *i18n.Localizer {
+	return h.userLocalizer(r, h.settingsUser(userID))
+}
+
+// Возвращает переводчик на язык и в часовой пояс пользователя, user может быть nil
+func (h *PageHandler) userLocalizer(r *http.Request, user *models.User) *i18n.Localizer {
+	var language, timezone string
+	if user != nil {
+		language, timezone = user.Language, user.Timezone
+	}
+
+	return i18n.Negotiate(language, r.Header.Get("Accept-Language")).In(h.userLocation(timezone))
+}
+
+// Возвращает часовой пояс пользователя, а если он не выбран или не загружается, то часовой пояс по умолчанию
+func (h *PageHandler) userLocation(timezone string) *time.Location {
+	if timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			return loc
+		}
+	}
+
+	if h.location == nil {
+		return time.UTC
+	}
+
+	return h.location
+}
+
+// Записывает действие пользователя в журнал аудита вместе с адресом и клиентом запроса
+// Ошибка записи не прерывает обработку запроса, так как действие уже совершено
+func (h *PageHandler) audit(r *http.Request, entry models.AuditEntry, before, after interface{}) {
+	entry.IP = clientIP(r)
+	entry.UserAgent = r.UserAgent()
+	_ = h.auditSrv.Record(&entry, before, after)
+}
+
+// Собирает абсолютную ссылку на регистрацию по приглашению
+func inviteLink(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s/registration/?invite=%s", scheme, r.Host, url.QueryEscape(token))
+}
+
+// Возвращает IP адрес клиента без порта
+// За доверенным прокси адрес клиента из X-Forwarded-For уже подставлен middleware.RealIP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
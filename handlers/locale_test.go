@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/i18n"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/templates"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// Комментарии HTML и шаблонов, в которых русский текст допустим
+	templateCommentRe = regexp.MustCompile(`(?s)<!--.*?-->|{{/\*.*?\*/}}`)
+	cyrillicRe        = regexp.MustCompile(`[А-Яа-яЁё]`)
+	// Вызовы функций перевода с ключом-литералом
+	translateRe = regexp.MustCompile(`{{-?\s*(tn?) "([^"]+)"`)
+)
+
+// Весь текст страниц берется из каталогов сообщений, а ключи в шаблонах есть в каталогах
+func Test_TemplatesTranslated(t *testing.T) {
+	fsys := templates.Embedded()
+	names, err := fs.Glob(fsys, "*.html")
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	en := i18n.New("en")
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		require.NoError(t, err)
+
+		text := templateCommentRe.ReplaceAllString(string(data), "")
+		assert.Falsef(t, cyrillicRe.MatchString(text), "%s: text is not translated: %q", name, cyrillicRe.FindString(text))
+
+		for _, m := range translateRe.FindAllStringSubmatch(text, -1) {
+			if m[1] == "tn" {
+				assert.NotEqualf(t, m[2], en.N(m[2], 1), "%s: unknown plural message %s", name, m[2])
+				continue
+			}
+			assert.NotEqualf(t, m[2], en.T(m[2]), "%s: unknown message %s", name, m[2])
+		}
+	}
+}
+
+func Test_OperationsLocalized(t *testing.T) {
+	handler, m := newEscapingTestHandler(t)
+	cookie := newTestSession(t, handler, strangerID, "token")
+
+	m.ledgerRoles.EXPECT().GetRole(strangerLedgerID, strangerID).Return(models.RoleOwner, nil).Times(2)
+	m.operations.EXPECT().Get(strangerLedgerID, int64(1), gomock.Any()).Return(&models.OperationPaginator{
+		Operations: []models.Operation{
+			{ID: 1, LedgerID: strangerLedgerID, Subject: "coffee", Amount: 123456, Type: models.Withdraw, Created: time.Date(2021, 9, 1, 22, 30, 5, 0, time.UTC)},
+		},
+	}, nil).Times(2)
+
+	// Язык выбирается по заголовку Accept-Language, так как в настройках пользователя он не задан
+	r := httptest.NewRequest(http.MethodGet, "/operations/", nil)
+	r.Header.Set("Accept-Language", "en-US,en;q=0.9,ru;q=0.8")
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	handler.Router().ServeHTTP(w, r)
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, body, `<html lang="en">`)
+	assert.Contains(t, body, "Withdrawal")
+	assert.Contains(t, body, "Browser default")
+	assert.Contains(t, body, "<b>Amount:</b> 1,234.56")
+	assert.Contains(t, body, "<b>Date:</b> Sep 1, 2021 10:30:05 PM")
+	assert.NotContains(t, body, "Списание")
+
+	// Без заголовка страница отдается на языке по умолчанию, а время показывается в часовом поясе по умолчанию
+	handler.location = time.FixedZone("Asia/Tokyo", 9*60*60)
+	r = httptest.NewRequest(http.MethodGet, "/operations/", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.Router().ServeHTTP(w, r)
+	body = w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, body, `<html lang="ru">`)
+	assert.Contains(t, body, "Списание")
+	assert.Contains(t, body, "<b>Сумма:</b> 1\u00a0234,56")
+	assert.Contains(t, body, "<b>Дата:</b> 02.09.2021 07:30:05")
+}
+
+func Test_UserLocation(t *testing.T) {
+	tokyo := time.FixedZone("Asia/Tokyo", 9*60*60)
+	handler := &PageHandler{location: tokyo}
+
+	assert.Equal(t, "Europe/Berlin", handler.userLocation("Europe/Berlin").String())
+	assert.Equal(t, tokyo, handler.userLocation(""))
+	assert.Equal(t, tokyo, handler.userLocation("Mars/Olympus"))
+	assert.Equal(t, time.UTC, (&PageHandler{}).userLocation(""))
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/bgoldovsky/casher/app/logger"
+)
+
+const (
+	openAPIVersion  = "3.0.3"
+	securitySession = "session"
+	contentTypeJSON = "application/json"
+	contentTypePNG  = "image/png"
+	contentTypeZIP  = "application/zip"
+)
+
+// Шаблон переменной пути gorilla/mux, например {id:[0-9]+}
+var pathVariable = regexp.MustCompile(`\{([^{}:]+)(:[^{}]*)?\}`)
+
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type        string                   `json:"type"`
+	Format      string                   `json:"format,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Properties  map[string]openAPISchema `json:"properties,omitempty"`
+	Required    []string                 `json:"required,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIComponents struct {
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+// OpenAPI Обработчик описания HTTP API в формате OpenAPI 3
+func (h *PageHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+
+	err := json.NewEncoder(w).Encode(newOpenAPIDocument(h.routes()))
+	if err != nil {
+		logger.Log.WithError(err).Error("openapi handler error")
+		http.Redirect(w, r, "/error/", http.StatusTemporaryRedirect)
+		return
+	}
+}
+
+// Строит документ OpenAPI по каталогу маршрутов
+func newOpenAPIDocument(routes []route) *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   "Casher",
+			Version: "1.0.0",
+		},
+		Paths: map[string]map[string]openAPIOperation{},
+		Components: openAPIComponents{
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				securitySession: {Type: "apiKey", In: "cookie", Name: sessionName},
+			},
+		},
+	}
+
+	for _, rt := range routes {
+		path, params := openAPIPath(rt)
+
+		operations, ok := doc.Paths[path]
+		if !ok {
+			operations = map[string]openAPIOperation{}
+			doc.Paths[path] = operations
+		}
+
+		for _, method := range rt.methods {
+			operations[strings.ToLower(method)] = openAPIOperationFor(rt, method, params)
+		}
+	}
+
+	return doc
+}
+
+// Конвертирует путь маршрута в формат OpenAPI
+// Регулярные выражения из переменных пути убираются, а маршруту с префиксом добавляется переменная path
+func openAPIPath(rt route) (string, []param) {
+	path := pathVariable.ReplaceAllString(rt.path, "{$1}")
+	params := rt.params
+
+	if rt.prefix {
+		path += "{path}"
+		params = append([]param{{name: "path", in: inPath, typ: typeString, required: true, description: "Путь к файлу"}}, params...)
+	}
+
+	return path, params
+}
+
+// Описывает вызов маршрута указанным HTTP методом
+func openAPIOperationFor(rt route, method string, params []param) openAPIOperation {
+	operation := openAPIOperation{
+		OperationID: strings.ToLower(method) + rt.name,
+		Summary:     rt.summary,
+		Responses:   openAPIResponses(rt),
+	}
+
+	if rt.auth {
+		operation.Security = []map[string][]string{{securitySession: {}}}
+	}
+
+	form := openAPISchema{
+		Type:       "object",
+		Properties: map[string]openAPISchema{},
+	}
+
+	for _, p := range params {
+		schema := openAPISchema{Type: p.typ, Format: p.format}
+
+		// Поля формы передаются только в теле POST запроса
+		if p.in == inForm {
+			if method != http.MethodPost {
+				continue
+			}
+
+			schema.Description = p.description
+			form.Properties[p.name] = schema
+			if p.required {
+				form.Required = append(form.Required, p.name)
+			}
+			continue
+		}
+
+		operation.Parameters = append(operation.Parameters, openAPIParameter{
+			Name:        p.name,
+			In:          p.in,
+			Description: p.description,
+			Required:    p.required || p.in == inPath,
+			Schema:      schema,
+		})
+	}
+
+	if len(form.Properties) != 0 {
+		operation.RequestBody = &openAPIRequestBody{
+			Required: len(form.Required) != 0,
+			Content: map[string]openAPIMediaType{
+				"application/x-www-form-urlencoded": {Schema: form},
+			},
+		}
+	}
+
+	return operation
+}
+
+// Описывает ответы маршрута
+func openAPIResponses(rt route) map[string]openAPIResponse {
+	switch {
+	case rt.prefix:
+		return map[string]openAPIResponse{
+			"200": {Description: "Содержимое файла"},
+			"404": {Description: "Файл не найден"},
+		}
+	case rt.contentType == contentTypeJSON:
+		return map[string]openAPIResponse{
+			"200": {
+				Description: rt.summary,
+				Content: map[string]openAPIMediaType{
+					contentTypeJSON: {Schema: openAPISchema{Type: "object"}},
+				},
+			},
+		}
+	case rt.contentType != "":
+		return map[string]openAPIResponse{
+			"200": {
+				Description: rt.summary,
+				Content: map[string]openAPIMediaType{
+					rt.contentType: {Schema: openAPISchema{Type: typeString, Format: "binary"}},
+				},
+			},
+		}
+	}
+
+	return map[string]openAPIResponse{
+		"200": {
+			Description: "HTML страница",
+			Content: map[string]openAPIMediaType{
+				"text/html": {Schema: openAPISchema{Type: typeString}},
+			},
+		},
+		"307": {Description: "Перенаправление на другую страницу"},
+	}
+}
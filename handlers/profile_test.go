@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bgoldovsky/casher/app/i18n"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/passwords"
+	"github.com/bgoldovsky/casher/app/services/attempts"
+	"github.com/bgoldovsky/casher/app/services/audit"
+	"github.com/bgoldovsky/casher/app/services/exports"
+	"github.com/bgoldovsky/casher/app/services/users"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ChangePasswordRedirect(t *testing.T) {
+	handler, m := newEscapingTestHandler(t)
+	cookie := newTestSession(t, handler, strangerID, "token")
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	hasher := users.NewMockhasher(ctrl)
+	hasher.EXPECT().Check("current", "hash").Return(true, false)
+	hasher.EXPECT().Hash("new-password").Return("new-hash", nil)
+	usersRepo := users.NewMockusersRepository(ctrl)
+	usersRepo.EXPECT().Get(strangerID).Return(&models.User{ID: strangerID, Login: "stranger", Password: "hash", Role: models.UserRoleUser}, nil).AnyTimes()
+	usersRepo.EXPECT().SetPassword(strangerID, "new-hash").Return(nil)
+	attemptsRepo := attempts.NewMockrepository(ctrl)
+	attemptsRepo.EXPECT().Reserve(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.LoginAttempts{}, nil).Times(2)
+	attemptsRepo.EXPECT().Release(gomock.Any()).Return(nil).Times(2)
+	auditRepo := audit.NewMockrepository(ctrl)
+	auditRepo.EXPECT().Create(gomock.Any()).Return(int64(1), nil)
+	exportsRepo := exports.NewMockrepository(ctrl)
+	exportsRepo.EXPECT().Latest(strangerID).Return(nil, sql.ErrNoRows)
+
+	handler.usersSrv = users.New(usersRepo, hasher, passwords.Policy{})
+	handler.attemptsSrv = attempts.New(attemptsRepo, attempts.Config{LoginLimit: 10, IPLimit: 10})
+	handler.auditSrv = audit.New(auditRepo)
+	handler.exportsSrv = exports.New(exportsRepo, nil, nil, []byte("secret"), 0)
+
+	// Остальные сеансы завершаются, а текущий продолжается под новым токеном
+	m.sessions.EXPECT().DeleteAllByUser(strangerID).Return(int64(1), nil)
+	m.sessions.EXPECT().Delete(gomock.Any()).Return(nil)
+
+	form := url.Values{"current-password": {"current"}, "password": {"new-password"}, "confirm-password": {"new-password"}}
+	w := serve(handler, http.MethodPost, "/profile/password", form, cookie, "token")
+
+	// Токен CSRF выпускается заново, поэтому форма профиля со старым токеном не рендерится,
+	// а браузер переходит на профиль GET запросом
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, "/profile/", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	w = serve(handler, http.MethodGet, w.Header().Get("Location"), url.Values{}, cookies[0], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), i18n.New(i18n.Default).T("profile.password.changed"))
+	assert.NotContains(t, w.Body.String(), `value="token"`)
+}
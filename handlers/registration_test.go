@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/passwords"
+	"github.com/bgoldovsky/casher/app/services/audit"
+	"github.com/bgoldovsky/casher/app/services/invites"
+	"github.com/bgoldovsky/casher/app/services/users"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegistrationRedirect(t *testing.T) {
+	handler, m := newEscapingTestHandler(t)
+	cookie := newTestSession(t, handler, 0, "token")
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	hasher := users.NewMockhasher(ctrl)
+	hasher.EXPECT().Hash("qwerty").Return("hash", nil)
+	usersRepo := users.NewMockusersRepository(ctrl)
+	usersRepo.EXPECT().Create(gomock.Any()).Return(strangerID, nil)
+	usersRepo.EXPECT().Get(strangerID).Return(&models.User{ID: strangerID, Login: "stranger", Role: models.UserRoleUser}, nil).AnyTimes()
+	auditRepo := audit.NewMockrepository(ctrl)
+	auditRepo.EXPECT().Create(gomock.Any()).Return(int64(1), nil)
+
+	handler.usersSrv = users.New(usersRepo, hasher, passwords.Policy{})
+	handler.invitesSrv = invites.New(m.invites, invites.ModeOpen, []byte("secret"))
+	handler.auditSrv = audit.New(auditRepo)
+
+	// Вход выпускает новую сессию, а старая удаляется
+	m.sessions.EXPECT().Delete(gomock.Any()).Return(nil)
+
+	form := url.Values{"login": {"stranger"}, "password": {"qwerty"}, "confirm-password": {"qwerty"}, "name": {"Stranger"}, "birth": {"1986-04-19"}}
+	w := serve(handler, http.MethodPost, "/registration/", form, cookie, "token")
+
+	// После входа токен CSRF выпускается заново, поэтому браузер должен перейти на главную GET запросом,
+	// а не повторить POST со старым токеном
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, "/", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	m.ledgerRoles.EXPECT().GetRole(strangerLedgerID, strangerID).Return(models.RoleOwner, nil)
+	m.operations.EXPECT().Get(strangerLedgerID, int64(0), int64(0)).Return(&models.OperationPaginator{
+		Operations: []models.Operation{{ID: 1, LedgerID: strangerLedgerID, Amount: 100, Type: models.Deposit, Created: time.Now()}},
+	}, nil)
+
+	w = serve(handler, http.MethodGet, w.Header().Get("Location"), url.Values{}, cookies[0], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,584 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// Расположение параметров маршрута
+const (
+	inPath  = "path"
+	inQuery = "query"
+	inForm  = "form"
+)
+
+// Типы параметров маршрута
+const (
+	typeString  = "string"
+	typeInteger = "integer"
+	typeNumber  = "number"
+)
+
+// route Описание HTTP маршрута приложения
+// По каталогу маршрутов настраивается роутер и генерируется документ OpenAPI
+type route struct {
+	name    string
+	path    string
+	methods []string
+	summary string
+	auth    bool
+	prefix  bool
+	params  []param
+	handler http.HandlerFunc
+	// Тип содержимого ответа, по умолчанию HTML страница
+	contentType string
+}
+
+// param Описание параметра маршрута
+type param struct {
+	name        string
+	in          string
+	typ         string
+	format      string
+	required    bool
+	description string
+}
+
+// Возвращает каталог всех маршрутов приложения
+func (h *PageHandler) routes() []route {
+	// Добавляем доступ к статическим файлам
+	fs := http.StripPrefix("/static/", http.FileServer(h.static))
+
+	return []route{
+		{
+			name:    "Static",
+			path:    "/static/",
+			methods: []string{http.MethodGet},
+			summary: "Статические файлы",
+			prefix:  true,
+			handler: fs.ServeHTTP,
+		},
+		{
+			name:        "OpenAPI",
+			path:        "/openapi.json",
+			methods:     []string{http.MethodGet},
+			summary:     "Описание HTTP API в формате OpenAPI 3",
+			handler:     h.OpenAPI,
+			contentType: contentTypeJSON,
+		},
+		// Роуты авторизации
+		{
+			name:    "Index",
+			path:    "/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Главная страница",
+			auth:    true,
+			handler: h.Index,
+		},
+		{
+			name:    "Auth",
+			path:    "/auth/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Авторизация пользователя",
+			params: []param{
+				{name: "login", in: inForm, typ: typeString, required: true, description: "Имя пользователя"},
+				{name: "password", in: inForm, typ: typeString, required: true, description: "Пароль"},
+			},
+			handler: h.Auth,
+		},
+		{
+			name:    "SecondFactor",
+			path:    "/auth/2fa/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Второй шаг авторизации: код из приложения или код восстановления",
+			params: []param{
+				{name: "code", in: inForm, typ: typeString, required: true, description: "Код из приложения-аутентификатора или код восстановления"},
+			},
+			handler: h.SecondFactor,
+		},
+		{
+			name:    "SSOLogin",
+			path:    "/auth/sso/",
+			methods: []string{http.MethodGet},
+			summary: "Переход на страницу входа провайдера OpenID Connect",
+			handler: h.SSOLogin,
+		},
+		{
+			name:    "SSOCallback",
+			path:    "/auth/sso/callback",
+			methods: []string{http.MethodGet},
+			summary: "Возврат от провайдера OpenID Connect с кодом авторизации",
+			params: []param{
+				{name: "state", in: inQuery, typ: typeString, required: true, description: "Значение state из запроса входа"},
+				{name: "code", in: inQuery, typ: typeString, description: "Код авторизации"},
+				{name: "error", in: inQuery, typ: typeString, description: "Код ошибки провайдера"},
+			},
+			handler: h.SSOCallback,
+		},
+		{
+			name:    "Logout",
+			path:    "/logout/",
+			methods: []string{http.MethodPost},
+			summary: "Выход из системы",
+			handler: h.Logout,
+		},
+		{
+			name:    "Registration",
+			path:    "/registration/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Регистрация нового пользователя",
+			params: []param{
+				{name: "login", in: inForm, typ: typeString, required: true, description: "Имя пользователя"},
+				{name: "password", in: inForm, typ: typeString, required: true, description: "Пароль"},
+				{name: "confirm-password", in: inForm, typ: typeString, required: true, description: "Повтор пароля"},
+				{name: "name", in: inForm, typ: typeString, required: true, description: "Настоящее имя"},
+				{name: "email", in: inForm, typ: typeString, format: "email", description: "Адрес электронной почты для восстановления пароля, обязателен, если это включено в конфигурации"},
+				{name: "birth", in: inForm, typ: typeString, format: "date", required: true, description: "Дата рождения"},
+				{name: "invite", in: inQuery, typ: typeString, description: "Приглашение, обязательно в режиме регистрации по приглашениям"},
+			},
+			handler: h.Registration,
+		},
+		{
+			name:    "ForgotPassword",
+			path:    "/password/forgot/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Запрос письма со ссылкой для смены пароля",
+			params: []param{
+				{name: "email", in: inForm, typ: typeString, format: "email", required: true, description: "Адрес электронной почты"},
+			},
+			handler: h.ForgotPassword,
+		},
+		{
+			name:    "ResetPassword",
+			path:    "/password/reset/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Смена пароля по одноразовой ссылке из письма",
+			params: []param{
+				{name: "token", in: inQuery, typ: typeString, required: true, description: "Токен из ссылки"},
+				{name: "password", in: inForm, typ: typeString, required: true, description: "Новый пароль"},
+				{name: "confirm-password", in: inForm, typ: typeString, required: true, description: "Повтор нового пароля"},
+			},
+			handler: h.ResetPassword,
+		},
+		{
+			name:    "VerifyEmail",
+			path:    "/email/verify/",
+			methods: []string{http.MethodGet},
+			summary: "Подтверждение адреса почты по ссылке из письма",
+			params: []param{
+				{name: "token", in: inQuery, typ: typeString, required: true, description: "Токен из ссылки"},
+			},
+			handler: h.VerifyEmail,
+		},
+		{
+			name:    "ResendVerification",
+			path:    "/email/verify/resend",
+			methods: []string{http.MethodPost},
+			summary: "Повторная отправка письма для подтверждения адреса почты",
+			auth:    true,
+			handler: h.ResendVerification,
+		},
+		// Роуты двухфакторной аутентификации
+		{
+			name:    "TwoFactor",
+			path:    "/2fa/",
+			methods: []string{http.MethodGet},
+			summary: "Настройки двухфакторной аутентификации",
+			auth:    true,
+			handler: h.TwoFactor,
+		},
+		{
+			name:    "TwoFactorEnroll",
+			path:    "/2fa/enroll",
+			methods: []string{http.MethodPost},
+			summary: "Начало подключения двухфакторной аутентификации",
+			auth:    true,
+			handler: h.TwoFactorEnroll,
+		},
+		{
+			name:        "TwoFactorQR",
+			path:        "/2fa/qr.png",
+			methods:     []string{http.MethodGet},
+			summary:     "QR код секрета, ожидающего подтверждения",
+			auth:        true,
+			handler:     h.TwoFactorQR,
+			contentType: contentTypePNG,
+		},
+		{
+			name:    "TwoFactorConfirm",
+			path:    "/2fa/confirm",
+			methods: []string{http.MethodPost},
+			summary: "Подтверждение подключения кодом из приложения",
+			auth:    true,
+			params: []param{
+				{name: "code", in: inForm, typ: typeString, required: true, description: "Код из приложения-аутентификатора"},
+			},
+			handler: h.TwoFactorConfirm,
+		},
+		{
+			name:    "TwoFactorCodes",
+			path:    "/2fa/codes",
+			methods: []string{http.MethodPost},
+			summary: "Выпуск новых кодов восстановления",
+			auth:    true,
+			params: []param{
+				{name: "code", in: inForm, typ: typeString, required: true, description: "Код из приложения-аутентификатора или код восстановления"},
+			},
+			handler: h.TwoFactorCodes,
+		},
+		{
+			name:    "TwoFactorDisable",
+			path:    "/2fa/disable",
+			methods: []string{http.MethodPost},
+			summary: "Отключение двухфакторной аутентификации",
+			auth:    true,
+			params: []param{
+				{name: "code", in: inForm, typ: typeString, required: true, description: "Код из приложения-аутентификатора или код восстановления"},
+			},
+			handler: h.TwoFactorDisable,
+		},
+		// Роуты профиля пользователя
+		{
+			name:    "Profile",
+			path:    "/profile/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Профиль пользователя",
+			auth:    true,
+			params: []param{
+				{name: "name", in: inForm, typ: typeString, description: "Настоящее имя"},
+				{name: "birth", in: inForm, typ: typeString, format: "date", description: "Дата рождения"},
+				{name: "timezone", in: inForm, typ: typeString, description: "Часовой пояс IANA, пустой для часового пояса по умолчанию"},
+			},
+			handler: h.Profile,
+		},
+		{
+			name:    "ChangeLanguage",
+			path:    "/profile/language",
+			methods: []string{http.MethodPost},
+			summary: "Смена языка интерфейса",
+			auth:    true,
+			params: []param{
+				{name: "language", in: inForm, typ: typeString, description: "Код языка, пустой для выбора по настройкам браузера"},
+			},
+			handler: h.ChangeLanguage,
+		},
+		{
+			name:    "ChangePassword",
+			path:    "/profile/password",
+			methods: []string{http.MethodPost},
+			summary: "Смена пароля",
+			auth:    true,
+			params: []param{
+				{name: "current-password", in: inForm, typ: typeString, required: true, description: "Текущий пароль"},
+				{name: "password", in: inForm, typ: typeString, required: true, description: "Новый пароль"},
+				{name: "confirm-password", in: inForm, typ: typeString, required: true, description: "Новый пароль еще раз"},
+			},
+			handler: h.ChangePassword,
+		},
+		{
+			name:    "DeleteAccount",
+			path:    "/profile/delete",
+			methods: []string{http.MethodPost},
+			summary: "Удаление аккаунта со всеми операциями",
+			auth:    true,
+			params: []param{
+				{name: "confirm", in: inForm, typ: typeString, required: true, description: "Имя пользователя для подтверждения"},
+				{name: "password", in: inForm, typ: typeString, required: true, description: "Текущий пароль"},
+			},
+			handler: h.DeleteAccount,
+		},
+		{
+			name:    "RequestExport",
+			path:    "/profile/export",
+			methods: []string{http.MethodPost},
+			summary: "Запрос выгрузки всех персональных данных",
+			auth:    true,
+			handler: h.RequestExport,
+		},
+		{
+			name:    "DownloadExport",
+			path:    "/profile/export/download",
+			methods: []string{http.MethodGet},
+			summary: "Скачивание архива с персональными данными",
+			auth:    true,
+			params: []param{
+				{name: "token", in: inQuery, typ: typeString, required: true, description: "Подписанный токен ссылки на архив"},
+			},
+			handler:     h.DownloadExport,
+			contentType: contentTypeZIP,
+		},
+		// Роуты устройств пользователя
+		{
+			name:    "Sessions",
+			path:    "/sessions/",
+			methods: []string{http.MethodGet},
+			summary: "Устройства, на которых выполнен вход",
+			auth:    true,
+			handler: h.Sessions,
+		},
+		{
+			name:    "RevokeSession",
+			path:    "/sessions/{id:[0-9]+}/revoke",
+			methods: []string{http.MethodPost},
+			summary: "Завершение сеанса на устройстве",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор сеанса"},
+			},
+			handler: h.RevokeSession,
+		},
+		{
+			name:    "RevokeAllSessions",
+			path:    "/sessions/revoke-all",
+			methods: []string{http.MethodPost},
+			summary: "Выход на всех устройствах",
+			auth:    true,
+			handler: h.RevokeAllSessions,
+		},
+		// Роуты для работы с операциями
+		{
+			name:    "Operations",
+			path:    "/operations/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Список операций",
+			auth:    true,
+			params: []param{
+				{name: "page", in: inQuery, typ: typeInteger, description: "Номер страницы"},
+			},
+			handler: h.Operations,
+		},
+		{
+			name:    "CreateOperation",
+			path:    "/operations/create/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Создание операции",
+			auth:    true,
+			params: []param{
+				{name: "subject", in: inForm, typ: typeString, required: true, description: "Статья расхода"},
+				{name: "amount", in: inForm, typ: typeNumber, required: true, description: "Сумма"},
+				{name: "type", in: inForm, typ: typeInteger, required: true, description: "Тип операции: 1 - пополнение, 2 - списание"},
+				{name: "message", in: inForm, typ: typeString, description: "Сообщение"},
+			},
+			handler: h.Create,
+		},
+		{
+			name:    "DeleteOperation",
+			path:    "/operations/delete/{id:[0-9]+}",
+			methods: []string{http.MethodPost},
+			summary: "Удаление операции из текущей бухгалтерии",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор операции"},
+			},
+			handler: h.Delete,
+		},
+		// Роуты для работы с токенами доступа к API
+		{
+			name:    "Tokens",
+			path:    "/tokens/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Токены доступа к gRPC API",
+			auth:    true,
+			params: []param{
+				{name: "name", in: inForm, typ: typeString, required: true, description: "Название токена"},
+			},
+			handler: h.Tokens,
+		},
+		{
+			name:    "DeleteToken",
+			path:    "/tokens/delete/{id:[0-9]+}",
+			methods: []string{http.MethodPost},
+			summary: "Отзыв токена доступа",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор токена"},
+			},
+			handler: h.DeleteToken,
+		},
+		// Роуты для работы с подписками на события
+		{
+			name:    "Webhooks",
+			path:    "/webhooks/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Подписки на события операций",
+			auth:    true,
+			params: []param{
+				{name: "url", in: inForm, typ: typeString, format: "uri", required: true, description: "Адрес получателя событий"},
+				{name: "events", in: inForm, typ: typeString, required: true, description: "События: operation.created, operation.deleted"},
+			},
+			handler: h.Webhooks,
+		},
+		{
+			name:    "DeleteWebhook",
+			path:    "/webhooks/delete/{id:[0-9]+}",
+			methods: []string{http.MethodPost},
+			summary: "Удаление подписки на события",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор подписки"},
+			},
+			handler: h.DeleteWebhook,
+		},
+		{
+			name:    "Deliveries",
+			path:    "/webhooks/{id:[0-9]+}/deliveries/",
+			methods: []string{http.MethodGet},
+			summary: "Журнал доставок по подписке",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор подписки"},
+			},
+			handler: h.Deliveries,
+		},
+		// Роуты для работы с бухгалтериями
+		{
+			name:    "Ledgers",
+			path:    "/ledgers/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Бухгалтерии пользователя",
+			auth:    true,
+			params: []param{
+				{name: "name", in: inForm, typ: typeString, required: true, description: "Название бухгалтерии"},
+			},
+			handler: h.Ledgers,
+		},
+		{
+			name:    "SwitchLedger",
+			path:    "/ledgers/switch/",
+			methods: []string{http.MethodPost},
+			summary: "Переключение текущей бухгалтерии",
+			auth:    true,
+			params: []param{
+				{name: "ledger_id", in: inForm, typ: typeInteger, required: true, description: "Идентификатор бухгалтерии"},
+			},
+			handler: h.SwitchLedger,
+		},
+		{
+			name:    "LedgerMembers",
+			path:    "/ledgers/{id:[0-9]+}/members/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Участники бухгалтерии",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор бухгалтерии"},
+				{name: "login", in: inForm, typ: typeString, required: true, description: "Логин нового участника"},
+				{name: "role", in: inForm, typ: typeString, required: true, description: "Роль: editor, viewer"},
+			},
+			handler: h.LedgerMembers,
+		},
+		{
+			name:    "DeleteLedgerMember",
+			path:    "/ledgers/{id:[0-9]+}/members/delete/{userID:[0-9]+}",
+			methods: []string{http.MethodPost},
+			summary: "Исключение участника из бухгалтерии",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор бухгалтерии"},
+				{name: "userID", in: inPath, typ: typeInteger, required: true, description: "Идентификатор участника"},
+			},
+			handler: h.DeleteLedgerMember,
+		},
+		// Роуты для приглашений на регистрацию
+		{
+			name:    "Invites",
+			path:    "/invites/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Приглашения на регистрацию, POST выпускает новое приглашение",
+			auth:    true,
+			handler: h.Invites,
+		},
+		{
+			name:    "RevokeInvite",
+			path:    "/invites/revoke/{id:[0-9]+}",
+			methods: []string{http.MethodPost},
+			summary: "Отзыв приглашения на регистрацию",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор приглашения"},
+			},
+			handler: h.RevokeInvite,
+		},
+		// Роуты панели администратора
+		{
+			name:    "Admin",
+			path:    "/admin/",
+			methods: []string{http.MethodGet},
+			summary: "Список пользователей, только для администраторов",
+			auth:    true,
+			params: []param{
+				{name: "page", in: inQuery, typ: typeInteger, description: "Номер страницы"},
+			},
+			handler: h.Admin,
+		},
+		{
+			name:    "AdminDisableUser",
+			path:    "/admin/users/{id:[0-9]+}/disable",
+			methods: []string{http.MethodPost},
+			summary: "Блокировка аккаунта пользователя, только для администраторов",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор пользователя"},
+			},
+			handler: h.AdminDisableUser,
+		},
+		{
+			name:    "AdminEnableUser",
+			path:    "/admin/users/{id:[0-9]+}/enable",
+			methods: []string{http.MethodPost},
+			summary: "Разблокировка аккаунта пользователя, только для администраторов",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор пользователя"},
+			},
+			handler: h.AdminEnableUser,
+		},
+		{
+			name:    "AdminDeleteUser",
+			path:    "/admin/users/{id:[0-9]+}/delete",
+			methods: []string{http.MethodPost},
+			summary: "Удаление аккаунта пользователя со всеми данными, только для администраторов",
+			auth:    true,
+			params: []param{
+				{name: "id", in: inPath, typ: typeInteger, required: true, description: "Идентификатор пользователя"},
+			},
+			handler: h.AdminDeleteUser,
+		},
+		{
+			name:    "AdminLog",
+			path:    "/admin/log/",
+			methods: []string{http.MethodGet},
+			summary: "Журнал действий администраторов, только для администраторов",
+			auth:    true,
+			params: []param{
+				{name: "page", in: inQuery, typ: typeInteger, description: "Номер страницы"},
+			},
+			handler: h.AdminLog,
+		},
+		// Роуты для журнала аудита
+		{
+			name:    "Audit",
+			path:    "/audit/",
+			methods: []string{http.MethodGet},
+			summary: "История действий пользователя",
+			auth:    true,
+			params: []param{
+				{name: "page", in: inQuery, typ: typeInteger, description: "Номер страницы"},
+			},
+			handler: h.Audit,
+		},
+		// Роуты для обработки ошибок
+		{
+			name:    "Error",
+			path:    "/error/",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Страница ошибки",
+			handler: h.Error,
+		},
+		{
+			name:    "ErrorUnauthorized",
+			path:    "/error/unauthorized",
+			methods: []string{http.MethodGet, http.MethodPost},
+			summary: "Страница ошибки для неавторизованного пользователя",
+			handler: h.ErrorUnauthorized,
+		},
+	}
+}
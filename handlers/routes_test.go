@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RoutesDescribed(t *testing.T) {
+	handler := New(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	described := map[string]bool{}
+	for _, rt := range handler.routes() {
+		for _, method := range rt.methods {
+			described[method+" "+rt.path] = true
+		}
+	}
+
+	err := handler.Router().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		methods, err := route.GetMethods()
+		if !assert.NoErrorf(t, err, "route %s has no methods", path) {
+			return nil
+		}
+
+		for _, method := range methods {
+			assert.Truef(t, described[method+" "+path], "route %s %s is not described in routes catalog", method, path)
+		}
+
+		return nil
+	})
+
+	require.NoError(t, err)
+}
+
+func Test_OpenAPI(t *testing.T) {
+	handler := New(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	handler.Router().ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contentTypeJSON, w.Header().Get("Content-Type"))
+
+	var doc openAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+
+	assert.Equal(t, openAPIVersion, doc.OpenAPI)
+	for _, rt := range handler.routes() {
+		path, _ := openAPIPath(rt)
+		assert.Containsf(t, doc.Paths, path, "route %s is not documented", rt.path)
+	}
+
+	deleteOperation := doc.Paths["/operations/delete/{id}"]["post"]
+	require.Len(t, deleteOperation.Parameters, 1)
+	assert.Equal(t, "id", deleteOperation.Parameters[0].Name)
+	assert.Equal(t, inPath, deleteOperation.Parameters[0].In)
+	assert.True(t, deleteOperation.Parameters[0].Required)
+
+	createOperation := doc.Paths["/operations/create/"]["post"]
+	assert.NotEmpty(t, createOperation.Security)
+	require.NotNil(t, createOperation.RequestBody)
+	form := createOperation.RequestBody.Content["application/x-www-form-urlencoded"].Schema
+	assert.Contains(t, form.Properties, "amount")
+	assert.ElementsMatch(t, []string{"subject", "amount", "type"}, form.Required)
+
+	assert.Nil(t, doc.Paths["/operations/create/"]["get"].RequestBody)
+	assert.Contains(t, doc.Paths, "/static/{path}")
+}
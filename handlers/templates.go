@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/i18n"
+	"github.com/bgoldovsky/casher/templates"
+)
+
+// Общие шаблоны, которые подключаются к каждой странице
+var templateLayouts = []string{"header.html", "header_unauthorized.html", "footer.html"}
+
+// Функции, доступные во всех шаблонах
+// Функции, зависящие от запроса, здесь возвращают пустые значения и подменяются при рендеринге страницы
+var templateFuncs = template.FuncMap{
+	"ledgers":         func() *ledgerSwitcher { return nil },
+	"invites":         func() bool { return false },
+	"admin":           func() bool { return false },
+	"unverifiedEmail": func() string { return "" },
+	"csrfField":       func() template.HTML { return "" },
+	"languages":       func() []languageOption { return nil },
+	// Функции перевода на язык по умолчанию
+	"t":    defaultLocaleFuncs["t"],
+	"tn":   defaultLocaleFuncs["tn"],
+	"lang": defaultLocaleFuncs["lang"],
+	// Функции форматирования дат и сумм
+	"datetime": defaultLocaleFuncs["datetime"],
+	"amount":   defaultLocaleFuncs["amount"],
+	// Функции пагинации
+	"inc": func(i int64) int64 {
+		return i + 1
+	},
+	"dec": func(i int64) int64 {
+		return i - 1
+	},
+}
+
+// Функции перевода для страниц, которым не передаются функции запроса
+var defaultLocaleFuncs = localeFuncs(func() *i18n.Localizer {
+	return i18n.New(i18n.Default)
+})
+
+// NewTemplates Разбирает и проверяет шаблоны всех страниц
+// В режиме reload шаблоны заново читаются из fsys, если файлы изменились, что удобно при разработке
+func NewTemplates(fsys fs.FS, reload bool) (*templates.Registry, error) {
+	return templates.New(fsys, templateFuncs, reload, templateLayouts...)
+}
+
+// Возвращает функции шаблона для перевода интерфейса, переводчик запрашивается один раз при первом переводе
+// t переводит сообщение, tn переводит сообщение во множественном числе для количества любого целого типа,
+// lang возвращает код языка страницы, datetime показывает время в часовом поясе пользователя,
+// amount форматирует сумму с разделителями разрядов языка
+func localeFuncs(localizer func() *i18n.Localizer) template.FuncMap {
+	var (
+		once sync.Once
+		l    *i18n.Localizer
+	)
+	get := func() *i18n.Localizer {
+		once.Do(func() {
+			l = localizer()
+		})
+		return l
+	}
+
+	return template.FuncMap{
+		"t": func(key string, args ...interface{}) string {
+			return get().T(key, args...)
+		},
+		"tn": func(key string, count interface{}, args ...interface{}) (string, error) {
+			n, err := toInt64(count)
+			if err != nil {
+				return "", err
+			}
+			return get().N(key, n, args...), nil
+		},
+		"lang": func() string {
+			return get().Lang()
+		},
+		"datetime": func(t time.Time) string {
+			return get().DateTime(t)
+		},
+		"amount": func(amount float64) string {
+			return get().Amount(amount)
+		},
+	}
+}
+
+// Приводит целое число любого типа к int64, например возраст пользователя типа uint16
+func toInt64(value interface{}) (int64, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	}
+
+	return 0, fmt.Errorf("unsupported count type %T", value)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/i18n"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/services/twofactor"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TwoFactorConfirmTooManyTries(t *testing.T) {
+	handler, _ := newEscapingTestHandler(t)
+	cookie := newTestSession(t, handler, strangerID, "token")
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// Подключение ожидает подтверждения, а попытки подтверждения исчерпаны
+	failed := time.Now()
+	repo := twofactor.NewMockrepository(ctrl)
+	repo.EXPECT().Get(strangerID).Return(&models.TwoFactor{UserID: strangerID, Secret: "JBSWY3DPEHPK3PXP", FailedAttempts: 5, FailedAt: &failed}, nil).AnyTimes()
+	handler.twofactorSrv = twofactor.New(repo)
+
+	w := serve(handler, http.MethodPost, "/2fa/confirm", url.Values{"code": {"123456"}}, cookie, "token")
+
+	// Форма показывается снова с сообщением о блокировке, а не страница ошибки
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, html.UnescapeString(w.Body.String()), i18n.New(i18n.Default).T("twofactor.error.attempts"))
+}
@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bgoldovsky/casher/app/i18n"
 	"github.com/bgoldovsky/casher/app/models"
+	sessionstore "github.com/bgoldovsky/casher/app/services/sessions"
 )
 
 /*
@@ -18,6 +24,8 @@ type user struct {
 	Name    string
 	Age     uint16
 	Balance float64
+	// Сообщение о неудачных попытках входа с прошлого визита
+	Notice string
 }
 
 // Конвертирует модель пользователя во view model
@@ -35,6 +43,42 @@ func userToView(model *models.User) *user {
 	}
 }
 
+type languageOption struct {
+	// Пустой код означает выбор языка по настройкам браузера
+	Code     string
+	Name     string
+	Selected bool
+}
+
+// Формирует список языков интерфейса для переключателя, preferred - язык из настроек пользователя
+func languagesToView(l *i18n.Localizer, preferred string) []languageOption {
+	res := []languageOption{{Name: l.T("language.auto"), Selected: preferred == ""}}
+	for _, lang := range i18n.Languages() {
+		res = append(res, languageOption{Code: lang.Code, Name: lang.Name, Selected: lang.Code == preferred})
+	}
+
+	return res
+}
+
+// Формирует сообщение владельцу аккаунта о неудачных попытках входа с прошлого визита
+func loginNotice(l *i18n.Localizer, failures *models.LoginAttempts) string {
+	notice := l.T("notice.failures", failures.TotalFailures)
+	if failures.Locked != nil {
+		notice += " " + l.T("notice.locked", l.DateTime(*failures.Locked))
+	}
+
+	return notice + " " + l.T("notice.hint")
+}
+
+// Формирует время ожидания до следующей попытки входа, округляя его вверх до секунд или минут
+func waitText(l *i18n.Localizer, wait time.Duration) string {
+	if wait <= time.Minute {
+		return l.N("wait.seconds", int64(math.Ceil(wait.Seconds())))
+	}
+
+	return l.N("wait.minutes", int64(math.Ceil(wait.Minutes())))
+}
+
 // Рассчитывает возраст по дате рождения и текущей дате
 func getAge(birthdate, today time.Time) uint16 {
 	today = today.In(birthdate.Location())
@@ -69,11 +113,12 @@ type pagingOperations struct {
 	Page       int64
 	HasPrev    bool
 	HasNext    bool
+	CanEdit    bool
 	Operations []operation
 }
 
 // Конвертирует модель операции во view model
-func operationToView(model *models.Operation) *operation {
+func operationToView(l *i18n.Localizer, model *models.Operation) *operation {
 	if model == nil {
 		return nil
 	}
@@ -83,29 +128,29 @@ func operationToView(model *models.Operation) *operation {
 		UserID:  model.UserID,
 		Subject: model.Subject,
 		Amount:  float64(model.Amount) / 100,
-		Type:    getOperationType(model.Type),
+		Type:    getOperationType(l, model.Type),
 		Message: model.Message,
 		Created: model.Created,
 	}
 }
 
-// Конвертирует тип операции в строку
-func getOperationType(model models.OperationType) string {
+// Конвертирует тип операции в строку на языке l
+func getOperationType(l *i18n.Localizer, model models.OperationType) string {
 	if model == models.Deposit {
-		return "Пополнение"
+		return l.T("operation.deposit")
 	} else if model == models.Withdraw {
-		return "Списание"
+		return l.T("operation.withdraw")
 	}
 
 	return ""
 }
 
 // Конвертирует массив моделей операций во view model
-func operationsToView(models []models.Operation) []operation {
+func operationsToView(l *i18n.Localizer, models []models.Operation) []operation {
 	res := make([]operation, len(models))
 
 	for idx, val := range models {
-		view := operationToView(&val)
+		view := operationToView(l, &val)
 		res[idx] = *view
 	}
 
@@ -113,11 +158,11 @@ func operationsToView(models []models.Operation) []operation {
 }
 
 // Конвертирует модель-обертку для операций во view model
-func toPagingView(page int64, paginator *models.OperationPaginator) pagingOperations {
+func toPagingView(l *i18n.Localizer, page int64, paginator *models.OperationPaginator) pagingOperations {
 	paging := pagingOperations{
 		Page:       page,
 		HasNext:    paginator.HasMore,
-		Operations: operationsToView(paginator.Operations),
+		Operations: operationsToView(l, paginator.Operations),
 	}
 
 	if page <= 1 {
@@ -128,3 +173,469 @@ func toPagingView(page int64, paginator *models.OperationPaginator) pagingOperat
 
 	return paging
 }
+
+type token struct {
+	ID      int64
+	Name    string
+	Created time.Time
+}
+
+type tokensPage struct {
+	Form   tokenForm
+	Tokens []token
+}
+
+// Конвертирует массив моделей токенов во view model
+func tokensToView(models []models.Token) []token {
+	res := make([]token, len(models))
+
+	for idx, val := range models {
+		res[idx] = token{
+			ID:      val.ID,
+			Name:    val.Name,
+			Created: val.Created,
+		}
+	}
+
+	return res
+}
+
+type webhook struct {
+	ID      int64
+	URL     string
+	Events  []string
+	Secret  string
+	Created time.Time
+}
+
+type eventOption struct {
+	Name    string
+	Checked bool
+}
+
+type webhooksPage struct {
+	Form     webhookForm
+	Events   []eventOption
+	Webhooks []webhook
+}
+
+// Конвертирует форму и подписки пользователя во view model страницы
+func toWebhooksPage(form webhookForm, list []models.Webhook) webhooksPage {
+	page := webhooksPage{
+		Form:     form,
+		Webhooks: make([]webhook, len(list)),
+	}
+
+	checked := map[string]bool{}
+	for _, event := range form.Events {
+		checked[event] = true
+	}
+
+	for _, event := range models.Events {
+		page.Events = append(page.Events, eventOption{Name: event, Checked: checked[event]})
+	}
+
+	for idx, val := range list {
+		page.Webhooks[idx] = webhook{
+			ID:      val.ID,
+			URL:     val.URL,
+			Events:  val.Events,
+			Secret:  val.Secret,
+			Created: val.Created,
+		}
+	}
+
+	return page
+}
+
+type delivery struct {
+	ID           int64
+	Event        string
+	Payload      string
+	Status       string
+	Attempts     int
+	ResponseCode int
+	LastError    string
+	Created      time.Time
+	NextAttempt  time.Time
+	Delivered    *time.Time
+}
+
+// Конвертирует массив моделей доставок во view model
+func deliveriesToView(models []models.Delivery) []delivery {
+	res := make([]delivery, len(models))
+
+	for idx, val := range models {
+		res[idx] = delivery{
+			ID:           val.ID,
+			Event:        val.Event,
+			Payload:      string(val.Payload),
+			Status:       string(val.Status),
+			Attempts:     val.Attempts,
+			ResponseCode: val.ResponseCode,
+			LastError:    val.LastError,
+			Created:      val.Created,
+			NextAttempt:  val.NextAttempt,
+			Delivered:    val.Delivered,
+		}
+	}
+
+	return res
+}
+
+type auditEntry struct {
+	Actor     string
+	Action    string
+	Target    string
+	Before    string
+	After     string
+	IP        string
+	UserAgent string
+	Created   time.Time
+}
+
+type pagingAudit struct {
+	Page    int64
+	HasPrev bool
+	HasNext bool
+	Entries []auditEntry
+}
+
+// Действия пользователя, для которых в каталоге есть название, ключ сообщения audit.action.<действие>
+var auditActions = map[string]bool{
+	models.AuditLogin:           true,
+	models.AuditLoginFailed:     true,
+	models.AuditLogout:          true,
+	models.AuditRegistration:    true,
+	models.AuditOperationCreate: true,
+	models.AuditOperationDelete: true,
+	models.AuditTokenCreate:     true,
+	models.AuditTokenDelete:     true,
+	models.AuditWebhookCreate:   true,
+	models.AuditWebhookDelete:   true,
+	models.AuditLedgerCreate:    true,
+	models.AuditMemberAdd:       true,
+	models.AuditMemberRemove:    true,
+	models.AuditInviteCreate:    true,
+	models.AuditInviteRevoke:    true,
+	models.AuditPasswordReset:   true,
+	models.AuditEmailVerify:     true,
+	models.AuditTwoFactorEnable: true,
+	models.AuditTwoFactorCodes:  true,
+	models.AuditTwoFactorOff:    true,
+	models.AuditSSOLink:         true,
+	models.AuditSessionRevoke:   true,
+	models.AuditSessionsRevoke:  true,
+	models.AuditProfileUpdate:   true,
+	models.AuditPasswordChange:  true,
+	models.AuditAccountDelete:   true,
+	models.AuditDataExport:      true,
+	models.AuditAdminDisable:    true,
+	models.AuditAdminEnable:     true,
+	models.AuditAdminDelete:     true,
+}
+
+// Конвертирует модель-обертку для журнала аудита во view model, названия действий переводятся на язык l
+func toPagingAudit(l *i18n.Localizer, page int64, paginator *models.AuditPaginator) pagingAudit {
+	paging := pagingAudit{
+		Page:    page,
+		HasPrev: page > 1,
+		HasNext: paginator.HasMore,
+		Entries: make([]auditEntry, len(paginator.Entries)),
+	}
+
+	for idx, val := range paginator.Entries {
+		action := val.Action
+		if auditActions[val.Action] {
+			action = l.T("audit.action." + val.Action)
+		}
+
+		target := val.TargetType
+		if val.TargetID != 0 {
+			target = fmt.Sprintf("%s #%d", val.TargetType, val.TargetID)
+		}
+
+		var actor string
+		if val.ActorID != 0 {
+			actor = fmt.Sprintf("user #%d", val.ActorID)
+		}
+
+		paging.Entries[idx] = auditEntry{
+			Actor:     actor,
+			Action:    action,
+			Target:    target,
+			Before:    string(val.Before),
+			After:     string(val.After),
+			IP:        val.IP,
+			UserAgent: val.UserAgent,
+			Created:   val.Created,
+		}
+	}
+
+	return paging
+}
+
+type ledger struct {
+	ID      int64
+	Name    string
+	Role    string
+	Current bool
+}
+
+type ledgerSwitcher struct {
+	Ledgers []ledger
+}
+
+type ledgersPage struct {
+	Form    ledgerForm
+	Ledgers []ledger
+}
+
+type member struct {
+	UserID    int64
+	Login     string
+	Name      string
+	Role      string
+	Removable bool
+}
+
+type membersPage struct {
+	Ledger    ledger
+	CanManage bool
+	Form      memberForm
+	Members   []member
+}
+
+// Конвертирует роль участника бухгалтерии в название на языке l
+func ledgerRole(l *i18n.Localizer, role models.LedgerRole) string {
+	if role == "" {
+		return ""
+	}
+
+	return l.T("ledger.role." + string(role))
+}
+
+// Конвертирует модель бухгалтерии во view model
+func ledgerToView(l *i18n.Localizer, model *models.Ledger, currentID int64) ledger {
+	return ledger{
+		ID:      model.ID,
+		Name:    model.Name,
+		Role:    ledgerRole(l, model.Role),
+		Current: model.ID == currentID,
+	}
+}
+
+// Конвертирует массив моделей бухгалтерий во view model
+func ledgersToView(l *i18n.Localizer, list []models.Ledger, currentID int64) []ledger {
+	res := make([]ledger, len(list))
+
+	for idx, val := range list {
+		res[idx] = ledgerToView(l, &val, currentID)
+	}
+
+	return res
+}
+
+// Конвертирует бухгалтерию и ее участников во view model страницы
+// Исключать участников может только владелец, сами владельцы не исключаются
+func toMembersPage(l *i18n.Localizer, model *models.Ledger, form memberForm, list []models.LedgerMember) membersPage {
+	page := membersPage{
+		Ledger:    ledgerToView(l, model, 0),
+		CanManage: model.Role.CanManage(),
+		Form:      form,
+		Members:   make([]member, len(list)),
+	}
+
+	for idx, val := range list {
+		page.Members[idx] = member{
+			UserID:    val.UserID,
+			Login:     val.Login,
+			Name:      val.Name,
+			Role:      ledgerRole(l, val.Role),
+			Removable: page.CanManage && val.Role != models.RoleOwner,
+		}
+	}
+
+	return page
+}
+
+type invite struct {
+	ID      int64
+	Status  string
+	Active  bool
+	Expires time.Time
+	Created time.Time
+}
+
+type invitesPage struct {
+	Disabled bool
+	Link     string
+	Invites  []invite
+}
+
+// Конвертирует массив моделей приглашений во view model
+// Статус приглашения рассчитывается на текущий момент и переводится на язык l
+func invitesToView(l *i18n.Localizer, list []models.Invite, now time.Time) []invite {
+	res := make([]invite, len(list))
+
+	for idx, val := range list {
+		view := invite{
+			ID:      val.ID,
+			Active:  val.Active(now),
+			Expires: val.Expires,
+			Created: val.Created,
+		}
+
+		switch {
+		case val.Used != nil:
+			view.Status = l.T("invites.status.used")
+		case val.Revoked != nil:
+			view.Status = l.T("invites.status.revoked")
+		case !view.Active:
+			view.Status = l.T("invites.status.expired")
+		default:
+			view.Status = l.T("invites.status.active")
+		}
+
+		res[idx] = view
+	}
+
+	return res
+}
+
+type adminUser struct {
+	ID         int64
+	Login      string
+	Name       string
+	Admin      bool
+	Disabled   bool
+	Self       bool
+	Operations int64
+	LastLogin  *time.Time
+	Created    time.Time
+}
+
+type adminPage struct {
+	Page    int64
+	HasPrev bool
+	HasNext bool
+	Users   []adminUser
+}
+
+// Конвертирует модель-обертку для списка пользователей во view model панели администратора
+// Действия над своим аккаунтом администратору не показываются
+func toAdminPage(page int64, paginator *models.UserPaginator, adminID int64) adminPage {
+	res := adminPage{
+		Page:    page,
+		HasPrev: page > 1,
+		HasNext: paginator.HasMore,
+		Users:   make([]adminUser, len(paginator.Users)),
+	}
+
+	for idx, val := range paginator.Users {
+		res.Users[idx] = adminUser{
+			ID:         val.ID,
+			Login:      val.Login,
+			Name:       val.Name,
+			Admin:      val.IsAdmin(),
+			Disabled:   val.Disabled != nil,
+			Self:       val.ID == adminID,
+			Operations: val.Operations,
+			LastLogin:  val.LastLogin,
+			Created:    val.Created,
+		}
+	}
+
+	return res
+}
+
+type verifyEmailPage struct {
+	Verified bool
+}
+
+type deviceView struct {
+	ID        int64
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	Current   bool
+}
+
+type sessionsPage struct {
+	Devices []deviceView
+}
+
+// Конвертирует сессии пользователя во view model страницы устройств
+func devicesToView(devices []sessionstore.Device) sessionsPage {
+	page := sessionsPage{Devices: make([]deviceView, len(devices))}
+
+	for idx, val := range devices {
+		page.Devices[idx] = deviceView{
+			ID:        val.ID,
+			IP:        val.IP,
+			UserAgent: val.UserAgent,
+			Created:   val.Created,
+			LastSeen:  val.LastSeen,
+			Current:   val.Current,
+		}
+	}
+
+	return page
+}
+
+type profilePage struct {
+	Login    string
+	Profile  profileForm
+	Password passwordForm
+	Delete   deleteAccountForm
+	// Последняя выгрузка персональных данных, nil если ее нет
+	Export *exportView
+	// Сообщение об успешно сохраненных изменениях
+	Saved string
+}
+
+type exportView struct {
+	Pending bool
+	Link    string
+	Size    string
+	Expires time.Time
+}
+
+// Конвертирует выгрузку персональных данных во view model
+// Ссылка на скачивание формируется только для собранного архива
+func exportToView(l *i18n.Localizer, export *models.Export, link func(*models.Export) string) *exportView {
+	if export == nil {
+		return nil
+	}
+
+	if export.Status != models.ExportReady || export.Expires == nil {
+		return &exportView{Pending: true}
+	}
+
+	return &exportView{
+		Link:    link(export),
+		Size:    sizeText(l, export.Size),
+		Expires: *export.Expires,
+	}
+}
+
+// Форматирует размер файла в килобайтах или мегабайтах с десятичным разделителем языка l
+func sizeText(l *i18n.Localizer, size int64) string {
+	key, value := "size.kb", float64(size)/1024
+	if size >= 1024*1024 {
+		key, value = "size.mb", value/1024
+	}
+
+	return l.T(key, strings.Replace(strconv.FormatFloat(value, 'f', 1, 64), ".", l.T("format.decimal"), 1))
+}
+
+type twoFactorPage struct {
+	Enabled bool
+	// Секрет, ожидающий подтверждения, для ручного ввода в приложение
+	Secret    string
+	CodesLeft int64
+	// Новые коды восстановления показываются только один раз
+	Codes []string
+	Error string
+}
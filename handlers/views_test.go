@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/bgoldovsky/casher/app/i18n"
 	"github.com/bgoldovsky/casher/app/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -35,7 +36,7 @@ func Test_OperationToView(t *testing.T) {
 		Created: time.Now(),
 	}
 
-	act := operationToView(&model)
+	act := operationToView(nil, &model)
 
 	assert.Equal(t, model.ID, act.ID)
 	assert.Equal(t, model.UserID, act.UserID)
@@ -44,4 +45,154 @@ func Test_OperationToView(t *testing.T) {
 	assert.Equal(t, "Пополнение", act.Type)
 	assert.Equal(t, model.Message, act.Message)
 	assert.Equal(t, model.Created, act.Created)
+
+	act = operationToView(i18n.New("en"), &models.Operation{Type: models.Withdraw})
+	assert.Equal(t, "Withdrawal", act.Type)
+}
+
+func Test_LanguagesToView(t *testing.T) {
+	act := languagesToView(i18n.New("en"), "")
+
+	assert.Equal(t, []languageOption{
+		{Code: "", Name: "Browser default", Selected: true},
+		{Code: "ru", Name: "Русский"},
+		{Code: "en", Name: "English"},
+	}, act)
+
+	act = languagesToView(nil, "en")
+	assert.False(t, act[0].Selected)
+	assert.True(t, act[2].Selected)
+}
+
+func Test_ToPagingAudit(t *testing.T) {
+	paginator := &models.AuditPaginator{
+		Entries: []models.AuditEntry{
+			{Action: models.AuditOperationDelete, TargetType: models.AuditTargetOperation, TargetID: 10, Before: []byte(`{"id":10}`)},
+			{Action: models.AuditTokenCreate, TargetType: models.AuditTargetToken},
+		},
+		HasMore: true,
+	}
+
+	act := toPagingAudit(nil, 2, paginator)
+
+	assert.True(t, act.HasPrev)
+	assert.True(t, act.HasNext)
+	assert.Equal(t, "Удаление операции", act.Entries[0].Action)
+	assert.Equal(t, "operation #10", act.Entries[0].Target)
+	assert.Equal(t, `{"id":10}`, act.Entries[0].Before)
+	assert.Equal(t, "token", act.Entries[1].Target)
+
+	act = toPagingAudit(i18n.New("en"), 2, paginator)
+	assert.Equal(t, "Operation deleted", act.Entries[0].Action)
+}
+
+func Test_ToMembersPage(t *testing.T) {
+	ledger := models.Ledger{ID: 1, Name: "Семейный бюджет", Role: models.RoleOwner}
+	members := []models.LedgerMember{
+		{LedgerID: 1, UserID: 10, Login: "jondoe", Role: models.RoleOwner},
+		{LedgerID: 1, UserID: 20, Login: "janedoe", Role: models.RoleViewer},
+	}
+
+	act := toMembersPage(nil, &ledger, memberForm{}, members)
+
+	assert.True(t, act.CanManage)
+	assert.False(t, act.Members[0].Removable)
+	assert.True(t, act.Members[1].Removable)
+	assert.Equal(t, "Наблюдатель", act.Members[1].Role)
+
+	// Участник без права управления никого не исключает
+	ledger.Role = models.RoleEditor
+	act = toMembersPage(i18n.New("en"), &ledger, memberForm{}, members)
+
+	assert.False(t, act.CanManage)
+	assert.False(t, act.Members[1].Removable)
+	assert.Equal(t, "Viewer", act.Members[1].Role)
+}
+
+func Test_InvitesToView(t *testing.T) {
+	now := time.Now()
+	used := now.Add(-time.Minute)
+	list := []models.Invite{
+		{ID: 1, Expires: now.Add(time.Hour)},
+		{ID: 2, Expires: now.Add(time.Hour), Used: &used},
+		{ID: 3, Expires: now.Add(time.Hour), Revoked: &used},
+		{ID: 4, Expires: now.Add(-time.Hour)},
+	}
+
+	act := invitesToView(nil, list, now)
+
+	assert.True(t, act[0].Active)
+	assert.Equal(t, "Действует", act[0].Status)
+	assert.Equal(t, "Использовано", act[1].Status)
+	assert.Equal(t, "Отозвано", act[2].Status)
+	assert.Equal(t, "Истекло", act[3].Status)
+	for _, val := range act[1:] {
+		assert.False(t, val.Active)
+	}
+
+	act = invitesToView(i18n.New("en"), list, now)
+	assert.Equal(t, "Active", act[0].Status)
+	assert.Equal(t, "Expired", act[3].Status)
+}
+
+func Test_ToAdminPage(t *testing.T) {
+	disabled := time.Now()
+	paginator := &models.UserPaginator{
+		Users: []models.User{
+			{ID: 1, Login: "admin", Role: models.UserRoleAdmin},
+			{ID: 2, Login: "jondoe", Role: models.UserRoleUser, Disabled: &disabled, Operations: 5},
+		},
+		HasMore: true,
+	}
+
+	act := toAdminPage(1, paginator, 1)
+
+	assert.False(t, act.HasPrev)
+	assert.True(t, act.HasNext)
+	assert.True(t, act.Users[0].Admin)
+	assert.True(t, act.Users[0].Self)
+	assert.False(t, act.Users[1].Self)
+	assert.True(t, act.Users[1].Disabled)
+	assert.Equal(t, int64(5), act.Users[1].Operations)
+}
+
+func Test_LoginNotice(t *testing.T) {
+	locked := time.Date(2021, 9, 1, 12, 30, 0, 0, time.UTC)
+
+	act := loginNotice(nil, &models.LoginAttempts{TotalFailures: 3})
+	assert.Equal(t, "Неудачных попыток входа в аккаунт с прошлого визита: 3. Если это были не вы, смените пароль.", act)
+
+	act = loginNotice(nil, &models.LoginAttempts{TotalFailures: 12, Locked: &locked})
+	assert.Contains(t, act, "Вход временно блокировался 01.09.2021 12:30:00.")
+
+	act = loginNotice(i18n.New("en"), &models.LoginAttempts{TotalFailures: 3})
+	assert.Equal(t, "Failed sign-in attempts since your last visit: 3. If it wasn't you, change your password.", act)
+}
+
+func Test_WaitText(t *testing.T) {
+	assert.Equal(t, "2 сек.", waitText(nil, 1500*time.Millisecond))
+	assert.Equal(t, "60 сек.", waitText(nil, time.Minute))
+	assert.Equal(t, "15 мин.", waitText(nil, 14*time.Minute+time.Second))
+	assert.Equal(t, "1 second", waitText(i18n.New("en"), time.Second))
+	assert.Equal(t, "15 minutes", waitText(i18n.New("en"), 14*time.Minute+time.Second))
+}
+
+func Test_ExportToView(t *testing.T) {
+	link := func(e *models.Export) string { return "/profile/export/download?token=abc" }
+	expires := time.Date(2021, 9, 2, 12, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, exportToView(nil, nil, link))
+
+	act := exportToView(nil, &models.Export{ID: 1, Status: models.ExportPending}, link)
+	assert.True(t, act.Pending)
+	assert.Empty(t, act.Link)
+
+	act = exportToView(nil, &models.Export{ID: 1, Status: models.ExportReady, Size: 2560, Expires: &expires}, link)
+	assert.False(t, act.Pending)
+	assert.Equal(t, "/profile/export/download?token=abc", act.Link)
+	assert.Equal(t, "2,5 КБ", act.Size)
+	assert.Equal(t, expires, act.Expires)
+
+	act = exportToView(i18n.New("en"), &models.Export{ID: 1, Status: models.ExportReady, Size: 3 * 1024 * 1024, Expires: &expires}, link)
+	assert.Equal(t, "3.0 MB", act.Size)
 }
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseProxies Разбирает адреса доверенных прокси: подсети CIDR или отдельные IP адреса
+func ParseProxies(list []string) ([]*net.IPNet, error) {
+	res := make([]*net.IPNet, 0, len(list))
+	for _, val := range list {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+
+		if !strings.Contains(val, "/") {
+			ip := net.ParseIP(val)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %q", val)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			res = append(res, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy network %q: %w", val, err)
+		}
+		res = append(res, network)
+	}
+
+	return res, nil
+}
+
+// RealIP Подставляет в r.RemoteAddr адрес клиента из заголовка X-Forwarded-For, если запрос пришел от доверенного прокси
+// Заголовок читается справа налево, пока адреса принадлежат доверенным прокси: левее может быть что угодно,
+// так как клиент сам присылает X-Forwarded-For, а прокси только дописывают адрес в конец
+// Без доверенных прокси заголовок игнорируется и адресом клиента считается адрес соединения
+func RealIP(next http.Handler, trusted []*net.IPNet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := forwardedFor(r, trusted); ip != "" {
+			r.RemoteAddr = ip
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Возвращает адрес клиента из X-Forwarded-For или пустую строку, если заголовку нельзя доверять
+func forwardedFor(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(net.ParseIP(host), trusted) {
+		return ""
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if !isTrusted(ip, trusted) {
+			return ip.String()
+		}
+	}
+
+	return ""
+}
+
+// Проверяет, что адрес принадлежит одному из доверенных прокси
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
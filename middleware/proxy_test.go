@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProxies(t *testing.T) {
+	act, err := ParseProxies([]string{"10.0.0.0/8", " 192.168.1.1", "", "::1"})
+	require.NoError(t, err)
+	require.Len(t, act, 3)
+	assert.Equal(t, "10.0.0.0/8", act[0].String())
+	assert.Equal(t, "192.168.1.1/32", act[1].String())
+	assert.Equal(t, "::1/128", act[2].String())
+
+	_, err = ParseProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+
+	_, err = ParseProxies([]string{"router"})
+	assert.Error(t, err)
+}
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		exp        string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:1234", exp: "203.0.113.5:1234"},
+		{name: "untrusted proxy", remoteAddr: "203.0.113.5:1234", forwarded: []string{"198.51.100.1"}, exp: "203.0.113.5:1234"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", forwarded: []string{"198.51.100.1"}, exp: "198.51.100.1"},
+		{name: "spoofed header", remoteAddr: "10.1.2.3:1234", forwarded: []string{"127.0.0.1, 198.51.100.1"}, exp: "198.51.100.1"},
+		{name: "proxy chain", remoteAddr: "10.1.2.3:1234", forwarded: []string{"198.51.100.1", "10.4.5.6"}, exp: "198.51.100.1"},
+		{name: "garbage", remoteAddr: "10.1.2.3:1234", forwarded: []string{"unknown"}, exp: "10.1.2.3:1234"},
+		{name: "only proxies", remoteAddr: "10.1.2.3:1234", forwarded: []string{"10.4.5.6"}, exp: "10.1.2.3:1234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var act string
+			next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				act = r.RemoteAddr
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, val := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", val)
+			}
+			RealIP(next, trusted).ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, tt.exp, act)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+const (
+	// Страницы используют только собственные стили и картинки, скриптов в приложении нет
+	// Картинки data: нужны для иконок, встроенных в стили Bootstrap
+	contentSecurityPolicy = "default-src 'self'; script-src 'none'; style-src 'self'; img-src 'self' data:; " +
+		"object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+	// Браузер запоминает, что сайт доступен только по HTTPS, на год вместе с поддоменами
+	strictTransportSecurity = "max-age=31536000; includeSubDomains"
+)
+
+// Security Добавляет ко всем ответам заголовки безопасности
+// HSTS стоит включать, только если приложение доступно по HTTPS, иначе браузер не откроет его по HTTP
+func Security(next http.Handler, hsts bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Content-Security-Policy", contentSecurityPolicy)
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("X-Content-Type-Options", "nosniff")
+		// Ссылки восстановления пароля, приглашений и выгрузок содержат токены, поэтому адрес страницы не уходит на чужие сайты
+		header.Set("Referrer-Policy", "same-origin")
+		if hsts {
+			header.Set("Strict-Transport-Security", strictTransportSecurity)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
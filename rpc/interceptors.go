@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationKey = "authorization"
+	bearerPrefix     = "Bearer "
+)
+
+type userIDContextKey struct{}
+
+// Логирует входящие unary запросы
+func unaryLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	logger.Log.WithField("method", info.FullMethod).Info("grpc request")
+	return handler(ctx, req)
+}
+
+// Логирует входящие stream запросы
+func streamLogging(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	logger.Log.WithField("method", info.FullMethod).Info("grpc stream")
+	return handler(srv, ss)
+}
+
+// Аутентифицирует unary запрос по токену пользователя
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// Аутентифицирует stream запрос по токену пользователя
+func (s *Server) streamAuth(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
+// Проверяет токен из метаданных запроса и сохраняет ID пользователя в контекст
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "token not specified")
+	}
+
+	values := md.Get(authorizationKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return nil, status.Error(codes.Unauthenticated, "token not specified")
+	}
+
+	userID, err := s.tokensSrv.Auth(strings.TrimPrefix(values[0], bearerPrefix))
+	if err != nil {
+		logger.Log.WithError(err).Error("grpc authentication error")
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return context.WithValue(ctx, userIDContextKey{}, userID), nil
+}
+
+// Возвращает ID аутентифицированного пользователя из контекста
+func userIDFromContext(ctx context.Context) int64 {
+	userID, _ := ctx.Value(userIDContextKey{}).(int64)
+	return userID
+}
+
+// Обертка над потоком с контекстом аутентифицированного пользователя
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context Возвращает контекст с ID пользователя
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
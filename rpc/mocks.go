@@ -0,0 +1,208 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: server.go
+
+// Package rpc is a generated GoMock package.
+package rpc
+
+import (
+	reflect "reflect"
+
+	models "github.com/bgoldovsky/casher/app/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockoperationsService is a mock of operationsService interface.
+type MockoperationsService struct {
+	ctrl     *gomock.Controller
+	recorder *MockoperationsServiceMockRecorder
+}
+
+// MockoperationsServiceMockRecorder is the mock recorder for MockoperationsService.
+type MockoperationsServiceMockRecorder struct {
+	mock *MockoperationsService
+}
+
+// NewMockoperationsService creates a new mock instance.
+func NewMockoperationsService(ctrl *gomock.Controller) *MockoperationsService {
+	mock := &MockoperationsService{ctrl: ctrl}
+	mock.recorder = &MockoperationsServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockoperationsService) EXPECT() *MockoperationsServiceMockRecorder {
+	return m.recorder
+}
+
+// Balance mocks base method.
+func (m *MockoperationsService) Balance(userID, ledgerID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Balance", userID, ledgerID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Balance indicates an expected call of Balance.
+func (mr *MockoperationsServiceMockRecorder) Balance(userID, ledgerID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockoperationsService)(nil).Balance), userID, ledgerID)
+}
+
+// Create mocks base method.
+func (m *MockoperationsService) Create(userID, ledgerID int64, subject string, amount int64, operationType models.OperationType, msg string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", userID, ledgerID, subject, amount, operationType, msg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockoperationsServiceMockRecorder) Create(userID, ledgerID, subject, amount, operationType, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockoperationsService)(nil).Create), userID, ledgerID, subject, amount, operationType, msg)
+}
+
+// Get mocks base method.
+func (m *MockoperationsService) Get(userID, ledgerID, page int64) (*models.OperationPaginator, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", userID, ledgerID, page)
+	ret0, _ := ret[0].(*models.OperationPaginator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockoperationsServiceMockRecorder) Get(userID, ledgerID, page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockoperationsService)(nil).Get), userID, ledgerID, page)
+}
+
+// Remove mocks base method.
+func (m *MockoperationsService) Remove(userID, ledgerID, operationID int64) (*models.Operation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove", userID, ledgerID, operationID)
+	ret0, _ := ret[0].(*models.Operation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockoperationsServiceMockRecorder) Remove(userID, ledgerID, operationID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockoperationsService)(nil).Remove), userID, ledgerID, operationID)
+}
+
+// MockledgersService is a mock of ledgersService interface.
+type MockledgersService struct {
+	ctrl     *gomock.Controller
+	recorder *MockledgersServiceMockRecorder
+}
+
+// MockledgersServiceMockRecorder is the mock recorder for MockledgersService.
+type MockledgersServiceMockRecorder struct {
+	mock *MockledgersService
+}
+
+// NewMockledgersService creates a new mock instance.
+func NewMockledgersService(ctrl *gomock.Controller) *MockledgersService {
+	mock := &MockledgersService{ctrl: ctrl}
+	mock.recorder = &MockledgersServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockledgersService) EXPECT() *MockledgersServiceMockRecorder {
+	return m.recorder
+}
+
+// Current mocks base method.
+func (m *MockledgersService) Current(userID, ledgerID int64) (*models.Ledger, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Current", userID, ledgerID)
+	ret0, _ := ret[0].(*models.Ledger)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Current indicates an expected call of Current.
+func (mr *MockledgersServiceMockRecorder) Current(userID, ledgerID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Current", reflect.TypeOf((*MockledgersService)(nil).Current), userID, ledgerID)
+}
+
+// MocktokensService is a mock of tokensService interface.
+type MocktokensService struct {
+	ctrl     *gomock.Controller
+	recorder *MocktokensServiceMockRecorder
+}
+
+// MocktokensServiceMockRecorder is the mock recorder for MocktokensService.
+type MocktokensServiceMockRecorder struct {
+	mock *MocktokensService
+}
+
+// NewMocktokensService creates a new mock instance.
+func NewMocktokensService(ctrl *gomock.Controller) *MocktokensService {
+	mock := &MocktokensService{ctrl: ctrl}
+	mock.recorder = &MocktokensServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocktokensService) EXPECT() *MocktokensServiceMockRecorder {
+	return m.recorder
+}
+
+// Auth mocks base method.
+func (m *MocktokensService) Auth(token string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Auth", token)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Auth indicates an expected call of Auth.
+func (mr *MocktokensServiceMockRecorder) Auth(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth", reflect.TypeOf((*MocktokensService)(nil).Auth), token)
+}
+
+// MockauditService is a mock of auditService interface.
+type MockauditService struct {
+	ctrl     *gomock.Controller
+	recorder *MockauditServiceMockRecorder
+}
+
+// MockauditServiceMockRecorder is the mock recorder for MockauditService.
+type MockauditServiceMockRecorder struct {
+	mock *MockauditService
+}
+
+// NewMockauditService creates a new mock instance.
+func NewMockauditService(ctrl *gomock.Controller) *MockauditService {
+	mock := &MockauditService{ctrl: ctrl}
+	mock.recorder = &MockauditServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockauditService) EXPECT() *MockauditServiceMockRecorder {
+	return m.recorder
+}
+
+// Record mocks base method.
+func (m *MockauditService) Record(entry *models.AuditEntry, before, after interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Record", entry, before, after)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockauditServiceMockRecorder) Record(entry, before, after interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockauditService)(nil).Record), entry, before, after)
+}
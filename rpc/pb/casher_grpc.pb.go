@@ -0,0 +1,245 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// CasherClient is the client API for Casher service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CasherClient interface {
+	// ListOperations Возвращает поток всех операций бухгалтерии, начиная с последней
+	ListOperations(ctx context.Context, in *ListOperationsRequest, opts ...grpc.CallOption) (Casher_ListOperationsClient, error)
+	// CreateOperation Создает новую операцию
+	CreateOperation(ctx context.Context, in *CreateOperationRequest, opts ...grpc.CallOption) (*CreateOperationResponse, error)
+	// DeleteOperation Удаляет операцию
+	DeleteOperation(ctx context.Context, in *DeleteOperationRequest, opts ...grpc.CallOption) (*DeleteOperationResponse, error)
+	// GetBalance Возвращает баланс бухгалтерии
+	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*GetBalanceResponse, error)
+}
+
+type casherClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCasherClient(cc grpc.ClientConnInterface) CasherClient {
+	return &casherClient{cc}
+}
+
+func (c *casherClient) ListOperations(ctx context.Context, in *ListOperationsRequest, opts ...grpc.CallOption) (Casher_ListOperationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Casher_ServiceDesc.Streams[0], "/casher.v1.Casher/ListOperations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &casherListOperationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Casher_ListOperationsClient interface {
+	Recv() (*Operation, error)
+	grpc.ClientStream
+}
+
+type casherListOperationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *casherListOperationsClient) Recv() (*Operation, error) {
+	m := new(Operation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *casherClient) CreateOperation(ctx context.Context, in *CreateOperationRequest, opts ...grpc.CallOption) (*CreateOperationResponse, error) {
+	out := new(CreateOperationResponse)
+	err := c.cc.Invoke(ctx, "/casher.v1.Casher/CreateOperation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casherClient) DeleteOperation(ctx context.Context, in *DeleteOperationRequest, opts ...grpc.CallOption) (*DeleteOperationResponse, error) {
+	out := new(DeleteOperationResponse)
+	err := c.cc.Invoke(ctx, "/casher.v1.Casher/DeleteOperation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casherClient) GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*GetBalanceResponse, error) {
+	out := new(GetBalanceResponse)
+	err := c.cc.Invoke(ctx, "/casher.v1.Casher/GetBalance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CasherServer is the server API for Casher service.
+// All implementations must embed UnimplementedCasherServer
+// for forward compatibility
+type CasherServer interface {
+	// ListOperations Возвращает поток всех операций бухгалтерии, начиная с последней
+	ListOperations(*ListOperationsRequest, Casher_ListOperationsServer) error
+	// CreateOperation Создает новую операцию
+	CreateOperation(context.Context, *CreateOperationRequest) (*CreateOperationResponse, error)
+	// DeleteOperation Удаляет операцию
+	DeleteOperation(context.Context, *DeleteOperationRequest) (*DeleteOperationResponse, error)
+	// GetBalance Возвращает баланс бухгалтерии
+	GetBalance(context.Context, *GetBalanceRequest) (*GetBalanceResponse, error)
+	mustEmbedUnimplementedCasherServer()
+}
+
+// UnimplementedCasherServer must be embedded to have forward compatible implementations.
+type UnimplementedCasherServer struct {
+}
+
+func (UnimplementedCasherServer) ListOperations(*ListOperationsRequest, Casher_ListOperationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListOperations not implemented")
+}
+func (UnimplementedCasherServer) CreateOperation(context.Context, *CreateOperationRequest) (*CreateOperationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateOperation not implemented")
+}
+func (UnimplementedCasherServer) DeleteOperation(context.Context, *DeleteOperationRequest) (*DeleteOperationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteOperation not implemented")
+}
+func (UnimplementedCasherServer) GetBalance(context.Context, *GetBalanceRequest) (*GetBalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedCasherServer) mustEmbedUnimplementedCasherServer() {}
+
+// UnsafeCasherServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CasherServer will
+// result in compilation errors.
+type UnsafeCasherServer interface {
+	mustEmbedUnimplementedCasherServer()
+}
+
+func RegisterCasherServer(s grpc.ServiceRegistrar, srv CasherServer) {
+	s.RegisterService(&Casher_ServiceDesc, srv)
+}
+
+func _Casher_ListOperations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListOperationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CasherServer).ListOperations(m, &casherListOperationsServer{stream})
+}
+
+type Casher_ListOperationsServer interface {
+	Send(*Operation) error
+	grpc.ServerStream
+}
+
+type casherListOperationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *casherListOperationsServer) Send(m *Operation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Casher_CreateOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateOperationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasherServer).CreateOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/casher.v1.Casher/CreateOperation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasherServer).CreateOperation(ctx, req.(*CreateOperationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Casher_DeleteOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteOperationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasherServer).DeleteOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/casher.v1.Casher/DeleteOperation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasherServer).DeleteOperation(ctx, req.(*DeleteOperationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Casher_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasherServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/casher.v1.Casher/GetBalance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasherServer).GetBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Casher_ServiceDesc is the grpc.ServiceDesc for Casher service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Casher_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "casher.v1.Casher",
+	HandlerType: (*CasherServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateOperation",
+			Handler:    _Casher_CreateOperation_Handler,
+		},
+		{
+			MethodName: "DeleteOperation",
+			Handler:    _Casher_DeleteOperation_Handler,
+		},
+		{
+			MethodName: "GetBalance",
+			Handler:    _Casher_GetBalance_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListOperations",
+			Handler:       _Casher_ListOperations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "casher.proto",
+}
@@ -0,0 +1,243 @@
+//go:generate buf generate
+//go:generate mockgen -source=server.go -destination=./mocks.go -package=rpc
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/bgoldovsky/casher/app/logger"
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/services/operations"
+	"github.com/bgoldovsky/casher/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type operationsService interface {
+	Get(userID, ledgerID int64, page int64) (*models.OperationPaginator, error)
+	Balance(userID, ledgerID int64) (int64, error)
+	Create(userID, ledgerID int64, subject string, amount int64, operationType models.OperationType, msg string) (int64, error)
+	Remove(userID, ledgerID, operationID int64) (*models.Operation, error)
+}
+
+type ledgersService interface {
+	Current(userID, ledgerID int64) (*models.Ledger, error)
+}
+
+type tokensService interface {
+	Auth(token string) (int64, error)
+}
+
+type auditService interface {
+	Record(entry *models.AuditEntry, before, after interface{}) error
+}
+
+// Server gRPC сервер для работы с операциями и балансом пользователя
+type Server struct {
+	pb.UnimplementedCasherServer
+	operationsSrv operationsService
+	ledgersSrv    ledgersService
+	tokensSrv     tokensService
+	auditSrv      auditService
+	server        *grpc.Server
+}
+
+// New Возвращает инициализированный экземпляр сервера
+func New(operationsSrv operationsService, ledgersSrv ledgersService, tokensSrv tokensService, auditSrv auditService) *Server {
+	s := &Server{
+		operationsSrv: operationsSrv,
+		ledgersSrv:    ledgersSrv,
+		tokensSrv:     tokensSrv,
+		auditSrv:      auditSrv,
+	}
+
+	s.server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryLogging, s.unaryAuth),
+		grpc.ChainStreamInterceptor(streamLogging, s.streamAuth),
+	)
+	pb.RegisterCasherServer(s.server, s)
+
+	return s
+}
+
+// GRPCServer Возвращает сконфигурированный gRPC сервер
+func (s *Server) GRPCServer() *grpc.Server {
+	return s.server
+}
+
+// ListOperations Отправляет в поток все операции бухгалтерии постранично
+func (s *Server) ListOperations(req *pb.ListOperationsRequest, stream pb.Casher_ListOperationsServer) error {
+	userID := userIDFromContext(stream.Context())
+
+	ledgerID, err := s.ledgerID(userID, req.GetLedgerId())
+	if err != nil {
+		return err
+	}
+
+	for page := int64(1); ; page++ {
+		paginator, err := s.operationsSrv.Get(userID, ledgerID, page)
+		if err != nil {
+			return operationsError(err, "get operations error")
+		}
+
+		for _, o := range paginator.Operations {
+			if err := stream.Send(operationToProto(&o)); err != nil {
+				logger.Log.WithError(err).WithField("userID", userID).Error("send operation error")
+				return err
+			}
+		}
+
+		if !paginator.HasMore {
+			return nil
+		}
+	}
+}
+
+// CreateOperation Создает новую операцию
+func (s *Server) CreateOperation(ctx context.Context, req *pb.CreateOperationRequest) (*pb.CreateOperationResponse, error) {
+	if strings.TrimSpace(req.GetSubject()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject is required")
+	}
+
+	if req.GetAmount() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount must be positive")
+	}
+
+	operationType := models.OperationType(req.GetType())
+	if operationType != models.Deposit && operationType != models.Withdraw {
+		return nil, status.Error(codes.InvalidArgument, "invalid operation type")
+	}
+
+	userID := userIDFromContext(ctx)
+	ledgerID, err := s.ledgerID(userID, req.GetLedgerId())
+	if err != nil {
+		return nil, err
+	}
+
+	created := models.Operation{
+		LedgerID: ledgerID,
+		UserID:   userID,
+		Subject:  req.GetSubject(),
+		Amount:   req.GetAmount(),
+		Type:     operationType,
+		Message:  req.GetMessage(),
+	}
+
+	operationID, err := s.operationsSrv.Create(userID, ledgerID, created.Subject, created.Amount, created.Type, created.Message)
+	if err != nil {
+		return nil, operationsError(err, "create operation error")
+	}
+
+	created.ID = operationID
+	s.audit(ctx, models.AuditEntry{
+		ActorID:    created.UserID,
+		Action:     models.AuditOperationCreate,
+		TargetType: models.AuditTargetOperation,
+		TargetID:   operationID,
+	}, nil, &created)
+
+	return &pb.CreateOperationResponse{Id: operationID}, nil
+}
+
+// DeleteOperation Удаляет операцию
+func (s *Server) DeleteOperation(ctx context.Context, req *pb.DeleteOperationRequest) (*pb.DeleteOperationResponse, error) {
+	userID := userIDFromContext(ctx)
+	ledgerID, err := s.ledgerID(userID, req.GetLedgerId())
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := s.operationsSrv.Remove(userID, ledgerID, req.GetId())
+	if err != nil {
+		return nil, operationsError(err, "delete operation error")
+	}
+
+	s.audit(ctx, models.AuditEntry{
+		ActorID:    userID,
+		Action:     models.AuditOperationDelete,
+		TargetType: models.AuditTargetOperation,
+		TargetID:   removed.ID,
+	}, removed, nil)
+
+	return &pb.DeleteOperationResponse{}, nil
+}
+
+// GetBalance Возвращает баланс бухгалтерии
+func (s *Server) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
+	userID := userIDFromContext(ctx)
+	ledgerID, err := s.ledgerID(userID, req.GetLedgerId())
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.operationsSrv.Balance(userID, ledgerID)
+	if err != nil {
+		return nil, operationsError(err, "get balance error")
+	}
+
+	return &pb.GetBalanceResponse{Balance: balance}, nil
+}
+
+// Возвращает бухгалтерию из запроса, а если она не указана, то первую доступную пользователю
+func (s *Server) ledgerID(userID, requested int64) (int64, error) {
+	if requested != 0 {
+		return requested, nil
+	}
+
+	ledger, err := s.ledgersSrv.Current(userID, 0)
+	if err != nil {
+		return 0, status.Error(codes.Internal, "get ledger error")
+	}
+
+	return ledger.ID, nil
+}
+
+// Преобразует ошибку сервиса операций в статус gRPC
+func operationsError(err error, msg string) error {
+	if errors.Is(err, operations.ErrForbidden) {
+		return status.Error(codes.PermissionDenied, "ledger access forbidden")
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		return status.Error(codes.NotFound, "not found")
+	}
+
+	return status.Error(codes.Internal, msg)
+}
+
+// Записывает действие пользователя в журнал аудита вместе с адресом и клиентом запроса
+// Ошибка записи не прерывает обработку запроса, так как действие уже совершено
+func (s *Server) audit(ctx context.Context, entry models.AuditEntry, before, after interface{}) {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(entry.IP); err == nil {
+			entry.IP = host
+		}
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		entry.UserAgent = strings.Join(md.Get("user-agent"), " ")
+	}
+
+	_ = s.auditSrv.Record(&entry, before, after)
+}
+
+// Конвертирует модель операции в protobuf сообщение
+func operationToProto(model *models.Operation) *pb.Operation {
+	return &pb.Operation{
+		Id:       model.ID,
+		LedgerId: model.LedgerID,
+		Subject:  model.Subject,
+		Amount:   model.Amount,
+		Type:     pb.OperationType(model.Type),
+		Message:  model.Message,
+		Created:  timestamppb.New(model.Created),
+	}
+}
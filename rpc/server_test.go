@@ -0,0 +1,259 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bgoldovsky/casher/app/models"
+	"github.com/bgoldovsky/casher/app/services/operations"
+	"github.com/bgoldovsky/casher/rpc/pb"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testToken  = "csh_test"
+	testUserID = int64(123)
+)
+
+var (
+	operation = models.Operation{
+		ID:      1,
+		UserID:  testUserID,
+		Subject: "test-subj",
+		Amount:  1000,
+		Type:    models.Deposit,
+		Message: "test-msg",
+		Created: time.Now(),
+	}
+
+	ledger = models.Ledger{ID: 7, Name: "Личный бюджет", Role: models.RoleOwner}
+)
+
+type testServer struct {
+	operations *MockoperationsService
+	ledgers    *MockledgersService
+	tokens     *MocktokensService
+	audit      *MockauditService
+	client     pb.CasherClient
+}
+
+// Запускает сервер с моками сервисов
+func newTestServer(t *testing.T) *testServer {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	ts := &testServer{
+		operations: NewMockoperationsService(ctrl),
+		ledgers:    NewMockledgersService(ctrl),
+		tokens:     NewMocktokensService(ctrl),
+		audit:      NewMockauditService(ctrl),
+	}
+
+	ts.client = serve(t, New(ts.operations, ts.ledgers, ts.tokens, ts.audit))
+	return ts
+}
+
+// Запускает сервер в памяти и возвращает клиента к нему
+func serve(t *testing.T, s *Server) pb.CasherClient {
+	lis := bufconn.Listen(1024 * 1024)
+	server := s.GRPCServer()
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewCasherClient(conn)
+}
+
+// Возвращает контекст с токеном пользователя
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), authorizationKey, bearerPrefix+testToken)
+}
+
+func TestServer_Unauthenticated(t *testing.T) {
+	ts := newTestServer(t)
+
+	_, err := ts.client.GetBalance(context.Background(), &pb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	stream, err := ts.client.ListOperations(context.Background(), &pb.ListOperationsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestServer_InvalidToken(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.tokens.EXPECT().Auth(testToken).Return(int64(0), errors.New("test error"))
+
+	_, err := ts.client.GetBalance(authorized(), &pb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestServer_GetBalance(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.tokens.EXPECT().Auth(testToken).Return(testUserID, nil)
+	ts.ledgers.EXPECT().Current(testUserID, int64(0)).Return(&ledger, nil)
+	ts.operations.EXPECT().Balance(testUserID, ledger.ID).Return(int64(1500), nil)
+
+	act, err := ts.client.GetBalance(authorized(), &pb.GetBalanceRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), act.GetBalance())
+}
+
+func TestServer_ListOperations(t *testing.T) {
+	ts := newTestServer(t)
+
+	second := operation
+	second.ID = 2
+
+	ts.tokens.EXPECT().Auth(testToken).Return(testUserID, nil)
+	ts.ledgers.EXPECT().Current(testUserID, int64(0)).Return(&ledger, nil)
+	gomock.InOrder(
+		ts.operations.EXPECT().Get(testUserID, ledger.ID, int64(1)).Return(&models.OperationPaginator{
+			Operations: []models.Operation{operation},
+			HasMore:    true,
+		}, nil),
+		ts.operations.EXPECT().Get(testUserID, ledger.ID, int64(2)).Return(&models.OperationPaginator{
+			Operations: []models.Operation{second},
+		}, nil),
+	)
+
+	stream, err := ts.client.ListOperations(authorized(), &pb.ListOperationsRequest{})
+	require.NoError(t, err)
+
+	var ids []int64
+	for {
+		o, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		ids = append(ids, o.GetId())
+	}
+
+	assert.Equal(t, []int64{1, 2}, ids)
+}
+
+func TestServer_CreateOperation(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.tokens.EXPECT().Auth(testToken).Return(testUserID, nil)
+	ts.ledgers.EXPECT().Current(testUserID, int64(0)).Return(&ledger, nil)
+	ts.operations.EXPECT().
+		Create(testUserID, ledger.ID, operation.Subject, operation.Amount, operation.Type, operation.Message).
+		Return(int64(55), nil)
+
+	var recorded *models.AuditEntry
+	ts.audit.EXPECT().Record(gomock.Any(), nil, gomock.Any()).DoAndReturn(func(e *models.AuditEntry, _, _ interface{}) error {
+		recorded = e
+		return nil
+	})
+
+	act, err := ts.client.CreateOperation(authorized(), &pb.CreateOperationRequest{
+		Subject: operation.Subject,
+		Amount:  operation.Amount,
+		Type:    pb.OperationType_OPERATION_TYPE_DEPOSIT,
+		Message: operation.Message,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(55), act.GetId())
+	assert.Equal(t, models.AuditOperationCreate, recorded.Action)
+	assert.Equal(t, testUserID, recorded.ActorID)
+	assert.Equal(t, int64(55), recorded.TargetID)
+	assert.NotEmpty(t, recorded.UserAgent)
+}
+
+func TestServer_CreateOperation_InvalidArgument(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.tokens.EXPECT().Auth(testToken).Return(testUserID, nil).Times(3)
+
+	requests := []*pb.CreateOperationRequest{
+		{Subject: " ", Amount: 100, Type: pb.OperationType_OPERATION_TYPE_DEPOSIT},
+		{Subject: "subj", Amount: 0, Type: pb.OperationType_OPERATION_TYPE_DEPOSIT},
+		{Subject: "subj", Amount: 100, Type: pb.OperationType_OPERATION_TYPE_UNSPECIFIED},
+	}
+
+	for _, req := range requests {
+		_, err := ts.client.CreateOperation(authorized(), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestServer_DeleteOperation(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.tokens.EXPECT().Auth(testToken).Return(testUserID, nil)
+	ts.operations.EXPECT().Remove(testUserID, int64(5), int64(1)).Return(&operation, nil)
+	ts.audit.EXPECT().Record(gomock.Any(), &operation, nil).Return(nil)
+
+	_, err := ts.client.DeleteOperation(authorized(), &pb.DeleteOperationRequest{Id: 1, LedgerId: 5})
+
+	assert.NoError(t, err)
+}
+
+func TestServer_DeleteOperation_Forbidden(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.tokens.EXPECT().Auth(testToken).Return(testUserID, nil)
+	ts.ledgers.EXPECT().Current(testUserID, int64(0)).Return(&ledger, nil)
+	ts.operations.EXPECT().Remove(testUserID, ledger.ID, int64(1)).Return(nil, operations.ErrForbidden)
+
+	_, err := ts.client.DeleteOperation(authorized(), &pb.DeleteOperationRequest{Id: 1})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestServer_DeleteOperation_ForeignLedger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// Сервис операций настоящий: владелец токена не состоит в чужой бухгалтерии, поэтому операция не удаляется
+	repo := operations.NewMockrepository(ctrl)
+	ledgersRepo := operations.NewMockledgersRepository(ctrl)
+	tokens := NewMocktokensService(ctrl)
+	client := serve(t, New(operations.New(repo, ledgersRepo), NewMockledgersService(ctrl), tokens, NewMockauditService(ctrl)))
+
+	tokens.EXPECT().Auth(testToken).Return(testUserID, nil)
+	ledgersRepo.EXPECT().GetRole(int64(5), testUserID).Return(models.LedgerRole(""), nil)
+	repo.EXPECT().Remove(gomock.Any(), gomock.Any()).Times(0)
+
+	_, err := client.DeleteOperation(authorized(), &pb.DeleteOperationRequest{Id: 1, LedgerId: 5})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_DeleteOperation_NotFound(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.tokens.EXPECT().Auth(testToken).Return(testUserID, nil)
+	ts.operations.EXPECT().Remove(testUserID, int64(5), int64(1)).Return(nil, &models.NotFoundError{Entity: "operation", ID: 1})
+
+	_, err := ts.client.DeleteOperation(authorized(), &pb.DeleteOperationRequest{Id: 1, LedgerId: 5})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
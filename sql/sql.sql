@@ -1,25 +1,243 @@
 create database casher;
 \c casher
 
+drop table audit_log;
+drop table exports;
+drop function audit_log_immutable;
+drop table outbox;
+drop table webhook_deliveries;
+drop table webhooks;
+drop table tokens;
 drop table operations;
+drop table ledger_members;
+drop table ledgers;
+drop table invites;
+drop table password_resets;
+drop table login_attempts;
+drop table sessions;
+drop table user_identities;
+drop table recovery_codes;
+drop table two_factor;
 drop table users;
 
 create table users (
     id serial primary key,
     login varchar(256) unique not null,
     password varchar(256) not null,
+    email varchar(256),
+    email_verified_at timestamp with time zone,
     name varchar(256) not null,
     birth timestamp with time zone not null,
+    invited_by bigint references users (id) on delete set null,
+    role varchar(16) default 'user' not null,
+    disabled_at timestamp with time zone,
+    last_login_at timestamp with time zone,
+    language varchar(8) default '' not null,
+    timezone varchar(64) default '' not null,
     created_at timestamp with time zone default now() not null
 );
 create index if not exists login_queue_idx on users (login);
+create unique index if not exists users_email_idx on users (lower(email));
 
+create table password_resets (
+    id bigserial primary key,
+    user_id bigint references users (id) on delete cascade not null,
+    hash varchar(64) unique not null,
+    expires_at timestamp with time zone not null,
+    used_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null
+);
+
+-- Сессии браузеров, в куки хранится только токен сессии, а в базе - его хеш
+-- Сессия без user_id принадлежит еще не авторизованному посетителю
+create table sessions (
+    id bigserial primary key,
+    hash varchar(64) unique not null,
+    user_id bigint references users (id) on delete cascade,
+    data bytea not null,
+    ip varchar(64) default '' not null,
+    user_agent text default '' not null,
+    created_at timestamp with time zone default now() not null,
+    last_seen_at timestamp with time zone default now() not null
+);
+create index if not exists sessions_user_idx on sessions (user_id, last_seen_at desc);
+
+-- Неудачные попытки входа по логину (login:...) и по адресу клиента (ip:...)
+-- failed_attempts считает текущую серию для задержек и блокировки, total_failures все неудачи с последнего входа
+-- Попытка учитывается как неудачная до проверки пароля и снимается после верного пароля,
+-- blocked_until хранит время следующей разрешенной попытки
+create table login_attempts (
+    key varchar(320) primary key,
+    failed_attempts int default 0 not null,
+    total_failures int default 0 not null,
+    failed_at timestamp with time zone not null,
+    locked_at timestamp with time zone,
+    blocked_until timestamp with time zone not null
+);
+create index if not exists login_attempts_failed_idx on login_attempts (failed_at);
+
+-- Внешние учетные записи пользователя у провайдеров OpenID Connect
+-- Пользователь однозначно определяется парой издатель и subject, адрес почты только информационный
+create table user_identities (
+    id bigserial primary key,
+    user_id bigint references users (id) on delete cascade not null,
+    issuer varchar(256) not null,
+    subject varchar(256) not null,
+    email varchar(256),
+    created_at timestamp with time zone default now() not null
+);
+create unique index if not exists user_identities_subject_idx on user_identities (issuer, subject);
+create unique index if not exists user_identities_user_idx on user_identities (user_id, issuer);
+
+-- Секрет TOTP нужен для вычисления кодов, поэтому хранится как есть
+-- last_counter защищает от повторного использования кода, failed_attempts - от перебора
+create table two_factor (
+    user_id bigint primary key references users (id) on delete cascade,
+    secret varchar(64) not null,
+    enabled_at timestamp with time zone,
+    last_counter bigint default 0 not null,
+    failed_attempts int default 0 not null,
+    failed_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null
+);
+
+create table recovery_codes (
+    id bigserial primary key,
+    user_id bigint references users (id) on delete cascade not null,
+    hash varchar(64) not null,
+    used_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null
+);
+create unique index if not exists recovery_codes_hash_idx on recovery_codes (user_id, hash);
+
+-- Администратор назначается вручную:
+-- update users set role = 'admin' where login = '<логин>';
+
+create table invites (
+    id bigserial primary key,
+    inviter_id bigint references users (id) on delete cascade not null,
+    hash varchar(64) unique not null,
+    expires_at timestamp with time zone not null,
+    used_by bigint references users (id) on delete set null,
+    used_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null
+);
+create index if not exists invites_inviter_idx on invites (inviter_id, created_at desc);
+
+create table ledgers (
+    id bigserial primary key,
+    name varchar(256) not null,
+    created_at timestamp with time zone default now() not null
+);
+
+create table ledger_members (
+    ledger_id bigint references ledgers (id) on delete cascade not null,
+    user_id bigint references users (id) on delete cascade not null,
+    role varchar(16) not null,
+    created_at timestamp with time zone default now() not null,
+    primary key (ledger_id, user_id)
+);
+create index if not exists ledger_members_user_idx on ledger_members (user_id);
+
+-- Автор операции пустой, если он удалил аккаунт, а операция осталась в чужой бухгалтерии
 create table operations (
     id serial primary key,
-    user_id bigint references users (id) not null,
+    ledger_id bigint references ledgers (id) on delete cascade not null,
+    user_id bigint references users (id),
     subject varchar(256) not null,
     amount bigint not null,
     type int not null,
     message text,
     created_at timestamp with time zone default now() not null
-);
\ No newline at end of file
+);
+create index if not exists operations_ledger_idx on operations (ledger_id, created_at desc);
+
+create table tokens (
+    id serial primary key,
+    user_id bigint references users (id) not null,
+    name varchar(256) not null,
+    hash varchar(64) unique not null,
+    created_at timestamp with time zone default now() not null
+);
+
+create table webhooks (
+    id serial primary key,
+    user_id bigint references users (id) not null,
+    url varchar(2048) not null,
+    events varchar(64)[] not null,
+    secret varchar(64) not null,
+    created_at timestamp with time zone default now() not null
+);
+
+create table webhook_deliveries (
+    id bigserial primary key,
+    webhook_id bigint references webhooks (id) on delete cascade not null,
+    event varchar(64) not null,
+    payload jsonb not null,
+    status varchar(16) not null,
+    attempts int default 0 not null,
+    next_attempt_at timestamp with time zone not null,
+    response_code int default 0 not null,
+    last_error text default '' not null,
+    created_at timestamp with time zone default now() not null,
+    delivered_at timestamp with time zone
+);
+create index if not exists webhook_deliveries_pending_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
+
+-- Событие забирается на время аренды через next_attempt_at и обрабатывается вне транзакции,
+-- handled хранит обработчики, которые уже успешно обработали событие, что бы повтор не вызывал их снова
+-- dead_at отмечает событие, исчерпавшее попытки, оно больше не забирается и ждет разбора по last_error
+create table outbox (
+    id bigserial primary key,
+    type varchar(64) not null,
+    payload jsonb not null,
+    attempts int default 0 not null,
+    last_error text default '' not null,
+    handled varchar(64)[] default '{}' not null,
+    next_attempt_at timestamp with time zone default now() not null,
+    created_at timestamp with time zone default now() not null,
+    processed_at timestamp with time zone,
+    dead_at timestamp with time zone
+);
+create index if not exists outbox_pending_idx on outbox (next_attempt_at) where processed_at is null and dead_at is null;
+
+-- Выгрузки персональных данных, архив собирается в фоне по событию из outbox
+-- Архив хранится до expires_at, ссылка на скачивание подписана и действует столько же
+create table exports (
+    id bigserial primary key,
+    user_id bigint references users (id) on delete cascade not null,
+    status varchar(16) default 'pending' not null,
+    data bytea,
+    size bigint default 0 not null,
+    expires_at timestamp with time zone,
+    ready_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null
+);
+create index if not exists exports_user_idx on exports (user_id, created_at desc);
+
+create table audit_log (
+    id bigserial primary key,
+    actor_id bigint,
+    action varchar(64) not null,
+    target_type varchar(64) default '' not null,
+    target_id bigint,
+    before jsonb,
+    after jsonb,
+    ip varchar(64) default '' not null,
+    user_agent text default '' not null,
+    created_at timestamp with time zone default now() not null
+);
+create index if not exists audit_log_actor_idx on audit_log (actor_id, created_at desc);
+create index if not exists audit_log_target_idx on audit_log (target_type, target_id);
+
+-- Журнал аудита только дополняется
+create or replace function audit_log_immutable() returns trigger as $$
+begin
+    raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+create trigger audit_log_append_only
+    before update or delete on audit_log
+    for each row execute function audit_log_immutable();
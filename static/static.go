@@ -0,0 +1,34 @@
+package static
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// Статические файлы, встроенные в бинарный файл
+// Сторонние файлы скачиваются командой cmd/assets перед сборкой
+//
+//go:embed assets
+var embedded embed.FS
+
+// Сторонние файлы, которые скачивает cmd/assets, без них страницы отображаются без стилей
+var thirdParty = []string{
+	"assets/css/bootstrap.min.css",
+}
+
+// Embedded Возвращает статические файлы, встроенные в бинарный файл при сборке
+func Embedded() fs.FS {
+	return embedded
+}
+
+// Check Проверяет, что в статических файлах есть все сторонние файлы
+func Check(fsys fs.FS) error {
+	for _, name := range thirdParty {
+		if _, err := fs.Stat(fsys, name); err != nil {
+			return fmt.Errorf("static file %s not found, run go run ./cmd/assets before build: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package templates
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sync"
+	"time"
+)
+
+// Шаблоны страниц, встроенные в бинарный файл
+//
+//go:embed *.html
+var embedded embed.FS
+
+// Embedded Возвращает шаблоны, встроенные в бинарный файл при сборке
+func Embedded() fs.FS {
+	return embedded
+}
+
+// Registry Реестр шаблонов страниц
+// Все страницы разбираются один раз при создании реестра, поэтому ошибка в шаблоне обнаруживается при запуске,
+// а не при первом запросе к странице. Функции шаблонов, зависящие от запроса, подставляются в копию страницы при рендеринге.
+// В режиме разработки реестр заново разбирает шаблоны, если файлы изменились
+type Registry struct {
+	fsys    fs.FS
+	funcs   template.FuncMap
+	layouts []string
+	reload  bool
+
+	mu       sync.Mutex
+	pages    map[string]*template.Template
+	modified time.Time
+}
+
+// New Разбирает и проверяет все шаблоны и возвращает реестр
+// layouts - общие шаблоны вроде шапки и подвала, которые подключаются к каждой странице,
+// остальные файлы *.html считаются страницами. funcs определяет все функции, которые используют шаблоны
+func New(fsys fs.FS, funcs template.FuncMap, reload bool, layouts ...string) (*Registry, error) {
+	r := &Registry{
+		fsys:    fsys,
+		funcs:   funcs,
+		layouts: layouts,
+		reload:  reload,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Page Возвращает копию страницы с функциями текущего запроса
+// Копия нужна потому, что html/template не позволяет менять функции шаблона после первого рендеринга
+func (r *Registry) Page(name string, funcs template.FuncMap) (*template.Template, error) {
+	page, err := r.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := page.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.Funcs(funcs), nil
+}
+
+// Возвращает разобранную страницу, в режиме разработки предварительно перечитав измененные шаблоны
+func (r *Registry) lookup(name string) (*template.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reload {
+		if err := r.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	page, ok := r.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+
+	return page, nil
+}
+
+// Заново разбирает шаблоны, если какой-то файл изменился после предыдущего разбора
+func (r *Registry) refresh() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	if !modified.After(r.modified) {
+		return nil
+	}
+
+	return r.load()
+}
+
+// Разбирает все страницы вместе с общими шаблонами и проверяет их
+func (r *Registry) load() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(r.fsys, "*.html")
+	if err != nil {
+		return err
+	}
+
+	layouts := map[string]bool{}
+	for _, layout := range r.layouts {
+		layouts[layout] = true
+	}
+
+	pages := map[string]*template.Template{}
+	for _, file := range files {
+		if layouts[file] {
+			continue
+		}
+
+		page, err := template.New(file).Funcs(r.funcs).ParseFS(r.fsys, append([]string{file}, r.layouts...)...)
+		if err != nil {
+			return err
+		}
+
+		if err = validate(page); err != nil {
+			return fmt.Errorf("template %s: %w", file, err)
+		}
+
+		pages[file] = page
+	}
+
+	r.pages, r.modified = pages, modified
+	return nil
+}
+
+// Возвращает время последнего изменения файлов шаблонов
+func (r *Registry) lastModified() (time.Time, error) {
+	var modified time.Time
+	err := fs.WalkDir(r.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".html" {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+		return nil
+	})
+
+	return modified, err
+}
+
+// Проверяет, что html/template может экранировать все шаблоны страницы
+// Экранирование выполняется при первом рендеринге, поэтому рендерится копия страницы без данных:
+// ошибки обращения к отсутствующим данным ожидаемы, а ошибки экранирования означают неверный шаблон
+func validate(page *template.Template) error {
+	clone, err := page.Clone()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range clone.Templates() {
+		err = clone.ExecuteTemplate(ioutil.Discard, t.Name(), nil)
+
+		var escapeErr *template.Error
+		if errors.As(err, &escapeErr) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package templates
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	modified = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	funcs    = template.FuncMap{"user": func() string { return "" }}
+)
+
+// Возвращает файловую систему со страницей и общим шаблоном
+func testFS(page string) fstest.MapFS {
+	return fstest.MapFS{
+		"layout.html": {Data: []byte(`{{ define "layout" }}<b>{{ user }}</b>{{ end }}`), ModTime: modified},
+		"page.html":   {Data: []byte(page), ModTime: modified},
+	}
+}
+
+// Рендерит страницу реестра с функцией, возвращающей имя пользователя
+func render(t *testing.T, r *Registry, user string) string {
+	tmpl, err := r.Page("page.html", template.FuncMap{"user": func() string { return user }})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.ExecuteTemplate(&buf, "page", nil))
+	return buf.String()
+}
+
+func TestRegistry_Page(t *testing.T) {
+	r, err := New(testFS(`{{ define "page" }}{{ template "layout" }}{{ end }}`), funcs, false, "layout.html")
+	require.NoError(t, err)
+
+	// Функции подставляются в копию страницы, поэтому разные запросы не видят данные друг друга
+	assert.Equal(t, "<b>&lt;jon&gt;</b>", render(t, r, "<jon>"))
+	assert.Equal(t, "<b>jane</b>", render(t, r, "jane"))
+
+	// Общий шаблон не является страницей
+	_, err = r.Page("layout.html", funcs)
+	assert.Error(t, err)
+	_, err = r.Page("missing.html", funcs)
+	assert.Error(t, err)
+}
+
+func TestRegistry_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+	}{
+		{name: "syntax", page: `{{ define "page" }}{{ if }}{{ end }}`},
+		{name: "unknown func", page: `{{ define "page" }}{{ missing }}{{ end }}`},
+		// Ветки условия заканчиваются в разных контекстах HTML, такой шаблон нельзя экранировать
+		{name: "escaping", page: `{{ define "page" }}<a {{ if . }}href="{{ end }}">{{ end }}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(testFS(tt.page), funcs, false, "layout.html")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	fsys := testFS(`{{ define "page" }}old{{ end }}`)
+
+	static, err := New(fsys, funcs, false, "layout.html")
+	require.NoError(t, err)
+	reloading, err := New(fsys, funcs, true, "layout.html")
+	require.NoError(t, err)
+
+	fsys["page.html"] = &fstest.MapFile{Data: []byte(`{{ define "page" }}new{{ end }}`), ModTime: modified.Add(time.Second)}
+
+	assert.Equal(t, "old", render(t, static, ""))
+	assert.Equal(t, "new", render(t, reloading, ""))
+
+	// Сломанный шаблон не подменяет рабочий, а возвращает ошибку, пока его не исправят
+	fsys["page.html"] = &fstest.MapFile{Data: []byte(`{{ define "page" }}{{ if }}{{ end }}`), ModTime: modified.Add(2 * time.Second)}
+	_, err = reloading.Page("page.html", funcs)
+	assert.Error(t, err)
+}
+
+func TestEmbedded(t *testing.T) {
+	_, err := Embedded().Open("header.html")
+	assert.NoError(t, err)
+}